@@ -8,6 +8,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // REPORT_TIMEZONE must resolve in minimal images
 
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
@@ -53,29 +54,96 @@ func main() {
 	assignmentRepo := repository.NewAssignmentRepo(pool)
 	auditRepo := repository.NewAuditRepo(pool)
 	rrRepo := repository.NewRRPointerRepo(pool)
+	starReportRepo := repository.NewStarReportRepo(pool)
+	alertRepo := repository.NewAlertRepo(pool)
+	clientRepo := repository.NewClientRepo(pool)
+	importRepo := repository.NewImportRepo(pool)
+	importProfileRepo := repository.NewImportProfileRepo(pool)
+	entityAuditRepo := repository.NewEntityAuditRepo(pool)
+	skillRepo := repository.NewSkillRepo(pool)
+	rebalanceRepo := repository.NewRebalanceRepo(pool)
+	teamRepo := repository.NewTeamRepo(pool)
+	messageRepo := repository.NewMessageRepo(pool)
+	replyTemplateRepo := repository.NewReplyTemplateRepo(pool)
 
 	// Routing engine
 	geoFilter := routing.NewGeoFilter(buRepo)
-	skillFilter := routing.NewSkillFilter()
+	skillFilter := routing.NewSkillFilter(skillRepo)
+	affinity := routing.NewAffinity(assignmentRepo, cfg.AffinityWindowDays)
 	loadBalancer := routing.NewLoadBalancer()
 	roundRobin := routing.NewRoundRobin(rrRepo, assignmentRepo, managerRepo, auditRepo)
 
 	// Services
-	importSvc := service.NewImportService(ticketRepo, managerRepo, buRepo)
-	routingSvc := service.NewRoutingService(pool, geoFilter, skillFilter, loadBalancer, roundRobin, managerRepo, auditRepo, ticketRepo)
+	threadSvc := service.NewThreadService(ticketRepo, cfg.ThreadWindow, cfg.ThreadRelatedSimilarity, cfg.ThreadDuplicateSimilarity)
+	importSvc := service.NewImportService(pool, ticketRepo, managerRepo, buRepo, clientRepo, importRepo, importProfileRepo, skillRepo, threadSvc, cfg.ImportSpoolDir, cfg.ImportChunkSize)
+	importSvc.OnProgress = handler.BroadcastImportProgress
+	if n, err := importSvc.RecoverInterrupted(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to recover interrupted imports")
+	} else if n > 0 {
+		log.Warn().Int64("count", n).Msg("imports interrupted by restart marked failed; resume via POST /api/v1/imports/{id}/resume")
+	}
+	routingSvc := service.NewRoutingService(pool, geoFilter, skillFilter, affinity, loadBalancer, roundRobin, managerRepo, auditRepo, ticketRepo, skillRepo)
 	ticketSvc := service.NewTicketService(ticketRepo, assignmentRepo, auditRepo, managerRepo, buRepo)
-	managerSvc := service.NewManagerService(managerRepo, buRepo)
-	dashboardSvc := service.NewDashboardService(pool)
-	starSvc := service.NewStarService(pool, cfg.OpenAIKey, cfg.OpenAIModel)
+	managerSvc := service.NewManagerService(pool, managerRepo, buRepo, ticketRepo, assignmentRepo, auditRepo, entityAuditRepo, skillRepo, routingSvc)
+	importProfileSvc := service.NewImportProfileService(importProfileRepo)
+	skillSvc := service.NewSkillService(skillRepo)
+	queueScoring, err := service.NewQueueScoring(cfg.QueueWeights, cfg.QueueSLAHours, cfg.QueueSegmentWeights)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid QUEUE_* configuration")
+	}
+	queueSvc := service.NewQueueService(pool, ticketRepo, managerRepo, assignmentRepo, auditRepo, queueScoring)
+	teamSvc := service.NewTeamService(pool, teamRepo, managerRepo, buRepo, ticketRepo, assignmentRepo, auditRepo, entityAuditRepo, queueScoring)
+	messageSvc := service.NewMessageService(pool, messageRepo, ticketRepo, managerRepo, cfg.MessageAttachmentsDir)
+	rebalanceSvc := service.NewRebalanceService(pool, rebalanceRepo, ticketRepo, managerRepo, assignmentRepo, auditRepo, routingSvc)
+	rebalanceSvc.OnProgress = handler.BroadcastRebalanceProgress
+	if n, err := rebalanceSvc.RecoverInterrupted(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to recover interrupted rebalances")
+	} else if n > 0 {
+		log.Warn().Int64("count", n).Msg("rebalances interrupted by restart marked failed; resume via POST /api/v1/rebalance/{id}/apply")
+	}
+	clientSvc := service.NewClientService(clientRepo, ticketRepo)
+	reportLoc, err := time.LoadLocation(cfg.ReportTimezone)
+	if err != nil {
+		log.Fatal().Err(err).Str("tz", cfg.ReportTimezone).Msg("invalid REPORT_TIMEZONE")
+	}
+	dashboardSvc := service.NewDashboardService(pool, reportLoc)
+	starSvc := service.NewStarService(pool, cfg.OpenAIKey, cfg.OpenAIModel, cfg.StarDBRole, cfg.StarStatementTimeout, cfg.StarMaxRows)
+	if err := starSvc.LoadSchema(ctx); err != nil {
+		log.Warn().Err(err).Msg("Star schema introspection failed, Star cannot read any columns")
+	}
+	starReportSvc := service.NewStarReportService(starReportRepo, starSvc, cfg.StarReportsDir, cfg.StarWebhookHosts)
+	if err := starReportSvc.Start(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to start Star report scheduler")
+	}
+	defer starReportSvc.Stop()
+	anomalySvc := service.NewAnomalyService(pool, alertRepo, cfg.AnomalyZThreshold, cfg.AnomalyMinCount)
+	anomalySvc.OnAlert = handler.BroadcastAlert
+	detectorCtx, stopDetector := context.WithCancel(ctx)
+	defer stopDetector()
+	if cfg.AnomalyInterval > 0 {
+		go anomalySvc.Run(detectorCtx, cfg.AnomalyInterval)
+	}
 	aiSvc := service.NewAIService(cfg.OpenAIKey, cfg.OpenAIModel, cfg.ImagesDir, ticketRepo, routingSvc)
+	replySvc := service.NewReplyService(replyTemplateRepo, ticketRepo, assignmentRepo, managerRepo, buRepo, clientRepo, aiSvc, reportLoc)
 
 	// Handlers
 	importH := handler.NewImportHandler(importSvc, aiSvc)
+	importProfileH := handler.NewImportProfileHandler(importProfileSvc)
 	callbackH := handler.NewCallbackHandler(ticketRepo, assignmentRepo, routingSvc)
 	ticketH := handler.NewTicketHandler(ticketSvc, aiSvc)
 	managerH := handler.NewManagerHandler(managerSvc, ticketSvc)
-	dashboardH := handler.NewDashboardHandler(dashboardSvc)
-	starH := handler.NewStarHandler(starSvc)
+	skillH := handler.NewSkillHandler(skillSvc)
+	routingH := handler.NewRoutingHandler(routingSvc)
+	rebalanceH := handler.NewRebalanceHandler(rebalanceSvc)
+	queueH := handler.NewQueueHandler(queueSvc)
+	teamH := handler.NewTeamHandler(teamSvc)
+	messageH := handler.NewMessageHandler(messageSvc, cfg.MessageMaxUploadBytes)
+	replyH := handler.NewReplyHandler(replySvc)
+	clientH := handler.NewClientHandler(clientSvc)
+	dashboardH := handler.NewDashboardHandler(dashboardSvc, cfg.ExportMaxRows)
+	starH := handler.NewStarHandler(starSvc, cfg.ExportMaxRows)
+	alertH := handler.NewAlertHandler(anomalySvc)
+	starReportH := handler.NewStarReportHandler(starReportSvc, reportLoc, cfg.ExportMaxRows)
 
 	// Router
 	r := chi.NewRouter()
@@ -83,7 +151,6 @@ func main() {
 	r.Use(chimw.RealIP)
 	r.Use(chimw.Logger)
 	r.Use(chimw.Recoverer)
-	r.Use(chimw.Timeout(120 * time.Second))
 	r.Use(mw.CORSHandler(cfg.CORSOrigins))
 
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -91,47 +158,139 @@ func main() {
 	})
 
 	r.Route("/api/v1", func(r chi.Router) {
-		// Import (universal auto-detect + specific endpoints)
+		// Imports stream large uploads and run as long as the file takes, so
+		// they are not bound by the request timeout.
 		r.Post("/import", importH.Import)
 		r.Post("/import/tickets", importH.ImportTickets)
 		r.Post("/import/managers", importH.ImportManagers)
 		r.Post("/import/business-units", importH.ImportBusinessUnits)
+		r.Post("/imports/{id}/resume", importH.Resume)
+		r.Post("/imports/{id}/rollback", importH.Rollback)
 
-		// Internal callbacks (kept for backward compatibility)
-		r.Route("/internal/callback", func(r chi.Router) {
-			r.Post("/enrich", callbackH.HandleEnrichment)
-			r.Post("/star-query", callbackH.HandleStarQuery)
-		})
+		r.Group(func(r chi.Router) {
+			r.Use(chimw.Timeout(120 * time.Second))
+
+			// Import
+			r.Get("/imports", importH.List)
+			r.Get("/imports/{id}", importH.Get)
+			r.Get("/import-profiles", importProfileH.List)
+			r.Post("/import-profiles", importProfileH.Create)
+			r.Post("/import-profiles/suggest", importProfileH.Suggest)
+			r.Get("/import-profiles/{id}", importProfileH.Get)
+			r.Put("/import-profiles/{id}", importProfileH.Update)
+			r.Delete("/import-profiles/{id}", importProfileH.Delete)
+
+			// Internal callbacks (kept for backward compatibility)
+			r.Route("/internal/callback", func(r chi.Router) {
+				r.Post("/enrich", callbackH.HandleEnrichment)
+				r.Post("/star-query", callbackH.HandleStarQuery)
+			})
+
+			// Tickets
+			r.Get("/tickets", ticketH.List)
+			r.Get("/tickets/map", ticketH.MapPoints)
+			r.Get("/tickets/{id}", ticketH.Get)
+			r.Patch("/tickets/{id}/status", ticketH.UpdateStatus)
+			r.Post("/tickets/{id}/enrich", ticketH.Enrich)
+			r.Post("/tickets/enrich-all", ticketH.EnrichAll)
+			r.Post("/tickets/{id}/escalate", teamH.Escalate)
+			r.Get("/tickets/{id}/escalations", teamH.Escalations)
+			r.Get("/tickets/{id}/messages", messageH.List)
+			r.Post("/tickets/{id}/messages", messageH.Post)
+			r.Get("/tickets/{id}/messages/{messageID}/attachments/{name}", messageH.Attachment)
+			r.Put("/tickets/{id}/actions/{index}", messageH.SetAction)
+			r.Post("/tickets/{id}/draft-reply", replyH.Draft)
 
-		// Tickets
-		r.Get("/tickets", ticketH.List)
-		r.Get("/tickets/map", ticketH.MapPoints)
-		r.Get("/tickets/{id}", ticketH.Get)
-		r.Patch("/tickets/{id}/status", ticketH.UpdateStatus)
-		r.Post("/tickets/{id}/enrich", ticketH.Enrich)
-		r.Post("/tickets/enrich-all", ticketH.EnrichAll)
-
-		// Managers
-		r.Get("/managers", managerH.List)
-		r.Get("/managers/{id}", managerH.Get)
-		r.Get("/managers/{id}/tickets", managerH.GetTickets)
-
-		// Offices
-		r.Get("/offices", managerH.ListOffices)
-		r.Get("/offices/{id}", managerH.GetOffice)
-
-		// Dashboard
-		r.Get("/dashboard/stats", dashboardH.Stats)
-		r.Get("/dashboard/sentiment", dashboardH.Sentiment)
-		r.Get("/dashboard/categories", dashboardH.Categories)
-		r.Get("/dashboard/manager-load", dashboardH.ManagerLoad)
-		r.Get("/dashboard/timeline", dashboardH.Timeline)
-
-		// Star Task
-		r.Post("/star/query", starH.Query)
-
-		// Real-time SSE events stream
-		r.Get("/events", handler.ServeWS)
+			// Managers
+			r.Get("/managers", managerH.List)
+			r.Get("/managers/{id}", managerH.Get)
+			r.Get("/managers/{id}/tickets", managerH.GetTickets)
+			r.Post("/managers", managerH.Create)
+			r.Put("/managers/{id}", managerH.Update)
+			r.Post("/managers/{id}/deactivate", managerH.Deactivate)
+			r.Post("/managers/{id}/activate", managerH.Activate)
+			r.Get("/managers/{id}/changes", managerH.Changes)
+			r.Get("/managers/{id}/skills", managerH.Skills)
+			r.Put("/managers/{id}/skills", managerH.SetSkills)
+			r.Get("/managers/{id}/queue", queueH.Queue)
+			r.Post("/managers/{id}/next", queueH.Next)
+
+			// Teams and supervisors
+			r.Get("/teams", teamH.List)
+			r.Post("/teams", teamH.Create)
+			r.Get("/teams/{id}", teamH.Get)
+			r.Put("/teams/{id}", teamH.Update)
+			r.Put("/teams/{id}/members", teamH.SetMembers)
+			r.Get("/teams/{id}/tickets", teamH.Tickets)
+			r.Post("/teams/{id}/deactivate", teamH.Deactivate)
+			r.Post("/teams/{id}/activate", teamH.Activate)
+			r.Get("/teams/{id}/changes", teamH.Changes)
+
+			// Skills
+			r.Get("/skills", skillH.List)
+			r.Post("/skills", skillH.Create)
+			r.Put("/skills/{id}", skillH.Update)
+			r.Get("/skill-requirements", skillH.ListRequirements)
+			r.Post("/skill-requirements", skillH.CreateRequirement)
+			r.Put("/skill-requirements/{id}", skillH.UpdateRequirement)
+
+			// Reply templates
+			r.Get("/reply-templates", replyH.ListTemplates)
+			r.Post("/reply-templates", replyH.CreateTemplate)
+			r.Put("/reply-templates/{id}", replyH.UpdateTemplate)
+
+			// Routing simulation (read-only)
+			r.Post("/routing/simulate", routingH.Simulate)
+			r.Post("/routing/replay", routingH.Replay)
+
+			// Rebalancing: preview, then apply
+			r.Post("/rebalance", rebalanceH.Preview)
+			r.Get("/rebalance", rebalanceH.List)
+			r.Get("/rebalance/{id}", rebalanceH.Get)
+			r.Get("/rebalance/{id}/moves", rebalanceH.Moves)
+			r.Post("/rebalance/{id}/apply", rebalanceH.Apply)
+
+			// Clients
+			r.Get("/clients/{id}", clientH.Get)
+
+			// Offices
+			r.Get("/offices", managerH.ListOffices)
+			r.Get("/offices/{id}", managerH.GetOffice)
+			r.Post("/offices", managerH.CreateOffice)
+			r.Put("/offices/{id}", managerH.UpdateOffice)
+			r.Post("/offices/{id}/deactivate", managerH.DeactivateOffice)
+			r.Post("/offices/{id}/activate", managerH.ActivateOffice)
+			r.Get("/offices/{id}/changes", managerH.OfficeChanges)
+
+			// Dashboard
+			r.Get("/dashboard/stats", dashboardH.Stats)
+			r.Get("/dashboard/sentiment", dashboardH.Sentiment)
+			r.Get("/dashboard/categories", dashboardH.Categories)
+			r.Get("/dashboard/manager-load", dashboardH.ManagerLoad)
+			r.Get("/dashboard/timeline", dashboardH.Timeline)
+			r.Get("/dashboard/latency", dashboardH.Latency)
+			r.Get("/dashboard/backlog-age", dashboardH.BacklogAge)
+			r.Get("/dashboard/throughput", dashboardH.Throughput)
+			r.Get("/dashboard/{dataset}/export", dashboardH.Export)
+
+			// Alerts
+			r.Get("/alerts", alertH.List)
+			r.Post("/alerts/{id}/acknowledge", alertH.Acknowledge)
+
+			// Star Task
+			r.Post("/star/query", starH.Query)
+			r.Post("/star/export", starH.Export)
+			r.Get("/star/reports", starReportH.List)
+			r.Post("/star/reports", starReportH.Create)
+			r.Get("/star/reports/{id}", starReportH.Get)
+			r.Put("/star/reports/{id}", starReportH.Update)
+			r.Delete("/star/reports/{id}", starReportH.Delete)
+			r.Post("/star/reports/{id}/run", starReportH.Run)
+			r.Get("/star/reports/{id}/export", starReportH.Export)
+
+			// Real-time SSE events stream
+			r.Get("/events", handler.ServeWS)
+		})
 	})
 
 	addr := fmt.Sprintf(":%s", cfg.Port)
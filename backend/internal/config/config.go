@@ -1,6 +1,10 @@
 package config
 
-import "github.com/kelseyhightower/envconfig"
+import (
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
 
 type Config struct {
 	Port          string `envconfig:"APP_PORT" default:"8080"`
@@ -10,6 +14,48 @@ type Config struct {
 	CORSOrigins   string `envconfig:"CORS_ORIGINS" default:"http://localhost:5173"`
 	MigrationsDir string `envconfig:"MIGRATIONS_DIR" default:"migrations"`
 	ImagesDir     string `envconfig:"IMAGES_DIR" default:"images"`
+
+	// Star assistant SQL sandbox
+	StarDBRole           string        `envconfig:"STAR_DB_ROLE" default:"star_readonly"`
+	StarStatementTimeout time.Duration `envconfig:"STAR_STATEMENT_TIMEOUT" default:"5s"`
+	StarMaxRows          int           `envconfig:"STAR_MAX_ROWS" default:"1000"`
+	StarReportsDir       string        `envconfig:"STAR_REPORTS_DIR" default:"reports"`
+	StarWebhookHosts     []string      `envconfig:"STAR_WEBHOOK_HOSTS"` // hosts reports may be posted to; none disables webhooks
+
+	// Reporting time zone for dashboard day boundaries and buckets
+	ReportTimezone string `envconfig:"REPORT_TIMEZONE" default:"Asia/Almaty"`
+
+	// Anomaly detector on hourly ticket inflow
+	AnomalyInterval   time.Duration `envconfig:"ANOMALY_INTERVAL" default:"10m"`
+	AnomalyZThreshold float64       `envconfig:"ANOMALY_Z_THRESHOLD" default:"3"`
+	AnomalyMinCount   int           `envconfig:"ANOMALY_MIN_COUNT" default:"5"`
+
+	// Linking a client's related tickets into threads
+	ThreadWindow              time.Duration `envconfig:"THREAD_WINDOW" default:"72h"`
+	ThreadRelatedSimilarity   float64       `envconfig:"THREAD_RELATED_SIMILARITY" default:"0.3"`
+	ThreadDuplicateSimilarity float64       `envconfig:"THREAD_DUPLICATE_SIMILARITY" default:"0.8"`
+
+	// Affinity routing: days to look back for a returning client's previous
+	// manager, per client segment. It is the only previous-manager preference:
+	// 0 or a missing segment routes that segment by load alone
+	AffinityWindowDays map[string]int `envconfig:"AFFINITY_WINDOW_DAYS" default:"VIP:180,Priority:60"`
+
+	// Manager work queue ordering: weight of each score component, SLA in
+	// hours per client segment ("default" for the rest) and segment weights
+	QueueWeights        map[string]float64 `envconfig:"QUEUE_WEIGHTS" default:"priority:0.4,sla:0.3,segment:0.2,age:0.1"`
+	QueueSLAHours       map[string]int     `envconfig:"QUEUE_SLA_HOURS" default:"VIP:4,Priority:8,default:24"`
+	QueueSegmentWeights map[string]float64 `envconfig:"QUEUE_SEGMENT_WEIGHTS" default:"VIP:1,Priority:0.6"`
+
+	// Imports: uploads are spooled here and committed in chunks of this many rows
+	ImportSpoolDir  string `envconfig:"IMPORT_SPOOL_DIR" default:"imports"`
+	ImportChunkSize int    `envconfig:"IMPORT_CHUNK_SIZE" default:"5000"`
+
+	// CSV/XLSX/JSONL exports
+	ExportMaxRows int `envconfig:"EXPORT_MAX_ROWS" default:"100000"`
+
+	// Ticket messages: uploaded attachments are kept here, up to this many bytes per message
+	MessageAttachmentsDir string `envconfig:"MESSAGE_ATTACHMENTS_DIR" default:"attachments"`
+	MessageMaxUploadBytes int64  `envconfig:"MESSAGE_MAX_UPLOAD_BYTES" default:"26214400"`
 }
 
 func Load() (*Config, error) {
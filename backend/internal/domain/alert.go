@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+
+	AlertMetricInflow   = "inflow"   // all new tickets
+	AlertMetricNegative = "negative" // tickets with negative sentiment
+)
+
+// Alert is an anomaly raised by the detector for one hourly bucket of a metric.
+type Alert struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	Metric         string     `json:"metric" db:"metric"`
+	Dimension      string     `json:"dimension" db:"dimension"` // type, city, channel
+	DimensionValue string     `json:"dimension_value" db:"dimension_value"`
+	BucketStart    time.Time  `json:"bucket_start" db:"bucket_start"`
+	Observed       float64    `json:"observed" db:"observed"`
+	Expected       float64    `json:"expected" db:"expected"`
+	ZScore         float64    `json:"z_score" db:"z_score"`
+	Severity       string     `json:"severity" db:"severity"`
+	Message        string     `json:"message" db:"message"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at" db:"acknowledged_at"`
+	AcknowledgedBy *string    `json:"acknowledged_by" db:"acknowledged_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
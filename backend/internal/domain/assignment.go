@@ -17,3 +17,9 @@ type TicketAssignment struct {
 	RoutingReason  *string   `json:"routing_reason" db:"routing_reason"`
 	IsCurrent      bool      `json:"is_current" db:"is_current"`
 }
+
+// ManagerAssignment is a manager's latest assignment time within some set of tickets.
+type ManagerAssignment struct {
+	ManagerID  uuid.UUID `json:"manager_id"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
@@ -20,8 +20,14 @@ type AuditLog struct {
 // Audit step constants.
 const (
 	AuditStepAIEnrich   = "ai_enrich"
+	AuditStepThread     = "thread"
 	AuditStepGeoFilter  = "geo_filter"
 	AuditStepSkillFilter = "skill_filter"
+	AuditStepAffinity    = "affinity"
 	AuditStepLoadBalance = "load_balance"
 	AuditStepRoundRobin  = "round_robin"
+	AuditStepHandoff     = "handoff"
+	AuditStepRebalance   = "rebalance"
+	AuditStepQueuePull   = "queue_pull"
+	AuditStepEscalation  = "escalation"
 )
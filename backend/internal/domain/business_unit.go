@@ -13,5 +13,6 @@ type BusinessUnit struct {
 	Address   *string   `json:"address" db:"address"`
 	Lat       *float64  `json:"lat" db:"lat"`
 	Lon       *float64  `json:"lon" db:"lon"`
+	IsActive  bool      `json:"is_active" db:"is_active"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
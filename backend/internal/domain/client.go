@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Client struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	GUID       string     `json:"guid" db:"guid"`
+	FullName   *string    `json:"full_name" db:"full_name"`
+	Segment    *string    `json:"segment" db:"segment"`
+	Gender     *string    `json:"gender" db:"gender"`
+	BirthDate  *time.Time `json:"birth_date" db:"birth_date"`
+	RawAddress *string    `json:"raw_address" db:"raw_address"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type ClientSegmentChange struct {
+	FromSegment *string   `json:"from_segment"`
+	ToSegment   *string   `json:"to_segment"`
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+// ClientSentimentPoint counts a client's tickets per sentiment in one month.
+type ClientSentimentPoint struct {
+	Month    string `json:"month"` // YYYY-MM
+	Positive int    `json:"positive"`
+	Neutral  int    `json:"neutral"`
+	Negative int    `json:"negative"`
+}
+
+// ClientManager is a manager currently assigned to at least one of the client's tickets.
+type ClientManager struct {
+	ManagerID      uuid.UUID `json:"manager_id"`
+	FullName       string    `json:"full_name"`
+	Office         string    `json:"office"`
+	Tickets        int       `json:"tickets"`
+	LastAssignedAt time.Time `json:"last_assigned_at"`
+}
+
+// ClientView is the 360° view returned by GET /clients/{id}.
+type ClientView struct {
+	Client         Client                 `json:"client"`
+	SegmentHistory []ClientSegmentChange  `json:"segment_history"`
+	Tickets        []Ticket               `json:"tickets"`
+	SentimentTrend []ClientSentimentPoint `json:"sentiment_trend"`
+	Managers       []ClientManager        `json:"managers"`
+}
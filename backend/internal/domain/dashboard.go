@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DashboardFilter narrows every /dashboard/* aggregate to a slice of tickets.
+// Zero values mean "no restriction".
+type DashboardFilter struct {
+	From         *time.Time // tickets.created_at >= From
+	To           *time.Time // tickets.created_at < To
+	BusinessUnit string     // business unit id, name or city of the current assignment
+	ManagerID    *uuid.UUID // currently assigned manager
+	Segment      string
+	Channel      string
+	Type         string
+	Lang         string
+}
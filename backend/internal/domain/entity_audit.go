@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entity audit actions.
+const (
+	EntityActionCreate     = "create"
+	EntityActionUpdate     = "update"
+	EntityActionDeactivate = "deactivate"
+	EntityActionActivate   = "activate"
+)
+
+// EntityTeams is the entity_audit entity of teams; managers and offices use
+// the import entity names.
+const EntityTeams = "teams"
+
+// EntityAudit is one change made to a manager, office or team through the API.
+type EntityAudit struct {
+	ID        int64           `json:"id" db:"id"`
+	Entity    string          `json:"entity" db:"entity"` // managers, business_units, teams
+	EntityID  uuid.UUID       `json:"entity_id" db:"entity_id"`
+	Action    string          `json:"action" db:"action"`
+	Actor     *string         `json:"actor" db:"actor"`
+	Before    json.RawMessage `json:"before" db:"before"` // null on create
+	After     json.RawMessage `json:"after" db:"after"`
+	Details   json.RawMessage `json:"details,omitempty" db:"details"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Import job statuses.
+const (
+	ImportStatusPending    = "pending"
+	ImportStatusRunning    = "running"
+	ImportStatusCompleted  = "completed"
+	ImportStatusFailed     = "failed"
+	ImportStatusRolledBack = "rolled_back"
+)
+
+// Import is one uploaded file and the progress of importing it. Counters up
+// to CheckpointRow are committed; a failed import resumes after that row.
+type Import struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	Type          *string    `json:"type" db:"type"` // tickets, managers, business_units; nil until detected
+	Status        string     `json:"status" db:"status"`
+	Format        string     `json:"format" db:"format"` // csv, xlsx, json, jsonl
+	Sheet         *string    `json:"sheet,omitempty" db:"sheet"`
+	ProfileID     *uuid.UUID `json:"profile_id,omitempty" db:"profile_id"`
+	Filename      *string    `json:"filename" db:"filename"`
+	FileHash      *string    `json:"file_hash" db:"file_hash"` // SHA-256 of the upload, hex
+	Initiator     *string    `json:"initiator" db:"initiator"`
+	SpoolPath     *string    `json:"-" db:"spool_path"`
+	SizeBytes     int64      `json:"size_bytes" db:"size_bytes"`
+	BytesRead     int64      `json:"bytes_read" db:"bytes_read"`
+	RowsRead      int        `json:"rows_read" db:"rows_read"`
+	Imported      int        `json:"imported" db:"imported"`
+	Skipped       int        `json:"skipped" db:"skipped"`
+	Linked        int        `json:"linked" db:"linked"`
+	CheckpointRow int        `json:"checkpoint_row" db:"checkpoint_row"`
+	ErrorCount    int        `json:"error_count" db:"error_count"`
+	Errors        []string   `json:"errors" db:"errors"` // first errors only, see ErrorCount
+	Error         *string    `json:"error" db:"error"`   // why the job failed
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+	FinishedAt    *time.Time `json:"finished_at" db:"finished_at"`
+	RolledBackAt  *time.Time `json:"rolled_back_at,omitempty" db:"rolled_back_at"`
+}
+
+type ImportListFilter struct {
+	Page      int
+	PerPage   int
+	Type      string
+	Status    string
+	FileHash  string
+	Initiator string
+}
+
+// Entities an import writes, as logged in import_changes.
+const (
+	ImportEntityTickets       = "tickets"
+	ImportEntityClients       = "clients"
+	ImportEntityManagers      = "managers"
+	ImportEntityBusinessUnits = "business_units"
+)
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Column transforms of an import profile, applied in order.
+const (
+	TransformTrim  = "trim"  // trim and collapse whitespace
+	TransformUpper = "upper" // upper-case
+	TransformLower = "lower" // lower-case
+	TransformSplit = "split" // split into a list on Separator; stored comma-separated, or one part per entry of Fields
+	TransformDate  = "date"  // parse with Layout (or the built-in date layouts) into YYYY-MM-DD
+	TransformEnum  = "enum"  // replace values via Values, matched case-insensitively
+)
+
+// ImportProfile maps the columns of a partner's files onto canonical import
+// fields. Columns it does not mention fall back to the built-in header aliases.
+type ImportProfile struct {
+	ID        uuid.UUID         `json:"id" db:"id"`
+	Name      string            `json:"name" db:"name"`
+	Type      *string           `json:"type" db:"type"` // tickets, managers, business_units; nil to detect
+	Columns   []ColumnMapping   `json:"columns" db:"columns"`
+	Defaults  map[string]string `json:"defaults" db:"defaults"` // field → value when the file leaves it empty
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// ColumnMapping maps one source column. Several columns mapped to the same
+// field are joined with a space (e.g. first and last name). A split column
+// may instead fill several fields, one part each (e.g. "Алматы; Абая 10" into
+// a city and a street field).
+type ColumnMapping struct {
+	Source     string            `json:"source"`           // header in the file, case-insensitive
+	Field      string            `json:"field"`            // canonical field, e.g. body, client_segment; empty with Fields
+	Fields     []string          `json:"fields,omitempty"` // for split: the field of each part, in order
+	Transforms []string          `json:"transforms,omitempty"`
+	Separator  string            `json:"separator,omitempty"` // for split
+	Layout     string            `json:"layout,omitempty"`    // for date, Go layout e.g. "02.01.2006"
+	Values     map[string]string `json:"values,omitempty"`    // for enum: source value → canonical value
+}
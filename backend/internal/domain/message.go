@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ticket message kinds.
+const (
+	MessageKindNote   = "note"   // internal, never shown to the client
+	MessageKindReply  = "reply"  // sent to the client
+	MessageKindClient = "client" // received from the client
+)
+
+// TicketMessage is one entry of a ticket's conversation.
+type TicketMessage struct {
+	ID          uuid.UUID           `json:"id" db:"id"`
+	TicketID    uuid.UUID           `json:"ticket_id" db:"ticket_id"`
+	Kind        string              `json:"kind" db:"kind"`
+	Author      string              `json:"author" db:"author"`
+	ManagerID   *uuid.UUID          `json:"manager_id" db:"manager_id"`
+	Body        string              `json:"body" db:"body"`
+	Attachments []MessageAttachment `json:"attachments" db:"attachments"`
+	DoneActions []int               `json:"done_actions" db:"done_actions"` // recommended actions checked off
+	CreatedAt   time.Time           `json:"created_at" db:"created_at"`
+}
+
+// MessageAttachment is a file of a message: uploaded with it, or a link
+// given by an integration.
+type MessageAttachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	URL         string `json:"url"`
+	Uploaded    bool   `json:"uploaded"` // kept under the attachments dir, served at URL
+}
+
+// TicketAction is one of the AI's recommended actions for a ticket.
+type TicketAction struct {
+	Index     int        `json:"index"`
+	Text      string     `json:"text"`
+	Done      bool       `json:"done"`
+	DoneBy    *string    `json:"done_by"`
+	DoneAt    *time.Time `json:"done_at"`
+	MessageID *uuid.UUID `json:"message_id"` // message that checked it off
+}
+
+// TicketConversation is a ticket's messages, oldest first, and its checklist
+// of recommended actions.
+type TicketConversation struct {
+	Messages []TicketMessage `json:"messages"`
+	Actions  []TicketAction  `json:"actions"`
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// Ticket statuses of the manager work queue.
+const (
+	TicketStatusRouted     = "routed"      // assigned, waiting to be taken
+	TicketStatusInProgress = "in_progress" // pulled by its manager
+)
+
+// QueueScoring orders a manager's work queue. A ticket's score is
+//
+//	Priority·priority/10 + SLA·min(elapsed/SLA, 2) + Segment·segment weight + Age·min(age/7d, 1)
+//
+// so overdue tickets keep rising until twice their SLA.
+type QueueScoring struct {
+	Priority float64
+	SLA      float64
+	Segment  float64
+	Age      float64
+
+	SLAHours        map[string]int // per client segment
+	DefaultSLAHours int            // other segments
+	SegmentWeights  map[string]float64
+}
+
+// QueueItem is a ticket in a manager's work queue.
+type QueueItem struct {
+	Ticket
+	Priority    *int      `json:"priority"`
+	SLADeadline time.Time `json:"sla_deadline"`
+	Overdue     bool      `json:"overdue"`
+	Score       float64   `json:"score"`
+	Pool        bool      `json:"pool"` // unassigned ticket the manager has the skills for
+}
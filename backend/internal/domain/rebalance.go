@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Rebalance modes.
+const (
+	RebalanceModeReroute    = "reroute"    // re-run routing, usually with managers excluded
+	RebalanceModeDistribute = "distribute" // spread tickets over target managers by share
+)
+
+// Rebalance job statuses.
+const (
+	RebalanceStatusPreview   = "preview"
+	RebalanceStatusRunning   = "running"
+	RebalanceStatusCompleted = "completed"
+	RebalanceStatusFailed    = "failed"
+)
+
+// Rebalance move statuses.
+const (
+	RebalanceMovePlanned = "planned"
+	RebalanceMoveApplied = "applied"
+	RebalanceMoveSkipped = "skipped"
+	RebalanceMoveFailed  = "failed"
+)
+
+// RebalanceFilter selects the open tickets of a rebalance. Empty fields match all.
+type RebalanceFilter struct {
+	ManagerIDs  []uuid.UUID `json:"manager_ids,omitempty"`
+	OfficeIDs   []uuid.UUID `json:"office_ids,omitempty"` // office of the current manager
+	MinAgeHours int         `json:"min_age_hours,omitempty"`
+	MinPriority *int        `json:"min_priority,omitempty"`
+	MaxPriority *int        `json:"max_priority,omitempty"`
+	Limit       int         `json:"limit,omitempty"`
+}
+
+// RebalanceTarget is a manager's share of the tickets of a distribute rebalance.
+type RebalanceTarget struct {
+	ManagerID uuid.UUID `json:"manager_id"`
+	Share     float64   `json:"share"` // relative weight, e.g. 1 and 2 for a third and two thirds
+}
+
+// RebalanceJob is a planned redistribution of open tickets and the progress
+// of applying it.
+type RebalanceJob struct {
+	ID         uuid.UUID         `json:"id" db:"id"`
+	Mode       string            `json:"mode" db:"mode"`
+	Status     string            `json:"status" db:"status"`
+	Filter     RebalanceFilter   `json:"filter" db:"filter"`
+	Policy     json.RawMessage   `json:"policy,omitempty" db:"policy"` // routing policy of a reroute
+	Targets    []RebalanceTarget `json:"targets" db:"targets"`
+	Initiator  *string           `json:"initiator" db:"initiator"`
+	Selected   int               `json:"selected" db:"selected"`   // open tickets matching the filter
+	Planned    int               `json:"planned" db:"planned"`     // of those, to move to another manager
+	Unchanged  int               `json:"unchanged" db:"unchanged"` // planned to stay with their manager
+	Applied    int               `json:"applied" db:"applied"`
+	Skipped    int               `json:"skipped" db:"skipped"`
+	Failed     int               `json:"failed" db:"failed"`
+	Error      *string           `json:"error" db:"error"` // why the job failed
+	CreatedAt  time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at" db:"updated_at"`
+	StartedAt  *time.Time        `json:"started_at" db:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at" db:"finished_at"`
+}
+
+// RebalanceMove is one ticket of a rebalance job.
+type RebalanceMove struct {
+	JobID         uuid.UUID  `json:"job_id" db:"job_id"`
+	TicketID      uuid.UUID  `json:"ticket_id" db:"ticket_id"`
+	Position      int        `json:"position" db:"position"`
+	FromManagerID uuid.UUID  `json:"from_manager_id" db:"from_manager_id"`
+	ToManagerID   *uuid.UUID `json:"to_manager_id" db:"to_manager_id"`
+	RoutingBucket string     `json:"routing_bucket" db:"routing_bucket"`
+	Reason        *string    `json:"reason" db:"reason"`
+	Status        string     `json:"status" db:"status"`
+	Error         *string    `json:"error" db:"error"`
+	AppliedAt     *time.Time `json:"applied_at" db:"applied_at"`
+}
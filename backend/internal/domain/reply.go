@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reply languages, as detected by enrichment.
+const (
+	LangRU = "RU"
+	LangKZ = "KZ"
+	LangEN = "EN"
+)
+
+// ReplyTemplate is an entry of the reply template library. It drafts replies
+// in Lang to tickets matching every non-empty condition; the body holds
+// {{placeholders}} filled from the client and the ticket.
+type ReplyTemplate struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Lang        string    `json:"lang" db:"lang"`
+	TicketTypes []string  `json:"ticket_types" db:"ticket_types"`
+	Segments    []string  `json:"segments" db:"segments"`
+	Body        string    `json:"body" db:"body"`
+	IsActive    bool      `json:"is_active" db:"is_active"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// DraftReply is a reply suggested to the manager of a ticket; nothing is sent
+// or stored until it is posted as a message.
+type DraftReply struct {
+	TicketID     uuid.UUID  `json:"ticket_id"`
+	Lang         string     `json:"lang"`
+	Type         string     `json:"type"`
+	Segment      string     `json:"segment"`
+	TemplateID   *uuid.UUID `json:"template_id"` // nil for the built-in template
+	TemplateName string     `json:"template_name"`
+	Draft        string     `json:"draft"`    // the filled template
+	Text         string     `json:"text"`     // the suggested reply: the draft, polished if an LLM was used
+	Polished     bool       `json:"polished"` // whether Text was rewritten by the LLM
+	Missing      []string   `json:"missing"`  // placeholders filled with a stand-in for lack of data
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Skill categories.
+const (
+	SkillCategoryProduct       = "product"
+	SkillCategoryLanguage      = "language"
+	SkillCategorySegment       = "segment"
+	SkillCategoryCertification = "certification"
+)
+
+// Skills the legacy manager columns map to.
+const (
+	SkillVIP       = "VIP"
+	SkillChiefSpec = "CHIEF_SPEC"
+)
+
+// Proficiency levels of a manager skill.
+const (
+	SkillLevelMin     = 1
+	SkillLevelMax     = 5
+	SkillLevelDefault = 3
+)
+
+// Skill is an entry of the skills catalogue.
+type Skill struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Code      string    `json:"code" db:"code"`
+	Name      string    `json:"name" db:"name"`
+	Category  string    `json:"category" db:"category"`
+	Aliases   []string  `json:"aliases" db:"aliases"` // other spellings accepted on import
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ManagerSkill is a skill a manager has, with their proficiency.
+type ManagerSkill struct {
+	Code     string `json:"code"`
+	Name     string `json:"name,omitempty"`
+	Category string `json:"category,omitempty"`
+	Level    int    `json:"level"`
+}
+
+// SkillRequirement is a rule of the routing skill stage: tickets matching
+// every non-empty condition need a manager with the skill at MinLevel or above.
+type SkillRequirement struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	SkillID     uuid.UUID `json:"skill_id" db:"skill_id"`
+	SkillCode   string    `json:"skill_code" db:"-"`
+	Category    string    `json:"category" db:"-"`
+	MinLevel    int       `json:"min_level" db:"min_level"`
+	Segments    []string  `json:"segments" db:"segments"`
+	TicketTypes []string  `json:"ticket_types" db:"ticket_types"`
+	Langs       []string  `json:"langs" db:"langs"`
+	Position    int       `json:"position" db:"position"`
+	IsActive    bool      `json:"is_active" db:"is_active"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
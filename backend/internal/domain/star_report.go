@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StarReport is a saved Star question with its validated SQL, optionally run on a cron schedule.
+type StarReport struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	Name           string          `json:"name" db:"name"`
+	Question       string          `json:"question" db:"question"`
+	SQL            string          `json:"sql" db:"sql"`
+	ChartType      string          `json:"chart_type" db:"chart_type"`
+	XLabel         *string         `json:"x_label" db:"x_label"`
+	YLabel         *string         `json:"y_label" db:"y_label"`
+	Schedule       *string         `json:"schedule" db:"schedule"`               // cron spec, e.g. "0 9 * * 1"
+	DeliveryFormat string          `json:"delivery_format" db:"delivery_format"` // "csv" | "json"
+	DeliveryTarget *string         `json:"delivery_target" db:"delivery_target"` // webhook URL or subdirectory of the reports dir
+	DefaultParams  json.RawMessage `json:"default_params" db:"default_params"`
+	LastRunAt      *time.Time      `json:"last_run_at" db:"last_run_at"`
+	LastError      *string         `json:"last_error" db:"last_error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// StarReportParams are substituted into :date_from, :date_to and :office placeholders of a report's SQL.
+type StarReportParams struct {
+	DateFrom *time.Time `json:"date_from,omitempty"`
+	DateTo   *time.Time `json:"date_to,omitempty"`
+	Office   *string    `json:"office,omitempty"`
+	LastDays int        `json:"last_days,omitempty"` // relative range for scheduled runs; ignored if DateFrom is set
+}
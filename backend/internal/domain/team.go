@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Team is a group of managers within an office, led by a supervisor.
+type Team struct {
+	ID               uuid.UUID  `json:"id" db:"id"`
+	Name             string     `json:"name" db:"name"`
+	BusinessUnitID   uuid.UUID  `json:"business_unit_id" db:"business_unit_id"`
+	SupervisorID     *uuid.UUID `json:"supervisor_id" db:"supervisor_id"`
+	EscalationTeamID *uuid.UUID `json:"escalation_team_id" db:"escalation_team_id"` // next team up
+	IsActive         bool       `json:"is_active" db:"is_active"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+}
+
+// TeamWithInfo is a team as listed, with its office, supervisor and size.
+type TeamWithInfo struct {
+	Team
+	OfficeName     string  `json:"office_name"`
+	SupervisorName *string `json:"supervisor_name"`
+	Members        int     `json:"members"`
+}
+
+// SLA states of an open ticket.
+const (
+	SLAStateOK      = "ok"
+	SLAStateAtRisk  = "at_risk" // more than 75% of the SLA elapsed
+	SLAStateOverdue = "overdue"
+)
+
+// TeamMember is a member of a team with their open workload.
+type TeamMember struct {
+	Manager
+	Utilization float64 `json:"utilization_pct"`
+	Open        int     `json:"open"` // assigned, not resolved or closed
+	InProgress  int     `json:"in_progress"`
+	AtRisk      int     `json:"at_risk"`
+	Overdue     int     `json:"overdue"`
+}
+
+// TeamOverview is a supervisor's view of a team: its members' load and the
+// SLA state of their open tickets.
+type TeamOverview struct {
+	TeamWithInfo
+	Supervisor  *Manager     `json:"supervisor"`
+	MemberLoad  []TeamMember `json:"member_load"`
+	Open        int          `json:"open"`
+	InProgress  int          `json:"in_progress"`
+	AtRisk      int          `json:"at_risk"`
+	Overdue     int          `json:"overdue"`
+	Escalated   int          `json:"escalated"` // open tickets the supervisor holds by escalation
+	Utilization float64      `json:"utilization_pct"`
+}
+
+// TeamTicket is an open ticket of a team member or of the team's supervisor.
+type TeamTicket struct {
+	Ticket
+	ManagerName string    `json:"manager_name"`
+	Priority    *int      `json:"priority"`
+	SLADeadline time.Time `json:"sla_deadline"`
+	SLAState    string    `json:"sla_state"`
+	Escalations int       `json:"escalations"`
+}
+
+// Escalation targets.
+const (
+	EscalationTargetSupervisor = "supervisor"
+	EscalationTargetChiefSpec  = "chief_specialist"
+	EscalationTargetManager    = "manager" // named in the request
+)
+
+// Escalation is one move of a ticket up the escalation path.
+type Escalation struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	TicketID      uuid.UUID  `json:"ticket_id" db:"ticket_id"`
+	FromManagerID *uuid.UUID `json:"from_manager_id" db:"from_manager_id"`
+	ToManagerID   uuid.UUID  `json:"to_manager_id" db:"to_manager_id"`
+	TeamID        *uuid.UUID `json:"team_id" db:"team_id"`
+	Target        string     `json:"target" db:"target"`
+	Level         int        `json:"level" db:"level"`
+	Reason        string     `json:"reason" db:"reason"`
+	Actor         *string    `json:"actor" db:"actor"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
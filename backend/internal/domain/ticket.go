@@ -18,6 +18,11 @@ type Ticket struct {
 	Status        string     `json:"status" db:"status"`
 	RawAddress    *string    `json:"raw_address" db:"raw_address"`
 	Attachments   *string    `json:"attachments" db:"attachments"`
+	ClientID      *uuid.UUID `json:"client_id" db:"client_id"`
+	ClientGUID    *string    `json:"client_guid" db:"client_guid"`
+	ThreadID      *uuid.UUID `json:"thread_id" db:"thread_id"`       // first ticket of the client's thread
+	DuplicateOf   *uuid.UUID `json:"duplicate_of" db:"duplicate_of"` // near-duplicate of this ticket
+	Similarity    *float64   `json:"similarity" db:"similarity"`     // MinHash similarity to the closest thread ticket
 	ManagerID     *uuid.UUID `json:"manager_id,omitempty" db:"manager_id"`
 	OfficeID      *uuid.UUID `json:"office_id,omitempty" db:"office_id"`
 	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
@@ -61,6 +66,7 @@ type TicketWithDetails struct {
 	Assignment *TicketAssignment  `json:"assignment"`
 	Manager    *ManagerWithOffice `json:"assigned_manager"`
 	AuditTrail []AuditLog         `json:"audit_trail"`
+	Thread     []Ticket           `json:"thread"` // all tickets of the client thread, oldest first; empty if not linked
 	GeoCity    *string            `json:"geo_city"`    // resolved city from geo_cache
 	DistanceKm *float64           `json:"distance_km"` // Haversine distance ticket→office (km)
 }
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+type AlertHandler struct {
+	svc *service.AnomalyService
+}
+
+func NewAlertHandler(svc *service.AnomalyService) *AlertHandler {
+	return &AlertHandler{svc: svc}
+}
+
+// List returns recent alerts; ?status=open|acknowledged narrows them, ?limit= caps the count (default 100).
+func (h *AlertHandler) List(w http.ResponseWriter, r *http.Request) {
+	var acknowledged *bool
+	switch r.URL.Query().Get("status") {
+	case "":
+	case "open":
+		v := false
+		acknowledged = &v
+	case "acknowledged":
+		v := true
+		acknowledged = &v
+	default:
+		RespondError(w, http.StatusBadRequest, "status must be open or acknowledged")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	alerts, err := h.svc.List(r.Context(), acknowledged, limit)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondOK(w, alerts)
+}
+
+func (h *AlertHandler) Acknowledge(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req struct {
+		By *string `json:"by"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+	}
+
+	alert, err := h.svc.Acknowledge(r.Context(), id, req.By)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			RespondError(w, http.StatusNotFound, "not found")
+			return
+		}
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondOK(w, alert)
+}
+
+// BroadcastAlert pushes a newly raised alert to SSE clients as an "anomaly" event.
+func BroadcastAlert(alert domain.Alert) {
+	GlobalHub.Broadcast(WSEvent{Type: "anomaly", Data: alert})
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+type ClientHandler struct {
+	svc *service.ClientService
+}
+
+func NewClientHandler(svc *service.ClientService) *ClientHandler {
+	return &ClientHandler{svc: svc}
+}
+
+// Get returns the 360° client view; {id} is the client id or its GUID.
+func (h *ClientHandler) Get(w http.ResponseWriter, r *http.Request) {
+	view, err := h.svc.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondClientError(w, err)
+		return
+	}
+	RespondOK(w, view)
+}
+
+func respondClientError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "not found")
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -1,21 +1,35 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/arslan/fire-challenge/internal/domain"
 	"github.com/arslan/fire-challenge/internal/service"
 )
 
 type DashboardHandler struct {
-	svc *service.DashboardService
+	svc           *service.DashboardService
+	exportMaxRows int
 }
 
-func NewDashboardHandler(svc *service.DashboardService) *DashboardHandler {
-	return &DashboardHandler{svc: svc}
+func NewDashboardHandler(svc *service.DashboardService, exportMaxRows int) *DashboardHandler {
+	return &DashboardHandler{svc: svc, exportMaxRows: exportMaxRows}
 }
 
 func (h *DashboardHandler) Stats(w http.ResponseWriter, r *http.Request) {
-	stats, err := h.svc.Stats(r.Context())
+	filter, err := parseDashboardFilter(r, h.svc.Location())
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats, err := h.svc.Stats(r.Context(), filter)
 	if err != nil {
 		RespondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -24,7 +38,13 @@ func (h *DashboardHandler) Stats(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DashboardHandler) Sentiment(w http.ResponseWriter, r *http.Request) {
-	data, err := h.svc.Sentiment(r.Context())
+	filter, err := parseDashboardFilter(r, h.svc.Location())
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.svc.Sentiment(r.Context(), filter)
 	if err != nil {
 		RespondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -33,7 +53,13 @@ func (h *DashboardHandler) Sentiment(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DashboardHandler) Categories(w http.ResponseWriter, r *http.Request) {
-	data, err := h.svc.Categories(r.Context())
+	filter, err := parseDashboardFilter(r, h.svc.Location())
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.svc.Categories(r.Context(), filter)
 	if err != nil {
 		RespondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -42,7 +68,13 @@ func (h *DashboardHandler) Categories(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *DashboardHandler) ManagerLoad(w http.ResponseWriter, r *http.Request) {
-	data, err := h.svc.ManagerLoad(r.Context())
+	filter, err := parseDashboardFilter(r, h.svc.Location())
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.svc.ManagerLoad(r.Context(), filter)
 	if err != nil {
 		RespondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -50,11 +82,168 @@ func (h *DashboardHandler) ManagerLoad(w http.ResponseWriter, r *http.Request) {
 	RespondOK(w, data)
 }
 
+// Timeline returns zero-filled ticket counts; ?bucket=hour|day|week|month, ?group_by=type|sentiment|office|channel.
 func (h *DashboardHandler) Timeline(w http.ResponseWriter, r *http.Request) {
-	data, err := h.svc.Timeline(r.Context())
+	filter, err := parseDashboardFilter(r, h.svc.Location())
 	if err != nil {
-		RespondError(w, http.StatusInternalServerError, err.Error())
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.svc.Timeline(r.Context(), filter, r.URL.Query().Get("bucket"), r.URL.Query().Get("group_by"))
+	if err != nil {
+		respondDashboardError(w, err)
+		return
+	}
+	RespondOK(w, data)
+}
+
+// Latency returns p50/p90/p99 stage latencies; ?bucket=hour|day|week|month groups them over time.
+func (h *DashboardHandler) Latency(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseDashboardFilter(r, h.svc.Location())
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.svc.Latency(r.Context(), filter, r.URL.Query().Get("bucket"))
+	if err != nil {
+		respondDashboardError(w, err)
+		return
+	}
+	RespondOK(w, data)
+}
+
+func (h *DashboardHandler) BacklogAge(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseDashboardFilter(r, h.svc.Location())
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.svc.BacklogAge(r.Context(), filter)
+	if err != nil {
+		respondDashboardError(w, err)
 		return
 	}
 	RespondOK(w, data)
 }
+
+// Throughput returns per-manager assigned/resolved counts; ?bucket= groups them over time.
+func (h *DashboardHandler) Throughput(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseDashboardFilter(r, h.svc.Location())
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.svc.Throughput(r.Context(), filter, r.URL.Query().Get("bucket"))
+	if err != nil {
+		respondDashboardError(w, err)
+		return
+	}
+	RespondOK(w, data)
+}
+
+// Export streams one dashboard dataset as CSV, XLSX or JSON Lines.
+func (h *DashboardHandler) Export(w http.ResponseWriter, r *http.Request) {
+	dataset := chi.URLParam(r, "dataset")
+	filter, err := parseDashboardFilter(r, h.svc.Location())
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var data interface{}
+	switch dataset {
+	case "stats":
+		data, err = h.svc.Stats(r.Context(), filter)
+	case "sentiment":
+		data, err = h.svc.Sentiment(r.Context(), filter)
+	case "categories":
+		data, err = h.svc.Categories(r.Context(), filter)
+	case "manager-load":
+		data, err = h.svc.ManagerLoad(r.Context(), filter)
+	case "timeline":
+		data, err = h.svc.Timeline(r.Context(), filter, r.URL.Query().Get("bucket"), r.URL.Query().Get("group_by"))
+	case "latency":
+		data, err = h.svc.Latency(r.Context(), filter, r.URL.Query().Get("bucket"))
+	case "backlog-age":
+		data, err = h.svc.BacklogAge(r.Context(), filter)
+	case "throughput":
+		data, err = h.svc.Throughput(r.Context(), filter, r.URL.Query().Get("bucket"))
+	default:
+		RespondError(w, http.StatusNotFound, "unknown dataset")
+		return
+	}
+	if err != nil {
+		respondDashboardError(w, err)
+		return
+	}
+
+	export, err := newExportResponse(w, r, "dashboard_"+dataset, h.exportMaxRows)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	truncated, err := service.ExportStructs(export, data, export.limit)
+	if err := export.finish(truncated, err); err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func respondDashboardError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrInvalidDashboardParam) {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	RespondError(w, http.StatusInternalServerError, err.Error())
+}
+
+// parseDashboardFilter reads the shared dashboard filter from the query string:
+// from, to (2006-01-02 or RFC3339; bare dates are in loc and "to" is inclusive),
+// business_unit (id, name or city), manager_id, segment, channel, type, lang.
+func parseDashboardFilter(r *http.Request, loc *time.Location) (domain.DashboardFilter, error) {
+	q := r.URL.Query()
+	f := domain.DashboardFilter{
+		BusinessUnit: q.Get("business_unit"),
+		Segment:      q.Get("segment"),
+		Channel:      q.Get("channel"),
+		Type:         q.Get("type"),
+		Lang:         q.Get("lang"),
+	}
+
+	if v := q.Get("from"); v != "" {
+		t, err := parseFilterTime(v, loc)
+		if err != nil {
+			return f, fmt.Errorf("invalid from")
+		}
+		f.From = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := parseFilterTime(v, loc)
+		if err != nil {
+			return f, fmt.Errorf("invalid to")
+		}
+		if len(v) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		f.To = &t
+	}
+	if v := q.Get("manager_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid manager_id")
+		}
+		f.ManagerID = &id
+	}
+	return f, nil
+}
+
+func parseFilterTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+// exportResponse streams an export as a file download. Headers are written
+// lazily on the first WriteHeader call, so errors raised before any data is
+// produced can still be answered with a normal JSON error.
+type exportResponse struct {
+	w        http.ResponseWriter
+	format   service.ExportFormat
+	filename string
+	limit    int
+	ew       service.ExportWriter
+}
+
+// newExportResponse reads ?format= and ?limit= from the request. The limit is
+// capped at maxRows.
+func newExportResponse(w http.ResponseWriter, r *http.Request, name string, maxRows int) (*exportResponse, error) {
+	format, err := service.ParseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		return nil, err
+	}
+
+	limit := maxRows
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid limit")
+		}
+		if maxRows <= 0 || n < maxRows {
+			limit = n
+		}
+	}
+
+	return &exportResponse{
+		w:        w,
+		format:   format,
+		filename: fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102_150405"), format.Extension()),
+		limit:    limit,
+	}, nil
+}
+
+func (e *exportResponse) WriteHeader(columns []string) error {
+	h := e.w.Header()
+	h.Set("Content-Type", e.format.ContentType())
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": e.filename}))
+	h.Set("Trailer", "X-Export-Truncated")
+	e.w.WriteHeader(http.StatusOK)
+
+	e.ew = service.NewExportWriter(e.w, e.format)
+	return e.ew.WriteHeader(columns)
+}
+
+func (e *exportResponse) WriteRow(values []interface{}) error {
+	return e.ew.WriteRow(values)
+}
+
+func (e *exportResponse) Close() error {
+	if e.ew == nil {
+		return nil
+	}
+	return e.ew.Close()
+}
+
+// finish flushes the export. It returns err unchanged if nothing has been sent
+// yet so the caller can respond with JSON; once streaming has started the
+// status can no longer change, so the connection is aborted instead of
+// leaving the client with a file that looks complete.
+func (e *exportResponse) finish(truncated bool, err error) error {
+	if err == nil {
+		err = e.Close()
+	}
+	if err != nil {
+		if e.ew == nil {
+			return err
+		}
+		log.Error().Err(err).Str("file", e.filename).Msg("export aborted")
+		panic(http.ErrAbortHandler)
+	}
+	e.w.Header().Set("X-Export-Truncated", strconv.FormatBool(truncated))
+	return nil
+}
@@ -2,11 +2,17 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
 
+	"github.com/arslan/fire-challenge/internal/domain"
 	"github.com/arslan/fire-challenge/internal/service"
 )
 
@@ -21,52 +27,231 @@ func NewImportHandler(svc *service.ImportService, ai *service.AIService) *Import
 
 // Import auto-detects file type from CSV headers and imports accordingly.
 func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
-	file, _, err := r.FormFile("file")
+	h.runImport(w, r, "")
+}
+
+func (h *ImportHandler) ImportTickets(w http.ResponseWriter, r *http.Request) {
+	h.runImport(w, r, "tickets")
+}
+
+func (h *ImportHandler) ImportManagers(w http.ResponseWriter, r *http.Request) {
+	h.runImport(w, r, "managers")
+}
+
+func (h *ImportHandler) ImportBusinessUnits(w http.ResponseWriter, r *http.Request) {
+	h.runImport(w, r, "business_units")
+}
+
+// List returns the import history, newest first. Filters: ?type=, ?status=,
+// ?file_hash= (find earlier uploads of the same file) and ?initiator=.
+func (h *ImportHandler) List(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if page <= 0 {
+		page = 1
+	}
+	if perPage <= 0 {
+		perPage = 20
+	}
+
+	filter := domain.ImportListFilter{
+		Page:      page,
+		PerPage:   perPage,
+		Type:      r.URL.Query().Get("type"),
+		Status:    r.URL.Query().Get("status"),
+		FileHash:  r.URL.Query().Get("file_hash"),
+		Initiator: r.URL.Query().Get("initiator"),
+	}
+
+	imports, total, err := h.svc.List(r.Context(), filter)
 	if err != nil {
-		RespondError(w, http.StatusBadRequest, "missing file field")
+		respondImportError(w, err)
 		return
 	}
-	defer file.Close()
 
-	result, err := h.svc.DetectAndImport(r.Context(), file)
+	totalPages := total / perPage
+	if total%perPage > 0 {
+		totalPages++
+	}
+
+	RespondJSON(w, http.StatusOK, PaginatedResponse{
+		Data: imports,
+		Pagination: Pagination{
+			Page:       page,
+			PerPage:    perPage,
+			Total:      total,
+			TotalPages: totalPages,
+		},
+	})
+}
+
+// Get returns an import job with its progress.
+func (h *ImportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		RespondError(w, http.StatusInternalServerError, err.Error())
+		RespondError(w, http.StatusBadRequest, "invalid id")
 		return
 	}
-
-	// Broadcast newly imported ticket IDs so frontend shows them live
-	for _, id := range result.ImportedIDs {
-		GlobalHub.Broadcast(WSEvent{Type: "ticket_update", TicketID: id.String(), Status: "new"})
+	job, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		respondImportError(w, err)
+		return
 	}
+	RespondOK(w, job)
+}
 
-	// Auto-trigger AI enrichment for imported tickets in background
-	if result.Type == "tickets" && len(result.ImportedIDs) > 0 && h.ai != nil {
-		go h.enrichImportedTickets(result.ImportedIDs)
+// Resume continues a failed import after its last committed chunk.
+func (h *ImportHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
 	}
+	h.start(w, r, id)
+}
 
+// Rollback undoes a completed or failed import: rows it inserted are deleted
+// and rows it overwrote get their previous values back.
+func (h *ImportHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	result, err := h.svc.Rollback(r.Context(), id)
+	if err != nil {
+		respondImportError(w, err)
+		return
+	}
 	RespondOK(w, result)
 }
 
-func (h *ImportHandler) ImportTickets(w http.ResponseWriter, r *http.Request) {
-	file, _, err := r.FormFile("file")
+// runImport spools the upload to disk and imports it. The file is streamed
+// from the request, either as the "file" field of a multipart form or as the
+// raw body (name in ?filename=). Its format (CSV, XLSX, JSON, JSON Lines) is
+// detected unless given as ?format=; ?sheet= picks an XLSX sheet and
+// ?profile= a column mapping profile (id or name). With
+// ?dry_run=true it only validates the file and returns the report of what
+// the import would do.
+func (h *ImportHandler) runImport(w http.ResponseWriter, r *http.Request, fileType string) {
+	file, src, err := uploadedFile(r)
 	if err != nil {
-		RespondError(w, http.StatusBadRequest, "missing file field")
+		RespondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 	defer file.Close()
+	src.Format = r.URL.Query().Get("format")
+	src.Sheet = r.URL.Query().Get("sheet")
+	src.Profile = r.URL.Query().Get("profile")
+	src.Initiator = requestUser(r)
 
-	result, err := h.svc.ImportTickets(r.Context(), file)
+	if r.URL.Query().Get("dry_run") == "true" {
+		report, err := h.svc.DryRun(r.Context(), src, fileType, file)
+		if err != nil {
+			respondImportError(w, err)
+			return
+		}
+		RespondOK(w, report)
+		return
+	}
+
+	// The job must outlive a client that gives up waiting for a big file.
+	job, err := h.svc.Spool(context.WithoutCancel(r.Context()), src, fileType, file)
 	if err != nil {
-		RespondError(w, http.StatusInternalServerError, err.Error())
+		respondImportError(w, err)
+		return
+	}
+	h.start(w, r, job.ID)
+}
+
+// start runs an import in the request, or with ?async=true in the
+// background, answering 202 with the job; progress is then pushed as
+// "import_progress" events and can be polled at GET /imports/{id}.
+func (h *ImportHandler) start(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if r.URL.Query().Get("async") != "true" {
+		result, err := h.svc.Run(context.WithoutCancel(r.Context()), id)
+		if err != nil {
+			respondImportError(w, err)
+			return
+		}
+		h.afterImport(result)
+		RespondOK(w, result)
+		return
+	}
+
+	job, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		respondImportError(w, err)
+		return
+	}
+	if job.Status != domain.ImportStatusPending && job.Status != domain.ImportStatusFailed {
+		respondImportError(w, service.ErrImportNotResumable)
 		return
 	}
+	go func() {
+		result, err := h.svc.Run(context.Background(), id)
+		if err != nil {
+			log.Error().Err(err).Str("import_id", id.String()).Msg("import failed")
+			return
+		}
+		h.afterImport(result)
+	}()
+	RespondJSON(w, http.StatusAccepted, APIResponse{Data: job})
+}
+
+// afterImport announces imported tickets and queues them for AI enrichment.
+func (h *ImportHandler) afterImport(result *service.ImportResult) {
+	// Broadcast newly imported ticket IDs so frontend shows them live
+	for _, id := range result.ImportedIDs {
+		GlobalHub.Broadcast(WSEvent{Type: "ticket_update", TicketID: id.String(), Status: "new"})
+	}
 
-	// Auto-trigger AI enrichment
-	if len(result.ImportedIDs) > 0 && h.ai != nil {
+	// Auto-trigger AI enrichment for imported tickets in background
+	if result.Type == "tickets" && len(result.ImportedIDs) > 0 && h.ai != nil {
 		go h.enrichImportedTickets(result.ImportedIDs)
 	}
+}
 
-	RespondOK(w, result)
+// uploadedFile returns the uploaded file without buffering it in memory.
+func uploadedFile(r *http.Request) (io.ReadCloser, service.ImportSource, error) {
+	mr, err := r.MultipartReader()
+	if errors.Is(err, http.ErrNotMultipart) {
+		return r.Body, service.ImportSource{Filename: r.URL.Query().Get("filename"), ContentType: r.Header.Get("Content-Type")}, nil
+	}
+	if err != nil {
+		return nil, service.ImportSource{}, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, service.ImportSource{}, errors.New("missing file field")
+		}
+		if err != nil {
+			return nil, service.ImportSource{}, err
+		}
+		if part.FormName() == "file" {
+			return part, service.ImportSource{Filename: part.FileName(), ContentType: part.Header.Get("Content-Type")}, nil
+		}
+		part.Close()
+	}
+}
+
+// BroadcastImportProgress pushes an import's progress to all SSE clients.
+func BroadcastImportProgress(job domain.Import) {
+	GlobalHub.Broadcast(WSEvent{Type: "import_progress", Data: job})
+}
+
+func respondImportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "not found")
+	case errors.Is(err, service.ErrImportNotResumable), errors.Is(err, service.ErrImportNotRollbackable):
+		RespondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrUnknownProfile):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
 }
 
 // enrichImportedTickets runs AI enrichment sequentially for each imported ticket.
@@ -88,37 +273,3 @@ func (h *ImportHandler) enrichImportedTickets(ids []uuid.UUID) {
 
 	log.Info().Int("count", len(ids)).Msg("auto AI enrichment completed")
 }
-
-func (h *ImportHandler) ImportManagers(w http.ResponseWriter, r *http.Request) {
-	file, _, err := r.FormFile("file")
-	if err != nil {
-		RespondError(w, http.StatusBadRequest, "missing file field")
-		return
-	}
-	defer file.Close()
-
-	result, err := h.svc.ImportManagers(r.Context(), file)
-	if err != nil {
-		RespondError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	RespondOK(w, result)
-}
-
-func (h *ImportHandler) ImportBusinessUnits(w http.ResponseWriter, r *http.Request) {
-	file, _, err := r.FormFile("file")
-	if err != nil {
-		RespondError(w, http.StatusBadRequest, "missing file field")
-		return
-	}
-	defer file.Close()
-
-	result, err := h.svc.ImportBusinessUnits(r.Context(), file)
-	if err != nil {
-		RespondError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	RespondOK(w, result)
-}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+type ImportProfileHandler struct {
+	svc *service.ImportProfileService
+}
+
+func NewImportProfileHandler(svc *service.ImportProfileService) *ImportProfileHandler {
+	return &ImportProfileHandler{svc: svc}
+}
+
+func (h *ImportProfileHandler) List(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.svc.List(r.Context())
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondOK(w, profiles)
+}
+
+// Get returns a profile; {id} is its id or name.
+func (h *ImportProfileHandler) Get(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.svc.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondProfileError(w, err)
+		return
+	}
+	RespondOK(w, profile)
+}
+
+func (h *ImportProfileHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var profile domain.ImportProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if err := h.svc.Create(r.Context(), &profile); err != nil {
+		respondProfileError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, APIResponse{Data: profile})
+}
+
+func (h *ImportProfileHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var profile domain.ImportProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	profile.ID = id
+
+	if err := h.svc.Update(r.Context(), &profile); err != nil {
+		respondProfileError(w, err)
+		return
+	}
+	RespondOK(w, profile)
+}
+
+func (h *ImportProfileHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), id); err != nil {
+		respondProfileError(w, err)
+		return
+	}
+	RespondOK(w, map[string]string{"status": "deleted"})
+}
+
+// Suggest ranks saved profiles and drafts a new one for a header, given as
+// JSON {"columns": [...]} or as an uploaded file whose header row is read.
+func (h *ImportProfileHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	var (
+		suggestion *service.ProfileSuggestion
+		err        error
+	)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var req struct {
+			Columns []string `json:"columns"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+		if len(req.Columns) == 0 {
+			RespondError(w, http.StatusBadRequest, "columns are required")
+			return
+		}
+		suggestion, err = h.svc.Suggest(r.Context(), req.Columns)
+	} else {
+		file, src, ferr := uploadedFile(r)
+		if ferr != nil {
+			RespondError(w, http.StatusBadRequest, ferr.Error())
+			return
+		}
+		defer file.Close()
+		src.Format = r.URL.Query().Get("format")
+		src.Sheet = r.URL.Query().Get("sheet")
+		suggestion, err = h.svc.SuggestForFile(r.Context(), src, file)
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondOK(w, suggestion)
+}
+
+func respondProfileError(w http.ResponseWriter, err error) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, service.ErrInvalidProfile):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "not found")
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		RespondError(w, http.StatusConflict, "a profile with this name already exists")
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
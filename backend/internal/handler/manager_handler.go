@@ -1,11 +1,17 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 
+	"github.com/arslan/fire-challenge/internal/domain"
 	"github.com/arslan/fire-challenge/internal/service"
 )
 
@@ -80,3 +86,228 @@ func (h *ManagerHandler) GetOffice(w http.ResponseWriter, r *http.Request) {
 	}
 	RespondOK(w, office)
 }
+
+func (h *ManagerHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var manager domain.Manager
+	if err := json.NewDecoder(r.Body).Decode(&manager); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if err := h.svc.Create(r.Context(), &manager, requestUser(r)); err != nil {
+		respondManagerError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, APIResponse{Data: manager})
+}
+
+func (h *ManagerHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var manager domain.Manager
+	if err := json.NewDecoder(r.Body).Decode(&manager); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	manager.ID = id
+
+	updated, err := h.svc.Update(r.Context(), &manager, requestUser(r))
+	if err != nil {
+		respondManagerError(w, err)
+		return
+	}
+	RespondOK(w, updated)
+}
+
+// Deactivate stops routing to a manager. The optional body
+// {"handoff_to": "<manager id>"} hands their open tickets to another
+// manager; without it the tickets are routed again.
+func (h *ManagerHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req struct {
+		HandoffTo *uuid.UUID `json:"handoff_to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	result, err := h.svc.Deactivate(r.Context(), id, req.HandoffTo, requestUser(r))
+	if err != nil {
+		respondManagerError(w, err)
+		return
+	}
+	for _, ticketID := range result.HandedOff {
+		GlobalHub.Broadcast(WSEvent{Type: "ticket_update", TicketID: ticketID.String(), Status: "routed"})
+	}
+	for _, ticketID := range result.Rerouted {
+		GlobalHub.Broadcast(WSEvent{Type: "ticket_update", TicketID: ticketID.String(), Status: "routed"})
+	}
+	for _, f := range result.Unrouted {
+		GlobalHub.Broadcast(WSEvent{Type: "ticket_update", TicketID: f.TicketID.String(), Status: "enriched"})
+	}
+	RespondOK(w, result)
+}
+
+func (h *ManagerHandler) Activate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	manager, err := h.svc.Activate(r.Context(), id, requestUser(r))
+	if err != nil {
+		respondManagerError(w, err)
+		return
+	}
+	RespondOK(w, manager)
+}
+
+// Skills lists a manager's catalogue skills with their levels.
+func (h *ManagerHandler) Skills(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	skills, err := h.svc.Skills(r.Context(), id)
+	if err != nil {
+		respondManagerError(w, err)
+		return
+	}
+	RespondOK(w, skills)
+}
+
+// SetSkills replaces a manager's skills with the body, a list of
+// {"code": "VIP", "level": 4}.
+func (h *ManagerHandler) SetSkills(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var skills []domain.ManagerSkill
+	if err := json.NewDecoder(r.Body).Decode(&skills); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	manager, err := h.svc.SetSkills(r.Context(), id, skills, requestUser(r))
+	if err != nil {
+		respondManagerError(w, err)
+		return
+	}
+	RespondOK(w, manager)
+}
+
+// Changes lists the audited changes to a manager, newest first.
+func (h *ManagerHandler) Changes(w http.ResponseWriter, r *http.Request) {
+	h.changes(w, r, domain.ImportEntityManagers)
+}
+
+func (h *ManagerHandler) CreateOffice(w http.ResponseWriter, r *http.Request) {
+	var office domain.BusinessUnit
+	if err := json.NewDecoder(r.Body).Decode(&office); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if err := h.svc.CreateOffice(r.Context(), &office, requestUser(r)); err != nil {
+		respondManagerError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, APIResponse{Data: office})
+}
+
+func (h *ManagerHandler) UpdateOffice(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var office domain.BusinessUnit
+	if err := json.NewDecoder(r.Body).Decode(&office); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	office.ID = id
+
+	updated, err := h.svc.UpdateOffice(r.Context(), &office, requestUser(r))
+	if err != nil {
+		respondManagerError(w, err)
+		return
+	}
+	RespondOK(w, updated)
+}
+
+func (h *ManagerHandler) DeactivateOffice(w http.ResponseWriter, r *http.Request) {
+	h.setOfficeActive(w, r, false)
+}
+
+func (h *ManagerHandler) ActivateOffice(w http.ResponseWriter, r *http.Request) {
+	h.setOfficeActive(w, r, true)
+}
+
+// OfficeChanges lists the audited changes to an office, newest first.
+func (h *ManagerHandler) OfficeChanges(w http.ResponseWriter, r *http.Request) {
+	h.changes(w, r, domain.ImportEntityBusinessUnits)
+}
+
+func (h *ManagerHandler) setOfficeActive(w http.ResponseWriter, r *http.Request, active bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	office, err := h.svc.SetOfficeActive(r.Context(), id, active, requestUser(r))
+	if err != nil {
+		respondManagerError(w, err)
+		return
+	}
+	RespondOK(w, office)
+}
+
+func (h *ManagerHandler) changes(w http.ResponseWriter, r *http.Request, entity string) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	changes, err := h.svc.Changes(r.Context(), entity, id)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondOK(w, changes)
+}
+
+func respondManagerError(w http.ResponseWriter, err error) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, service.ErrInvalidManager), errors.Is(err, service.ErrInvalidOffice):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "not found")
+	case errors.Is(err, service.ErrOfficeInUse):
+		RespondError(w, http.StatusConflict, err.Error())
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		RespondError(w, http.StatusConflict, "a manager with this email or an office with this name already exists")
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+// multipartMemory is how much of a message upload is kept in memory; the
+// rest goes to temporary files.
+const multipartMemory = 8 << 20
+
+type MessageHandler struct {
+	svc            *service.MessageService
+	maxUploadBytes int64
+}
+
+func NewMessageHandler(svc *service.MessageService, maxUploadBytes int64) *MessageHandler {
+	return &MessageHandler{svc: svc, maxUploadBytes: maxUploadBytes}
+}
+
+// List returns a ticket's conversation and its recommended actions.
+func (h *MessageHandler) List(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	conversation, err := h.svc.Conversation(r.Context(), id)
+	if err != nil {
+		respondMessageError(w, err)
+		return
+	}
+	RespondOK(w, conversation)
+}
+
+// Post adds a message to a ticket, as JSON or as multipart/form-data with
+// the same fields (done_actions comma-separated) and the files in "files".
+func (h *MessageHandler) Post(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req service.PostMessageRequest
+	var uploads []service.MessageUpload
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadBytes)
+		if err := r.ParseMultipartForm(multipartMemory); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				RespondError(w, http.StatusRequestEntityTooLarge, "attachments are too large")
+				return
+			}
+			RespondError(w, http.StatusBadRequest, "invalid multipart form")
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		if req, err = messageFromForm(r); err != nil {
+			RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, fh := range r.MultipartForm.File["files"] {
+			uploads = append(uploads, service.MessageUpload{
+				Name:        fh.Filename,
+				ContentType: fh.Header.Get("Content-Type"),
+				Open:        func() (io.ReadCloser, error) { return fh.Open() },
+			})
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	msg, err := h.svc.Post(r.Context(), id, req, uploads, requestUser(r))
+	if err != nil {
+		respondMessageError(w, err)
+		return
+	}
+	GlobalHub.Broadcast(WSEvent{Type: "ticket_message", TicketID: id.String(), Data: msg})
+	RespondJSON(w, http.StatusCreated, APIResponse{Data: msg})
+}
+
+// messageFromForm reads the message fields of a multipart form.
+func messageFromForm(r *http.Request) (service.PostMessageRequest, error) {
+	req := service.PostMessageRequest{
+		Kind:   r.FormValue("kind"),
+		Body:   r.FormValue("body"),
+		Author: r.FormValue("author"),
+	}
+	if v := r.FormValue("manager_id"); v != "" {
+		managerID, err := uuid.Parse(v)
+		if err != nil {
+			return req, errors.New("invalid manager_id")
+		}
+		req.ManagerID = &managerID
+	}
+	for _, v := range strings.Split(r.FormValue("done_actions"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return req, errors.New("invalid done_actions")
+		}
+		req.DoneActions = append(req.DoneActions, i)
+	}
+	return req, nil
+}
+
+// SetAction checks a recommended action off, or unchecks it, with
+// {"done": true|false}.
+func (h *MessageHandler) SetAction(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	index, err := strconv.Atoi(chi.URLParam(r, "index"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid action index")
+		return
+	}
+
+	var req struct {
+		Done bool `json:"done"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	actions, err := h.svc.SetAction(r.Context(), id, index, req.Done, requestUser(r))
+	if err != nil {
+		respondMessageError(w, err)
+		return
+	}
+	GlobalHub.Broadcast(WSEvent{Type: "ticket_actions", TicketID: id.String(), Data: actions})
+	RespondOK(w, actions)
+}
+
+// Attachment downloads a file uploaded with a message.
+func (h *MessageHandler) Attachment(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	messageID, err := uuid.Parse(chi.URLParam(r, "messageID"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid message id")
+		return
+	}
+
+	path, attachment, err := h.svc.Attachment(r.Context(), id, messageID, chi.URLParam(r, "name"))
+	if err != nil {
+		respondMessageError(w, err)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		RespondError(w, http.StatusNotFound, "attachment file is missing")
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if attachment.ContentType != "" {
+		w.Header().Set("Content-Type", attachment.ContentType)
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	http.ServeContent(w, r, attachment.Name, stat.ModTime(), f)
+}
+
+func respondMessageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidMessage):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "not found")
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
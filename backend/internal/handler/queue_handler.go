@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+type QueueHandler struct {
+	svc *service.QueueService
+}
+
+func NewQueueHandler(svc *service.QueueService) *QueueHandler {
+	return &QueueHandler{svc: svc}
+}
+
+// Queue returns a manager's work queue, best ticket first (?limit=, default 50).
+func (h *QueueHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	items, err := h.svc.Queue(r.Context(), id, limit)
+	if err != nil {
+		respondQueueError(w, err)
+		return
+	}
+	RespondOK(w, items)
+}
+
+// Next hands the manager the best ticket of their queue and sets it in
+// progress; 204 when the queue is empty.
+func (h *QueueHandler) Next(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	item, err := h.svc.Next(r.Context(), id)
+	if err != nil {
+		respondQueueError(w, err)
+		return
+	}
+	if item == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	GlobalHub.Broadcast(WSEvent{Type: "ticket_update", TicketID: item.ID.String(), Status: item.Status})
+	RespondOK(w, item)
+}
+
+func respondQueueError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "manager not found")
+	case errors.Is(err, service.ErrManagerUnavailable):
+		RespondError(w, http.StatusConflict, err.Error())
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+type RebalanceHandler struct {
+	svc *service.RebalanceService
+}
+
+func NewRebalanceHandler(svc *service.RebalanceService) *RebalanceHandler {
+	return &RebalanceHandler{svc: svc}
+}
+
+// Preview plans a rebalance and stores it as a job to apply.
+func (h *RebalanceHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	var req service.RebalanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	preview, err := h.svc.Preview(r.Context(), req, requestUser(r))
+	if err != nil {
+		respondRebalanceError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, APIResponse{Data: preview})
+}
+
+func (h *RebalanceHandler) List(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.svc.List(r.Context())
+	if err != nil {
+		respondRebalanceError(w, err)
+		return
+	}
+	RespondOK(w, jobs)
+}
+
+// Get returns a rebalance job with its progress.
+func (h *RebalanceHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	job, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		respondRebalanceError(w, err)
+		return
+	}
+	RespondOK(w, job)
+}
+
+// Moves lists the planned moves of a job; ?status= filters them.
+func (h *RebalanceHandler) Moves(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	moves, err := h.svc.Moves(r.Context(), id, r.URL.Query().Get("status"))
+	if err != nil {
+		respondRebalanceError(w, err)
+		return
+	}
+	RespondOK(w, moves)
+}
+
+// Apply carries out a previewed (or resumes a failed) job in the request, or
+// with ?async=true in the background, answering 202 with the job; progress
+// is then pushed as "rebalance_progress" events and can be polled at
+// GET /rebalance/{id}.
+func (h *RebalanceHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	if r.URL.Query().Get("async") != "true" {
+		result, err := h.svc.Apply(r.Context(), id)
+		if err != nil {
+			respondRebalanceError(w, err)
+			return
+		}
+		afterRebalance(result)
+		RespondOK(w, result)
+		return
+	}
+
+	job, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		respondRebalanceError(w, err)
+		return
+	}
+	if job.Status != domain.RebalanceStatusPreview && job.Status != domain.RebalanceStatusFailed {
+		respondRebalanceError(w, service.ErrRebalanceNotRunnable)
+		return
+	}
+	go func() {
+		result, err := h.svc.Apply(context.Background(), id)
+		if err != nil {
+			log.Error().Err(err).Str("rebalance_id", id.String()).Msg("rebalance failed")
+			return
+		}
+		afterRebalance(result)
+	}()
+	RespondJSON(w, http.StatusAccepted, APIResponse{Data: job})
+}
+
+// afterRebalance announces the moved tickets.
+func afterRebalance(result *service.RebalanceResult) {
+	for _, id := range result.Moved {
+		GlobalHub.Broadcast(WSEvent{Type: "ticket_update", TicketID: id.String(), Status: "routed"})
+	}
+}
+
+// BroadcastRebalanceProgress pushes a rebalance job's progress to all SSE clients.
+func BroadcastRebalanceProgress(job domain.RebalanceJob) {
+	GlobalHub.Broadcast(WSEvent{Type: "rebalance_progress", Data: job})
+}
+
+func respondRebalanceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidRebalance):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "not found")
+	case errors.Is(err, service.ErrRebalanceNotRunnable):
+		RespondError(w, http.StatusConflict, err.Error())
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+type ReplyHandler struct {
+	svc *service.ReplyService
+}
+
+func NewReplyHandler(svc *service.ReplyService) *ReplyHandler {
+	return &ReplyHandler{svc: svc}
+}
+
+// ListTemplates returns the reply template library (?lang= narrows it).
+func (h *ReplyHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.svc.ListTemplates(r.Context(), r.URL.Query().Get("lang"))
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondOK(w, templates)
+}
+
+func (h *ReplyHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var t domain.ReplyTemplate
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if err := h.svc.CreateTemplate(r.Context(), &t); err != nil {
+		respondReplyError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, APIResponse{Data: t})
+}
+
+func (h *ReplyHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var t domain.ReplyTemplate
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	t.ID = id
+
+	if err := h.svc.UpdateTemplate(r.Context(), &t); err != nil {
+		respondReplyError(w, err)
+		return
+	}
+	RespondOK(w, t)
+}
+
+// Draft suggests a reply to a ticket; the body is optional.
+func (h *ReplyHandler) Draft(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req service.DraftReplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	reply, err := h.svc.Draft(r.Context(), id, req)
+	if err != nil {
+		respondReplyError(w, err)
+		return
+	}
+	RespondOK(w, reply)
+}
+
+func respondReplyError(w http.ResponseWriter, err error) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, service.ErrInvalidReplyTemplate):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrSpamTicket):
+		RespondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "not found")
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		RespondError(w, http.StatusConflict, "a template with this name already exists")
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
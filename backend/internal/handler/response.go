@@ -2,7 +2,9 @@ package handler
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
+	"strings"
 )
 
 type APIResponse struct {
@@ -35,3 +37,15 @@ func RespondError(w http.ResponseWriter, status int, msg string) {
 func RespondOK(w http.ResponseWriter, data interface{}) {
 	RespondJSON(w, http.StatusOK, APIResponse{Data: data})
 }
+
+// requestUser names who made a request, for provenance and audit: the
+// X-User header set by the frontend or a proxy, else the client address.
+func requestUser(r *http.Request) string {
+	if user := strings.TrimSpace(r.Header.Get("X-User")); user != "" {
+		return user
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+type RoutingHandler struct {
+	svc *service.RoutingService
+}
+
+func NewRoutingHandler(svc *service.RoutingService) *RoutingHandler {
+	return &RoutingHandler{svc: svc}
+}
+
+// ReplayRequest selects the tickets to replay under a candidate policy.
+// Dates accept "2006-01-02" or RFC3339.
+type ReplayRequest struct {
+	Limit    int                   `json:"limit"`
+	DateFrom string                `json:"date_from"`
+	DateTo   string                `json:"date_to"`
+	Policy   service.RoutingPolicy `json:"policy"`
+}
+
+// Simulate shows how a ticket would be routed, without assigning it.
+func (h *RoutingHandler) Simulate(w http.ResponseWriter, r *http.Request) {
+	var req service.SimulationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	result, err := h.svc.Simulate(r.Context(), req)
+	if err != nil {
+		respondRoutingError(w, err)
+		return
+	}
+	RespondOK(w, result)
+}
+
+// Replay re-routes historical tickets under a policy and reports how the
+// distribution across managers would change.
+func (h *RoutingHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	var req ReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	params := service.ReplayParams{Limit: req.Limit, Policy: req.Policy}
+	var err error
+	if params.DateFrom, err = parseReportDate(req.DateFrom, time.UTC); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid date_from")
+		return
+	}
+	if params.DateTo, err = parseReportDate(req.DateTo, time.UTC); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid date_to")
+		return
+	}
+
+	result, err := h.svc.Replay(r.Context(), params)
+	if err != nil {
+		respondRoutingError(w, err)
+		return
+	}
+	RespondOK(w, result)
+}
+
+func respondRoutingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidSimulation):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "ticket not found")
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+type SkillHandler struct {
+	svc *service.SkillService
+}
+
+func NewSkillHandler(svc *service.SkillService) *SkillHandler {
+	return &SkillHandler{svc: svc}
+}
+
+func (h *SkillHandler) List(w http.ResponseWriter, r *http.Request) {
+	skills, err := h.svc.List(r.Context())
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondOK(w, skills)
+}
+
+func (h *SkillHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var skill domain.Skill
+	if err := json.NewDecoder(r.Body).Decode(&skill); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if err := h.svc.Create(r.Context(), &skill); err != nil {
+		respondSkillError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, APIResponse{Data: skill})
+}
+
+// Update renames a skill and replaces its aliases.
+func (h *SkillHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var skill domain.Skill
+	if err := json.NewDecoder(r.Body).Decode(&skill); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	skill.ID = id
+
+	updated, err := h.svc.Update(r.Context(), &skill)
+	if err != nil {
+		respondSkillError(w, err)
+		return
+	}
+	RespondOK(w, updated)
+}
+
+func (h *SkillHandler) ListRequirements(w http.ResponseWriter, r *http.Request) {
+	reqs, err := h.svc.ListRequirements(r.Context())
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondOK(w, reqs)
+}
+
+func (h *SkillHandler) CreateRequirement(w http.ResponseWriter, r *http.Request) {
+	var req domain.SkillRequirement
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	created, err := h.svc.CreateRequirement(r.Context(), &req)
+	if err != nil {
+		respondSkillError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, APIResponse{Data: created})
+}
+
+func (h *SkillHandler) UpdateRequirement(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req domain.SkillRequirement
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	req.ID = id
+
+	updated, err := h.svc.UpdateRequirement(r.Context(), &req)
+	if err != nil {
+		respondSkillError(w, err)
+		return
+	}
+	RespondOK(w, updated)
+}
+
+func respondSkillError(w http.ResponseWriter, err error) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, service.ErrInvalidSkill):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "not found")
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		RespondError(w, http.StatusConflict, "a skill with this code already exists")
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -2,17 +2,19 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/arslan/fire-challenge/internal/service"
 )
 
 type StarHandler struct {
-	svc *service.StarService
+	svc           *service.StarService
+	exportMaxRows int
 }
 
-func NewStarHandler(svc *service.StarService) *StarHandler {
-	return &StarHandler{svc: svc}
+func NewStarHandler(svc *service.StarService, exportMaxRows int) *StarHandler {
+	return &StarHandler{svc: svc, exportMaxRows: exportMaxRows}
 }
 
 type StarRequest struct {
@@ -38,6 +40,11 @@ func (h *StarHandler) Query(w http.ResponseWriter, r *http.Request) {
 	if req.SQL != "" {
 		result, err := h.svc.ExecuteReadOnlySQL(r.Context(), req.SQL)
 		if err != nil {
+			var rej *service.SQLRejection
+			if errors.As(err, &rej) {
+				RespondJSON(w, http.StatusBadRequest, APIResponse{Data: rej, Error: rej.Error()})
+				return
+			}
 			RespondError(w, http.StatusBadRequest, err.Error())
 			return
 		}
@@ -61,3 +68,32 @@ func (h *StarHandler) Query(w http.ResponseWriter, r *http.Request) {
 
 	RespondOK(w, result)
 }
+
+// Export streams the result of a Star SQL query as CSV, XLSX or JSON Lines.
+func (h *StarHandler) Export(w http.ResponseWriter, r *http.Request) {
+	var req StarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if req.SQL == "" {
+		RespondError(w, http.StatusBadRequest, "sql is required")
+		return
+	}
+
+	export, err := newExportResponse(w, r, "star", h.exportMaxRows)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	truncated, err := h.svc.Export(r.Context(), req.SQL, export.limit, export)
+	if err := export.finish(truncated, err); err != nil {
+		var rej *service.SQLRejection
+		if errors.As(err, &rej) {
+			RespondJSON(w, http.StatusBadRequest, APIResponse{Data: rej, Error: rej.Error()})
+			return
+		}
+		RespondError(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+type StarReportHandler struct {
+	svc           *service.StarReportService
+	loc           *time.Location
+	exportMaxRows int
+}
+
+// NewStarReportHandler returns a handler reading date-only report parameters
+// in loc, the reporting time zone of the dashboard.
+func NewStarReportHandler(svc *service.StarReportService, loc *time.Location, exportMaxRows int) *StarReportHandler {
+	return &StarReportHandler{svc: svc, loc: loc, exportMaxRows: exportMaxRows}
+}
+
+// RunReportRequest carries parameters for an ad-hoc report run.
+// Dates accept "2006-01-02" (in the reporting time zone) or RFC3339. Unset
+// parameters fall back to the report's default_params.
+type RunReportRequest struct {
+	DateFrom string  `json:"date_from"`
+	DateTo   string  `json:"date_to"`
+	Office   *string `json:"office"`
+	LastDays int     `json:"last_days"`
+}
+
+func (h *StarReportHandler) List(w http.ResponseWriter, r *http.Request) {
+	reports, err := h.svc.List(r.Context())
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondOK(w, reports)
+}
+
+func (h *StarReportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	report, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		RespondError(w, http.StatusNotFound, "not found")
+		return
+	}
+	RespondOK(w, report)
+}
+
+func (h *StarReportHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var report domain.StarReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if err := h.svc.Create(r.Context(), &report); err != nil {
+		respondReportError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, APIResponse{Data: report})
+}
+
+func (h *StarReportHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var report domain.StarReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	report.ID = id
+
+	if err := h.svc.Update(r.Context(), &report); err != nil {
+		respondReportError(w, err)
+		return
+	}
+	RespondOK(w, report)
+}
+
+func (h *StarReportHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), id); err != nil {
+		respondReportError(w, err)
+		return
+	}
+	RespondOK(w, map[string]string{"status": "deleted"})
+}
+
+func (h *StarReportHandler) Run(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req RunReportRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+	}
+
+	params, err := req.toParams(h.loc)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.svc.Run(r.Context(), id, params)
+	if err != nil {
+		respondReportError(w, err)
+		return
+	}
+	RespondOK(w, result)
+}
+
+// Export streams a saved report as CSV, XLSX or JSON Lines. Report parameters
+// are taken from the query string: date_from, date_to, office, last_days.
+func (h *StarReportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	q := r.URL.Query()
+	req := RunReportRequest{DateFrom: q.Get("date_from"), DateTo: q.Get("date_to")}
+	if office := q.Get("office"); office != "" {
+		req.Office = &office
+	}
+	if v := q.Get("last_days"); v != "" {
+		if req.LastDays, err = strconv.Atoi(v); err != nil {
+			RespondError(w, http.StatusBadRequest, "invalid last_days")
+			return
+		}
+	}
+	params, err := req.toParams(h.loc)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	export, err := newExportResponse(w, r, "report", h.exportMaxRows)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	truncated, err := h.svc.Export(r.Context(), id, params, export.limit, export)
+	if err := export.finish(truncated, err); err != nil {
+		respondReportError(w, err)
+	}
+}
+
+func (req RunReportRequest) toParams(loc *time.Location) (domain.StarReportParams, error) {
+	params := domain.StarReportParams{Office: req.Office, LastDays: req.LastDays}
+
+	var err error
+	if params.DateFrom, err = parseReportDate(req.DateFrom, loc); err != nil {
+		return params, fmt.Errorf("invalid date_from: %w", err)
+	}
+	if params.DateTo, err = parseReportDate(req.DateTo, loc); err != nil {
+		return params, fmt.Errorf("invalid date_to: %w", err)
+	}
+	return params, nil
+}
+
+func parseReportDate(s string, loc *time.Location) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := parseFilterTime(s, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func respondReportError(w http.ResponseWriter, err error) {
+	var rej *service.SQLRejection
+	switch {
+	case errors.As(err, &rej):
+		RespondJSON(w, http.StatusBadRequest, APIResponse{Data: rej, Error: rej.Error()})
+	case errors.Is(err, service.ErrInvalidReport):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "not found")
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/service"
+)
+
+type TeamHandler struct {
+	svc *service.TeamService
+}
+
+func NewTeamHandler(svc *service.TeamService) *TeamHandler {
+	return &TeamHandler{svc: svc}
+}
+
+// List returns the teams; ?office_id= and ?supervisor_id= filter them.
+func (h *TeamHandler) List(w http.ResponseWriter, r *http.Request) {
+	officeID, err := optionalUUIDParam(r, "office_id")
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid office_id")
+		return
+	}
+	supervisorID, err := optionalUUIDParam(r, "supervisor_id")
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid supervisor_id")
+		return
+	}
+
+	teams, err := h.svc.List(r.Context(), officeID, supervisorID)
+	if err != nil {
+		respondTeamError(w, err)
+		return
+	}
+	RespondOK(w, teams)
+}
+
+// Get returns a team with its members' load and SLA state.
+func (h *TeamHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	overview, err := h.svc.Overview(r.Context(), id)
+	if err != nil {
+		respondTeamError(w, err)
+		return
+	}
+	RespondOK(w, overview)
+}
+
+// Tickets lists the open tickets of a team's members and supervisor, nearest
+// SLA deadline first; ?sla=ok|at_risk|overdue and ?limit= narrow the list.
+func (h *TeamHandler) Tickets(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	tickets, err := h.svc.Tickets(r.Context(), id, r.URL.Query().Get("sla"), limit)
+	if err != nil {
+		respondTeamError(w, err)
+		return
+	}
+	RespondOK(w, tickets)
+}
+
+func (h *TeamHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var team domain.Team
+	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if err := h.svc.Create(r.Context(), &team, requestUser(r)); err != nil {
+		respondTeamError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, APIResponse{Data: team})
+}
+
+func (h *TeamHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var team domain.Team
+	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	team.ID = id
+
+	updated, err := h.svc.Update(r.Context(), &team, requestUser(r))
+	if err != nil {
+		respondTeamError(w, err)
+		return
+	}
+	RespondOK(w, updated)
+}
+
+// SetMembers replaces a team's members with {"manager_ids": [...]}.
+func (h *TeamHandler) SetMembers(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req struct {
+		ManagerIDs []uuid.UUID `json:"manager_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	overview, err := h.svc.SetMembers(r.Context(), id, req.ManagerIDs, requestUser(r))
+	if err != nil {
+		respondTeamError(w, err)
+		return
+	}
+	RespondOK(w, overview)
+}
+
+func (h *TeamHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	h.setActive(w, r, false)
+}
+
+func (h *TeamHandler) Activate(w http.ResponseWriter, r *http.Request) {
+	h.setActive(w, r, true)
+}
+
+// Changes lists the audited changes to a team, newest first.
+func (h *TeamHandler) Changes(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	changes, err := h.svc.Changes(r.Context(), id)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondOK(w, changes)
+}
+
+func (h *TeamHandler) setActive(w http.ResponseWriter, r *http.Request, active bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	team, err := h.svc.SetActive(r.Context(), id, active, requestUser(r))
+	if err != nil {
+		respondTeamError(w, err)
+		return
+	}
+	RespondOK(w, team)
+}
+
+// Escalate moves a ticket up its manager's escalation path, or to the
+// manager named in the body: {"reason": "...", "to_manager_id": "<id>"}.
+func (h *TeamHandler) Escalate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req service.EscalationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	result, err := h.svc.Escalate(r.Context(), id, req, requestUser(r))
+	if err != nil {
+		respondTeamError(w, err)
+		return
+	}
+	GlobalHub.Broadcast(WSEvent{
+		Type:     "ticket_update",
+		TicketID: id.String(),
+		Status:   result.Status,
+		Manager:  result.Manager.FullName,
+		Data:     result.Escalation,
+	})
+	RespondOK(w, result)
+}
+
+// Escalations lists a ticket's escalations, oldest first.
+func (h *TeamHandler) Escalations(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	escalations, err := h.svc.Escalations(r.Context(), id)
+	if err != nil {
+		respondTeamError(w, err)
+		return
+	}
+	RespondOK(w, escalations)
+}
+
+// optionalUUIDParam parses an optional id query parameter; nil when absent.
+func optionalUUIDParam(r *http.Request, name string) (*uuid.UUID, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func respondTeamError(w http.ResponseWriter, err error) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, service.ErrInvalidTeam), errors.Is(err, service.ErrInvalidEscalation):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		RespondError(w, http.StatusNotFound, "not found")
+	case errors.Is(err, service.ErrNotEscalatable), errors.Is(err, service.ErrNoEscalationTarget):
+		RespondError(w, http.StatusConflict, err.Error())
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		RespondError(w, http.StatusConflict, "the office already has a team with this name")
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
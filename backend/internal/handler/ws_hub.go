@@ -10,10 +10,11 @@ import (
 
 // WSEvent is the message broadcast to all WebSocket clients.
 type WSEvent struct {
-	Type     string `json:"type"` // "ticket_update"
-	TicketID string `json:"ticket_id"`
-	Status   string `json:"status"`
-	Manager  string `json:"manager,omitempty"`
+	Type     string      `json:"type"` // "ticket_update", "anomaly", "import_progress"
+	TicketID string      `json:"ticket_id"`
+	Status   string      `json:"status"`
+	Manager  string      `json:"manager,omitempty"`
+	Data     interface{} `json:"data,omitempty"` // event payload, e.g. the alert for "anomaly"
 }
 
 // Hub manages all active WebSocket connections.
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+type AlertRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewAlertRepo(pool *pgxpool.Pool) *AlertRepo {
+	return &AlertRepo{pool: pool}
+}
+
+const alertColumns = `id, metric, dimension, dimension_value, bucket_start, observed, expected, z_score,
+	severity, message, acknowledged_at, acknowledged_by, created_at`
+
+func scanAlert(row pgx.Row) (*domain.Alert, error) {
+	var a domain.Alert
+	err := row.Scan(&a.ID, &a.Metric, &a.Dimension, &a.DimensionValue, &a.BucketStart, &a.Observed, &a.Expected, &a.ZScore,
+		&a.Severity, &a.Message, &a.AcknowledgedAt, &a.AcknowledgedBy, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Insert stores a new alert. It returns false without error if an alert for
+// the same metric, dimension value and bucket already exists.
+func (r *AlertRepo) Insert(ctx context.Context, a *domain.Alert) (bool, error) {
+	err := r.pool.QueryRow(ctx,
+		`INSERT INTO alerts (id, metric, dimension, dimension_value, bucket_start, observed, expected, z_score, severity, message)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 ON CONFLICT (metric, dimension, dimension_value, bucket_start) DO NOTHING
+		 RETURNING created_at`,
+		a.ID, a.Metric, a.Dimension, a.DimensionValue, a.BucketStart, a.Observed, a.Expected, a.ZScore, a.Severity, a.Message,
+	).Scan(&a.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// List returns the newest alerts; acknowledged selects open (false), acknowledged (true) or all (nil).
+func (r *AlertRepo) List(ctx context.Context, acknowledged *bool, limit int) ([]domain.Alert, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+alertColumns+` FROM alerts
+		 WHERE $1::boolean IS NULL OR (acknowledged_at IS NOT NULL) = $1
+		 ORDER BY created_at DESC
+		 LIMIT $2`, acknowledged, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []domain.Alert{}
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, *a)
+	}
+	return alerts, rows.Err()
+}
+
+// Acknowledge marks an alert as handled. Acknowledging twice keeps the first timestamp.
+func (r *AlertRepo) Acknowledge(ctx context.Context, id uuid.UUID, by *string) (*domain.Alert, error) {
+	return scanAlert(r.pool.QueryRow(ctx,
+		`UPDATE alerts SET
+		   acknowledged_at = COALESCE(acknowledged_at, now()),
+		   acknowledged_by = COALESCE(acknowledged_by, $2)
+		 WHERE id = $1
+		 RETURNING `+alertColumns, id, by))
+}
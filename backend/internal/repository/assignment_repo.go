@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -18,20 +20,26 @@ func NewAssignmentRepo(pool *pgxpool.Pool) *AssignmentRepo {
 	return &AssignmentRepo{pool: pool}
 }
 
+// ErrAlreadyAssigned is returned by Insert when the ticket got a current
+// assignment from a concurrent transaction.
+var ErrAlreadyAssigned = errors.New("ticket is already assigned")
+
+// Insert assigns a ticket that has no current assignment. It never takes a
+// ticket over: moving an assigned ticket goes through Move.
 func (r *AssignmentRepo) Insert(ctx context.Context, tx pgx.Tx, a *domain.TicketAssignment) error {
-	_, err := tx.Exec(ctx,
+	ct, err := tx.Exec(ctx,
 		`INSERT INTO ticket_assignment (id, ticket_id, manager_id, business_unit_id, office_id, routing_bucket, routing_reason, is_current)
 		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		 ON CONFLICT (ticket_id) WHERE is_current = true DO UPDATE SET
-		   manager_id = EXCLUDED.manager_id,
-		   business_unit_id = EXCLUDED.business_unit_id,
-		   office_id = EXCLUDED.office_id,
-		   routing_bucket = EXCLUDED.routing_bucket,
-		   routing_reason = EXCLUDED.routing_reason,
-		   assigned_at = now()`,
+		 ON CONFLICT (ticket_id) WHERE is_current = true DO NOTHING`,
 		a.ID, a.TicketID, a.ManagerID, a.BusinessUnitID, a.OfficeID, a.RoutingBucket, a.RoutingReason, a.IsCurrent,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrAlreadyAssigned
+	}
+	return nil
 }
 
 func (r *AssignmentRepo) GetByTicketID(ctx context.Context, ticketID uuid.UUID) (*domain.TicketAssignment, error) {
@@ -46,3 +54,94 @@ func (r *AssignmentRepo) GetByTicketID(ctx context.Context, ticketID uuid.UUID)
 	}
 	return &a, nil
 }
+
+// ReleaseOpen removes the assignments of a manager's open (not resolved or
+// closed) tickets and returns those tickets, oldest assignment first. A ticket
+// has one assignment row (idx_assignment_ticket_unique); earlier assignments
+// are kept in its audit log.
+func (r *AssignmentRepo) ReleaseOpen(ctx context.Context, tx pgx.Tx, managerID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := tx.Query(ctx,
+		`WITH released AS (
+		   DELETE FROM ticket_assignment a
+		   USING tickets t
+		   WHERE t.id = a.ticket_id AND a.manager_id = $1 AND a.is_current = true
+		     AND t.status NOT IN ('resolved', 'closed')
+		   RETURNING a.ticket_id, a.assigned_at
+		 )
+		 SELECT ticket_id FROM released ORDER BY assigned_at`, managerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// LockCurrent locks a ticket's assignment within tx and returns its manager,
+// or nil if the ticket is unassigned.
+func (r *AssignmentRepo) LockCurrent(ctx context.Context, tx pgx.Tx, ticketID uuid.UUID) (*uuid.UUID, error) {
+	var managerID uuid.UUID
+	err := tx.QueryRow(ctx,
+		`SELECT manager_id FROM ticket_assignment WHERE ticket_id = $1 AND is_current = true FOR UPDATE`, ticketID,
+	).Scan(&managerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &managerID, nil
+}
+
+// Move reassigns an open (not resolved or closed) ticket from one manager to
+// another, within tx. It reports false, changing nothing, if the ticket is no
+// longer open or no longer assigned to from.
+func (r *AssignmentRepo) Move(ctx context.Context, tx pgx.Tx, ticketID, from uuid.UUID, to *domain.Manager, bucket, reason string) (bool, error) {
+	ct, err := tx.Exec(ctx,
+		`UPDATE ticket_assignment a SET
+		   manager_id = $3, business_unit_id = $4, office_id = $4, routing_bucket = $5, routing_reason = $6, assigned_at = now()
+		 FROM tickets t
+		 WHERE t.id = a.ticket_id AND a.ticket_id = $1 AND a.manager_id = $2 AND a.is_current = true
+		   AND t.status NOT IN ('resolved', 'closed')`,
+		ticketID, from, to.ID, to.BusinessUnitID, bucket, reason)
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() > 0, nil
+}
+
+// ClientManagersSince returns, most recent first, the managers assigned to
+// other tickets of the ticket's client since the given time, with the time
+// of their latest assignment.
+func (r *AssignmentRepo) ClientManagersSince(ctx context.Context, ticketID uuid.UUID, since time.Time) ([]domain.ManagerAssignment, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT a.manager_id, MAX(a.assigned_at)
+		 FROM tickets self
+		 JOIN tickets t ON t.client_id = self.client_id AND t.id <> self.id
+		 JOIN ticket_assignment a ON a.ticket_id = t.id
+		 WHERE self.id = $1 AND a.assigned_at >= $2
+		 GROUP BY a.manager_id
+		 ORDER BY MAX(a.assigned_at) DESC`, ticketID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []domain.ManagerAssignment{}
+	for rows.Next() {
+		var m domain.ManagerAssignment
+		if err := rows.Scan(&m.ManagerID, &m.AssignedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+	return result, rows.Err()
+}
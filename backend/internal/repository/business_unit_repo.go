@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -18,29 +19,74 @@ func NewBusinessUnitRepo(pool *pgxpool.Pool) *BusinessUnitRepo {
 	return &BusinessUnitRepo{pool: pool}
 }
 
-func (r *BusinessUnitRepo) Insert(ctx context.Context, bu *domain.BusinessUnit) error {
-	_, err := r.pool.Exec(ctx,
-		`INSERT INTO business_units (id, name, city, address, lat, lon)
-		 VALUES ($1, $2, $3, $4, $5, $6)
-		 ON CONFLICT (name) DO NOTHING`,
-		bu.ID, bu.Name, bu.City, bu.Address, bu.Lat, bu.Lon,
-	)
-	return err
+const businessUnitColumns = `id, name, city, address, lat, lon, is_active, created_at`
+
+func scanBusinessUnit(row pgx.Row) (*domain.BusinessUnit, error) {
+	var bu domain.BusinessUnit
+	if err := row.Scan(&bu.ID, &bu.Name, &bu.City, &bu.Address, &bu.Lat, &bu.Lon, &bu.IsActive, &bu.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &bu, nil
 }
 
-func (r *BusinessUnitRepo) BulkInsert(ctx context.Context, units []domain.BusinessUnit) (int, error) {
+func (r *BusinessUnitRepo) Insert(ctx context.Context, tx pgx.Tx, bu *domain.BusinessUnit) error {
+	return tx.QueryRow(ctx,
+		`INSERT INTO business_units (id, name, city, address, lat, lon, is_active)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING created_at`,
+		bu.ID, bu.Name, bu.City, bu.Address, bu.Lat, bu.Lon, bu.IsActive,
+	).Scan(&bu.CreatedAt)
+}
+
+func (r *BusinessUnitRepo) Update(ctx context.Context, tx pgx.Tx, bu *domain.BusinessUnit) (*domain.BusinessUnit, error) {
+	return scanBusinessUnit(tx.QueryRow(ctx,
+		`UPDATE business_units SET name = $2, city = $3, address = $4, lat = $5, lon = $6
+		 WHERE id = $1
+		 RETURNING `+businessUnitColumns,
+		bu.ID, bu.Name, bu.City, bu.Address, bu.Lat, bu.Lon))
+}
+
+// GetForUpdate returns an office, locking it for the rest of tx.
+func (r *BusinessUnitRepo) GetForUpdate(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*domain.BusinessUnit, error) {
+	return scanBusinessUnit(tx.QueryRow(ctx, `SELECT `+businessUnitColumns+` FROM business_units WHERE id = $1 FOR UPDATE`, id))
+}
+
+func (r *BusinessUnitRepo) SetActive(ctx context.Context, tx pgx.Tx, id uuid.UUID, active bool) (*domain.BusinessUnit, error) {
+	return scanBusinessUnit(tx.QueryRow(ctx,
+		`UPDATE business_units SET is_active = $2 WHERE id = $1 RETURNING `+businessUnitColumns, id, active))
+}
+
+// CountActiveManagers returns how many active managers work in the office.
+func (r *BusinessUnitRepo) CountActiveManagers(ctx context.Context, tx pgx.Tx, id uuid.UUID) (int, error) {
+	var n int
+	err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM managers WHERE business_unit_id = $1 AND is_active = true`, id).Scan(&n)
+	return n, err
+}
+
+// BulkInsert upserts offices by name within tx, stamping them with importID
+// and logging each insert or update to import_changes.
+func (r *BusinessUnitRepo) BulkInsert(ctx context.Context, tx pgx.Tx, importID uuid.UUID, units []domain.BusinessUnit) (int, error) {
 	batch := &pgx.Batch{}
 	for _, bu := range units {
 		batch.Queue(
-			`INSERT INTO business_units (id, name, city, address, lat, lon)
-			 VALUES ($1, $2, $3, $4, $5, $6)
-			 ON CONFLICT (name) DO UPDATE SET
-			   city = EXCLUDED.city,
-			   address = EXCLUDED.address`,
-			bu.ID, bu.Name, bu.City, bu.Address, bu.Lat, bu.Lon,
+			`WITH before AS (
+			   SELECT * FROM business_units WHERE name = $2
+			 ), merged AS (
+			   INSERT INTO business_units (id, name, city, address, lat, lon, import_id)
+			   VALUES ($1, $2, $3, $4, $5, $6, $7)
+			   ON CONFLICT (name) DO UPDATE SET
+			     city = EXCLUDED.city,
+			     address = EXCLUDED.address,
+			     import_id = EXCLUDED.import_id
+			   RETURNING id
+			 )
+			 INSERT INTO import_changes (import_id, entity, row_id, action, before)
+			 SELECT $7, 'business_units', m.id, CASE WHEN b.id IS NULL THEN 'insert' ELSE 'update' END, to_jsonb(b)
+			 FROM merged m LEFT JOIN before b ON b.id = m.id`,
+			bu.ID, bu.Name, bu.City, bu.Address, bu.Lat, bu.Lon, importID,
 		)
 	}
-	br := r.pool.SendBatch(ctx, batch)
+	br := tx.SendBatch(ctx, batch)
 	defer br.Close()
 
 	inserted := 0
@@ -54,21 +100,42 @@ func (r *BusinessUnitRepo) BulkInsert(ctx context.Context, units []domain.Busine
 	return inserted, nil
 }
 
-func (r *BusinessUnitRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.BusinessUnit, error) {
-	row := r.pool.QueryRow(ctx,
-		`SELECT id, name, city, address, lat, lon, created_at FROM business_units WHERE id = $1`, id)
-
-	var bu domain.BusinessUnit
-	err := row.Scan(&bu.ID, &bu.Name, &bu.City, &bu.Address, &bu.Lat, &bu.Lon, &bu.CreatedAt)
+// DeleteImported deletes offices an import created, with their round-robin
+// pointers. It fails with a foreign key violation if managers or tickets
+// were assigned to any of them since.
+func (r *BusinessUnitRepo) DeleteImported(ctx context.Context, tx pgx.Tx, ids []uuid.UUID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM rr_pointer WHERE business_unit_id = ANY($1)`, ids); err != nil {
+		return 0, fmt.Errorf("delete round-robin pointers: %w", err)
+	}
+	ct, err := tx.Exec(ctx, `DELETE FROM business_units WHERE id = ANY($1)`, ids)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	return &bu, nil
+	return int(ct.RowsAffected()), nil
+}
+
+func (r *BusinessUnitRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.BusinessUnit, error) {
+	return scanBusinessUnit(r.pool.QueryRow(ctx, `SELECT `+businessUnitColumns+` FROM business_units WHERE id = $1`, id))
 }
 
 func (r *BusinessUnitRepo) List(ctx context.Context) ([]domain.BusinessUnit, error) {
-	rows, err := r.pool.Query(ctx,
-		`SELECT id, name, city, address, lat, lon, created_at FROM business_units ORDER BY name`)
+	return r.list(ctx, `SELECT `+businessUnitColumns+` FROM business_units ORDER BY name`)
+}
+
+func (r *BusinessUnitRepo) GetAll(ctx context.Context) ([]domain.BusinessUnit, error) {
+	return r.List(ctx)
+}
+
+// ListActive returns the offices routing may send tickets to.
+func (r *BusinessUnitRepo) ListActive(ctx context.Context) ([]domain.BusinessUnit, error) {
+	return r.list(ctx, `SELECT `+businessUnitColumns+` FROM business_units WHERE is_active = true ORDER BY name`)
+}
+
+func (r *BusinessUnitRepo) list(ctx context.Context, query string) ([]domain.BusinessUnit, error) {
+	rows, err := r.pool.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -76,15 +143,11 @@ func (r *BusinessUnitRepo) List(ctx context.Context) ([]domain.BusinessUnit, err
 
 	units := []domain.BusinessUnit{}
 	for rows.Next() {
-		var bu domain.BusinessUnit
-		if err := rows.Scan(&bu.ID, &bu.Name, &bu.City, &bu.Address, &bu.Lat, &bu.Lon, &bu.CreatedAt); err != nil {
+		bu, err := scanBusinessUnit(rows)
+		if err != nil {
 			return nil, err
 		}
-		units = append(units, bu)
+		units = append(units, *bu)
 	}
-	return units, nil
-}
-
-func (r *BusinessUnitRepo) GetAll(ctx context.Context) ([]domain.BusinessUnit, error) {
-	return r.List(ctx)
+	return units, rows.Err()
 }
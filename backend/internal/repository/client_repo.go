@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+type ClientRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewClientRepo(pool *pgxpool.Pool) *ClientRepo {
+	return &ClientRepo{pool: pool}
+}
+
+const clientColumns = `id, guid, full_name, segment, gender, birth_date, raw_address, created_at, updated_at`
+
+func scanClient(row pgx.Row) (*domain.Client, error) {
+	var c domain.Client
+	err := row.Scan(&c.ID, &c.GUID, &c.FullName, &c.Segment, &c.Gender, &c.BirthDate, &c.RawAddress,
+		&c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// BulkUpsert inserts or updates clients by GUID within tx and returns their
+// ids keyed by GUID. Empty profile fields never overwrite known values. Each
+// insert or update is logged to import_changes for importID.
+func (r *ClientRepo) BulkUpsert(ctx context.Context, tx pgx.Tx, importID uuid.UUID, clients []domain.Client) (map[string]uuid.UUID, error) {
+	batch := &pgx.Batch{}
+	for _, c := range clients {
+		batch.Queue(
+			`WITH before AS (
+			   SELECT * FROM clients WHERE guid = $2
+			 ), merged AS (
+			   INSERT INTO clients (id, guid, full_name, segment, gender, birth_date, raw_address)
+			   VALUES ($1, $2, $3, $4, $5, $6, $7)
+			   ON CONFLICT (guid) DO UPDATE SET
+			     full_name = COALESCE(EXCLUDED.full_name, clients.full_name),
+			     segment = COALESCE(EXCLUDED.segment, clients.segment),
+			     gender = COALESCE(EXCLUDED.gender, clients.gender),
+			     birth_date = COALESCE(EXCLUDED.birth_date, clients.birth_date),
+			     raw_address = COALESCE(EXCLUDED.raw_address, clients.raw_address),
+			     updated_at = now()
+			   RETURNING id
+			 ), changes AS (
+			   INSERT INTO import_changes (import_id, entity, row_id, action, before)
+			   SELECT $8, 'clients', m.id, CASE WHEN b.id IS NULL THEN 'insert' ELSE 'update' END, to_jsonb(b)
+			   FROM merged m LEFT JOIN before b ON b.id = m.id
+			 )
+			 SELECT id FROM merged`,
+			c.ID, c.GUID, c.FullName, c.Segment, c.Gender, c.BirthDate, c.RawAddress, importID,
+		)
+	}
+	br := tx.SendBatch(ctx, batch)
+	defer br.Close()
+
+	ids := make(map[string]uuid.UUID, len(clients))
+	for _, c := range clients {
+		var id uuid.UUID
+		if err := br.QueryRow().Scan(&id); err != nil {
+			return ids, err
+		}
+		ids[c.GUID] = id
+	}
+	return ids, nil
+}
+
+// DeleteUnreferenced deletes the given clients that no ticket refers to and
+// returns how many it deleted.
+func (r *ClientRepo) DeleteUnreferenced(ctx context.Context, tx pgx.Tx, ids []uuid.UUID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	ct, err := tx.Exec(ctx,
+		`DELETE FROM clients c
+		 WHERE c.id = ANY($1) AND NOT EXISTS (SELECT 1 FROM tickets t WHERE t.client_id = c.id)`, ids)
+	if err != nil {
+		return 0, err
+	}
+	return int(ct.RowsAffected()), nil
+}
+
+// GetByKey finds a client by id or by GUID.
+func (r *ClientRepo) GetByKey(ctx context.Context, key string) (*domain.Client, error) {
+	var id *uuid.UUID
+	if parsed, err := uuid.Parse(key); err == nil {
+		id = &parsed
+	}
+	return scanClient(r.pool.QueryRow(ctx,
+		`SELECT `+clientColumns+` FROM clients
+		 WHERE id = $1 OR guid = $2
+		 ORDER BY id = $1 DESC
+		 LIMIT 1`, id, key))
+}
+
+func (r *ClientRepo) SegmentHistory(ctx context.Context, id uuid.UUID) ([]domain.ClientSegmentChange, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT from_segment, to_segment, changed_at
+		 FROM client_segment_history
+		 WHERE client_id = $1
+		 ORDER BY changed_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []domain.ClientSegmentChange{}
+	for rows.Next() {
+		var h domain.ClientSegmentChange
+		if err := rows.Scan(&h.FromSegment, &h.ToSegment, &h.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+// SentimentTrend counts the client's enriched tickets per month and sentiment.
+func (r *ClientRepo) SentimentTrend(ctx context.Context, id uuid.UUID) ([]domain.ClientSentimentPoint, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT to_char(date_trunc('month', t.created_at), 'YYYY-MM') AS month,
+		        COUNT(*) FILTER (WHERE ai.sentiment ILIKE 'позитив%' OR ai.sentiment ILIKE 'positive%'),
+		        COUNT(*) FILTER (WHERE ai.sentiment ILIKE 'нейтрал%' OR ai.sentiment ILIKE 'neutral%'),
+		        COUNT(*) FILTER (WHERE ai.sentiment ILIKE 'негатив%' OR ai.sentiment ILIKE 'negative%')
+		 FROM tickets t
+		 JOIN ticket_ai ai ON ai.ticket_id = t.id
+		 WHERE t.client_id = $1
+		 GROUP BY month
+		 ORDER BY month`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trend := []domain.ClientSentimentPoint{}
+	for rows.Next() {
+		var p domain.ClientSentimentPoint
+		if err := rows.Scan(&p.Month, &p.Positive, &p.Neutral, &p.Negative); err != nil {
+			return nil, err
+		}
+		trend = append(trend, p)
+	}
+	return trend, rows.Err()
+}
+
+// Managers lists the managers currently assigned to the client's tickets, most recent first.
+func (r *ClientRepo) Managers(ctx context.Context, id uuid.UUID) ([]domain.ClientManager, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT m.id, m.full_name, COALESCE(bu.name, ''), COUNT(*), MAX(a.assigned_at)
+		 FROM tickets t
+		 JOIN ticket_assignment a ON a.ticket_id = t.id AND a.is_current = true
+		 JOIN managers m ON m.id = a.manager_id
+		 LEFT JOIN business_units bu ON bu.id = m.business_unit_id
+		 WHERE t.client_id = $1
+		 GROUP BY m.id, m.full_name, bu.name
+		 ORDER BY MAX(a.assigned_at) DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	managers := []domain.ClientManager{}
+	for rows.Next() {
+		var m domain.ClientManager
+		if err := rows.Scan(&m.ManagerID, &m.FullName, &m.Office, &m.Tickets, &m.LastAssignedAt); err != nil {
+			return nil, err
+		}
+		managers = append(managers, m)
+	}
+	return managers, rows.Err()
+}
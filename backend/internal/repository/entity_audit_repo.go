@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+type EntityAuditRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewEntityAuditRepo(pool *pgxpool.Pool) *EntityAuditRepo {
+	return &EntityAuditRepo{pool: pool}
+}
+
+// Insert records a change within the transaction that makes it.
+func (r *EntityAuditRepo) Insert(ctx context.Context, tx pgx.Tx, e *domain.EntityAudit) error {
+	return tx.QueryRow(ctx,
+		`INSERT INTO entity_audit (entity, entity_id, action, actor, before, after, details)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING id, created_at`,
+		e.Entity, e.EntityID, e.Action, e.Actor, e.Before, e.After, e.Details,
+	).Scan(&e.ID, &e.CreatedAt)
+}
+
+// List returns the changes to one manager or office, newest first.
+func (r *EntityAuditRepo) List(ctx context.Context, entity string, id uuid.UUID) ([]domain.EntityAudit, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT id, entity, entity_id, action, actor, before, after, details, created_at
+		 FROM entity_audit
+		 WHERE entity = $1 AND entity_id = $2
+		 ORDER BY created_at DESC, id DESC`, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []domain.EntityAudit{}
+	for rows.Next() {
+		var e domain.EntityAudit
+		if err := rows.Scan(&e.ID, &e.Entity, &e.EntityID, &e.Action, &e.Actor, &e.Before, &e.After, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, e)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+type ImportProfileRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewImportProfileRepo(pool *pgxpool.Pool) *ImportProfileRepo {
+	return &ImportProfileRepo{pool: pool}
+}
+
+const importProfileColumns = `id, name, type, columns, defaults, created_at, updated_at`
+
+func scanImportProfile(row pgx.Row) (*domain.ImportProfile, error) {
+	var p domain.ImportProfile
+	err := row.Scan(&p.ID, &p.Name, &p.Type, &p.Columns, &p.Defaults, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if p.Columns == nil {
+		p.Columns = []domain.ColumnMapping{}
+	}
+	if p.Defaults == nil {
+		p.Defaults = map[string]string{}
+	}
+	return &p, nil
+}
+
+func (r *ImportProfileRepo) Insert(ctx context.Context, p *domain.ImportProfile) error {
+	return r.pool.QueryRow(ctx,
+		`INSERT INTO import_profiles (id, name, type, columns, defaults)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING created_at, updated_at`,
+		p.ID, p.Name, p.Type, p.Columns, p.Defaults,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+}
+
+func (r *ImportProfileRepo) Update(ctx context.Context, p *domain.ImportProfile) error {
+	return r.pool.QueryRow(ctx,
+		`UPDATE import_profiles SET name = $2, type = $3, columns = $4, defaults = $5, updated_at = now()
+		 WHERE id = $1
+		 RETURNING created_at, updated_at`,
+		p.ID, p.Name, p.Type, p.Columns, p.Defaults,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+}
+
+func (r *ImportProfileRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx, `DELETE FROM import_profiles WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *ImportProfileRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.ImportProfile, error) {
+	return scanImportProfile(r.pool.QueryRow(ctx, `SELECT `+importProfileColumns+` FROM import_profiles WHERE id = $1`, id))
+}
+
+// GetByKey finds a profile by id or by name.
+func (r *ImportProfileRepo) GetByKey(ctx context.Context, key string) (*domain.ImportProfile, error) {
+	var id *uuid.UUID
+	if parsed, err := uuid.Parse(key); err == nil {
+		id = &parsed
+	}
+	return scanImportProfile(r.pool.QueryRow(ctx,
+		`SELECT `+importProfileColumns+` FROM import_profiles
+		 WHERE id = $1 OR name = $2
+		 ORDER BY id = $1 DESC
+		 LIMIT 1`, id, key))
+}
+
+func (r *ImportProfileRepo) List(ctx context.Context) ([]domain.ImportProfile, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+importProfileColumns+` FROM import_profiles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []domain.ImportProfile{}
+	for rows.Next() {
+		p, err := scanImportProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *p)
+	}
+	return profiles, rows.Err()
+}
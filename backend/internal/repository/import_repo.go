@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+type ImportRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewImportRepo(pool *pgxpool.Pool) *ImportRepo {
+	return &ImportRepo{pool: pool}
+}
+
+const importColumns = `id, type, status, format, sheet, profile_id, filename, file_hash, initiator, spool_path, size_bytes, bytes_read, rows_read, imported, skipped, linked,
+	checkpoint_row, error_count, errors, error, created_at, updated_at, finished_at, rolled_back_at`
+
+func scanImport(row pgx.Row) (*domain.Import, error) {
+	var j domain.Import
+	err := row.Scan(&j.ID, &j.Type, &j.Status, &j.Format, &j.Sheet, &j.ProfileID, &j.Filename, &j.FileHash, &j.Initiator, &j.SpoolPath, &j.SizeBytes, &j.BytesRead, &j.RowsRead, &j.Imported, &j.Skipped, &j.Linked,
+		&j.CheckpointRow, &j.ErrorCount, &j.Errors, &j.Error, &j.CreatedAt, &j.UpdatedAt, &j.FinishedAt, &j.RolledBackAt)
+	if err != nil {
+		return nil, err
+	}
+	if j.Errors == nil {
+		j.Errors = []string{}
+	}
+	return &j, nil
+}
+
+func (r *ImportRepo) Create(ctx context.Context, j *domain.Import) error {
+	return r.pool.QueryRow(ctx,
+		`INSERT INTO imports (id, type, status, format, sheet, profile_id, filename, file_hash, initiator, spool_path, size_bytes)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING created_at, updated_at`,
+		j.ID, j.Type, j.Status, j.Format, j.Sheet, j.ProfileID, j.Filename, j.FileHash, j.Initiator, j.SpoolPath, j.SizeBytes,
+	).Scan(&j.CreatedAt, &j.UpdatedAt)
+}
+
+func (r *ImportRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Import, error) {
+	return scanImport(r.pool.QueryRow(ctx, `SELECT `+importColumns+` FROM imports WHERE id = $1`, id))
+}
+
+// List returns imports, newest first, and the total matching the filter.
+func (r *ImportRepo) List(ctx context.Context, f domain.ImportListFilter) ([]domain.Import, int, error) {
+	var conditions []string
+	var args []interface{}
+	argIdx := 1
+
+	if f.Type != "" {
+		conditions = append(conditions, fmt.Sprintf("type = $%d", argIdx))
+		args = append(args, f.Type)
+		argIdx++
+	}
+	if f.Status != "" {
+		conditions = append(conditions, fmt.Sprintf("status = $%d", argIdx))
+		args = append(args, f.Status)
+		argIdx++
+	}
+	if f.FileHash != "" {
+		conditions = append(conditions, fmt.Sprintf("file_hash = $%d", argIdx))
+		args = append(args, strings.ToLower(f.FileHash))
+		argIdx++
+	}
+	if f.Initiator != "" {
+		conditions = append(conditions, fmt.Sprintf("initiator = $%d", argIdx))
+		args = append(args, f.Initiator)
+		argIdx++
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.pool.QueryRow(ctx, "SELECT COUNT(*) FROM imports "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if f.PerPage <= 0 {
+		f.PerPage = 20
+	}
+	if f.Page <= 0 {
+		f.Page = 1
+	}
+	query := fmt.Sprintf(`SELECT %s FROM imports %s ORDER BY created_at DESC LIMIT $%d OFFSET $%d`,
+		importColumns, where, argIdx, argIdx+1)
+	args = append(args, f.PerPage, (f.Page-1)*f.PerPage)
+
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	imports := []domain.Import{}
+	for rows.Next() {
+		j, err := scanImport(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		imports = append(imports, *j)
+	}
+	return imports, total, rows.Err()
+}
+
+// Claim marks a pending or failed import as running and returns it. It
+// returns pgx.ErrNoRows if the import does not exist or is running or done.
+func (r *ImportRepo) Claim(ctx context.Context, id uuid.UUID) (*domain.Import, error) {
+	return scanImport(r.pool.QueryRow(ctx,
+		`UPDATE imports SET status = 'running', error = NULL, updated_at = now()
+		 WHERE id = $1 AND status IN ('pending', 'failed')
+		 RETURNING `+importColumns, id))
+}
+
+// Checkpoint stores the import's progress. Called inside the transaction that
+// commits a chunk, it makes the chunk and the checkpoint atomic.
+func (r *ImportRepo) Checkpoint(ctx context.Context, tx pgx.Tx, j *domain.Import) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE imports SET
+		   type = $2, bytes_read = $3, rows_read = $4, imported = $5, skipped = $6, linked = $7,
+		   checkpoint_row = $8, error_count = $9, errors = $10, updated_at = now()
+		 WHERE id = $1`,
+		j.ID, j.Type, j.BytesRead, j.RowsRead, j.Imported, j.Skipped, j.Linked,
+		j.CheckpointRow, j.ErrorCount, j.Errors)
+	return err
+}
+
+// Finish records the final counters and status of an import.
+func (r *ImportRepo) Finish(ctx context.Context, j *domain.Import) error {
+	return r.pool.QueryRow(ctx,
+		`UPDATE imports SET
+		   type = $2, status = $3, bytes_read = $4, rows_read = $5, imported = $6, skipped = $7, linked = $8,
+		   checkpoint_row = $9, error_count = $10, errors = $11, error = $12,
+		   updated_at = now(), finished_at = CASE WHEN $3 = 'completed' THEN now() END
+		 WHERE id = $1
+		 RETURNING updated_at, finished_at`,
+		j.ID, j.Type, j.Status, j.BytesRead, j.RowsRead, j.Imported, j.Skipped, j.Linked,
+		j.CheckpointRow, j.ErrorCount, j.Errors, j.Error,
+	).Scan(&j.UpdatedAt, &j.FinishedAt)
+}
+
+// FailInterrupted marks imports left running by a previous process as failed so they can be resumed.
+func (r *ImportRepo) FailInterrupted(ctx context.Context) (int64, error) {
+	ct, err := r.pool.Exec(ctx,
+		`UPDATE imports SET status = 'failed', error = 'interrupted by server restart', updated_at = now()
+		 WHERE status = 'running'`)
+	if err != nil {
+		return 0, err
+	}
+	return ct.RowsAffected(), nil
+}
+
+// Fail marks an import as failed. Counters keep their last checkpointed values.
+func (r *ImportRepo) Fail(ctx context.Context, id uuid.UUID, msg string) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE imports SET status = 'failed', error = $2, updated_at = now() WHERE id = $1`, id, msg)
+	return err
+}
+
+// ── Rollback ──
+
+// supersededChange holds for a change c when an import that is not rolled
+// back wrote the same row after it; rolling c back would undo that import too.
+const supersededChange = `EXISTS (
+	SELECT 1 FROM import_changes l JOIN imports i ON i.id = l.import_id
+	WHERE l.entity = c.entity AND l.row_id = c.row_id AND l.id > c.id
+	  AND l.import_id <> c.import_id AND i.status <> 'rolled_back')`
+
+// restoredColumns are the columns each import upsert overwrites, and so the
+// ones a rollback restores from the before-images. A manager's current_load
+// is left out: the before-image is stale by the time of the rollback, so it
+// is recounted from the current assignments instead. A manager's
+// before-image also carries their manager_skills rows, restored separately.
+var restoredColumns = map[string][]string{
+	domain.ImportEntityTickets: {
+		"client_guid", "client_id", "subject", "body", "client_name", "client_segment", "source_channel",
+		"raw_address", "attachments", "import_id",
+	},
+	domain.ImportEntityClients:       {"full_name", "segment", "gender", "birth_date", "raw_address"},
+	domain.ImportEntityManagers:      {"full_name", "business_unit_id", "is_vip_skill", "is_chief_spec", "languages", "import_id"},
+	domain.ImportEntityBusinessUnits: {"city", "address", "import_id"},
+}
+
+// LockForRollback returns an import, locking it for the rollback transaction.
+func (r *ImportRepo) LockForRollback(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*domain.Import, error) {
+	return scanImport(tx.QueryRow(ctx, `SELECT `+importColumns+` FROM imports WHERE id = $1 FOR UPDATE`, id))
+}
+
+// ChangedRows returns the rows of entity the import inserted or overwrote, in
+// the order it first wrote them.
+func (r *ImportRepo) ChangedRows(ctx context.Context, id uuid.UUID, entity string) ([]uuid.UUID, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT row_id FROM import_changes
+		 WHERE import_id = $1 AND entity = $2
+		 GROUP BY row_id
+		 ORDER BY MIN(id)`, id, entity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var rowID uuid.UUID
+		if err := rows.Scan(&rowID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, rowID)
+	}
+	return ids, rows.Err()
+}
+
+// InsertedRows returns the rows of entity the import inserted, leaving out
+// those a later import has written since, which are counted as superseded.
+func (r *ImportRepo) InsertedRows(ctx context.Context, tx pgx.Tx, id uuid.UUID, entity string) ([]uuid.UUID, int, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT c.row_id, `+supersededChange+`
+		 FROM import_changes c
+		 WHERE c.import_id = $1 AND c.entity = $2 AND c.action = 'insert'
+		 ORDER BY c.id`, id, entity)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	superseded := 0
+	for rows.Next() {
+		var rowID uuid.UUID
+		var skip bool
+		if err := rows.Scan(&rowID, &skip); err != nil {
+			return nil, 0, err
+		}
+		if skip {
+			superseded++
+		} else {
+			ids = append(ids, rowID)
+		}
+	}
+	return ids, superseded, rows.Err()
+}
+
+// RestoreUpdated writes back the values an import overwrote on rows of entity
+// it did not insert, from the before-image of its first change to each row.
+// Rows a later import has written since are left alone and counted as superseded.
+func (r *ImportRepo) RestoreUpdated(ctx context.Context, tx pgx.Tx, id uuid.UUID, entity string) (restored, superseded int, err error) {
+	columns, ok := restoredColumns[entity]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown import entity %q", entity)
+	}
+	set := make([]string, len(columns))
+	for i, col := range columns {
+		set[i] = col + " = r." + col
+	}
+
+	firstChanges := `SELECT DISTINCT ON (c.row_id) c.id, c.row_id, c.before, ` + supersededChange + ` AS superseded
+		 FROM import_changes c
+		 WHERE c.import_id = $1 AND c.entity = $2
+		   AND NOT EXISTS (
+		     SELECT 1 FROM import_changes ins
+		     WHERE ins.import_id = c.import_id AND ins.entity = c.entity AND ins.row_id = c.row_id AND ins.action = 'insert')
+		 ORDER BY c.row_id, c.id`
+
+	if err := tx.QueryRow(ctx,
+		`SELECT COUNT(*) FROM (`+firstChanges+`) f WHERE f.superseded`, id, entity,
+	).Scan(&superseded); err != nil {
+		return 0, 0, err
+	}
+	ct, err := tx.Exec(ctx,
+		`WITH f AS (`+firstChanges+`)
+		 UPDATE `+entity+` t SET `+strings.Join(set, ", ")+`
+		 FROM f CROSS JOIN LATERAL jsonb_populate_record(NULL::`+entity+`, f.before) r
+		 WHERE t.id = f.row_id AND NOT f.superseded`, id, entity)
+	if err != nil {
+		return 0, 0, err
+	}
+	if entity == domain.ImportEntityManagers {
+		if _, err := tx.Exec(ctx,
+			`WITH f AS (`+firstChanges+`)
+			 UPDATE managers m SET current_load = (
+			   SELECT COUNT(*) FROM ticket_assignment a WHERE a.manager_id = m.id AND a.is_current)
+			 FROM f
+			 WHERE m.id = f.row_id AND NOT f.superseded`, id, entity); err != nil {
+			return 0, 0, fmt.Errorf("recount load: %w", err)
+		}
+		// Restoring the legacy skill columns above re-synced manager_skills
+		// from them; put back the rows the import replaced instead.
+		if _, err := tx.Exec(ctx,
+			`WITH f AS (`+firstChanges+`)
+			 DELETE FROM manager_skills ms
+			 USING f
+			 WHERE ms.manager_id = f.row_id AND NOT f.superseded AND f.before ? 'manager_skills'`, id, entity); err != nil {
+			return 0, 0, fmt.Errorf("clear skills: %w", err)
+		}
+		if _, err := tx.Exec(ctx,
+			`WITH f AS (`+firstChanges+`)
+			 INSERT INTO manager_skills (manager_id, skill_id, level)
+			 SELECT f.row_id, s.id, (e->>'level')::smallint
+			 FROM f CROSS JOIN LATERAL jsonb_array_elements(f.before->'manager_skills') e
+			 JOIN skills s ON s.code = e->>'code'
+			 WHERE NOT f.superseded
+			 ON CONFLICT DO NOTHING`, id, entity); err != nil {
+			return 0, 0, fmt.Errorf("restore skills: %w", err)
+		}
+	}
+	return int(ct.RowsAffected()), superseded, nil
+}
+
+// MarkRolledBack records that an import's changes were undone.
+func (r *ImportRepo) MarkRolledBack(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*domain.Import, error) {
+	return scanImport(tx.QueryRow(ctx,
+		`UPDATE imports SET status = 'rolled_back', rolled_back_at = now(), updated_at = now()
+		 WHERE id = $1
+		 RETURNING `+importColumns, id))
+}
@@ -18,52 +18,126 @@ func NewManagerRepo(pool *pgxpool.Pool) *ManagerRepo {
 	return &ManagerRepo{pool: pool}
 }
 
-func (r *ManagerRepo) Insert(ctx context.Context, m *domain.Manager) error {
-	_, err := r.pool.Exec(ctx,
+const managerColumns = `id, full_name, email, business_unit_id, is_vip_skill, is_chief_spec, languages, max_load, current_load, is_active, team_id, created_at`
+
+func scanManager(row pgx.Row) (*domain.Manager, error) {
+	var m domain.Manager
+	err := row.Scan(&m.ID, &m.FullName, &m.Email, &m.BusinessUnitID, &m.IsVIPSkill, &m.IsChiefSpec, &m.Languages, &m.MaxLoad, &m.CurrentLoad, &m.IsActive, &m.TeamID, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *ManagerRepo) Insert(ctx context.Context, tx pgx.Tx, m *domain.Manager) error {
+	return tx.QueryRow(ctx,
 		`INSERT INTO managers (id, full_name, email, business_unit_id, is_vip_skill, is_chief_spec, languages, max_load, current_load, is_active)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING created_at`,
 		m.ID, m.FullName, m.Email, m.BusinessUnitID, m.IsVIPSkill, m.IsChiefSpec, m.Languages, m.MaxLoad, m.CurrentLoad, m.IsActive,
-	)
+	).Scan(&m.CreatedAt)
+}
+
+// Update writes a manager's editable fields; load and the active flag are left
+// alone. A manager moved to another office leaves their team.
+func (r *ManagerRepo) Update(ctx context.Context, tx pgx.Tx, m *domain.Manager) (*domain.Manager, error) {
+	return scanManager(tx.QueryRow(ctx,
+		`UPDATE managers SET
+		   full_name = $2, email = $3, business_unit_id = $4, is_vip_skill = $5, is_chief_spec = $6, languages = $7, max_load = $8,
+		   team_id = CASE WHEN business_unit_id = $4 THEN team_id END
+		 WHERE id = $1
+		 RETURNING `+managerColumns,
+		m.ID, m.FullName, m.Email, m.BusinessUnitID, m.IsVIPSkill, m.IsChiefSpec, m.Languages, m.MaxLoad))
+}
+
+// GetForUpdate returns a manager, locking it for the rest of tx.
+func (r *ManagerRepo) GetForUpdate(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*domain.Manager, error) {
+	return scanManager(tx.QueryRow(ctx, `SELECT `+managerColumns+` FROM managers WHERE id = $1 FOR UPDATE`, id))
+}
+
+func (r *ManagerRepo) SetActive(ctx context.Context, tx pgx.Tx, id uuid.UUID, active bool) (*domain.Manager, error) {
+	return scanManager(tx.QueryRow(ctx,
+		`UPDATE managers SET is_active = $2 WHERE id = $1 RETURNING `+managerColumns, id, active))
+}
+
+// AddLoad changes a manager's load by delta, never below zero.
+func (r *ManagerRepo) AddLoad(ctx context.Context, tx pgx.Tx, id uuid.UUID, delta int) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE managers SET current_load = GREATEST(current_load + $2, 0) WHERE id = $1`, id, delta)
 	return err
 }
 
-func (r *ManagerRepo) BulkInsert(ctx context.Context, managers []domain.Manager) (int, error) {
+// BulkInsert upserts managers by email within tx, stamping them with
+// importID and logging each insert or update to import_changes. Managers
+// that already existed get the id of their row.
+func (r *ManagerRepo) BulkInsert(ctx context.Context, tx pgx.Tx, importID uuid.UUID, managers []domain.Manager) (int, error) {
 	batch := &pgx.Batch{}
 	for _, m := range managers {
 		batch.Queue(
-			`INSERT INTO managers (id, full_name, email, business_unit_id, is_vip_skill, is_chief_spec, languages, max_load, current_load, is_active)
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-			 ON CONFLICT (email) DO UPDATE SET
-			   full_name = EXCLUDED.full_name,
-			   business_unit_id = EXCLUDED.business_unit_id,
-			   is_vip_skill = EXCLUDED.is_vip_skill,
-			   is_chief_spec = EXCLUDED.is_chief_spec,
-			   languages = EXCLUDED.languages,
-			   current_load = EXCLUDED.current_load`,
-			m.ID, m.FullName, m.Email, m.BusinessUnitID, m.IsVIPSkill, m.IsChiefSpec, m.Languages, m.MaxLoad, m.CurrentLoad, m.IsActive,
+			`WITH before AS (
+			   SELECT m.*, (
+			     SELECT COALESCE(jsonb_agg(jsonb_build_object('code', s.code, 'level', ms.level)), '[]')
+			     FROM manager_skills ms JOIN skills s ON s.id = ms.skill_id
+			     WHERE ms.manager_id = m.id) AS manager_skills
+			   FROM managers m WHERE m.email = $3
+			 ), merged AS (
+			   INSERT INTO managers (id, full_name, email, business_unit_id, is_vip_skill, is_chief_spec, languages, max_load, current_load, is_active, import_id)
+			   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			   ON CONFLICT (email) DO UPDATE SET
+			     full_name = EXCLUDED.full_name,
+			     business_unit_id = EXCLUDED.business_unit_id,
+			     is_vip_skill = EXCLUDED.is_vip_skill,
+			     is_chief_spec = EXCLUDED.is_chief_spec,
+			     languages = EXCLUDED.languages,
+			     current_load = EXCLUDED.current_load,
+			     import_id = EXCLUDED.import_id
+			   RETURNING id
+			 )
+			 INSERT INTO import_changes (import_id, entity, row_id, action, before)
+			 SELECT $11, 'managers', m.id, CASE WHEN b.id IS NULL THEN 'insert' ELSE 'update' END, to_jsonb(b)
+			 FROM merged m LEFT JOIN before b ON b.id = m.id
+			 RETURNING row_id`,
+			m.ID, m.FullName, m.Email, m.BusinessUnitID, m.IsVIPSkill, m.IsChiefSpec, m.Languages, m.MaxLoad, m.CurrentLoad, m.IsActive, importID,
 		)
 	}
-	br := r.pool.SendBatch(ctx, batch)
+	br := tx.SendBatch(ctx, batch)
 	defer br.Close()
 
 	inserted := 0
-	for range managers {
-		ct, err := br.Exec()
-		if err != nil {
+	for i := range managers {
+		if err := br.QueryRow().Scan(&managers[i].ID); err != nil {
 			return inserted, err
 		}
-		inserted += int(ct.RowsAffected())
+		inserted++
 	}
 	return inserted, nil
 }
 
+// DeleteImported deletes managers an import created. It fails with a foreign
+// key violation if tickets were assigned to any of them since.
+func (r *ManagerRepo) DeleteImported(ctx context.Context, tx pgx.Tx, ids []uuid.UUID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	ct, err := tx.Exec(ctx, `DELETE FROM managers WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return 0, err
+	}
+	return int(ct.RowsAffected()), nil
+}
+
+// ExistingEmails returns which of the given emails already belong to a manager.
+func (r *ManagerRepo) ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	return existingKeys(ctx, r.pool, `SELECT email FROM managers WHERE email = ANY($1)`, emails)
+}
+
 func (r *ManagerRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Manager, error) {
 	row := r.pool.QueryRow(ctx,
-		`SELECT id, full_name, email, business_unit_id, is_vip_skill, is_chief_spec, languages, max_load, current_load, is_active, created_at
+		`SELECT `+managerColumns+`
 		 FROM managers WHERE id = $1`, id)
 
 	var m domain.Manager
-	err := row.Scan(&m.ID, &m.FullName, &m.Email, &m.BusinessUnitID, &m.IsVIPSkill, &m.IsChiefSpec, &m.Languages, &m.MaxLoad, &m.CurrentLoad, &m.IsActive, &m.CreatedAt)
+	err := row.Scan(&m.ID, &m.FullName, &m.Email, &m.BusinessUnitID, &m.IsVIPSkill, &m.IsChiefSpec, &m.Languages, &m.MaxLoad, &m.CurrentLoad, &m.IsActive, &m.TeamID, &m.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +147,7 @@ func (r *ManagerRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Manage
 func (r *ManagerRepo) List(ctx context.Context) ([]domain.ManagerWithOffice, error) {
 	rows, err := r.pool.Query(ctx,
 		`SELECT m.id, m.full_name, m.email, m.business_unit_id, m.is_vip_skill, m.is_chief_spec, m.languages,
-		        m.max_load, m.current_load, m.is_active, m.created_at,
+		        m.max_load, m.current_load, m.is_active, m.team_id, m.created_at,
 		        bu.name as office_name, bu.city as office_city
 		 FROM managers m
 		 JOIN business_units bu ON bu.id = m.business_unit_id
@@ -87,7 +161,7 @@ func (r *ManagerRepo) List(ctx context.Context) ([]domain.ManagerWithOffice, err
 	for rows.Next() {
 		var m domain.ManagerWithOffice
 		if err := rows.Scan(&m.ID, &m.FullName, &m.Email, &m.BusinessUnitID, &m.IsVIPSkill, &m.IsChiefSpec, &m.Languages,
-			&m.MaxLoad, &m.CurrentLoad, &m.IsActive, &m.CreatedAt, &m.OfficeName, &m.OfficeCity); err != nil {
+			&m.MaxLoad, &m.CurrentLoad, &m.IsActive, &m.TeamID, &m.CreatedAt, &m.OfficeName, &m.OfficeCity); err != nil {
 			return nil, err
 		}
 		if m.MaxLoad > 0 {
@@ -100,7 +174,7 @@ func (r *ManagerRepo) List(ctx context.Context) ([]domain.ManagerWithOffice, err
 
 func (r *ManagerRepo) ListByBusinessUnit(ctx context.Context, buID uuid.UUID) ([]domain.Manager, error) {
 	rows, err := r.pool.Query(ctx,
-		`SELECT id, full_name, email, business_unit_id, is_vip_skill, is_chief_spec, languages, max_load, current_load, is_active, created_at
+		`SELECT `+managerColumns+`
 		 FROM managers WHERE business_unit_id = $1 AND is_active = true
 		 ORDER BY current_load ASC`, buID)
 	if err != nil {
@@ -111,7 +185,7 @@ func (r *ManagerRepo) ListByBusinessUnit(ctx context.Context, buID uuid.UUID) ([
 	managers := []domain.Manager{}
 	for rows.Next() {
 		var m domain.Manager
-		if err := rows.Scan(&m.ID, &m.FullName, &m.Email, &m.BusinessUnitID, &m.IsVIPSkill, &m.IsChiefSpec, &m.Languages, &m.MaxLoad, &m.CurrentLoad, &m.IsActive, &m.CreatedAt); err != nil {
+		if err := rows.Scan(&m.ID, &m.FullName, &m.Email, &m.BusinessUnitID, &m.IsVIPSkill, &m.IsChiefSpec, &m.Languages, &m.MaxLoad, &m.CurrentLoad, &m.IsActive, &m.TeamID, &m.CreatedAt); err != nil {
 			return nil, err
 		}
 		managers = append(managers, m)
@@ -121,7 +195,7 @@ func (r *ManagerRepo) ListByBusinessUnit(ctx context.Context, buID uuid.UUID) ([
 
 func (r *ManagerRepo) ListAllActive(ctx context.Context) ([]domain.Manager, error) {
 	rows, err := r.pool.Query(ctx,
-		`SELECT id, full_name, email, business_unit_id, is_vip_skill, is_chief_spec, languages, max_load, current_load, is_active, created_at
+		`SELECT `+managerColumns+`
 		 FROM managers WHERE is_active = true
 		 ORDER BY current_load ASC`)
 	if err != nil {
@@ -132,7 +206,7 @@ func (r *ManagerRepo) ListAllActive(ctx context.Context) ([]domain.Manager, erro
 	managers := []domain.Manager{}
 	for rows.Next() {
 		var m domain.Manager
-		if err := rows.Scan(&m.ID, &m.FullName, &m.Email, &m.BusinessUnitID, &m.IsVIPSkill, &m.IsChiefSpec, &m.Languages, &m.MaxLoad, &m.CurrentLoad, &m.IsActive, &m.CreatedAt); err != nil {
+		if err := rows.Scan(&m.ID, &m.FullName, &m.Email, &m.BusinessUnitID, &m.IsVIPSkill, &m.IsChiefSpec, &m.Languages, &m.MaxLoad, &m.CurrentLoad, &m.IsActive, &m.TeamID, &m.CreatedAt); err != nil {
 			return nil, err
 		}
 		managers = append(managers, m)
@@ -140,6 +214,23 @@ func (r *ManagerRepo) ListAllActive(ctx context.Context) ([]domain.Manager, erro
 	return managers, nil
 }
 
+// LeastLoadedWithSkill returns, within tx, the active manager with the skill
+// (a catalogue code) and the lowest utilization, preferring those of the
+// given office and leaving out exclude. It returns pgx.ErrNoRows if there is
+// none.
+func (r *ManagerRepo) LeastLoadedWithSkill(ctx context.Context, tx pgx.Tx, skill string, officeID uuid.UUID, exclude []uuid.UUID) (*domain.Manager, error) {
+	return scanManager(tx.QueryRow(ctx,
+		`SELECT `+managerColumns+`
+		 FROM managers m
+		 WHERE m.is_active = true AND NOT m.id = ANY($3)
+		   AND EXISTS (SELECT 1 FROM manager_skills ms JOIN skills s ON s.id = ms.skill_id
+		               WHERE ms.manager_id = m.id AND s.code = $1)
+		 ORDER BY m.business_unit_id = $2 DESC,
+		          CASE WHEN m.max_load > 0 THEN m.current_load::float8 / m.max_load ELSE 0 END,
+		          m.current_load, m.id
+		 LIMIT 1`, skill, officeID, exclude))
+}
+
 func (r *ManagerRepo) IncrementLoad(ctx context.Context, tx pgx.Tx, managerID uuid.UUID) error {
 	_, err := tx.Exec(ctx,
 		`UPDATE managers SET current_load = current_load + 1 WHERE id = $1`, managerID)
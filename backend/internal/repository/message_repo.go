@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+type MessageRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewMessageRepo(pool *pgxpool.Pool) *MessageRepo {
+	return &MessageRepo{pool: pool}
+}
+
+const messageColumns = `id, ticket_id, kind, author, manager_id, body, attachments, done_actions, created_at`
+
+func scanMessage(row pgx.Row) (*domain.TicketMessage, error) {
+	var m domain.TicketMessage
+	if err := row.Scan(&m.ID, &m.TicketID, &m.Kind, &m.Author, &m.ManagerID, &m.Body, &m.Attachments, &m.DoneActions, &m.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Insert adds a message within tx.
+func (r *MessageRepo) Insert(ctx context.Context, tx pgx.Tx, m *domain.TicketMessage) error {
+	attachments, err := json.Marshal(m.Attachments)
+	if err != nil {
+		return err
+	}
+	return tx.QueryRow(ctx,
+		`INSERT INTO ticket_messages (id, ticket_id, kind, author, manager_id, body, attachments, done_actions)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING created_at`,
+		m.ID, m.TicketID, m.Kind, m.Author, m.ManagerID, m.Body, attachments, m.DoneActions,
+	).Scan(&m.CreatedAt)
+}
+
+// List returns a ticket's messages, oldest first.
+func (r *MessageRepo) List(ctx context.Context, ticketID uuid.UUID) ([]domain.TicketMessage, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+messageColumns+` FROM ticket_messages WHERE ticket_id = $1 ORDER BY created_at, id`, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []domain.TicketMessage{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *m)
+	}
+	return messages, rows.Err()
+}
+
+// GetByID returns a message of the ticket.
+func (r *MessageRepo) GetByID(ctx context.Context, ticketID, id uuid.UUID) (*domain.TicketMessage, error) {
+	return scanMessage(r.pool.QueryRow(ctx,
+		`SELECT `+messageColumns+` FROM ticket_messages WHERE ticket_id = $1 AND id = $2`, ticketID, id))
+}
+
+// ── Recommended actions ──
+
+// DoneActions returns the checked-off actions of a ticket by position, with
+// the action text they were checked off for.
+func (r *MessageRepo) DoneActions(ctx context.Context, ticketID uuid.UUID) (map[int]domain.TicketAction, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT position, action, message_id, done_by, done_at FROM ticket_action_status WHERE ticket_id = $1`, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := map[int]domain.TicketAction{}
+	for rows.Next() {
+		a := domain.TicketAction{Done: true}
+		if err := rows.Scan(&a.Index, &a.Text, &a.MessageID, &a.DoneBy, &a.DoneAt); err != nil {
+			return nil, err
+		}
+		done[a.Index] = a
+	}
+	return done, rows.Err()
+}
+
+// MarkActionsDone checks off actions (text by position) of a ticket within
+// tx; actions already checked off are taken over by this check.
+func (r *MessageRepo) MarkActionsDone(ctx context.Context, tx pgx.Tx, ticketID uuid.UUID, actions map[int]string, messageID *uuid.UUID, by string) error {
+	batch := &pgx.Batch{}
+	for position, action := range actions {
+		batch.Queue(
+			`INSERT INTO ticket_action_status (ticket_id, position, action, message_id, done_by)
+			 VALUES ($1, $2, $3, $4, $5)
+			 ON CONFLICT (ticket_id, position) DO UPDATE SET
+			   action = EXCLUDED.action, message_id = EXCLUDED.message_id, done_by = EXCLUDED.done_by, done_at = now()`,
+			ticketID, position, action, messageID, by)
+	}
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// ClearAction unchecks an action of a ticket.
+func (r *MessageRepo) ClearAction(ctx context.Context, ticketID uuid.UUID, position int) error {
+	_, err := r.pool.Exec(ctx,
+		`DELETE FROM ticket_action_status WHERE ticket_id = $1 AND position = $2`, ticketID, position)
+	return err
+}
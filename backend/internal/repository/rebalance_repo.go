@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+type RebalanceRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewRebalanceRepo(pool *pgxpool.Pool) *RebalanceRepo {
+	return &RebalanceRepo{pool: pool}
+}
+
+const rebalanceJobColumns = `id, mode, status, filter, policy, targets, initiator, selected, planned, unchanged, applied, skipped, failed,
+	error, created_at, updated_at, started_at, finished_at`
+
+func scanRebalanceJob(row pgx.Row) (*domain.RebalanceJob, error) {
+	var j domain.RebalanceJob
+	err := row.Scan(&j.ID, &j.Mode, &j.Status, &j.Filter, &j.Policy, &j.Targets, &j.Initiator, &j.Selected, &j.Planned, &j.Unchanged,
+		&j.Applied, &j.Skipped, &j.Failed, &j.Error, &j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if j.Targets == nil {
+		j.Targets = []domain.RebalanceTarget{}
+	}
+	return &j, nil
+}
+
+// Create stores a job with its planned moves.
+func (r *RebalanceRepo) Create(ctx context.Context, tx pgx.Tx, j *domain.RebalanceJob, moves []domain.RebalanceMove) error {
+	err := tx.QueryRow(ctx,
+		`INSERT INTO rebalance_jobs (id, mode, status, filter, policy, targets, initiator, selected, planned, unchanged, failed)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING created_at, updated_at`,
+		j.ID, j.Mode, j.Status, j.Filter, j.Policy, j.Targets, j.Initiator, j.Selected, j.Planned, j.Unchanged, j.Failed,
+	).Scan(&j.CreatedAt, &j.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"rebalance_moves"},
+		[]string{"job_id", "ticket_id", "position", "from_manager_id", "to_manager_id", "routing_bucket", "reason", "status", "error"},
+		pgx.CopyFromSlice(len(moves), func(i int) ([]any, error) {
+			m := moves[i]
+			return []any{j.ID, m.TicketID, m.Position, m.FromManagerID, m.ToManagerID, m.RoutingBucket, m.Reason, m.Status, m.Error}, nil
+		}))
+	return err
+}
+
+func (r *RebalanceRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.RebalanceJob, error) {
+	return scanRebalanceJob(r.pool.QueryRow(ctx, `SELECT `+rebalanceJobColumns+` FROM rebalance_jobs WHERE id = $1`, id))
+}
+
+// List returns the latest jobs, newest first.
+func (r *RebalanceRepo) List(ctx context.Context, limit int) ([]domain.RebalanceJob, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+rebalanceJobColumns+` FROM rebalance_jobs ORDER BY created_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []domain.RebalanceJob{}
+	for rows.Next() {
+		j, err := scanRebalanceJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, rows.Err()
+}
+
+// Moves returns a job's moves in the order they apply; status filters them
+// unless empty.
+func (r *RebalanceRepo) Moves(ctx context.Context, jobID uuid.UUID, status string) ([]domain.RebalanceMove, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT job_id, ticket_id, position, from_manager_id, to_manager_id, routing_bucket, reason, status, error, applied_at
+		 FROM rebalance_moves
+		 WHERE job_id = $1 AND ($2 = '' OR status = $2)
+		 ORDER BY position`, jobID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moves := []domain.RebalanceMove{}
+	for rows.Next() {
+		var m domain.RebalanceMove
+		if err := rows.Scan(&m.JobID, &m.TicketID, &m.Position, &m.FromManagerID, &m.ToManagerID, &m.RoutingBucket,
+			&m.Reason, &m.Status, &m.Error, &m.AppliedAt); err != nil {
+			return nil, err
+		}
+		moves = append(moves, m)
+	}
+	return moves, rows.Err()
+}
+
+// Claim marks a previewed or failed job as running and returns it. It
+// returns pgx.ErrNoRows if the job does not exist or is running or done.
+func (r *RebalanceRepo) Claim(ctx context.Context, id uuid.UUID) (*domain.RebalanceJob, error) {
+	return scanRebalanceJob(r.pool.QueryRow(ctx,
+		`UPDATE rebalance_jobs SET status = 'running', error = NULL, started_at = COALESCE(started_at, now()), updated_at = now()
+		 WHERE id = $1 AND status IN ('preview', 'failed')
+		 RETURNING `+rebalanceJobColumns, id))
+}
+
+// RecordMove sets the outcome of a planned move and counts it on the job,
+// within tx, and returns the job with its new counters.
+func (r *RebalanceRepo) RecordMove(ctx context.Context, tx pgx.Tx, jobID, ticketID uuid.UUID, status string, msg *string) (*domain.RebalanceJob, error) {
+	return scanRebalanceJob(tx.QueryRow(ctx,
+		`WITH m AS (
+		   UPDATE rebalance_moves SET status = $3, error = $4, applied_at = CASE WHEN $3 = 'applied' THEN now() END
+		   WHERE job_id = $1 AND ticket_id = $2 AND status = 'planned'
+		   RETURNING status
+		 )
+		 UPDATE rebalance_jobs SET
+		   applied = applied + (SELECT count(*) FROM m WHERE status = 'applied'),
+		   skipped = skipped + (SELECT count(*) FROM m WHERE status = 'skipped'),
+		   failed = failed + (SELECT count(*) FROM m WHERE status = 'failed'),
+		   updated_at = now()
+		 WHERE id = $1
+		 RETURNING `+rebalanceJobColumns, jobID, ticketID, status, msg))
+}
+
+// Finish marks a running job as completed.
+func (r *RebalanceRepo) Finish(ctx context.Context, id uuid.UUID) (*domain.RebalanceJob, error) {
+	return scanRebalanceJob(r.pool.QueryRow(ctx,
+		`UPDATE rebalance_jobs SET status = 'completed', updated_at = now(), finished_at = now()
+		 WHERE id = $1
+		 RETURNING `+rebalanceJobColumns, id))
+}
+
+// Fail marks a job as failed; its remaining planned moves apply when it is run again.
+func (r *RebalanceRepo) Fail(ctx context.Context, id uuid.UUID, msg string) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE rebalance_jobs SET status = 'failed', error = $2, updated_at = now() WHERE id = $1`, id, msg)
+	return err
+}
+
+// FailInterrupted marks jobs left running by a previous process as failed so they can be resumed.
+func (r *RebalanceRepo) FailInterrupted(ctx context.Context) (int64, error) {
+	ct, err := r.pool.Exec(ctx,
+		`UPDATE rebalance_jobs SET status = 'failed', error = 'interrupted by server restart', updated_at = now()
+		 WHERE status = 'running'`)
+	if err != nil {
+		return 0, err
+	}
+	return ct.RowsAffected(), nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+type ReplyTemplateRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewReplyTemplateRepo(pool *pgxpool.Pool) *ReplyTemplateRepo {
+	return &ReplyTemplateRepo{pool: pool}
+}
+
+const replyTemplateColumns = `id, name, lang, ticket_types, segments, body, is_active, created_at, updated_at`
+
+func scanReplyTemplate(row pgx.Row) (*domain.ReplyTemplate, error) {
+	var t domain.ReplyTemplate
+	err := row.Scan(&t.ID, &t.Name, &t.Lang, &t.TicketTypes, &t.Segments, &t.Body, &t.IsActive, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *ReplyTemplateRepo) Insert(ctx context.Context, t *domain.ReplyTemplate) error {
+	return r.pool.QueryRow(ctx,
+		`INSERT INTO reply_templates (id, name, lang, ticket_types, segments, body, is_active)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING created_at, updated_at`,
+		t.ID, t.Name, t.Lang, t.TicketTypes, t.Segments, t.Body, t.IsActive,
+	).Scan(&t.CreatedAt, &t.UpdatedAt)
+}
+
+func (r *ReplyTemplateRepo) Update(ctx context.Context, t *domain.ReplyTemplate) error {
+	return r.pool.QueryRow(ctx,
+		`UPDATE reply_templates SET name = $2, lang = $3, ticket_types = $4, segments = $5, body = $6, is_active = $7, updated_at = now()
+		 WHERE id = $1
+		 RETURNING created_at, updated_at`,
+		t.ID, t.Name, t.Lang, t.TicketTypes, t.Segments, t.Body, t.IsActive,
+	).Scan(&t.CreatedAt, &t.UpdatedAt)
+}
+
+func (r *ReplyTemplateRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.ReplyTemplate, error) {
+	return scanReplyTemplate(r.pool.QueryRow(ctx, `SELECT `+replyTemplateColumns+` FROM reply_templates WHERE id = $1`, id))
+}
+
+// List returns the library, optionally of one language only.
+func (r *ReplyTemplateRepo) List(ctx context.Context, lang string) ([]domain.ReplyTemplate, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+replyTemplateColumns+` FROM reply_templates
+		 WHERE $1 = '' OR lang = $1
+		 ORDER BY lang, name`, lang)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []domain.ReplyTemplate{}
+	for rows.Next() {
+		t, err := scanReplyTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	return templates, rows.Err()
+}
+
+// Match returns the active template in lang that fits a ticket best: one
+// for its type wins over one for its segment, which wins over a general one.
+// It returns pgx.ErrNoRows if none fits.
+func (r *ReplyTemplateRepo) Match(ctx context.Context, lang, ticketType, segment string) (*domain.ReplyTemplate, error) {
+	return scanReplyTemplate(r.pool.QueryRow(ctx,
+		`SELECT `+replyTemplateColumns+` FROM reply_templates
+		 WHERE is_active AND lang = $1
+		   AND (cardinality(ticket_types) = 0 OR $2 = ANY(ticket_types))
+		   AND (cardinality(segments) = 0 OR $3 = ANY(segments))
+		 ORDER BY cardinality(ticket_types) > 0 DESC, cardinality(segments) > 0 DESC, name
+		 LIMIT 1`, lang, ticketType, segment))
+}
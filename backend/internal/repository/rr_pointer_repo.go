@@ -53,3 +53,16 @@ func (r *RRPointerRepo) GetAndAdvance(ctx context.Context, tx pgx.Tx, buID uuid.
 
 	return nextIdx, nil
 }
+
+// Peek returns the last index a round-robin bucket assigned, or -1 if the
+// bucket has not assigned yet, without advancing it.
+func (r *RRPointerRepo) Peek(ctx context.Context, buID uuid.UUID, skillGroup string) (int, error) {
+	var lastIdx int
+	err := r.pool.QueryRow(ctx,
+		`SELECT last_manager_idx FROM rr_pointer
+		 WHERE business_unit_id = $1 AND skill_group = $2`, buID, skillGroup).Scan(&lastIdx)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return -1, nil
+	}
+	return lastIdx, err
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+type SkillRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewSkillRepo(pool *pgxpool.Pool) *SkillRepo {
+	return &SkillRepo{pool: pool}
+}
+
+const skillColumns = `id, code, name, category, aliases, created_at`
+
+func scanSkill(row pgx.Row) (*domain.Skill, error) {
+	var s domain.Skill
+	if err := row.Scan(&s.ID, &s.Code, &s.Name, &s.Category, &s.Aliases, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// List returns the catalogue ordered by category and code.
+func (r *SkillRepo) List(ctx context.Context) ([]domain.Skill, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+skillColumns+` FROM skills ORDER BY category, code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skills := []domain.Skill{}
+	for rows.Next() {
+		s, err := scanSkill(rows)
+		if err != nil {
+			return nil, err
+		}
+		skills = append(skills, *s)
+	}
+	return skills, rows.Err()
+}
+
+func (r *SkillRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Skill, error) {
+	return scanSkill(r.pool.QueryRow(ctx, `SELECT `+skillColumns+` FROM skills WHERE id = $1`, id))
+}
+
+func (r *SkillRepo) Insert(ctx context.Context, s *domain.Skill) error {
+	return r.pool.QueryRow(ctx,
+		`INSERT INTO skills (id, code, name, category, aliases)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING created_at`,
+		s.ID, s.Code, s.Name, s.Category, s.Aliases,
+	).Scan(&s.CreatedAt)
+}
+
+// Update renames a skill and replaces its aliases; code and category are fixed
+// once created, as the legacy manager columns and import files refer to them.
+func (r *SkillRepo) Update(ctx context.Context, s *domain.Skill) (*domain.Skill, error) {
+	return scanSkill(r.pool.QueryRow(ctx,
+		`UPDATE skills SET name = $2, aliases = $3 WHERE id = $1 RETURNING `+skillColumns,
+		s.ID, s.Name, s.Aliases))
+}
+
+// ── Manager skills ──
+
+// ManagerSkills returns a manager's skills ordered by category and code.
+func (r *SkillRepo) ManagerSkills(ctx context.Context, managerID uuid.UUID) ([]domain.ManagerSkill, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT s.code, s.name, s.category, ms.level
+		 FROM manager_skills ms JOIN skills s ON s.id = ms.skill_id
+		 WHERE ms.manager_id = $1
+		 ORDER BY s.category, s.code`, managerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skills := []domain.ManagerSkill{}
+	for rows.Next() {
+		var s domain.ManagerSkill
+		if err := rows.Scan(&s.Code, &s.Name, &s.Category, &s.Level); err != nil {
+			return nil, err
+		}
+		skills = append(skills, s)
+	}
+	return skills, rows.Err()
+}
+
+// ReplaceManagerSkills sets a manager's skills to exactly the given ones,
+// looked up by code, within tx.
+func (r *SkillRepo) ReplaceManagerSkills(ctx context.Context, tx pgx.Tx, managerID uuid.UUID, skills []domain.ManagerSkill) error {
+	codes := make([]string, len(skills))
+	levels := make([]int, len(skills))
+	for i, s := range skills {
+		codes[i], levels[i] = s.Code, s.Level
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM manager_skills WHERE manager_id = $1`, managerID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx,
+		`INSERT INTO manager_skills (manager_id, skill_id, level)
+		 SELECT $1, s.id, v.level
+		 FROM unnest($2::text[], $3::int[]) AS v(code, level)
+		 JOIN skills s ON s.code = v.code
+		 ON CONFLICT (manager_id, skill_id) DO UPDATE SET level = EXCLUDED.level`,
+		managerID, codes, levels)
+	return err
+}
+
+// Levels returns the skill levels of the given managers: manager → skill code → level.
+func (r *SkillRepo) Levels(ctx context.Context, managerIDs []uuid.UUID) (map[uuid.UUID]map[string]int, error) {
+	levels := make(map[uuid.UUID]map[string]int, len(managerIDs))
+	if len(managerIDs) == 0 {
+		return levels, nil
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT ms.manager_id, s.code, ms.level
+		 FROM manager_skills ms JOIN skills s ON s.id = ms.skill_id
+		 WHERE ms.manager_id = ANY($1)`, managerIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var code string
+		var level int
+		if err := rows.Scan(&id, &code, &level); err != nil {
+			return nil, err
+		}
+		if levels[id] == nil {
+			levels[id] = map[string]int{}
+		}
+		levels[id][code] = level
+	}
+	return levels, rows.Err()
+}
+
+// ── Requirements ──
+
+const requirementColumns = `r.id, r.skill_id, s.code, s.category, r.min_level, r.segments, r.ticket_types, r.langs, r.position, r.is_active, r.created_at`
+
+func scanRequirement(row pgx.Row) (*domain.SkillRequirement, error) {
+	var q domain.SkillRequirement
+	if err := row.Scan(&q.ID, &q.SkillID, &q.SkillCode, &q.Category, &q.MinLevel, &q.Segments, &q.TicketTypes,
+		&q.Langs, &q.Position, &q.IsActive, &q.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// ListRequirements returns all skill requirements in the order routing applies them.
+func (r *SkillRepo) ListRequirements(ctx context.Context) ([]domain.SkillRequirement, error) {
+	return r.listRequirements(ctx,
+		`SELECT `+requirementColumns+`
+		 FROM skill_requirements r JOIN skills s ON s.id = r.skill_id
+		 ORDER BY r.position, r.created_at`)
+}
+
+// MatchingRequirements returns the active requirements that apply to a
+// ticket, in the order routing applies them.
+func (r *SkillRepo) MatchingRequirements(ctx context.Context, segment, ticketType, lang string) ([]domain.SkillRequirement, error) {
+	return r.listRequirements(ctx,
+		`SELECT `+requirementColumns+`
+		 FROM skill_requirements r JOIN skills s ON s.id = r.skill_id
+		 WHERE r.is_active
+		   AND (cardinality(r.segments) = 0 OR $1 = ANY(r.segments))
+		   AND (cardinality(r.ticket_types) = 0 OR $2 = ANY(r.ticket_types))
+		   AND (cardinality(r.langs) = 0 OR $3 = ANY(r.langs))
+		 ORDER BY r.position, r.created_at`, segment, ticketType, lang)
+}
+
+func (r *SkillRepo) listRequirements(ctx context.Context, query string, args ...interface{}) ([]domain.SkillRequirement, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reqs := []domain.SkillRequirement{}
+	for rows.Next() {
+		q, err := scanRequirement(rows)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, *q)
+	}
+	return reqs, rows.Err()
+}
+
+func (r *SkillRepo) GetRequirement(ctx context.Context, id uuid.UUID) (*domain.SkillRequirement, error) {
+	return scanRequirement(r.pool.QueryRow(ctx,
+		`SELECT `+requirementColumns+`
+		 FROM skill_requirements r JOIN skills s ON s.id = r.skill_id
+		 WHERE r.id = $1`, id))
+}
+
+func (r *SkillRepo) InsertRequirement(ctx context.Context, q *domain.SkillRequirement) error {
+	_, err := r.pool.Exec(ctx,
+		`INSERT INTO skill_requirements (id, skill_id, min_level, segments, ticket_types, langs, position, is_active)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		q.ID, q.SkillID, q.MinLevel, q.Segments, q.TicketTypes, q.Langs, q.Position, q.IsActive)
+	return err
+}
+
+func (r *SkillRepo) UpdateRequirement(ctx context.Context, q *domain.SkillRequirement) error {
+	ct, err := r.pool.Exec(ctx,
+		`UPDATE skill_requirements SET
+		   skill_id = $2, min_level = $3, segments = $4, ticket_types = $5, langs = $6, position = $7, is_active = $8
+		 WHERE id = $1`,
+		q.ID, q.SkillID, q.MinLevel, q.Segments, q.TicketTypes, q.Langs, q.Position, q.IsActive)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+type StarReportRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewStarReportRepo(pool *pgxpool.Pool) *StarReportRepo {
+	return &StarReportRepo{pool: pool}
+}
+
+const starReportColumns = `id, name, question, sql, chart_type, x_label, y_label, schedule, delivery_format, delivery_target,
+	default_params, last_run_at, last_error, created_at, updated_at`
+
+func scanStarReport(row pgx.Row) (*domain.StarReport, error) {
+	var r domain.StarReport
+	err := row.Scan(&r.ID, &r.Name, &r.Question, &r.SQL, &r.ChartType, &r.XLabel, &r.YLabel, &r.Schedule, &r.DeliveryFormat, &r.DeliveryTarget,
+		&r.DefaultParams, &r.LastRunAt, &r.LastError, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (r *StarReportRepo) Insert(ctx context.Context, rep *domain.StarReport) error {
+	row := r.pool.QueryRow(ctx,
+		`INSERT INTO star_reports (id, name, question, sql, chart_type, x_label, y_label, schedule, delivery_format, delivery_target, default_params)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING created_at, updated_at`,
+		rep.ID, rep.Name, rep.Question, rep.SQL, rep.ChartType, rep.XLabel, rep.YLabel, rep.Schedule, rep.DeliveryFormat, rep.DeliveryTarget, rep.DefaultParams,
+	)
+	return row.Scan(&rep.CreatedAt, &rep.UpdatedAt)
+}
+
+func (r *StarReportRepo) Update(ctx context.Context, rep *domain.StarReport) error {
+	row := r.pool.QueryRow(ctx,
+		`UPDATE star_reports SET
+		   name = $2, question = $3, sql = $4, chart_type = $5, x_label = $6, y_label = $7,
+		   schedule = $8, delivery_format = $9, delivery_target = $10, default_params = $11, updated_at = now()
+		 WHERE id = $1
+		 RETURNING updated_at`,
+		rep.ID, rep.Name, rep.Question, rep.SQL, rep.ChartType, rep.XLabel, rep.YLabel, rep.Schedule, rep.DeliveryFormat, rep.DeliveryTarget, rep.DefaultParams,
+	)
+	return row.Scan(&rep.UpdatedAt)
+}
+
+func (r *StarReportRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	ct, err := r.pool.Exec(ctx, `DELETE FROM star_reports WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *StarReportRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.StarReport, error) {
+	return scanStarReport(r.pool.QueryRow(ctx,
+		`SELECT `+starReportColumns+` FROM star_reports WHERE id = $1`, id))
+}
+
+func (r *StarReportRepo) List(ctx context.Context) ([]domain.StarReport, error) {
+	return r.list(ctx, `SELECT `+starReportColumns+` FROM star_reports ORDER BY name`)
+}
+
+func (r *StarReportRepo) ListScheduled(ctx context.Context) ([]domain.StarReport, error) {
+	return r.list(ctx, `SELECT `+starReportColumns+` FROM star_reports WHERE schedule IS NOT NULL ORDER BY name`)
+}
+
+func (r *StarReportRepo) list(ctx context.Context, query string) ([]domain.StarReport, error) {
+	rows, err := r.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []domain.StarReport{}
+	for rows.Next() {
+		rep, err := scanStarReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *rep)
+	}
+	return reports, rows.Err()
+}
+
+// UpdateRunStatus records the outcome of the latest run; runErr is nil on success.
+func (r *StarReportRepo) UpdateRunStatus(ctx context.Context, id uuid.UUID, runErr *string) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE star_reports SET last_run_at = now(), last_error = $2 WHERE id = $1`, id, runErr)
+	return err
+}
@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+type TeamRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewTeamRepo(pool *pgxpool.Pool) *TeamRepo {
+	return &TeamRepo{pool: pool}
+}
+
+const teamColumns = `id, name, business_unit_id, supervisor_id, escalation_team_id, is_active, created_at`
+
+func scanTeam(row pgx.Row) (*domain.Team, error) {
+	var t domain.Team
+	if err := row.Scan(&t.ID, &t.Name, &t.BusinessUnitID, &t.SupervisorID, &t.EscalationTeamID, &t.IsActive, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *TeamRepo) Insert(ctx context.Context, tx pgx.Tx, t *domain.Team) error {
+	return tx.QueryRow(ctx,
+		`INSERT INTO teams (id, name, business_unit_id, supervisor_id, escalation_team_id, is_active)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING created_at`,
+		t.ID, t.Name, t.BusinessUnitID, t.SupervisorID, t.EscalationTeamID, t.IsActive,
+	).Scan(&t.CreatedAt)
+}
+
+// Update writes a team's name, supervisor and escalation team; the office is
+// fixed once created, as the members work there.
+func (r *TeamRepo) Update(ctx context.Context, tx pgx.Tx, t *domain.Team) (*domain.Team, error) {
+	return scanTeam(tx.QueryRow(ctx,
+		`UPDATE teams SET name = $2, supervisor_id = $3, escalation_team_id = $4
+		 WHERE id = $1
+		 RETURNING `+teamColumns,
+		t.ID, t.Name, t.SupervisorID, t.EscalationTeamID))
+}
+
+func (r *TeamRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Team, error) {
+	return scanTeam(r.pool.QueryRow(ctx, `SELECT `+teamColumns+` FROM teams WHERE id = $1`, id))
+}
+
+// GetTx returns a team within tx.
+func (r *TeamRepo) GetTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*domain.Team, error) {
+	return scanTeam(tx.QueryRow(ctx, `SELECT `+teamColumns+` FROM teams WHERE id = $1`, id))
+}
+
+// GetForUpdate returns a team, locking it for the rest of tx.
+func (r *TeamRepo) GetForUpdate(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*domain.Team, error) {
+	return scanTeam(tx.QueryRow(ctx, `SELECT `+teamColumns+` FROM teams WHERE id = $1 FOR UPDATE`, id))
+}
+
+func (r *TeamRepo) SetActive(ctx context.Context, tx pgx.Tx, id uuid.UUID, active bool) (*domain.Team, error) {
+	return scanTeam(tx.QueryRow(ctx,
+		`UPDATE teams SET is_active = $2 WHERE id = $1 RETURNING `+teamColumns, id, active))
+}
+
+// List returns the teams, optionally of one office or supervisor, ordered by
+// office and name.
+func (r *TeamRepo) List(ctx context.Context, officeID, supervisorID *uuid.UUID) ([]domain.TeamWithInfo, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT t.id, t.name, t.business_unit_id, t.supervisor_id, t.escalation_team_id, t.is_active, t.created_at,
+		        bu.name, s.full_name,
+		        (SELECT COUNT(*) FROM managers m WHERE m.team_id = t.id)
+		 FROM teams t
+		 JOIN business_units bu ON bu.id = t.business_unit_id
+		 LEFT JOIN managers s ON s.id = t.supervisor_id
+		 WHERE ($1::uuid IS NULL OR t.business_unit_id = $1)
+		   AND ($2::uuid IS NULL OR t.supervisor_id = $2)
+		 ORDER BY bu.name, t.name`, officeID, supervisorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []domain.TeamWithInfo{}
+	for rows.Next() {
+		var t domain.TeamWithInfo
+		if err := rows.Scan(&t.ID, &t.Name, &t.BusinessUnitID, &t.SupervisorID, &t.EscalationTeamID, &t.IsActive, &t.CreatedAt,
+			&t.OfficeName, &t.SupervisorName, &t.Members); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	return teams, rows.Err()
+}
+
+// SetMembers makes managerIDs the members of a team within tx: managers not
+// listed leave it, listed managers leave their previous team.
+func (r *TeamRepo) SetMembers(ctx context.Context, tx pgx.Tx, teamID uuid.UUID, managerIDs []uuid.UUID) error {
+	if _, err := tx.Exec(ctx,
+		`UPDATE managers SET team_id = NULL WHERE team_id = $1 AND NOT id = ANY($2)`, teamID, managerIDs); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `UPDATE managers SET team_id = $1 WHERE id = ANY($2)`, teamID, managerIDs)
+	return err
+}
+
+// MemberIDs returns the members of a team within tx, by name.
+func (r *TeamRepo) MemberIDs(ctx context.Context, tx pgx.Tx, teamID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := tx.Query(ctx, `SELECT id FROM managers WHERE team_id = $1 ORDER BY full_name`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// openTicketsSLA selects the open (assigned, not resolved or closed) tickets
+// with their SLA deadline and state. $2–$4 are the SLA hours by segment and
+// the default hours.
+const openTicketsSLA = `SELECT t.id, t.external_id, t.subject, t.body, t.client_name, t.client_segment, t.source_channel, t.status, t.raw_address, t.attachments, t.client_id, t.client_guid, t.thread_id, t.duplicate_of, t.similarity, t.created_at, t.updated_at,
+	        a.manager_id, a.office_id, ai.priority_1_10,
+	        t.created_at + make_interval(hours => sla.hours) AS sla_deadline,
+	        CASE WHEN now() >= t.created_at + make_interval(hours => sla.hours) THEN 'overdue'
+	             WHEN now() >= t.created_at + make_interval(hours => sla.hours) * 0.75 THEN 'at_risk'
+	             ELSE 'ok' END AS sla_state
+	 FROM tickets t
+	 JOIN ticket_assignment a ON a.ticket_id = t.id AND a.is_current = true
+	 LEFT JOIN ticket_ai ai ON ai.ticket_id = t.id
+	 CROSS JOIN LATERAL (
+	   SELECT COALESCE((SELECT s.hours FROM unnest($2::text[], $3::int[]) AS s(segment, hours) WHERE s.segment = t.client_segment), $4::int) AS hours
+	 ) sla
+	 WHERE t.status NOT IN ('resolved', 'closed')`
+
+// Members returns a team's members, by name, with the count of their open
+// tickets by progress and SLA state.
+func (r *TeamRepo) Members(ctx context.Context, teamID uuid.UUID, sc domain.QueueScoring) ([]domain.TeamMember, error) {
+	segments, hours := slaArrays(sc)
+	rows, err := r.pool.Query(ctx,
+		`SELECT m.id, m.full_name, m.email, m.business_unit_id, m.is_vip_skill, m.is_chief_spec, m.languages,
+		        m.max_load, m.current_load, m.is_active, m.team_id, m.created_at,
+		        COUNT(o.id),
+		        COUNT(o.id) FILTER (WHERE o.status = 'in_progress'),
+		        COUNT(o.id) FILTER (WHERE o.sla_state = 'at_risk'),
+		        COUNT(o.id) FILTER (WHERE o.sla_state = 'overdue')
+		 FROM managers m
+		 LEFT JOIN (`+openTicketsSLA+`) o ON o.manager_id = m.id
+		 WHERE m.team_id = $1
+		 GROUP BY m.id
+		 ORDER BY m.full_name`, teamID, segments, hours, sc.DefaultSLAHours)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []domain.TeamMember{}
+	for rows.Next() {
+		var m domain.TeamMember
+		if err := rows.Scan(&m.ID, &m.FullName, &m.Email, &m.BusinessUnitID, &m.IsVIPSkill, &m.IsChiefSpec, &m.Languages,
+			&m.MaxLoad, &m.CurrentLoad, &m.IsActive, &m.TeamID, &m.CreatedAt,
+			&m.Open, &m.InProgress, &m.AtRisk, &m.Overdue); err != nil {
+			return nil, err
+		}
+		if m.MaxLoad > 0 {
+			m.Utilization = float64(m.CurrentLoad) / float64(m.MaxLoad) * 100
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// Tickets returns up to limit open tickets of a team's members and its
+// supervisor, nearest SLA deadline first; slaState, when not empty, keeps
+// only tickets in that state.
+func (r *TeamRepo) Tickets(ctx context.Context, teamID uuid.UUID, sc domain.QueueScoring, slaState string, limit int) ([]domain.TeamTicket, error) {
+	segments, hours := slaArrays(sc)
+	rows, err := r.pool.Query(ctx,
+		`SELECT o.*, m.full_name, (SELECT COUNT(*) FROM ticket_escalations e WHERE e.ticket_id = o.id)
+		 FROM (`+openTicketsSLA+`) o
+		 JOIN managers m ON m.id = o.manager_id
+		 WHERE (m.team_id = $1 OR m.id = (SELECT supervisor_id FROM teams WHERE id = $1))
+		   AND ($5 = '' OR o.sla_state = $5)
+		 ORDER BY o.sla_deadline, o.id
+		 LIMIT $6`, teamID, segments, hours, sc.DefaultSLAHours, slaState, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tickets := []domain.TeamTicket{}
+	for rows.Next() {
+		var tt domain.TeamTicket
+		t := &tt.Ticket
+		if err := rows.Scan(&t.ID, &t.ExternalID, &t.Subject, &t.Body, &t.ClientName, &t.ClientSegment, &t.SourceChannel, &t.Status, &t.RawAddress, &t.Attachments, &t.ClientID, &t.ClientGUID, &t.ThreadID, &t.DuplicateOf, &t.Similarity, &t.CreatedAt, &t.UpdatedAt,
+			&t.ManagerID, &t.OfficeID, &tt.Priority, &tt.SLADeadline, &tt.SLAState, &tt.ManagerName, &tt.Escalations); err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, tt)
+	}
+	return tickets, rows.Err()
+}
+
+// CountEscalatedTo returns how many open tickets a manager holds because
+// they were last escalated to them.
+func (r *TeamRepo) CountEscalatedTo(ctx context.Context, managerID uuid.UUID) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx,
+		`SELECT COUNT(*)
+		 FROM ticket_assignment a
+		 JOIN tickets t ON t.id = a.ticket_id
+		 WHERE a.manager_id = $1 AND a.is_current = true AND t.status NOT IN ('resolved', 'closed')
+		   AND (SELECT e.to_manager_id FROM ticket_escalations e
+		        WHERE e.ticket_id = t.id ORDER BY e.created_at DESC LIMIT 1) = $1`, managerID).Scan(&n)
+	return n, err
+}
+
+// ── Escalations ──
+
+const escalationColumns = `id, ticket_id, from_manager_id, to_manager_id, team_id, target, level, reason, actor, created_at`
+
+// InsertEscalation records an escalation within tx, numbering it after the
+// ticket's earlier ones.
+func (r *TeamRepo) InsertEscalation(ctx context.Context, tx pgx.Tx, e *domain.Escalation) error {
+	return tx.QueryRow(ctx,
+		`INSERT INTO ticket_escalations (id, ticket_id, from_manager_id, to_manager_id, team_id, target, level, reason, actor)
+		 VALUES ($1, $2, $3, $4, $5, $6, (SELECT COUNT(*) + 1 FROM ticket_escalations WHERE ticket_id = $2), $7, $8)
+		 RETURNING level, created_at`,
+		e.ID, e.TicketID, e.FromManagerID, e.ToManagerID, e.TeamID, e.Target, e.Reason, e.Actor,
+	).Scan(&e.Level, &e.CreatedAt)
+}
+
+// Escalations returns a ticket's escalations, oldest first.
+func (r *TeamRepo) Escalations(ctx context.Context, ticketID uuid.UUID) ([]domain.Escalation, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT `+escalationColumns+` FROM ticket_escalations WHERE ticket_id = $1 ORDER BY created_at, level`, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	escalations := []domain.Escalation{}
+	for rows.Next() {
+		var e domain.Escalation
+		if err := rows.Scan(&e.ID, &e.TicketID, &e.FromManagerID, &e.ToManagerID, &e.TeamID, &e.Target, &e.Level, &e.Reason, &e.Actor, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		escalations = append(escalations, e)
+	}
+	return escalations, rows.Err()
+}
@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -22,56 +24,178 @@ func NewTicketRepo(pool *pgxpool.Pool) *TicketRepo {
 
 func (r *TicketRepo) Insert(ctx context.Context, t *domain.Ticket) error {
 	_, err := r.pool.Exec(ctx,
-		`INSERT INTO tickets (id, external_id, subject, body, client_name, client_segment, source_channel, status, raw_address, attachments)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
-		t.ID, t.ExternalID, t.Subject, t.Body, t.ClientName, t.ClientSegment, t.SourceChannel, t.Status, t.RawAddress, t.Attachments,
+		`INSERT INTO tickets (id, external_id, subject, body, client_name, client_segment, source_channel, status, raw_address, attachments, client_guid, client_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		t.ID, t.ExternalID, t.Subject, t.Body, t.ClientName, t.ClientSegment, t.SourceChannel, t.Status, t.RawAddress, t.Attachments, t.ClientGUID, t.ClientID,
 	)
 	return err
 }
 
-func (r *TicketRepo) BulkInsert(ctx context.Context, tickets []domain.Ticket) ([]uuid.UUID, error) {
-	batch := &pgx.Batch{}
-	for _, t := range tickets {
-		batch.Queue(
-			`INSERT INTO tickets (id, external_id, subject, body, client_name, client_segment, source_channel, status, raw_address, attachments)
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-			 ON CONFLICT (external_id) DO UPDATE SET
-			   subject = EXCLUDED.subject,
-			   body = EXCLUDED.body,
-			   client_name = EXCLUDED.client_name,
-			   client_segment = EXCLUDED.client_segment,
-			   source_channel = EXCLUDED.source_channel,
-			   raw_address = EXCLUDED.raw_address,
-			   attachments = EXCLUDED.attachments
-			 RETURNING id`,
-			t.ID, t.ExternalID, t.Subject, t.Body, t.ClientName, t.ClientSegment, t.SourceChannel, t.Status, t.RawAddress, t.Attachments,
-		)
-	}
-	br := r.pool.SendBatch(ctx, batch)
-	defer br.Close()
+// ticketStagingColumns are the columns COPY fills in ticket_import_staging.
+var ticketStagingColumns = []string{
+	"line", "id", "external_id", "subject", "body", "client_name", "client_segment", "source_channel",
+	"status", "raw_address", "attachments", "client_guid", "client_id",
+}
 
-	ids := make([]uuid.UUID, 0, len(tickets))
-	for range tickets {
+// CopyMerge loads a chunk of imported tickets with COPY into a temporary
+// staging table and merges it into tickets, upserting on external_id (the
+// last row wins when a chunk repeats an external_id). Merged tickets are
+// stamped with importID, and each insert or overwrite is logged to
+// import_changes with the ticket's previous values. It returns the ids of
+// the merged tickets in the order they first appear in the chunk.
+func (r *TicketRepo) CopyMerge(ctx context.Context, tx pgx.Tx, importID uuid.UUID, tickets []domain.Ticket) ([]uuid.UUID, error) {
+	if _, err := tx.Exec(ctx,
+		`CREATE TEMP TABLE ticket_import_staging (
+		   line INT, id UUID, external_id TEXT, subject TEXT, body TEXT, client_name TEXT, client_segment TEXT,
+		   source_channel TEXT, status TEXT, raw_address TEXT, attachments TEXT, client_guid TEXT, client_id UUID
+		 ) ON COMMIT DROP`); err != nil {
+		return nil, fmt.Errorf("create staging table: %w", err)
+	}
+
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"ticket_import_staging"}, ticketStagingColumns,
+		pgx.CopyFromSlice(len(tickets), func(i int) ([]any, error) {
+			t := tickets[i]
+			return []any{i, t.ID, t.ExternalID, t.Subject, t.Body, t.ClientName, t.ClientSegment, t.SourceChannel,
+				t.Status, t.RawAddress, t.Attachments, t.ClientGUID, t.ClientID}, nil
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("copy to staging: %w", err)
+	}
+
+	// All parts of the statement see the tickets as they were before it, so
+	// "before" holds the values the merge overwrites.
+	rows, err := tx.Query(ctx,
+		`WITH src AS (
+		   SELECT DISTINCT ON (COALESCE(external_id, id::text))
+		          id, external_id, subject, body, client_name, client_segment, source_channel, status, raw_address, attachments, client_guid, client_id
+		   FROM ticket_import_staging
+		   ORDER BY COALESCE(external_id, id::text), line DESC
+		 ), before AS (
+		   SELECT t.* FROM tickets t JOIN src ON src.external_id = t.external_id
+		 ), merged AS (
+		   INSERT INTO tickets (id, external_id, subject, body, client_name, client_segment, source_channel, status, raw_address, attachments, client_guid, client_id, import_id)
+		   SELECT id, external_id, subject, body, client_name, client_segment, source_channel, status, raw_address, attachments, client_guid, client_id, $1
+		   FROM src
+		   ON CONFLICT (external_id) DO UPDATE SET
+		     client_guid = EXCLUDED.client_guid,
+		     client_id = EXCLUDED.client_id,
+		     subject = EXCLUDED.subject,
+		     body = EXCLUDED.body,
+		     client_name = EXCLUDED.client_name,
+		     client_segment = EXCLUDED.client_segment,
+		     source_channel = EXCLUDED.source_channel,
+		     raw_address = EXCLUDED.raw_address,
+		     attachments = EXCLUDED.attachments,
+		     import_id = EXCLUDED.import_id
+		   RETURNING id, external_id
+		 ), changes AS (
+		   INSERT INTO import_changes (import_id, entity, row_id, action, before)
+		   SELECT $1, 'tickets', m.id, CASE WHEN b.id IS NULL THEN 'insert' ELSE 'update' END, to_jsonb(b)
+		   FROM merged m LEFT JOIN before b ON b.id = m.id
+		 )
+		 SELECT id, external_id FROM merged`, importID)
+	if err != nil {
+		return nil, fmt.Errorf("merge staging: %w", err)
+	}
+	defer rows.Close()
+
+	byExternalID := map[string]uuid.UUID{}
+	for rows.Next() {
 		var id uuid.UUID
-		err := br.QueryRow().Scan(&id)
-		if err != nil {
-			return ids, err
+		var externalID *string
+		if err := rows.Scan(&id, &externalID); err != nil {
+			return nil, err
+		}
+		if externalID != nil {
+			byExternalID[*externalID] = id
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(tickets))
+	seen := make(map[uuid.UUID]bool, len(tickets))
+	for _, t := range tickets {
+		id := t.ID
+		if t.ExternalID != nil {
+			id = byExternalID[*t.ExternalID]
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
 		}
-		ids = append(ids, id)
 	}
 	return ids, nil
 }
 
+// DeleteImported deletes tickets along with their routing: the current
+// assignments are released from their managers' load, and the assignments
+// and audit trail are removed (AI results and status history cascade).
+func (r *TicketRepo) DeleteImported(ctx context.Context, tx pgx.Tx, ids []uuid.UUID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE managers m SET current_load = GREATEST(m.current_load - a.n, 0)
+		 FROM (
+		   SELECT manager_id, COUNT(*) AS n FROM ticket_assignment
+		   WHERE ticket_id = ANY($1) AND is_current = true
+		   GROUP BY manager_id
+		 ) a
+		 WHERE m.id = a.manager_id`, ids); err != nil {
+		return 0, fmt.Errorf("release manager load: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM ticket_assignment WHERE ticket_id = ANY($1)`, ids); err != nil {
+		return 0, fmt.Errorf("delete assignments: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM audit_log WHERE ticket_id = ANY($1)`, ids); err != nil {
+		return 0, fmt.Errorf("delete audit log: %w", err)
+	}
+	ct, err := tx.Exec(ctx, `DELETE FROM tickets WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return 0, err
+	}
+	return int(ct.RowsAffected()), nil
+}
+
+// ExistingExternalIDs returns which of the given external ids already belong to a ticket.
+func (r *TicketRepo) ExistingExternalIDs(ctx context.Context, externalIDs []string) (map[string]bool, error) {
+	return existingKeys(ctx, r.pool, `SELECT external_id FROM tickets WHERE external_id = ANY($1)`, externalIDs)
+}
+
+// existingKeys runs query, which selects one text column filtered by
+// "= ANY($1)", and returns the keys it found.
+func existingKeys(ctx context.Context, pool *pgxpool.Pool, query string, keys []string) (map[string]bool, error) {
+	found := make(map[string]bool)
+	if len(keys) == 0 {
+		return found, nil
+	}
+	rows, err := pool.Query(ctx, query, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		found[key] = true
+	}
+	return found, rows.Err()
+}
+
 func (r *TicketRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Ticket, error) {
 	row := r.pool.QueryRow(ctx,
-		`SELECT t.id, t.external_id, t.subject, t.body, t.client_name, t.client_segment, t.source_channel, t.status, t.raw_address, t.attachments, t.created_at, t.updated_at,
+		`SELECT t.id, t.external_id, t.subject, t.body, t.client_name, t.client_segment, t.source_channel, t.status, t.raw_address, t.attachments, t.client_id, t.client_guid, t.thread_id, t.duplicate_of, t.similarity, t.created_at, t.updated_at,
 		        a.manager_id, a.office_id
 		 FROM tickets t
 		 LEFT JOIN ticket_assignment a ON a.ticket_id = t.id AND a.is_current = true
 		 WHERE t.id = $1`, id)
 
 	var t domain.Ticket
-	err := row.Scan(&t.ID, &t.ExternalID, &t.Subject, &t.Body, &t.ClientName, &t.ClientSegment, &t.SourceChannel, &t.Status, &t.RawAddress, &t.Attachments, &t.CreatedAt, &t.UpdatedAt,
+	err := row.Scan(&t.ID, &t.ExternalID, &t.Subject, &t.Body, &t.ClientName, &t.ClientSegment, &t.SourceChannel, &t.Status, &t.RawAddress, &t.Attachments, &t.ClientID, &t.ClientGUID, &t.ThreadID, &t.DuplicateOf, &t.Similarity, &t.CreatedAt, &t.UpdatedAt,
 		&t.ManagerID, &t.OfficeID)
 	if err != nil {
 		return nil, err
@@ -135,7 +259,7 @@ func (r *TicketRepo) List(ctx context.Context, f domain.TicketListFilter) ([]dom
 	offset := (f.Page - 1) * f.PerPage
 
 	query := fmt.Sprintf(
-		`SELECT t.id, t.external_id, t.subject, t.body, t.client_name, t.client_segment, t.source_channel, t.status, t.raw_address, t.attachments, t.created_at, t.updated_at,
+		`SELECT t.id, t.external_id, t.subject, t.body, t.client_name, t.client_segment, t.source_channel, t.status, t.raw_address, t.attachments, t.client_id, t.client_guid, t.thread_id, t.duplicate_of, t.similarity, t.created_at, t.updated_at,
 		        a.manager_id, a.office_id
 		 FROM tickets t
 		 %s
@@ -153,7 +277,7 @@ func (r *TicketRepo) List(ctx context.Context, f domain.TicketListFilter) ([]dom
 	tickets := []domain.Ticket{}
 	for rows.Next() {
 		var t domain.Ticket
-		if err := rows.Scan(&t.ID, &t.ExternalID, &t.Subject, &t.Body, &t.ClientName, &t.ClientSegment, &t.SourceChannel, &t.Status, &t.RawAddress, &t.Attachments, &t.CreatedAt, &t.UpdatedAt,
+		if err := rows.Scan(&t.ID, &t.ExternalID, &t.Subject, &t.Body, &t.ClientName, &t.ClientSegment, &t.SourceChannel, &t.Status, &t.RawAddress, &t.Attachments, &t.ClientID, &t.ClientGUID, &t.ThreadID, &t.DuplicateOf, &t.Similarity, &t.CreatedAt, &t.UpdatedAt,
 			&t.ManagerID, &t.OfficeID); err != nil {
 			return nil, 0, err
 		}
@@ -165,7 +289,7 @@ func (r *TicketRepo) List(ctx context.Context, f domain.TicketListFilter) ([]dom
 
 func (r *TicketRepo) ListByManager(ctx context.Context, managerID uuid.UUID) ([]domain.Ticket, error) {
 	rows, err := r.pool.Query(ctx,
-		`SELECT t.id, t.external_id, t.subject, t.body, t.client_name, t.client_segment, t.source_channel, t.status, t.raw_address, t.attachments, t.created_at, t.updated_at,
+		`SELECT t.id, t.external_id, t.subject, t.body, t.client_name, t.client_segment, t.source_channel, t.status, t.raw_address, t.attachments, t.client_id, t.client_guid, t.thread_id, t.duplicate_of, t.similarity, t.created_at, t.updated_at,
 		        ta.manager_id, ta.office_id
 		 FROM tickets t
 		 JOIN ticket_assignment ta ON ta.ticket_id = t.id AND ta.is_current = true
@@ -180,7 +304,7 @@ func (r *TicketRepo) ListByManager(ctx context.Context, managerID uuid.UUID) ([]
 	tickets := []domain.Ticket{}
 	for rows.Next() {
 		var t domain.Ticket
-		if err := rows.Scan(&t.ID, &t.ExternalID, &t.Subject, &t.Body, &t.ClientName, &t.ClientSegment, &t.SourceChannel, &t.Status, &t.RawAddress, &t.Attachments, &t.CreatedAt, &t.UpdatedAt,
+		if err := rows.Scan(&t.ID, &t.ExternalID, &t.Subject, &t.Body, &t.ClientName, &t.ClientSegment, &t.SourceChannel, &t.Status, &t.RawAddress, &t.Attachments, &t.ClientID, &t.ClientGUID, &t.ThreadID, &t.DuplicateOf, &t.Similarity, &t.CreatedAt, &t.UpdatedAt,
 			&t.ManagerID, &t.OfficeID); err != nil {
 			return nil, err
 		}
@@ -189,6 +313,242 @@ func (r *TicketRepo) ListByManager(ctx context.Context, managerID uuid.UUID) ([]
 	return tickets, nil
 }
 
+// ListByIDs returns the given tickets, oldest first.
+func (r *TicketRepo) ListByIDs(ctx context.Context, ids []uuid.UUID) ([]domain.Ticket, error) {
+	return r.listWhere(ctx, `t.id = ANY($1)`, `t.created_at, t.id`, ids)
+}
+
+// ListByClient returns a client's tickets created in [from, to], oldest first.
+func (r *TicketRepo) ListByClient(ctx context.Context, clientGUID string, from, to time.Time) ([]domain.Ticket, error) {
+	return r.listWhere(ctx, `t.client_guid = $1 AND t.created_at BETWEEN $2 AND $3`, `t.created_at, t.id`, clientGUID, from, to)
+}
+
+// ListByClientID returns all tickets of a client, newest first.
+func (r *TicketRepo) ListByClientID(ctx context.Context, clientID uuid.UUID) ([]domain.Ticket, error) {
+	return r.listWhere(ctx, `t.client_id = $1`, `t.created_at DESC, t.id`, clientID)
+}
+
+// ListThread returns every ticket of a thread, oldest first.
+func (r *TicketRepo) ListThread(ctx context.Context, threadID uuid.UUID) ([]domain.Ticket, error) {
+	return r.listWhere(ctx, `t.thread_id = $1`, `t.created_at, t.id`, threadID)
+}
+
+// ListForReplay returns up to limit of the latest enriched tickets created in
+// [from, to], oldest first; nil bounds are open.
+func (r *TicketRepo) ListForReplay(ctx context.Context, from, to *time.Time, limit int) ([]domain.Ticket, error) {
+	tickets, err := r.listWhere(ctx,
+		`EXISTS (SELECT 1 FROM ticket_ai ai WHERE ai.ticket_id = t.id)
+		   AND ($1::timestamptz IS NULL OR t.created_at >= $1)
+		   AND ($2::timestamptz IS NULL OR t.created_at <= $2)`,
+		`t.created_at DESC, t.id DESC LIMIT $3`, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(tickets)
+	return tickets, nil
+}
+
+// ListOpenForRebalance returns up to limit open (assigned, not resolved or
+// closed) tickets matching f, oldest first.
+func (r *TicketRepo) ListOpenForRebalance(ctx context.Context, f domain.RebalanceFilter, limit int) ([]domain.Ticket, error) {
+	conditions := []string{`a.manager_id IS NOT NULL`, `t.status NOT IN ('resolved', 'closed')`}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(f.ManagerIDs) > 0 {
+		conditions = append(conditions, "a.manager_id = ANY("+arg(f.ManagerIDs)+")")
+	}
+	if len(f.OfficeIDs) > 0 {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM managers m WHERE m.id = a.manager_id AND m.business_unit_id = ANY("+arg(f.OfficeIDs)+"))")
+	}
+	if f.MinAgeHours > 0 {
+		conditions = append(conditions, "t.created_at <= now() - make_interval(hours => "+arg(f.MinAgeHours)+")")
+	}
+	if f.MinPriority != nil {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM ticket_ai ai WHERE ai.ticket_id = t.id AND ai.priority_1_10 >= "+arg(*f.MinPriority)+")")
+	}
+	if f.MaxPriority != nil {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM ticket_ai ai WHERE ai.ticket_id = t.id AND ai.priority_1_10 <= "+arg(*f.MaxPriority)+")")
+	}
+
+	return r.listWhere(ctx, strings.Join(conditions, " AND "), "t.created_at, t.id LIMIT "+arg(limit), args...)
+}
+
+func (r *TicketRepo) listWhere(ctx context.Context, where, orderBy string, args ...interface{}) ([]domain.Ticket, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT t.id, t.external_id, t.subject, t.body, t.client_name, t.client_segment, t.source_channel, t.status, t.raw_address, t.attachments, t.client_id, t.client_guid, t.thread_id, t.duplicate_of, t.similarity, t.created_at, t.updated_at,
+		        a.manager_id, a.office_id
+		 FROM tickets t
+		 LEFT JOIN ticket_assignment a ON a.ticket_id = t.id AND a.is_current = true
+		 WHERE `+where+`
+		 ORDER BY `+orderBy, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tickets := []domain.Ticket{}
+	for rows.Next() {
+		var t domain.Ticket
+		if err := rows.Scan(&t.ID, &t.ExternalID, &t.Subject, &t.Body, &t.ClientName, &t.ClientSegment, &t.SourceChannel, &t.Status, &t.RawAddress, &t.Attachments, &t.ClientID, &t.ClientGUID, &t.ThreadID, &t.DuplicateOf, &t.Similarity, &t.CreatedAt, &t.UpdatedAt,
+			&t.ManagerID, &t.OfficeID); err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets, rows.Err()
+}
+
+// queueSelect is the work queue of manager $1: their tickets waiting to be
+// taken ("routed") and, when $2, unassigned enriched tickets for which they
+// meet every matching skill requirement. $3–$11 are the QueueScoring values.
+const queueSelect = `SELECT t.id, t.external_id, t.subject, t.body, t.client_name, t.client_segment, t.source_channel, t.status, t.raw_address, t.attachments, t.client_id, t.client_guid, t.thread_id, t.duplicate_of, t.similarity, t.created_at, t.updated_at,
+	        a.manager_id, a.office_id,
+	        ai.priority_1_10, t.created_at + make_interval(hours => sla.hours), a.manager_id IS NULL,
+	        $3::float8 * COALESCE(ai.priority_1_10, 5) / 10.0
+	        + $4::float8 * LEAST(EXTRACT(EPOCH FROM now() - t.created_at) / (sla.hours * 3600.0), 2)
+	        + $5::float8 * COALESCE((SELECT w.weight FROM unnest($9::text[], $10::float8[]) AS w(segment, weight) WHERE w.segment = t.client_segment), 0)
+	        + $6::float8 * LEAST(EXTRACT(EPOCH FROM now() - t.created_at) / 604800.0, 1) AS score
+	 FROM tickets t
+	 JOIN ticket_ai ai ON ai.ticket_id = t.id
+	 LEFT JOIN ticket_assignment a ON a.ticket_id = t.id AND a.is_current = true
+	 CROSS JOIN LATERAL (
+	   SELECT COALESCE((SELECT s.hours FROM unnest($7::text[], $8::int[]) AS s(segment, hours) WHERE s.segment = t.client_segment), $11::int) AS hours
+	 ) sla
+	 WHERE t.id <> ALL(COALESCE($12::uuid[], '{}'))
+	   AND ((a.manager_id = $1 AND t.status = 'routed')
+	    OR ($2::bool AND a.manager_id IS NULL AND t.status = 'enriched' AND NOT EXISTS (
+	          SELECT 1 FROM skill_requirements r
+	          WHERE r.is_active
+	            AND (cardinality(r.segments) = 0 OR COALESCE(t.client_segment, '') = ANY(r.segments))
+	            AND (cardinality(r.ticket_types) = 0 OR COALESCE(ai.type, '') = ANY(r.ticket_types))
+	            AND (cardinality(r.langs) = 0 OR ai.lang = ANY(r.langs))
+	            AND NOT EXISTS (
+	              SELECT 1 FROM manager_skills ms
+	              WHERE ms.manager_id = $1 AND ms.skill_id = r.skill_id AND ms.level >= r.min_level))))
+	 ORDER BY score DESC, t.created_at, t.id`
+
+func queueArgs(managerID uuid.UUID, withPool bool, sc domain.QueueScoring, exclude []uuid.UUID) []interface{} {
+	slaSegments, slaHours := slaArrays(sc)
+	var weightSegments []string
+	var weights []float64
+	for seg, w := range sc.SegmentWeights {
+		weightSegments, weights = append(weightSegments, seg), append(weights, w)
+	}
+	return []interface{}{managerID, withPool, sc.Priority, sc.SLA, sc.Segment, sc.Age,
+		slaSegments, slaHours, weightSegments, weights, sc.DefaultSLAHours, exclude}
+}
+
+// slaArrays returns the SLA hours by segment as parallel arrays for unnest.
+func slaArrays(sc domain.QueueScoring) ([]string, []int) {
+	segments, hours := []string{}, []int{}
+	for seg, h := range sc.SLAHours {
+		segments, hours = append(segments, seg), append(hours, h)
+	}
+	return segments, hours
+}
+
+func scanQueueItem(row pgx.Row) (*domain.QueueItem, error) {
+	var q domain.QueueItem
+	t := &q.Ticket
+	err := row.Scan(&t.ID, &t.ExternalID, &t.Subject, &t.Body, &t.ClientName, &t.ClientSegment, &t.SourceChannel, &t.Status, &t.RawAddress, &t.Attachments, &t.ClientID, &t.ClientGUID, &t.ThreadID, &t.DuplicateOf, &t.Similarity, &t.CreatedAt, &t.UpdatedAt,
+		&t.ManagerID, &t.OfficeID, &q.Priority, &q.SLADeadline, &q.Pool, &q.Score)
+	if err != nil {
+		return nil, err
+	}
+	q.Overdue = time.Now().After(q.SLADeadline)
+	return &q, nil
+}
+
+// Queue returns the first limit tickets of a manager's work queue, best first.
+func (r *TicketRepo) Queue(ctx context.Context, managerID uuid.UUID, withPool bool, sc domain.QueueScoring, limit int) ([]domain.QueueItem, error) {
+	rows, err := r.pool.Query(ctx, queueSelect+` LIMIT $13`, append(queueArgs(managerID, withPool, sc, nil), limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []domain.QueueItem{}
+	for rows.Next() {
+		q, err := scanQueueItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *q)
+	}
+	return items, rows.Err()
+}
+
+// LockNextInQueue locks and returns the best ticket of a manager's work
+// queue, within tx, skipping tickets other transactions hold, so concurrent
+// pulls never get the same ticket, and the tickets in exclude, which tx may
+// already hold. It returns pgx.ErrNoRows if the queue is empty.
+func (r *TicketRepo) LockNextInQueue(ctx context.Context, tx pgx.Tx, managerID uuid.UUID, withPool bool, sc domain.QueueScoring, exclude []uuid.UUID) (*domain.QueueItem, error) {
+	return scanQueueItem(tx.QueryRow(ctx, queueSelect+` LIMIT 1 FOR UPDATE OF t SKIP LOCKED`, queueArgs(managerID, withPool, sc, exclude)...))
+}
+
+// LinkThread puts a ticket into threadID and opens the thread on its root
+// ticket if this is the first link. duplicateOf is nil for merely related tickets.
+func (r *TicketRepo) LinkThread(ctx context.Context, id, threadID uuid.UUID, duplicateOf *uuid.UUID, similarity float64) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE tickets SET
+		   thread_id = $2,
+		   duplicate_of = CASE WHEN id = $1 THEN $3::uuid ELSE duplicate_of END,
+		   similarity = CASE WHEN id = $1 THEN $4::float8 ELSE similarity END
+		 WHERE id = $1 OR (id = $2 AND thread_id IS NULL)`,
+		id, threadID, duplicateOf, similarity)
+	return err
+}
+
+// LockStatus locks a ticket for the rest of tx and returns its status.
+func (r *TicketRepo) LockStatus(ctx context.Context, tx pgx.Tx, id uuid.UUID) (string, error) {
+	var status string
+	err := tx.QueryRow(ctx, `SELECT status FROM tickets WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	return status, err
+}
+
+// Unqualified returns the tickets of ids for which a manager misses the skill
+// of a matching active requirement, the same check that lets them pull a
+// ticket from the pool.
+func (r *TicketRepo) Unqualified(ctx context.Context, tx pgx.Tx, managerID uuid.UUID, ids []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT t.id FROM tickets t
+		 LEFT JOIN ticket_ai ai ON ai.ticket_id = t.id
+		 WHERE t.id = ANY($2) AND EXISTS (
+		   SELECT 1 FROM skill_requirements r
+		   WHERE r.is_active
+		     AND (cardinality(r.segments) = 0 OR COALESCE(t.client_segment, '') = ANY(r.segments))
+		     AND (cardinality(r.ticket_types) = 0 OR COALESCE(ai.type, '') = ANY(r.ticket_types))
+		     AND (cardinality(r.langs) = 0 OR COALESCE(ai.lang, '') = ANY(r.langs))
+		     AND NOT EXISTS (
+		       SELECT 1 FROM manager_skills ms
+		       WHERE ms.manager_id = $1 AND ms.skill_id = r.skill_id AND ms.level >= r.min_level))
+		 ORDER BY t.id`, managerID, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unqualified := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		unqualified = append(unqualified, id)
+	}
+	return unqualified, rows.Err()
+}
+
+// SetStatus changes a ticket's status within tx.
+func (r *TicketRepo) SetStatus(ctx context.Context, tx pgx.Tx, id uuid.UUID, status string) error {
+	_, err := tx.Exec(ctx, `UPDATE tickets SET status = $1, updated_at = now() WHERE id = $2`, status, id)
+	return err
+}
+
 func (r *TicketRepo) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
 	_, err := r.pool.Exec(ctx,
 		`UPDATE tickets SET status = $1, updated_at = now() WHERE id = $2`, status, id)
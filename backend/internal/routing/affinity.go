@@ -0,0 +1,88 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+// Affinity keeps returning clients with the manager who handled them before.
+// windowDays sets, per client segment, how far back to look; segments that
+// are missing or set to 0 skip the stage.
+type Affinity struct {
+	assignmentRepo *repository.AssignmentRepo
+	windowDays     map[string]int
+}
+
+func NewAffinity(ar *repository.AssignmentRepo, windowDays map[string]int) *Affinity {
+	return &Affinity{assignmentRepo: ar, windowDays: windowDays}
+}
+
+type AffinityResult struct {
+	Manager        *domain.Manager // nil when no previous manager qualifies
+	WindowDays     int
+	LastAssignedAt *time.Time
+	Decision       string
+}
+
+// WithWindows returns the stage with other per-segment windows, e.g. for a
+// routing simulation.
+func (a *Affinity) WithWindows(windowDays map[string]int) *Affinity {
+	return &Affinity{assignmentRepo: a.assignmentRepo, windowDays: windowDays}
+}
+
+// Enabled reports whether the affinity stage runs for segment.
+func (a *Affinity) Enabled(segment string) bool {
+	return a.windowDays[segment] > 0
+}
+
+// Prefer returns the candidate who most recently handled another ticket of the
+// same client within the segment's window and still has free capacity.
+// Candidates must already be filtered by skills (and therefore active).
+func (a *Affinity) Prefer(ctx context.Context, ticketID uuid.UUID, segment string, candidates []domain.Manager) (*AffinityResult, error) {
+	days := a.windowDays[segment]
+	result := &AffinityResult{WindowDays: days}
+
+	since := time.Now().AddDate(0, 0, -days)
+	previous, err := a.assignmentRepo.ClientManagersSince(ctx, ticketID, since)
+	if err != nil {
+		return nil, err
+	}
+	if len(previous) == 0 {
+		result.Decision = fmt.Sprintf("No earlier tickets of this client assigned in the last %d days", days)
+		return result, nil
+	}
+
+	byID := make(map[uuid.UUID]domain.Manager, len(candidates))
+	for _, m := range candidates {
+		byID[m.ID] = m
+	}
+
+	var skipped []string
+	for _, p := range previous {
+		m, ok := byID[p.ManagerID]
+		if !ok {
+			skipped = append(skipped, fmt.Sprintf("%s not among skill candidates", p.ManagerID))
+			continue
+		}
+		if m.MaxLoad > 0 && m.CurrentLoad >= m.MaxLoad {
+			skipped = append(skipped, fmt.Sprintf("%s overloaded (%d/%d)", m.FullName, m.CurrentLoad, m.MaxLoad))
+			continue
+		}
+		assignedAt := p.AssignedAt
+		result.Manager = &m
+		result.LastAssignedAt = &assignedAt
+		result.Decision = fmt.Sprintf("Previous manager %s (last assigned %s, window %d days, load %d/%d)",
+			m.FullName, assignedAt.Format("2006-01-02"), days, m.CurrentLoad, m.MaxLoad)
+		return result, nil
+	}
+
+	result.Decision = "No previous manager qualifies: " + strings.Join(skipped, "; ")
+	return result, nil
+}
@@ -36,7 +36,7 @@ type GeoResult struct {
 }
 
 func (g *GeoFilter) Resolve(ctx context.Context, ticketID uuid.UUID, lat, lon *float64, geoStatus, rawCity string) (*GeoResult, error) {
-	offices, err := g.buRepo.GetAll(ctx)
+	offices, err := g.buRepo.ListActive(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("get offices: %w", err)
 	}
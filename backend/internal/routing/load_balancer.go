@@ -19,6 +19,11 @@ type LoadResult struct {
 }
 
 func (lb *LoadBalancer) PickTwo(candidates []domain.Manager) *LoadResult {
+	return lb.Pick(candidates, 2)
+}
+
+// Pick passes the count least-loaded candidates on to round robin.
+func (lb *LoadBalancer) Pick(candidates []domain.Manager, count int) *LoadResult {
 	if len(candidates) == 0 {
 		return &LoadResult{
 			Decision: "No candidates available",
@@ -32,7 +37,6 @@ func (lb *LoadBalancer) PickTwo(candidates []domain.Manager) *LoadResult {
 		return sorted[i].CurrentLoad < sorted[j].CurrentLoad
 	})
 
-	count := 2
 	if len(sorted) < count {
 		count = len(sorted)
 	}
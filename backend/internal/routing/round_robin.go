@@ -2,6 +2,7 @@ package routing
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -25,11 +26,19 @@ func NewRoundRobin(rr *repository.RRPointerRepo, ar *repository.AssignmentRepo,
 type RRResult struct {
 	SelectedManager domain.Manager
 	Decision        string
+	Existing        bool `json:"-"` // the ticket was already assigned or taken; nothing was changed
 }
 
 // Assign performs the transactional round-robin assignment.
 // Must be called within a transaction.
 func (rr *RoundRobin) Assign(ctx context.Context, tx pgx.Tx, ticketID, buID uuid.UUID, skillGroup string, finalists []domain.Manager, routingReason string) (*RRResult, error) {
+	// Lock the ticket first, so a concurrent queue pull, escalation or
+	// handoff has either committed its assignment or waits for ours.
+	var status string
+	if err := tx.QueryRow(ctx, `SELECT status FROM tickets WHERE id = $1 FOR UPDATE`, ticketID).Scan(&status); err != nil {
+		return nil, fmt.Errorf("lock ticket: %w", err)
+	}
+
 	// Check if already assigned (idempotency for n8n)
 	var existingID uuid.UUID
 	err := tx.QueryRow(ctx, `SELECT manager_id FROM ticket_assignment WHERE ticket_id = $1 AND is_current = true FOR UPDATE`, ticketID).Scan(&existingID)
@@ -38,6 +47,17 @@ func (rr *RoundRobin) Assign(ctx context.Context, tx pgx.Tx, ticketID, buID uuid
 		return &RRResult{
 			SelectedManager: domain.Manager{ID: existingID},
 			Decision:        "Already assigned (reusing existing)",
+			Existing:        true,
+		}, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("check assignment: %w", err)
+	}
+	switch status {
+	case domain.TicketStatusInProgress, "resolved", "closed":
+		return &RRResult{
+			Decision: fmt.Sprintf("Ticket is %s, not routed", status),
+			Existing: true,
 		}, nil
 	}
 
@@ -105,3 +125,35 @@ func (rr *RoundRobin) Assign(ctx context.Context, tx pgx.Tx, ticketID, buID uuid
 		Decision:        fmt.Sprintf("Round-robin index=%d → assigned to %s", nextIdx, selected.FullName),
 	}, nil
 }
+
+// Preview picks the manager Assign would pick, without assigning or advancing
+// the pointer. pointers holds the last index of each bucket ("office/group")
+// as a simulation advances it; buckets not in it start from the stored pointer.
+func (rr *RoundRobin) Preview(ctx context.Context, buID uuid.UUID, skillGroup string, finalists []domain.Manager, pointers map[string]int) (*RRResult, error) {
+	if len(finalists) == 0 {
+		return nil, fmt.Errorf("no finalists for round robin")
+	}
+	if len(finalists) == 1 {
+		return &RRResult{
+			SelectedManager: finalists[0],
+			Decision:        fmt.Sprintf("Single candidate — would assign to %s", finalists[0].FullName),
+		}, nil
+	}
+
+	key := buID.String() + "/" + skillGroup
+	lastIdx, ok := pointers[key]
+	if !ok {
+		var err error
+		if lastIdx, err = rr.rrRepo.Peek(ctx, buID, skillGroup); err != nil {
+			return nil, fmt.Errorf("rr peek: %w", err)
+		}
+	}
+	nextIdx := (lastIdx + 1) % len(finalists)
+	pointers[key] = nextIdx
+
+	selected := finalists[nextIdx]
+	return &RRResult{
+		SelectedManager: selected,
+		Decision:        fmt.Sprintf("Round-robin index=%d → would assign to %s", nextIdx, selected.FullName),
+	}, nil
+}
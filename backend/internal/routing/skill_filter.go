@@ -1,97 +1,132 @@
 package routing
 
 import (
+	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
 
 	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
 )
 
-type SkillFilter struct{}
+type SkillFilter struct {
+	skillRepo *repository.SkillRepo
+}
 
-func NewSkillFilter() *SkillFilter {
-	return &SkillFilter{}
+func NewSkillFilter(skillRepo *repository.SkillRepo) *SkillFilter {
+	return &SkillFilter{skillRepo: skillRepo}
 }
 
 type SkillResult struct {
-	Candidates []domain.Manager
-	SkillGroup string
-	Decision   string
+	Candidates   []domain.Manager
+	SkillGroup   string
+	Decision     string
+	Requirements []domain.SkillRequirement
+}
+
+// Filter applies the active skill requirements matching the ticket.
+func (sf *SkillFilter) Filter(ctx context.Context, managers []domain.Manager, segment, ticketType, lang string) (*SkillResult, error) {
+	reqs, err := sf.skillRepo.MatchingRequirements(ctx, segment, ticketType, lang)
+	if err != nil {
+		return nil, fmt.Errorf("skill requirements: %w", err)
+	}
+	return sf.Apply(ctx, managers, reqs, segment, ticketType, lang)
 }
 
-func (sf *SkillFilter) Filter(managers []domain.Manager, segment, ticketType, lang string) *SkillResult {
+// Apply applies requirements already matched to the ticket, in order. Each
+// keeps the candidates with the skill at the required level or above; a
+// requirement no candidate meets is skipped so the ticket still gets routed.
+func (sf *SkillFilter) Apply(ctx context.Context, managers []domain.Manager, reqs []domain.SkillRequirement, segment, ticketType, lang string) (*SkillResult, error) {
 	candidates := make([]domain.Manager, len(managers))
 	copy(candidates, managers)
 	skillGroup := "general"
 	var decisions []string
 
-	// Rule 1: VIP/Priority segment → only VIP-skill managers
-	if segment == "VIP" || segment == "Priority" {
-		var filtered []domain.Manager
-		for _, m := range candidates {
-			if m.IsVIPSkill {
-				filtered = append(filtered, m)
-			}
+	if len(reqs) > 0 {
+		ids := make([]uuid.UUID, len(candidates))
+		for i, m := range candidates {
+			ids[i] = m.ID
 		}
-		if len(filtered) > 0 {
-			candidates = filtered
-			skillGroup = "vip"
-			decisions = append(decisions, fmt.Sprintf("Segment '%s' → filtered to %d VIP-skill managers", segment, len(filtered)))
-		} else {
-			decisions = append(decisions, fmt.Sprintf("Segment '%s' → no VIP managers found, keeping all %d", segment, len(candidates)))
+		levels, err := sf.skillRepo.Levels(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("manager skills: %w", err)
 		}
-	}
 
-	// Rule 2: Type "Change Data" / "Смена данных" → only Chief Specialist
-	if ticketType == "Change Data" || ticketType == "Смена данных" {
-		var filtered []domain.Manager
-		for _, m := range candidates {
-			if m.IsChiefSpec {
-				filtered = append(filtered, m)
-			}
-		}
-		if len(filtered) > 0 {
-			candidates = filtered
-			skillGroup = "chief_spec"
-			decisions = append(decisions, fmt.Sprintf("Type '%s' → filtered to %d Chief Specialists", ticketType, len(filtered)))
-		} else {
-			decisions = append(decisions, fmt.Sprintf("Type '%s' → no Chief Spec found, keeping current %d candidates", ticketType, len(candidates)))
-		}
-	}
-
-	// Rule 3: Language KZ or ENG → only managers with that language
-	if lang == "KZ" || lang == "ENG" {
-		var filtered []domain.Manager
-		for _, m := range candidates {
-			for _, l := range m.Languages {
-				if l == lang {
+		for _, req := range reqs {
+			var filtered []domain.Manager
+			for _, m := range candidates {
+				if levels[m.ID][req.SkillCode] >= req.MinLevel {
 					filtered = append(filtered, m)
-					break
 				}
 			}
-		}
-		if len(filtered) > 0 {
-			candidates = filtered
-			skillGroup = fmt.Sprintf("lang_%s", lang)
-			decisions = append(decisions, fmt.Sprintf("Language '%s' → filtered to %d managers with that skill", lang, len(filtered)))
-		} else {
-			decisions = append(decisions, fmt.Sprintf("Language '%s' → no matching managers, keeping current %d candidates", lang, len(candidates)))
+			reason := requirementReason(req, segment, ticketType, lang)
+			if len(filtered) > 0 {
+				candidates = filtered
+				skillGroup = skillBucket(req)
+				decisions = append(decisions, fmt.Sprintf("%s → filtered to %d managers with %s ≥ %d", reason, len(filtered), req.SkillCode, req.MinLevel))
+			} else {
+				decisions = append(decisions, fmt.Sprintf("%s → no managers with %s ≥ %d, keeping current %d candidates", reason, req.SkillCode, req.MinLevel, len(candidates)))
+			}
 		}
 	}
 
 	decision := fmt.Sprintf("Pool: %d managers (no skill filters applied)", len(candidates))
 	if len(decisions) > 0 {
-		decision = ""
-		for i, d := range decisions {
-			if i > 0 {
-				decision += "; "
-			}
-			decision += d
-		}
+		decision = strings.Join(decisions, "; ")
 	}
 
 	return &SkillResult{
-		Candidates: candidates,
-		SkillGroup: skillGroup,
-		Decision:   decision,
+		Candidates:   candidates,
+		SkillGroup:   skillGroup,
+		Decision:     decision,
+		Requirements: reqs,
+	}, nil
+}
+
+// MatchRequirements returns the active requirements of reqs that apply to a
+// ticket, in the order they apply; it mirrors SkillRepo.MatchingRequirements
+// for rules that are not stored.
+func MatchRequirements(reqs []domain.SkillRequirement, segment, ticketType, lang string) []domain.SkillRequirement {
+	matches := func(values []string, v string) bool {
+		return len(values) == 0 || slices.Contains(values, v)
+	}
+	matched := []domain.SkillRequirement{}
+	for _, req := range reqs {
+		if req.IsActive && matches(req.Segments, segment) && matches(req.TicketTypes, ticketType) && matches(req.Langs, lang) {
+			matched = append(matched, req)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Position < matched[j].Position })
+	return matched
+}
+
+// requirementReason names the ticket attributes a requirement matched on.
+func requirementReason(req domain.SkillRequirement, segment, ticketType, lang string) string {
+	var parts []string
+	if len(req.Segments) > 0 {
+		parts = append(parts, fmt.Sprintf("Segment '%s'", segment))
+	}
+	if len(req.TicketTypes) > 0 {
+		parts = append(parts, fmt.Sprintf("Type '%s'", ticketType))
+	}
+	if len(req.Langs) > 0 {
+		parts = append(parts, fmt.Sprintf("Language '%s'", lang))
+	}
+	if len(parts) == 0 {
+		return "All tickets"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// skillBucket is the round-robin bucket of tickets narrowed by a requirement:
+// "vip", "chief_spec", "lang_KZ" and so on.
+func skillBucket(req domain.SkillRequirement) string {
+	if req.Category == domain.SkillCategoryLanguage {
+		return "lang_" + req.SkillCode
 	}
+	return strings.ToLower(req.SkillCode)
 }
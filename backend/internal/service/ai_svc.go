@@ -260,11 +260,38 @@ func (s *AIService) buildUserMessage(ticket *domain.Ticket) string {
 }
 
 func (s *AIService) callOpenAI(ctx context.Context, userMessage string) (*aiResult, error) {
+	content, err := s.chat(ctx, systemPrompt, userMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Strip markdown code fences if present
+	content = stripCodeFences(content)
+
+	var result aiResult
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return nil, fmt.Errorf("parse AI JSON: %w (raw: %s)", err, content)
+	}
+
+	// Clamp priority
+	if result.Priority110 < 1 {
+		result.Priority110 = 1
+	}
+	if result.Priority110 > 10 {
+		result.Priority110 = 10
+	}
+
+	return &result, nil
+}
+
+// chat sends a system and a user message to the chat completions API and
+// returns the reply.
+func (s *AIService) chat(ctx context.Context, system, user string) (string, error) {
 	reqBody := openAIRequest{
 		Model: s.model,
 		Messages: []openAIMessage{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: userMessage},
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
 		},
 	}
 
@@ -272,55 +299,37 @@ func (s *AIService) callOpenAI(ctx context.Context, userMessage string) (*aiResu
 
 	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewReader(bodyBytes))
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+s.apiKey)
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("http request: %w", err)
+		return "", fmt.Errorf("http request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBytes, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("OpenAI API status %d: %s", resp.StatusCode, string(respBytes))
+		return "", fmt.Errorf("OpenAI API status %d: %s", resp.StatusCode, string(respBytes))
 	}
 
 	var openAIResp openAIResponse
 	if err := json.Unmarshal(respBytes, &openAIResp); err != nil {
-		return nil, fmt.Errorf("parse response: %w", err)
+		return "", fmt.Errorf("parse response: %w", err)
 	}
 
 	if openAIResp.Error != nil {
-		return nil, fmt.Errorf("OpenAI error: %s", openAIResp.Error.Message)
+		return "", fmt.Errorf("OpenAI error: %s", openAIResp.Error.Message)
 	}
 
 	if len(openAIResp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
-	}
-
-	content := openAIResp.Choices[0].Message.Content
-
-	// Strip markdown code fences if present
-	content = stripCodeFences(content)
-
-	var result aiResult
-	if err := json.Unmarshal([]byte(content), &result); err != nil {
-		return nil, fmt.Errorf("parse AI JSON: %w (raw: %s)", err, content)
-	}
-
-	// Clamp priority
-	if result.Priority110 < 1 {
-		result.Priority110 = 1
-	}
-	if result.Priority110 > 10 {
-		result.Priority110 = 10
+		return "", fmt.Errorf("no choices in response")
 	}
 
-	return &result, nil
+	return openAIResp.Choices[0].Message.Content, nil
 }
 
 func (s *AIService) resolveGeo(ctx context.Context, city string) (*float64, *float64, string) {
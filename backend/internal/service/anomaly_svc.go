@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+// anomalyDimensions are the breakdowns the detector watches, as series
+// expressions over dashboardTicketFrom. "city" is the client's city as
+// geocoded from the ticket address, so it is known before routing.
+var anomalyDimensions = []struct {
+	Name string
+	Expr string
+}{
+	{"type", timelineGroups["type"]},
+	{"city", `COALESCE((SELECT g.resolved_city FROM geo_cache g WHERE g.raw_address = t.raw_address), 'unknown')`},
+	{"channel", timelineGroups["channel"]},
+}
+
+const (
+	anomalyLookback = 7 * 24 // hourly buckets of history per series
+	anomalyAlpha    = 0.1    // EWMA smoothing factor
+)
+
+type AnomalyService struct {
+	pool       *pgxpool.Pool
+	repo       *repository.AlertRepo
+	zThreshold float64
+	minCount   int
+
+	// OnAlert is called for every newly raised alert (e.g. to push it over SSE).
+	OnAlert func(domain.Alert)
+}
+
+func NewAnomalyService(pool *pgxpool.Pool, repo *repository.AlertRepo, zThreshold float64, minCount int) *AnomalyService {
+	return &AnomalyService{pool: pool, repo: repo, zThreshold: zThreshold, minCount: minCount}
+}
+
+func (s *AnomalyService) List(ctx context.Context, acknowledged *bool, limit int) ([]domain.Alert, error) {
+	return s.repo.List(ctx, acknowledged, limit)
+}
+
+func (s *AnomalyService) Acknowledge(ctx context.Context, id uuid.UUID, by *string) (*domain.Alert, error) {
+	return s.repo.Acknowledge(ctx, id, by)
+}
+
+// Run evaluates the detector every interval until ctx is cancelled.
+func (s *AnomalyService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Detect(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("anomaly detection failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Detect scores the last complete hour of every series against an EWMA
+// baseline of the preceding week and stores an alert for each spike.
+func (s *AnomalyService) Detect(ctx context.Context) error {
+	current := time.Now().Truncate(time.Hour).Add(-time.Hour)
+	start := current.Add(-anomalyLookback * time.Hour)
+
+	for _, dim := range anomalyDimensions {
+		series, err := s.hourlyCounts(ctx, dim.Expr, start, current.Add(time.Hour))
+		if err != nil {
+			return fmt.Errorf("hourly counts by %s: %w", dim.Name, err)
+		}
+		for value, counts := range series {
+			s.check(ctx, domain.AlertMetricInflow, dim.Name, value, current, counts.inflow)
+			s.check(ctx, domain.AlertMetricNegative, dim.Name, value, current, counts.negative)
+		}
+	}
+	return nil
+}
+
+type hourlySeries struct {
+	inflow   []float64
+	negative []float64
+}
+
+// hourlyCounts returns zero-filled hourly ticket counts in [from, to) per
+// dimension value.
+func (s *AnomalyService) hourlyCounts(ctx context.Context, expr string, from, to time.Time) (map[string]*hourlySeries, error) {
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(
+		`SELECT %s AS value, date_trunc('hour', t.created_at), COUNT(*),
+		        COUNT(*) FILTER (WHERE ai.sentiment ILIKE 'негатив%%' OR ai.sentiment ILIKE 'negative%%')
+		 FROM %s
+		 WHERE t.created_at >= $1 AND t.created_at < $2
+		 GROUP BY 1, 2`, expr, dashboardTicketFrom), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	n := int(to.Sub(from) / time.Hour)
+	series := map[string]*hourlySeries{}
+	for rows.Next() {
+		var value string
+		var bucket time.Time
+		var inflow, negative int
+		if err := rows.Scan(&value, &bucket, &inflow, &negative); err != nil {
+			return nil, err
+		}
+		i := int(bucket.Sub(from) / time.Hour)
+		if i < 0 || i >= n {
+			continue
+		}
+		sr, ok := series[value]
+		if !ok {
+			sr = &hourlySeries{inflow: make([]float64, n), negative: make([]float64, n)}
+			series[value] = sr
+		}
+		sr.inflow[i] = float64(inflow)
+		sr.negative[i] = float64(negative)
+	}
+	return series, rows.Err()
+}
+
+// check compares the last point of counts with the EWMA of the points before it.
+func (s *AnomalyService) check(ctx context.Context, metric, dimension, value string, bucket time.Time, counts []float64) {
+	if len(counts) < 2 {
+		return
+	}
+	observed := counts[len(counts)-1]
+	if observed < float64(s.minCount) {
+		return
+	}
+
+	mean, variance := ewma(counts[:len(counts)-1], anomalyAlpha)
+	// Counts are roughly Poisson, so never trust a spread tighter than sqrt(mean).
+	sigma := math.Max(math.Sqrt(variance), math.Max(math.Sqrt(mean), 1))
+	z := (observed - mean) / sigma
+	if z < s.zThreshold {
+		return
+	}
+
+	severity := domain.AlertSeverityWarning
+	if z >= 2*s.zThreshold {
+		severity = domain.AlertSeverityCritical
+	}
+
+	alert := domain.Alert{
+		ID:             uuid.New(),
+		Metric:         metric,
+		Dimension:      dimension,
+		DimensionValue: value,
+		BucketStart:    bucket,
+		Observed:       observed,
+		Expected:       mean,
+		ZScore:         z,
+		Severity:       severity,
+		Message:        anomalyMessage(metric, dimension, value, observed, mean),
+	}
+	inserted, err := s.repo.Insert(ctx, &alert)
+	if err != nil {
+		log.Error().Err(err).Str("metric", metric).Str(dimension, value).Msg("failed to store alert")
+		return
+	}
+	if !inserted {
+		return
+	}
+
+	log.Warn().Str("metric", metric).Str(dimension, value).Float64("observed", observed).
+		Float64("expected", mean).Float64("z", z).Msg("anomaly detected")
+	if s.OnAlert != nil {
+		s.OnAlert(alert)
+	}
+}
+
+// ewma returns the exponentially weighted mean and variance of xs.
+func ewma(xs []float64, alpha float64) (mean, variance float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	mean = xs[0]
+	for _, x := range xs[1:] {
+		diff := x - mean
+		incr := alpha * diff
+		mean += incr
+		variance = (1 - alpha) * (variance + diff*incr)
+	}
+	return mean, variance
+}
+
+func anomalyMessage(metric, dimension, value string, observed, expected float64) string {
+	what := "Всплеск обращений"
+	if metric == domain.AlertMetricNegative {
+		what = "Всплеск негативных обращений"
+	}
+	var where string
+	switch dimension {
+	case "type":
+		where = fmt.Sprintf("типа «%s»", value)
+	case "city":
+		where = fmt.Sprintf("из города %s", value)
+	case "channel":
+		where = fmt.Sprintf("из канала %s", value)
+	}
+	return fmt.Sprintf("%s %s: %.0f за час при норме %.1f", what, where, observed, expected)
+}
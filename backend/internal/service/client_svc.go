@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+type ClientService struct {
+	clientRepo *repository.ClientRepo
+	ticketRepo *repository.TicketRepo
+}
+
+func NewClientService(cr *repository.ClientRepo, tr *repository.TicketRepo) *ClientService {
+	return &ClientService{clientRepo: cr, ticketRepo: tr}
+}
+
+// Get returns the 360° view of a client looked up by id or GUID.
+func (s *ClientService) Get(ctx context.Context, key string) (*domain.ClientView, error) {
+	client, err := s.clientRepo.GetByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	view := &domain.ClientView{Client: *client}
+
+	if view.SegmentHistory, err = s.clientRepo.SegmentHistory(ctx, client.ID); err != nil {
+		return nil, fmt.Errorf("segment history: %w", err)
+	}
+	if view.Tickets, err = s.ticketRepo.ListByClientID(ctx, client.ID); err != nil {
+		return nil, fmt.Errorf("tickets: %w", err)
+	}
+	if view.SentimentTrend, err = s.clientRepo.SentimentTrend(ctx, client.ID); err != nil {
+		return nil, fmt.Errorf("sentiment trend: %w", err)
+	}
+	if view.Managers, err = s.clientRepo.Managers(ctx, client.ID); err != nil {
+		return nil, fmt.Errorf("managers: %w", err)
+	}
+	return view, nil
+}
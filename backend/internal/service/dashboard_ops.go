@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+// ErrInvalidDashboardParam is returned for unsupported dashboard query parameters.
+var ErrInvalidDashboardParam = errors.New("invalid dashboard parameter")
+
+var ErrInvalidBucket = fmt.Errorf("%w: bucket must be hour, day, week or month", ErrInvalidDashboardParam)
+
+// dashboardBuckets maps the public bucket names to date_trunc units.
+var dashboardBuckets = map[string]string{
+	"hour":  "hour",
+	"day":   "day",
+	"week":  "week",
+	"month": "month",
+}
+
+// bucketFormats renders a truncated bucket start as a label.
+var bucketFormats = map[string]string{
+	"hour":  "YYYY-MM-DD HH24:00",
+	"day":   "YYYY-MM-DD",
+	"week":  "YYYY-MM-DD",
+	"month": "YYYY-MM",
+}
+
+// dashboardStageJoin adds the first time each ticket reached a stage, from
+// ticket_status_history, as sh.enriched_at, sh.routed_at and sh.resolved_at.
+const dashboardStageJoin = `LEFT JOIN (
+		     SELECT ticket_id,
+		            MIN(changed_at) FILTER (WHERE to_status = 'enriched') AS enriched_at,
+		            MIN(changed_at) FILTER (WHERE to_status = 'routed') AS routed_at,
+		            MIN(changed_at) FILTER (WHERE to_status IN ('resolved', 'closed')) AS resolved_at
+		     FROM ticket_status_history
+		     GROUP BY ticket_id
+		 ) sh ON sh.ticket_id = t.id`
+
+// bucketExpr returns a label expression truncating col to bucket in the
+// reporting time zone, or NULL when bucket is empty (no bucketing).
+func (s *DashboardService) bucketExpr(bucket, col string) (string, error) {
+	if bucket == "" {
+		return "NULL::text", nil
+	}
+	unit, ok := dashboardBuckets[bucket]
+	if !ok {
+		return "", ErrInvalidBucket
+	}
+	return fmt.Sprintf("to_char(date_trunc('%s', %s AT TIME ZONE %s), '%s')", unit, col, s.tz(), bucketFormats[bucket]), nil
+}
+
+type StageLatency struct {
+	Stage  string  `json:"stage"` // enrich, route, resolve, total
+	Bucket *string `json:"bucket,omitempty"`
+	Count  int     `json:"count"`
+	P50Sec float64 `json:"p50_sec"`
+	P90Sec float64 `json:"p90_sec"`
+	P99Sec float64 `json:"p99_sec"`
+}
+
+// Latency returns p50/p90/p99 durations of each pipeline stage, bucketed by
+// the time the stage completed:
+//
+//	enrich  created  → enriched
+//	route   enriched → routed (created → routed if never enriched)
+//	resolve routed   → resolved/closed
+//	total   created  → resolved/closed
+func (s *DashboardService) Latency(ctx context.Context, f domain.DashboardFilter, bucket string) ([]StageLatency, error) {
+	bucketCol, err := s.bucketExpr(bucket, "d.ended_at")
+	if err != nil {
+		return nil, err
+	}
+
+	var args []interface{}
+	where := dashboardTicketWhere(f, &args)
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(
+		`WITH st AS (
+		     SELECT t.created_at, sh.enriched_at, sh.routed_at, sh.resolved_at
+		     FROM %s
+		     %s
+		     %s
+		 ), d AS (
+		     SELECT 'enrich' AS stage, 1 AS ord, enriched_at AS ended_at, enriched_at - created_at AS dur FROM st WHERE enriched_at IS NOT NULL
+		     UNION ALL
+		     SELECT 'route', 2, routed_at, routed_at - COALESCE(enriched_at, created_at) FROM st WHERE routed_at IS NOT NULL
+		     UNION ALL
+		     SELECT 'resolve', 3, resolved_at, resolved_at - routed_at FROM st WHERE resolved_at IS NOT NULL AND routed_at IS NOT NULL
+		     UNION ALL
+		     SELECT 'total', 4, resolved_at, resolved_at - created_at FROM st WHERE resolved_at IS NOT NULL
+		 )
+		 SELECT d.stage, %s AS bucket, COUNT(*),
+		        percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM d.dur)),
+		        percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM d.dur)),
+		        percentile_cont(0.99) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM d.dur))
+		 FROM d
+		 GROUP BY d.stage, d.ord, bucket
+		 ORDER BY bucket, d.ord`, dashboardTicketFrom, dashboardStageJoin, where, bucketCol), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []StageLatency{}
+	for rows.Next() {
+		var d StageLatency
+		if err := rows.Scan(&d.Stage, &d.Bucket, &d.Count, &d.P50Sec, &d.P90Sec, &d.P99Sec); err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+type BacklogAgeData struct {
+	Status    string `json:"status"`
+	AgeBucket string `json:"age_bucket"` // <1h, 1-4h, 4-24h, 1-3d, 3-7d, >7d
+	Count     int    `json:"count"`
+}
+
+// backlogAgeLabels names the age bins produced by BacklogAge's CASE expression.
+var backlogAgeLabels = []string{"<1h", "1-4h", "4-24h", "1-3d", "3-7d", ">7d"}
+
+// BacklogAge histograms open (not resolved/closed) tickets by status and time since creation.
+func (s *DashboardService) BacklogAge(ctx context.Context, f domain.DashboardFilter) ([]BacklogAgeData, error) {
+	var args []interface{}
+	where := andWhere(dashboardTicketWhere(f, &args), "t.status NOT IN ('resolved', 'closed')")
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(
+		`SELECT t.status,
+		        CASE
+		            WHEN now() - t.created_at < INTERVAL '1 hour'  THEN 0
+		            WHEN now() - t.created_at < INTERVAL '4 hours' THEN 1
+		            WHEN now() - t.created_at < INTERVAL '1 day'   THEN 2
+		            WHEN now() - t.created_at < INTERVAL '3 days'  THEN 3
+		            WHEN now() - t.created_at < INTERVAL '7 days'  THEN 4
+		            ELSE 5
+		        END AS age_bin,
+		        COUNT(*)
+		 FROM %s
+		 %s
+		 GROUP BY t.status, age_bin
+		 ORDER BY t.status, age_bin`, dashboardTicketFrom, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []BacklogAgeData{}
+	for rows.Next() {
+		var d BacklogAgeData
+		var bin int
+		if err := rows.Scan(&d.Status, &bin, &d.Count); err != nil {
+			return nil, err
+		}
+		d.AgeBucket = backlogAgeLabels[bin]
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+type ManagerThroughputData struct {
+	ManagerName   string  `json:"manager_name"`
+	Office        string  `json:"office"`
+	Bucket        *string `json:"bucket,omitempty"`
+	Assigned      int     `json:"assigned"`
+	Resolved      int     `json:"resolved"`
+	P50ResolveSec float64 `json:"p50_resolve_sec"`
+}
+
+// Throughput counts tickets assigned to and resolved by each manager (current
+// assignment), bucketed by the time of the respective event.
+func (s *DashboardService) Throughput(ctx context.Context, f domain.DashboardFilter, bucket string) ([]ManagerThroughputData, error) {
+	assignedBucket, err := s.bucketExpr(bucket, "a.assigned_at")
+	if err != nil {
+		return nil, err
+	}
+	resolvedBucket, _ := s.bucketExpr(bucket, "sh.resolved_at")
+
+	var args []interface{}
+	where := andWhere(dashboardTicketWhere(f, &args), "a.manager_id IS NOT NULL")
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(
+		`WITH ev AS (
+		     SELECT a.manager_id, %s AS bucket, 'assigned' AS kind, NULL::interval AS dur
+		     FROM %s
+		     %s
+		     UNION ALL
+		     SELECT a.manager_id, %s, 'resolved', sh.resolved_at - COALESCE(sh.routed_at, a.assigned_at)
+		     FROM %s
+		     %s
+		     %s
+		 )
+		 SELECT m.full_name, bu.city, ev.bucket,
+		        COUNT(*) FILTER (WHERE ev.kind = 'assigned'),
+		        COUNT(*) FILTER (WHERE ev.kind = 'resolved'),
+		        COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM ev.dur)), 0)
+		 FROM ev
+		 JOIN managers m ON m.id = ev.manager_id
+		 JOIN business_units bu ON bu.id = m.business_unit_id
+		 GROUP BY m.id, m.full_name, bu.city, ev.bucket
+		 ORDER BY ev.bucket, COUNT(*) FILTER (WHERE ev.kind = 'resolved') DESC, m.full_name`,
+		assignedBucket, dashboardTicketFrom, where,
+		resolvedBucket, dashboardTicketFrom, dashboardStageJoin, andWhere(where, "sh.resolved_at IS NOT NULL")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []ManagerThroughputData{}
+	for rows.Next() {
+		var d ManagerThroughputData
+		if err := rows.Scan(&d.ManagerName, &d.Office, &d.Bucket, &d.Assigned, &d.Resolved, &d.P50ResolveSec); err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
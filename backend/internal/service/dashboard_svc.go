@@ -2,16 +2,33 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
 )
 
 type DashboardService struct {
 	pool *pgxpool.Pool
+	loc  *time.Location // reporting time zone for day boundaries and buckets
+}
+
+func NewDashboardService(pool *pgxpool.Pool, loc *time.Location) *DashboardService {
+	return &DashboardService{pool: pool, loc: loc}
+}
+
+// Location returns the reporting time zone.
+func (s *DashboardService) Location() *time.Location {
+	return s.loc
 }
 
-func NewDashboardService(pool *pgxpool.Pool) *DashboardService {
-	return &DashboardService{pool: pool}
+// tz returns the reporting time zone as a SQL string literal.
+func (s *DashboardService) tz() string {
+	return "'" + strings.ReplaceAll(s.loc.String(), "'", "''") + "'"
 }
 
 type DashboardStats struct {
@@ -29,29 +46,41 @@ type DashboardStats struct {
 	AvgProcessingMs  float64 `json:"avg_processing_ms"`
 }
 
-func (s *DashboardService) Stats(ctx context.Context) (*DashboardStats, error) {
-	var stats DashboardStats
+// Stats computes all KPIs in a single round-trip: one pass over the filtered
+// tickets with FILTER clauses, plus scalar subqueries for managers and offices.
+func (s *DashboardService) Stats(ctx context.Context, f domain.DashboardFilter) (*DashboardStats, error) {
+	var args []interface{}
+	where := dashboardTicketWhere(f, &args)
+	mgrWhere := andWhere(dashboardManagerWhere(f, &args), "m.is_active = true")
+	buWhere := dashboardOfficeWhere(f, &args)
 
-	err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM tickets`).Scan(&stats.TotalTickets)
+	var stats DashboardStats
+	var today, yesterday int
+	err := s.pool.QueryRow(ctx, fmt.Sprintf(
+		`SELECT COUNT(*),
+		        COUNT(*) FILTER (WHERE t.status = 'routed'),
+		        COUNT(*) FILTER (WHERE t.status IN ('new', 'enriching')),
+		        COALESCE(AVG(ai.priority_1_10), 0)::float8,
+		        COALESCE(AVG(ai.confidence_type), 0)::float8,
+		        COUNT(*) FILTER (WHERE t.client_segment = 'VIP'),
+		        COUNT(*) FILTER (WHERE ai.geo_status IN ('unknown', 'NOT_FOUND', 'NO_ADDRESS', 'pending')),
+		        COUNT(ai.id),
+		        COALESCE(AVG(ai.processing_ms), 0)::float8,
+		        COUNT(*) FILTER (WHERE t.created_at >= %[5]s),
+		        COUNT(*) FILTER (WHERE t.created_at >= %[5]s - INTERVAL '1 day' AND t.created_at < %[5]s),
+		        (SELECT COUNT(*) FROM managers m %[1]s),
+		        (SELECT COUNT(*) FROM business_units bu %[2]s)
+		 FROM %[3]s %[4]s`, mgrWhere, buWhere, dashboardTicketFrom, where, s.startOfToday()), args...).Scan(
+		&stats.TotalTickets, &stats.RoutedTickets, &stats.PendingTickets,
+		&stats.AvgPriority, &stats.AvgConfidence, &stats.VIPCount, &stats.UnknownGeoCount,
+		&stats.AIProcessedCount, &stats.AvgProcessingMs, &today, &yesterday,
+		&stats.ActiveManagers, &stats.TotalOffices,
+	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("dashboard stats: %w", err)
 	}
 
-	s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM tickets WHERE status = 'routed'`).Scan(&stats.RoutedTickets)
-	s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM tickets WHERE status IN ('new', 'enriching')`).Scan(&stats.PendingTickets)
-	s.pool.QueryRow(ctx, `SELECT COALESCE(AVG(priority_1_10), 0) FROM ticket_ai`).Scan(&stats.AvgPriority)
-	s.pool.QueryRow(ctx, `SELECT COALESCE(AVG(confidence_type), 0) FROM ticket_ai`).Scan(&stats.AvgConfidence)
-	s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM tickets WHERE client_segment = 'VIP'`).Scan(&stats.VIPCount)
-	s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM ticket_ai WHERE geo_status IN ('unknown', 'NOT_FOUND', 'NO_ADDRESS', 'pending')`).Scan(&stats.UnknownGeoCount)
-	s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM managers WHERE is_active = true`).Scan(&stats.ActiveManagers)
-	s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM business_units`).Scan(&stats.TotalOffices)
-	s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM ticket_ai`).Scan(&stats.AIProcessedCount)
-	s.pool.QueryRow(ctx, `SELECT COALESCE(AVG(processing_ms), 0) FROM ticket_ai WHERE processing_ms IS NOT NULL`).Scan(&stats.AvgProcessingMs)
-
 	// Tickets change: today vs yesterday
-	var today, yesterday int
-	s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM tickets WHERE created_at >= CURRENT_DATE`).Scan(&today)
-	s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM tickets WHERE created_at >= CURRENT_DATE - INTERVAL '1 day' AND created_at < CURRENT_DATE`).Scan(&yesterday)
 	if yesterday > 0 {
 		stats.TicketsChangePct = float64(today-yesterday) / float64(yesterday) * 100
 	} else if today > 0 {
@@ -66,10 +95,13 @@ type SentimentData struct {
 	Count     int    `json:"count"`
 }
 
-func (s *DashboardService) Sentiment(ctx context.Context) ([]SentimentData, error) {
-	rows, err := s.pool.Query(ctx,
-		`SELECT COALESCE(sentiment, 'unknown'), COUNT(*)
-		 FROM ticket_ai GROUP BY sentiment ORDER BY COUNT(*) DESC`)
+func (s *DashboardService) Sentiment(ctx context.Context, f domain.DashboardFilter) ([]SentimentData, error) {
+	var args []interface{}
+	where := dashboardTicketWhere(f, &args)
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(
+		`SELECT COALESCE(ai.sentiment, 'unknown'), COUNT(*)
+		 FROM %s %s
+		 GROUP BY ai.sentiment ORDER BY COUNT(*) DESC`, dashboardTicketFrom, andWhere(where, "ai.id IS NOT NULL")), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -83,7 +115,7 @@ func (s *DashboardService) Sentiment(ctx context.Context) ([]SentimentData, erro
 		}
 		result = append(result, d)
 	}
-	return result, nil
+	return result, rows.Err()
 }
 
 type CategoryData struct {
@@ -91,10 +123,13 @@ type CategoryData struct {
 	Count int    `json:"count"`
 }
 
-func (s *DashboardService) Categories(ctx context.Context) ([]CategoryData, error) {
-	rows, err := s.pool.Query(ctx,
-		`SELECT COALESCE(type, 'unknown'), COUNT(*)
-		 FROM ticket_ai GROUP BY type ORDER BY COUNT(*) DESC`)
+func (s *DashboardService) Categories(ctx context.Context, f domain.DashboardFilter) ([]CategoryData, error) {
+	var args []interface{}
+	where := dashboardTicketWhere(f, &args)
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(
+		`SELECT COALESCE(ai.type, 'unknown'), COUNT(*)
+		 FROM %s %s
+		 GROUP BY ai.type ORDER BY COUNT(*) DESC`, dashboardTicketFrom, andWhere(where, "ai.id IS NOT NULL")), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +143,7 @@ func (s *DashboardService) Categories(ctx context.Context) ([]CategoryData, erro
 		}
 		result = append(result, d)
 	}
-	return result, nil
+	return result, rows.Err()
 }
 
 type ManagerLoadData struct {
@@ -117,14 +152,26 @@ type ManagerLoadData struct {
 	CurrentLoad int     `json:"current_load"`
 	MaxLoad     int     `json:"max_load"`
 	Utilization float64 `json:"utilization_pct"`
+	Tickets     int     `json:"tickets"` // currently assigned tickets matching the filter
 }
 
-func (s *DashboardService) ManagerLoad(ctx context.Context) ([]ManagerLoadData, error) {
-	rows, err := s.pool.Query(ctx,
-		`SELECT m.full_name, bu.city, m.current_load, m.max_load
+// ManagerLoad lists active managers in the filtered business unit. Load is a
+// live snapshot; Tickets counts the manager's assigned tickets within the filter.
+func (s *DashboardService) ManagerLoad(ctx context.Context, f domain.DashboardFilter) ([]ManagerLoadData, error) {
+	var args []interface{}
+	where := dashboardTicketWhere(f, &args)
+	mgrWhere := dashboardManagerWhere(f, &args)
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(
+		`SELECT m.full_name, bu.city, m.current_load, m.max_load, COALESCE(ft.n, 0)
 		 FROM managers m JOIN business_units bu ON bu.id = m.business_unit_id
-		 WHERE m.is_active = true
-		 ORDER BY m.current_load DESC`)
+		 LEFT JOIN (
+		     SELECT a.manager_id, COUNT(*) AS n
+		     FROM %s %s
+		     GROUP BY a.manager_id
+		 ) ft ON ft.manager_id = m.id
+		 %s
+		 ORDER BY m.current_load DESC`,
+		dashboardTicketFrom, andWhere(where, "a.manager_id IS NOT NULL"), andWhere(mgrWhere, "m.is_active = true")), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -133,7 +180,7 @@ func (s *DashboardService) ManagerLoad(ctx context.Context) ([]ManagerLoadData,
 	result := []ManagerLoadData{}
 	for rows.Next() {
 		var d ManagerLoadData
-		if err := rows.Scan(&d.ManagerName, &d.Office, &d.CurrentLoad, &d.MaxLoad); err != nil {
+		if err := rows.Scan(&d.ManagerName, &d.Office, &d.CurrentLoad, &d.MaxLoad, &d.Tickets); err != nil {
 			return nil, err
 		}
 		if d.MaxLoad > 0 {
@@ -141,21 +188,90 @@ func (s *DashboardService) ManagerLoad(ctx context.Context) ([]ManagerLoadData,
 		}
 		result = append(result, d)
 	}
-	return result, nil
+	return result, rows.Err()
 }
 
 type TimelineData struct {
-	Date  string `json:"date"`
-	Count int    `json:"count"`
+	Date   string `json:"date"`
+	Series string `json:"series,omitempty"` // group_by value; empty when not grouped
+	Count  int    `json:"count"`
+}
+
+// timelineGroups maps group_by values to the series expression over dashboardTicketFrom.
+var timelineGroups = map[string]string{
+	"type":      `COALESCE(ai.type, 'unknown')`,
+	"sentiment": `COALESCE(ai.sentiment, 'unknown')`,
+	"channel":   `COALESCE(t.source_channel, 'unknown')`,
+	"office":    `COALESCE((SELECT bu.city FROM business_units bu WHERE bu.id = a.business_unit_id), 'unassigned')`,
 }
 
-func (s *DashboardService) Timeline(ctx context.Context) ([]TimelineData, error) {
-	rows, err := s.pool.Query(ctx,
-		`SELECT DATE(created_at)::text, COUNT(*)
-		 FROM tickets
-		 GROUP BY DATE(created_at)
-		 ORDER BY DATE(created_at) DESC
-		 LIMIT 30`)
+// timelineMaxBuckets bounds generate_series for a single request.
+const timelineMaxBuckets = 2000
+
+// Timeline returns ticket counts per bucket (hour, day, week or month in the
+// reporting time zone) in ascending order, with empty buckets filled with zeros.
+// Without a range in f it covers a default window ending now. groupBy splits
+// counts into one series per type, sentiment, office or channel.
+func (s *DashboardService) Timeline(ctx context.Context, f domain.DashboardFilter, bucket, groupBy string) ([]TimelineData, error) {
+	if bucket == "" {
+		bucket = "day"
+	}
+	unit, ok := dashboardBuckets[bucket]
+	if !ok {
+		return nil, ErrInvalidBucket
+	}
+	series := "NULL::text"
+	if groupBy != "" {
+		if series, ok = timelineGroups[groupBy]; !ok {
+			return nil, fmt.Errorf("%w: group_by must be type, sentiment, office or channel", ErrInvalidDashboardParam)
+		}
+	}
+
+	to := time.Now()
+	if f.To != nil {
+		to = *f.To
+	}
+	from := timelineDefaultFrom(bucket, to)
+	if f.From != nil {
+		from = *f.From
+	}
+	if !from.Before(to) {
+		return []TimelineData{}, nil
+	}
+	if n := to.Sub(from) / bucketApproxDuration(bucket); n > timelineMaxBuckets {
+		return nil, fmt.Errorf("%w: range too large for %s buckets", ErrInvalidDashboardParam, bucket)
+	}
+	f.From, f.To = &from, &to
+
+	var args []interface{}
+	where := dashboardTicketWhere(f, &args)
+	fromArg, toArg := bindArg(&args, from), bindArg(&args, to)
+
+	seriesSet := `SELECT NULL::text AS series`
+	if groupBy != "" {
+		seriesSet = `SELECT DISTINCT series FROM c`
+	}
+
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(
+		`WITH b AS (
+		     SELECT generate_series(
+		         date_trunc('%[1]s', %[2]s::timestamptz AT TIME ZONE %[3]s),
+		         date_trunc('%[1]s', (%[4]s::timestamptz - INTERVAL '1 microsecond') AT TIME ZONE %[3]s),
+		         INTERVAL '1 %[1]s') AS bucket
+		 ), c AS (
+		     SELECT date_trunc('%[1]s', t.created_at AT TIME ZONE %[3]s) AS bucket, %[5]s AS series, COUNT(*) AS n
+		     FROM %[6]s
+		     %[7]s
+		     GROUP BY 1, 2
+		 ), s AS (
+		     %[8]s
+		 )
+		 SELECT to_char(b.bucket, '%[9]s'), COALESCE(s.series, ''), COALESCE(c.n, 0)
+		 FROM b
+		 CROSS JOIN s
+		 LEFT JOIN c ON c.bucket = b.bucket AND c.series IS NOT DISTINCT FROM s.series
+		 ORDER BY b.bucket, s.series`,
+		unit, fromArg, s.tz(), toArg, series, dashboardTicketFrom, where, seriesSet, bucketFormats[bucket]), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -164,10 +280,135 @@ func (s *DashboardService) Timeline(ctx context.Context) ([]TimelineData, error)
 	result := []TimelineData{}
 	for rows.Next() {
 		var d TimelineData
-		if err := rows.Scan(&d.Date, &d.Count); err != nil {
+		if err := rows.Scan(&d.Date, &d.Series, &d.Count); err != nil {
 			return nil, err
 		}
 		result = append(result, d)
 	}
-	return result, nil
+	return result, rows.Err()
+}
+
+// timelineDefaultFrom is the start of the default window for a bucket size.
+func timelineDefaultFrom(bucket string, to time.Time) time.Time {
+	switch bucket {
+	case "hour":
+		return to.Add(-48 * time.Hour)
+	case "week":
+		return to.AddDate(0, 0, -7*12)
+	case "month":
+		return to.AddDate(0, -12, 0)
+	default:
+		return to.AddDate(0, 0, -30)
+	}
+}
+
+func bucketApproxDuration(bucket string) time.Duration {
+	switch bucket {
+	case "hour":
+		return time.Hour
+	case "week":
+		return 7 * 24 * time.Hour
+	case "month":
+		return 28 * 24 * time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
+// startOfToday is a SQL expression for local midnight in the reporting time zone.
+func (s *DashboardService) startOfToday() string {
+	return fmt.Sprintf("(date_trunc('day', now() AT TIME ZONE %[1]s) AT TIME ZONE %[1]s)", s.tz())
+}
+
+// dashboardTicketFrom joins each ticket with its AI enrichment and current
+// assignment; the aliases t, ai and a are what dashboardTicketWhere filters on.
+const dashboardTicketFrom = `tickets t
+		 LEFT JOIN ticket_ai ai ON ai.ticket_id = t.id
+		 LEFT JOIN ticket_assignment a ON a.ticket_id = t.id AND a.is_current = true`
+
+// dashboardTicketWhere builds the WHERE clause applying f to dashboardTicketFrom,
+// appending its parameters to args.
+func dashboardTicketWhere(f domain.DashboardFilter, args *[]interface{}) string {
+	var conditions []string
+
+	if f.From != nil {
+		conditions = append(conditions, "t.created_at >= "+bindArg(args, *f.From))
+	}
+	if f.To != nil {
+		conditions = append(conditions, "t.created_at < "+bindArg(args, *f.To))
+	}
+	if f.BusinessUnit != "" {
+		conditions = append(conditions, businessUnitCondition("a.business_unit_id", f.BusinessUnit, args))
+	}
+	if f.ManagerID != nil {
+		conditions = append(conditions, "a.manager_id = "+bindArg(args, *f.ManagerID))
+	}
+	if f.Segment != "" {
+		conditions = append(conditions, "t.client_segment = "+bindArg(args, f.Segment))
+	}
+	if f.Channel != "" {
+		conditions = append(conditions, "t.source_channel = "+bindArg(args, f.Channel))
+	}
+	if f.Type != "" {
+		conditions = append(conditions, "ai.type = "+bindArg(args, f.Type))
+	}
+	if f.Lang != "" {
+		conditions = append(conditions, "ai.lang = "+bindArg(args, f.Lang))
+	}
+
+	return whereClause(conditions)
+}
+
+// dashboardManagerWhere applies the business unit and manager parts of f to managers m.
+func dashboardManagerWhere(f domain.DashboardFilter, args *[]interface{}) string {
+	var conditions []string
+	if f.BusinessUnit != "" {
+		conditions = append(conditions, businessUnitCondition("m.business_unit_id", f.BusinessUnit, args))
+	}
+	if f.ManagerID != nil {
+		conditions = append(conditions, "m.id = "+bindArg(args, *f.ManagerID))
+	}
+	return whereClause(conditions)
+}
+
+// dashboardOfficeWhere applies the business unit part of f to business_units bu.
+func dashboardOfficeWhere(f domain.DashboardFilter, args *[]interface{}) string {
+	if f.BusinessUnit == "" {
+		return ""
+	}
+	return whereClause([]string{businessUnitCondition("bu.id", f.BusinessUnit, args)})
+}
+
+// businessUnitCondition matches a business unit column against an id, or
+// against the unit's name or city when value is not a UUID.
+func businessUnitCondition(column, value string, args *[]interface{}) string {
+	if id, err := uuid.Parse(value); err == nil {
+		return column + " = " + bindArg(args, id)
+	}
+	p := bindArg(args, value)
+	return fmt.Sprintf("%s IN (SELECT id FROM business_units WHERE name = %s OR city = %s)", column, p, p)
+}
+
+// bindArg appends v to args and returns its positional placeholder.
+func bindArg(args *[]interface{}, v interface{}) string {
+	*args = append(*args, v)
+	return fmt.Sprintf("$%d", len(*args))
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// andWhere appends cond to an existing WHERE clause, or starts one.
+func andWhere(where, cond string) string {
+	if cond == "" {
+		return where
+	}
+	if where == "" {
+		return "WHERE " + cond
+	}
+	return where + " AND " + cond
 }
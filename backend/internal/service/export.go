@@ -0,0 +1,344 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/xuri/excelize/v2"
+)
+
+// ExportFormat is a file format for streamed exports.
+type ExportFormat string
+
+const (
+	ExportCSV   ExportFormat = "csv"
+	ExportXLSX  ExportFormat = "xlsx"
+	ExportJSONL ExportFormat = "jsonl"
+)
+
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+// utf8BOM makes Excel open CSV exports as UTF-8 instead of the system code page.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch ExportFormat(strings.ToLower(s)) {
+	case "", ExportCSV:
+		return ExportCSV, nil
+	case ExportXLSX:
+		return ExportXLSX, nil
+	case ExportJSONL, "ndjson":
+		return ExportJSONL, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q (csv, xlsx, jsonl)", s)
+}
+
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ExportJSONL:
+		return "application/x-ndjson"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+func (f ExportFormat) Extension() string {
+	return string(f)
+}
+
+// ExportWriter receives a header row followed by data rows and encodes them
+// incrementally. Close must be called to flush the output.
+type ExportWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+func NewExportWriter(w io.Writer, format ExportFormat) ExportWriter {
+	switch format {
+	case ExportXLSX:
+		return &xlsxExportWriter{w: w}
+	case ExportJSONL:
+		return &jsonlExportWriter{w: bufio.NewWriter(w)}
+	default:
+		return &csvExportWriter{w: w}
+	}
+}
+
+// ── CSV ──
+
+type csvExportWriter struct {
+	w      io.Writer
+	csv    *csv.Writer
+	record []string
+}
+
+func (e *csvExportWriter) WriteHeader(columns []string) error {
+	if _, err := e.w.Write(utf8BOM); err != nil {
+		return err
+	}
+	e.csv = csv.NewWriter(e.w)
+	e.record = make([]string, len(columns))
+	return e.csv.Write(columns)
+}
+
+func (e *csvExportWriter) WriteRow(values []interface{}) error {
+	for i, v := range values {
+		e.record[i] = exportString(normalizeExportValue(v))
+	}
+	return e.csv.Write(e.record)
+}
+
+func (e *csvExportWriter) Close() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+// ── JSON Lines ──
+
+type jsonlExportWriter struct {
+	w       *bufio.Writer
+	columns [][]byte
+}
+
+func (e *jsonlExportWriter) WriteHeader(columns []string) error {
+	e.columns = make([][]byte, len(columns))
+	for i, c := range columns {
+		key, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		e.columns[i] = key
+	}
+	return nil
+}
+
+// WriteRow emits one JSON object per line with keys in column order.
+func (e *jsonlExportWriter) WriteRow(values []interface{}) error {
+	e.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		e.w.Write(e.columns[i])
+		e.w.WriteByte(':')
+
+		var val interface{}
+		switch raw := v.(type) {
+		case map[string]interface{}, []interface{}:
+			val = raw // keep JSON values nested rather than as text
+		default:
+			val = normalizeExportValue(v)
+			if t, ok := val.(time.Time); ok {
+				val = t.Format(time.RFC3339)
+			}
+		}
+		b, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		e.w.Write(b)
+	}
+	e.w.WriteByte('}')
+	return e.w.WriteByte('\n')
+}
+
+func (e *jsonlExportWriter) Close() error {
+	return e.w.Flush()
+}
+
+// ── XLSX ──
+
+// xlsxExportWriter uses excelize's stream writer, which spills rows to a temp
+// file instead of holding the whole sheet in memory.
+type xlsxExportWriter struct {
+	w         io.Writer
+	file      *excelize.File
+	sheet     *excelize.StreamWriter
+	row       int
+	dateStyle int
+}
+
+func (e *xlsxExportWriter) WriteHeader(columns []string) error {
+	e.file = excelize.NewFile()
+	sheet, err := e.file.NewStreamWriter("Sheet1")
+	if err != nil {
+		return err
+	}
+	e.sheet = sheet
+
+	headerStyle, err := e.file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	dateFmt := "yyyy-mm-dd hh:mm:ss"
+	if e.dateStyle, err = e.file.NewStyle(&excelize.Style{CustomNumFmt: &dateFmt}); err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, c := range columns {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: c}
+	}
+	e.row = 1
+	return e.sheet.SetRow("A1", header)
+}
+
+// WriteRow keeps numbers, booleans and timestamps as typed cells.
+func (e *xlsxExportWriter) WriteRow(values []interface{}) error {
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		switch val := normalizeExportValue(v).(type) {
+		case time.Time:
+			cells[i] = excelize.Cell{StyleID: e.dateStyle, Value: val}
+		case int64, float64, bool, nil:
+			cells[i] = val
+		default:
+			cells[i] = exportString(val)
+		}
+	}
+	e.row++
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+	return e.sheet.SetRow(cell, cells)
+}
+
+func (e *xlsxExportWriter) Close() error {
+	if e.file == nil {
+		return nil
+	}
+	defer e.file.Close()
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.file.Write(e.w)
+}
+
+// normalizeExportValue maps pgx and Go values onto string, int64, float64,
+// bool, time.Time or nil.
+func normalizeExportValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil, string, bool, int64, float64, time.Time:
+		return val
+	case int:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case int16:
+		return int64(val)
+	case float32:
+		return float64(val)
+	case []byte:
+		return string(val)
+	case [16]byte:
+		return uuid.UUID(val).String()
+	case uuid.UUID:
+		return val.String()
+	case *string:
+		if val == nil {
+			return nil
+		}
+		return *val
+	case *time.Time:
+		if val == nil {
+			return nil
+		}
+		return *val
+	case pgtype.Numeric:
+		if !val.Valid {
+			return nil
+		}
+		f, err := val.Float64Value()
+		if err != nil || !f.Valid {
+			return nil
+		}
+		return f.Float64
+	case fmt.Stringer:
+		return val.String()
+	}
+
+	// Arrays and JSON values are exported as their JSON text.
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func exportString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case time.Time:
+		return val.Format(exportTimeLayout)
+	}
+	return fmt.Sprint(v)
+}
+
+// ExportStructs writes a struct or slice of structs, using json tags as column
+// names. It stops after limit rows (0 = no limit) and reports whether it did.
+func ExportStructs(w ExportWriter, data interface{}, limit int) (bool, error) {
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Slice {
+		slice := reflect.MakeSlice(reflect.SliceOf(v.Type()), 1, 1)
+		slice.Index(0).Set(v)
+		v = slice
+	}
+
+	elem := v.Type().Elem()
+	var columns []string
+	var fields []int
+	for i := 0; i < elem.NumField(); i++ {
+		f := elem.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		columns = append(columns, name)
+		fields = append(fields, i)
+	}
+
+	if err := w.WriteHeader(columns); err != nil {
+		return false, err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if limit > 0 && i >= limit {
+			return true, nil
+		}
+		row := make([]interface{}, len(fields))
+		for j, f := range fields {
+			row[j] = v.Index(i).Field(f).Interface()
+		}
+		if err := w.WriteRow(row); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
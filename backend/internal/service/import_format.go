@@ -0,0 +1,312 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ImportFormat is a file format accepted by imports.
+type ImportFormat string
+
+const (
+	ImportCSV   ImportFormat = "csv"
+	ImportXLSX  ImportFormat = "xlsx"
+	ImportJSON  ImportFormat = "json"  // an array of objects
+	ImportJSONL ImportFormat = "jsonl" // one object per line
+)
+
+func ParseImportFormat(s string) (ImportFormat, error) {
+	switch ImportFormat(strings.ToLower(s)) {
+	case ImportCSV:
+		return ImportCSV, nil
+	case ImportXLSX:
+		return ImportXLSX, nil
+	case ImportJSON:
+		return ImportJSON, nil
+	case ImportJSONL, "ndjson":
+		return ImportJSONL, nil
+	}
+	return "", fmt.Errorf("unsupported import format %q (csv, xlsx, json, jsonl)", s)
+}
+
+// ImportSource describes an uploaded file.
+type ImportSource struct {
+	Filename    string
+	ContentType string
+	Format      string // explicit format; detected from the content when empty
+	Sheet       string // XLSX sheet to read; the first sheet when empty
+	Profile     string // mapping profile id or name; built-in aliases only when empty
+	Initiator   string // who uploaded the file
+}
+
+var (
+	zipMagic = []byte("PK\x03\x04")
+	oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1} // legacy .xls
+)
+
+// detectImportFormat picks the format of a file from its first bytes, then
+// from the declared content type or file extension; CSV is the fallback.
+func detectImportFormat(head []byte, src ImportSource) (ImportFormat, error) {
+	if src.Format != "" {
+		return ParseImportFormat(src.Format)
+	}
+	if bytes.HasPrefix(head, zipMagic) {
+		return ImportXLSX, nil
+	}
+	if bytes.HasPrefix(head, oleMagic) {
+		return "", fmt.Errorf("legacy .xls files are not supported, save the file as .xlsx")
+	}
+	text := bytes.TrimLeft(bytes.TrimPrefix(head, utf8BOM), " \t\r\n")
+	if len(text) > 0 {
+		switch text[0] {
+		case '[':
+			return ImportJSON, nil
+		case '{':
+			return ImportJSONL, nil
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(src.ContentType)
+	switch mediaType {
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return ImportXLSX, nil
+	case "application/json":
+		return ImportJSON, nil
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return ImportJSONL, nil
+	}
+	switch strings.ToLower(filepath.Ext(src.Filename)) {
+	case ".xlsx":
+		return ImportXLSX, nil
+	case ".json":
+		return ImportJSON, nil
+	case ".jsonl", ".ndjson":
+		return ImportJSONL, nil
+	}
+	return ImportCSV, nil
+}
+
+// rowReader yields the rows of an import file as records, the header first.
+// Read returns io.EOF after the last row. Errors wrapping errUnreadable end
+// the file; other errors concern a single row, which can be skipped.
+type rowReader interface {
+	Read() ([]string, error)
+	Close() error
+}
+
+// errUnreadable marks read errors after which no further rows can be read.
+var errUnreadable = errors.New("file is unreadable")
+
+// newRowReader opens r as a file of the given format. r should be buffered
+// with room to look at the first 64 KB, which decide the CSV encoding.
+func newRowReader(r *bufio.Reader, format ImportFormat, sheet string) (rowReader, error) {
+	switch format {
+	case ImportXLSX:
+		return newXLSXRows(r, sheet)
+	case ImportJSON, ImportJSONL:
+		if head, _ := r.Peek(len(utf8BOM)); bytes.Equal(head, utf8BOM) {
+			r.Discard(len(utf8BOM))
+		}
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		return &jsonRows{dec: dec, array: format == ImportJSON}, nil
+	default:
+		var src io.Reader = r
+		if head, _ := r.Peek(64 * 1024); !looksLikeUTF8(head) {
+			// Exports from Windows-era systems come in Cyrillic code page 1251.
+			src = charmap.Windows1251.NewDecoder().Reader(r)
+		}
+		reader := csv.NewReader(src)
+		reader.LazyQuotes = true
+		reader.TrimLeadingSpace = true
+		reader.ReuseRecord = true
+		return csvRows{reader}, nil
+	}
+}
+
+// looksLikeUTF8 reports whether b is valid UTF-8, allowing the last rune to
+// be cut off where the sample ends.
+func looksLikeUTF8(b []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if utf8.Valid(b) {
+			return true
+		}
+		b = b[:len(b)-1]
+	}
+	return len(b) == 0
+}
+
+// ── CSV ──
+
+type csvRows struct {
+	*csv.Reader
+}
+
+func (csvRows) Close() error {
+	return nil
+}
+
+// ── XLSX ──
+
+type xlsxRows struct {
+	file *excelize.File
+	rows *excelize.Rows
+}
+
+func newXLSXRows(r io.Reader, sheet string) (*xlsxRows, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("open xlsx: %w", err)
+	}
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		f.Close()
+		return nil, fmt.Errorf("open xlsx: the workbook has no sheets")
+	}
+	if sheet == "" {
+		sheet = sheets[0]
+	} else if !slices.Contains(sheets, sheet) {
+		f.Close()
+		return nil, fmt.Errorf("sheet %q not found, the workbook has: %s", sheet, strings.Join(sheets, ", "))
+	}
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read sheet %q: %w", sheet, err)
+	}
+	return &xlsxRows{file: f, rows: rows}, nil
+}
+
+// Read returns the next non-empty row. Cells are read unformatted, so dates
+// come as Excel serial numbers (see parseBirthDate).
+func (x *xlsxRows) Read() ([]string, error) {
+	for x.rows.Next() {
+		record, err := x.rows.Columns(excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errUnreadable, err)
+		}
+		if slices.ContainsFunc(record, func(v string) bool { return strings.TrimSpace(v) != "" }) {
+			return record, nil
+		}
+	}
+	if err := x.rows.Error(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnreadable, err)
+	}
+	return nil, io.EOF
+}
+
+func (x *xlsxRows) Close() error {
+	x.rows.Close()
+	return x.file.Close()
+}
+
+// ── JSON / JSON Lines ──
+
+// jsonRows reads objects from a JSON array or a stream of objects. The keys
+// of the first object, sorted, become the header; keys that appear only in
+// later objects are ignored.
+type jsonRows struct {
+	dec    *json.Decoder
+	array  bool
+	header []string
+	first  map[string]any // read along with the header, returned next
+}
+
+func (j *jsonRows) Read() ([]string, error) {
+	if j.header == nil {
+		if j.array {
+			if tok, err := j.dec.Token(); err != nil || tok != json.Delim('[') {
+				return nil, fmt.Errorf("%w: expected a JSON array of objects", errUnreadable)
+			}
+		}
+		obj, err := j.next()
+		if err != nil {
+			return nil, err
+		}
+		j.header = make([]string, 0, len(obj))
+		for k := range obj {
+			j.header = append(j.header, k)
+		}
+		slices.Sort(j.header)
+		j.first = obj
+		return j.header, nil
+	}
+
+	obj := j.first
+	j.first = nil
+	if obj == nil {
+		var err error
+		if obj, err = j.next(); err != nil {
+			return nil, err
+		}
+	}
+	record := make([]string, len(j.header))
+	for i, k := range j.header {
+		record[i] = jsonCell(obj[k])
+	}
+	return record, nil
+}
+
+func (j *jsonRows) Close() error {
+	return nil
+}
+
+// next decodes the next object. A malformed document ends the file; an
+// element that is valid JSON but not an object is a row error.
+func (j *jsonRows) next() (map[string]any, error) {
+	if j.array && !j.dec.More() {
+		return nil, io.EOF
+	}
+	var raw json.RawMessage
+	if err := j.dec.Decode(&raw); err != nil {
+		if err == io.EOF && !j.array {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: %v", errUnreadable, err)
+	}
+	var obj map[string]any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil || obj == nil {
+		return map[string]any{}, fmt.Errorf("expected an object")
+	}
+	return obj, nil
+}
+
+// jsonCell renders a JSON value as the text a CSV cell would hold. Arrays
+// become comma-separated lists, as in the "Навыки" column.
+func jsonCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = jsonCell(e)
+		}
+		return strings.Join(parts, ",")
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+// ErrImportNotResumable is returned when running an import that is already running or completed.
+var ErrImportNotResumable = errors.New("import is already running or completed")
+
+// maxStoredImportErrors caps the row errors kept on an import; the rest are only counted.
+const maxStoredImportErrors = 100
+
+// importRun is the in-memory state of one attempt at an import.
+type importRun struct {
+	job     *domain.Import
+	counter *countingReader
+}
+
+func (r *importRun) addError(msg string) {
+	r.job.ErrorCount++
+	if len(r.job.Errors) < maxStoredImportErrors {
+		r.job.Errors = append(r.job.Errors, msg)
+	}
+}
+
+func (r *importRun) bytesRead() int64 {
+	return r.counter.n
+}
+
+// countingReader counts the bytes read from the spooled file for progress reporting.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Spool copies an upload to disk and records it as a pending import.
+// fileType is "tickets", "managers", "business_units" or "" to detect it
+// from the header.
+func (s *ImportService) Spool(ctx context.Context, src ImportSource, fileType string, r io.Reader) (*domain.Import, error) {
+	profile, err := s.profile(ctx, src.Profile)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, _ := br.Peek(512)
+	format, err := detectImportFormat(head, src)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.spoolDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create spool dir: %w", err)
+	}
+
+	job := &domain.Import{ID: uuid.New(), Status: domain.ImportStatusPending, Format: string(format), Errors: []string{}}
+	if src.Filename != "" {
+		job.Filename = &src.Filename
+	}
+	if src.Sheet != "" {
+		job.Sheet = &src.Sheet
+	}
+	if src.Initiator != "" {
+		job.Initiator = &src.Initiator
+	}
+	if profile != nil {
+		job.ProfileID = &profile.ID
+		if fileType == "" {
+			job.Type = profile.Type
+		}
+	}
+	if fileType != "" {
+		job.Type = &fileType
+	}
+	path := filepath.Join(s.spoolDir, job.ID.String()+".upload")
+	job.SpoolPath = &path
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create spool file: %w", err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, hash), br)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("spool upload: %w", err)
+	}
+	job.SizeBytes = size
+	fileHash := hex.EncodeToString(hash.Sum(nil))
+	job.FileHash = &fileHash
+
+	if err := s.importRepo.Create(ctx, job); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("create import: %w", err)
+	}
+	return job, nil
+}
+
+// Run imports a spooled file. A failed import resumes after its last
+// committed chunk. The spool file is removed once the import completes.
+func (s *ImportService) Run(ctx context.Context, id uuid.UUID) (*ImportResult, error) {
+	job, err := s.importRepo.Claim(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := s.importRepo.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrImportNotResumable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claim import: %w", err)
+	}
+
+	run := &importRun{job: job}
+	if err := s.run(ctx, run); err != nil {
+		// The request may be gone by now; the failure must still be recorded.
+		ctx := context.WithoutCancel(ctx)
+		if ferr := s.importRepo.Fail(ctx, job.ID, err.Error()); ferr != nil {
+			return nil, fmt.Errorf("%w (mark import failed: %v)", err, ferr)
+		}
+		if failed, gerr := s.importRepo.GetByID(ctx, job.ID); gerr == nil {
+			run.job = failed
+			s.progress(run)
+		}
+		return nil, err
+	}
+
+	job.Status = domain.ImportStatusCompleted
+	if err := s.importRepo.Finish(ctx, job); err != nil {
+		return nil, fmt.Errorf("finish import: %w", err)
+	}
+	if job.SpoolPath != nil {
+		os.Remove(*job.SpoolPath)
+	}
+	s.progress(run)
+
+	// Earlier, failed attempts committed chunks too; report every ticket the
+	// import wrote, not just this attempt's.
+	ids, err := s.importRepo.ChangedRows(ctx, job.ID, "tickets")
+	if err != nil {
+		return nil, fmt.Errorf("list imported tickets: %w", err)
+	}
+	result := &ImportResult{
+		ImportID:    job.ID,
+		Total:       job.RowsRead,
+		Imported:    job.Imported,
+		Skipped:     job.Skipped,
+		Errors:      job.Errors,
+		ImportedIDs: ids,
+		Linked:      job.Linked,
+	}
+	if job.Type != nil {
+		result.Type = *job.Type
+	}
+	return result, nil
+}
+
+// run reads the spooled file and dispatches it to the importer for its type.
+func (s *ImportService) run(ctx context.Context, run *importRun) error {
+	job := run.job
+	if job.SpoolPath == nil {
+		return fmt.Errorf("import has no spooled file")
+	}
+	f, err := os.Open(*job.SpoolPath)
+	if err != nil {
+		return fmt.Errorf("open spooled file: %w", err)
+	}
+	defer f.Close()
+
+	run.counter = &countingReader{r: f}
+	var sheet string
+	if job.Sheet != nil {
+		sheet = *job.Sheet
+	}
+	var reader rowReader
+	reader, err = newRowReader(bufio.NewReaderSize(run.counter, 64*1024), ImportFormat(job.Format), sheet)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if job.ProfileID != nil {
+		profile, err := s.profileRepo.GetByID(ctx, *job.ProfileID)
+		if err != nil {
+			return fmt.Errorf("load import profile: %w", err)
+		}
+		reader = newProfileRows(reader, profile)
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+	colIdx := mapColumns(header)
+
+	fileType := detectFileType(colIdx)
+	if job.Type != nil {
+		fileType = *job.Type
+	}
+
+	switch fileType {
+	case "tickets":
+		job.Type = &fileType
+		return s.importTickets(ctx, run, reader, colIdx)
+	case "managers":
+		job.Type = &fileType
+		return s.importManagers(ctx, run, reader, colIdx)
+	case "business_units":
+		job.Type = &fileType
+		return s.importBusinessUnits(ctx, run, reader, colIdx)
+	default:
+		return fmt.Errorf("unable to detect file type from CSV headers: %v", header)
+	}
+}
+
+// profile resolves a mapping profile by id or name; nil when key is empty.
+func (s *ImportService) profile(ctx context.Context, key string) (*domain.ImportProfile, error) {
+	if key == "" {
+		return nil, nil
+	}
+	p, err := s.profileRepo.GetByKey(ctx, key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w %q", ErrUnknownProfile, key)
+	}
+	return p, err
+}
+
+// List returns the import history, newest first, and the total matching the filter.
+func (s *ImportService) List(ctx context.Context, f domain.ImportListFilter) ([]domain.Import, int, error) {
+	return s.importRepo.List(ctx, f)
+}
+
+// Get returns an import with its progress.
+func (s *ImportService) Get(ctx context.Context, id uuid.UUID) (*domain.Import, error) {
+	return s.importRepo.GetByID(ctx, id)
+}
+
+// RecoverInterrupted marks imports that were running when the server stopped
+// as failed, so they can be resumed. Call it once at startup.
+func (s *ImportService) RecoverInterrupted(ctx context.Context) (int64, error) {
+	return s.importRepo.FailInterrupted(ctx)
+}
+
+func (s *ImportService) progress(run *importRun) {
+	if s.OnProgress != nil {
+		s.OnProgress(*run.job)
+	}
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+// profileRows applies a mapping profile to the rows of an import file: mapped
+// columns are renamed to their canonical field and transformed, defaults fill
+// empty fields, and other columns pass through to the built-in aliases.
+type profileRows struct {
+	rowReader
+	profile *domain.ImportProfile
+	mapping []*domain.ColumnMapping // per source column, nil when not mapped
+	target  [][]int                 // per source column, its output columns (several for a split into fields)
+	fields  map[string]int          // canonical field → output column
+	width   int
+}
+
+func newProfileRows(r rowReader, p *domain.ImportProfile) *profileRows {
+	return &profileRows{rowReader: r, profile: p}
+}
+
+func (p *profileRows) Read() ([]string, error) {
+	record, err := p.rowReader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if p.mapping == nil {
+		return p.readHeader(record), nil
+	}
+
+	out := make([]string, p.width)
+	for i, v := range record {
+		if i >= len(p.target) {
+			break
+		}
+		values := []string{v}
+		if m := p.mapping[i]; m != nil {
+			if values, err = applyTransforms(m, v); err != nil {
+				return nil, err
+			}
+		}
+		for k, j := range p.target[i] {
+			v := strings.TrimSpace(values[k])
+			if v == "" {
+				continue
+			}
+			if out[j] == "" {
+				out[j] = v
+			} else {
+				out[j] += " " + v
+			}
+		}
+	}
+	for field, v := range p.profile.Defaults {
+		if j := p.fields[field]; out[j] == "" {
+			out[j] = v
+		}
+	}
+	return out, nil
+}
+
+func (p *profileRows) readHeader(header []string) []string {
+	bySource := make(map[string]*domain.ColumnMapping, len(p.profile.Columns))
+	for i := range p.profile.Columns {
+		m := &p.profile.Columns[i]
+		bySource[headerKey(m.Source)] = m
+	}
+
+	var out []string
+	p.fields = map[string]int{}
+	p.mapping = make([]*domain.ColumnMapping, len(header))
+	p.target = make([][]int, len(header))
+	for i, col := range header {
+		m, mapped := bySource[headerKey(col)]
+		if !mapped {
+			// Passed through; remember its canonical field so defaults fill it.
+			field := headerKey(col)
+			if alias, ok := columnAliases[field]; ok {
+				field = alias
+			}
+			if _, ok := p.fields[field]; !ok {
+				p.fields[field] = len(out)
+			}
+			p.target[i] = []int{len(out)}
+			out = append(out, col)
+			continue
+		}
+		p.mapping[i] = m
+		fields := m.Fields
+		if len(fields) == 0 {
+			fields = []string{m.Field}
+		}
+		for _, field := range fields {
+			j, ok := p.fields[field]
+			if !ok {
+				j = len(out)
+				p.fields[field] = j
+				out = append(out, field)
+			}
+			p.target[i] = append(p.target[i], j)
+		}
+	}
+	for field := range p.profile.Defaults {
+		if _, ok := p.fields[field]; !ok {
+			p.fields[field] = len(out)
+			out = append(out, field)
+		}
+	}
+	p.width = len(out)
+	return out
+}
+
+// applyTransforms runs a column's transforms over one value and returns the
+// value of each of its fields: one, or one per entry of Fields. A split into
+// fields keeps empty parts, so each part stays in its place.
+func applyTransforms(m *domain.ColumnMapping, v string) ([]string, error) {
+	if len(m.Fields) > 0 && strings.TrimSpace(v) == "" {
+		return make([]string, len(m.Fields)), nil
+	}
+	values := []string{v}
+	for _, t := range m.Transforms {
+		if t == domain.TransformSplit {
+			var parts []string
+			for _, v := range values {
+				for _, part := range strings.Split(v, m.Separator) {
+					if part = strings.TrimSpace(part); part != "" || len(m.Fields) > 0 {
+						parts = append(parts, part)
+					}
+				}
+			}
+			values = parts
+			continue
+		}
+		for i, v := range values {
+			switch t {
+			case domain.TransformTrim:
+				values[i] = strings.Join(strings.Fields(v), " ")
+			case domain.TransformUpper:
+				values[i] = strings.ToUpper(v)
+			case domain.TransformLower:
+				values[i] = strings.ToLower(v)
+			case domain.TransformEnum:
+				values[i] = mapEnum(m.Values, v)
+			case domain.TransformDate:
+				d, err := parseProfileDate(m.Layout, v)
+				if err != nil {
+					return nil, FieldError{Field: mappedField(m, i), Message: fmt.Sprintf("%s: %v", m.Source, err)}
+				}
+				values[i] = d
+			}
+		}
+	}
+	if len(m.Fields) == 0 {
+		return []string{strings.Join(values, ",")}, nil
+	}
+	if len(values) != len(m.Fields) {
+		return nil, FieldError{Field: strings.Join(m.Fields, ","), Message: fmt.Sprintf("%s: split into %d parts, expected %d (%s)",
+			m.Source, len(values), len(m.Fields), strings.Join(m.Fields, ", "))}
+	}
+	return values, nil
+}
+
+// mappedField names the field the i-th value of a column goes to.
+func mappedField(m *domain.ColumnMapping, i int) string {
+	if i < len(m.Fields) {
+		return m.Fields[i]
+	}
+	return m.Field
+}
+
+func mapEnum(values map[string]string, v string) string {
+	key := strings.TrimSpace(v)
+	for from, to := range values {
+		if strings.EqualFold(from, key) {
+			return to
+		}
+	}
+	return v
+}
+
+// parseProfileDate parses v with layout, or with the built-in date layouts
+// when layout is empty, and formats it as YYYY-MM-DD. Empty stays empty.
+func parseProfileDate(layout, v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", nil
+	}
+	if layout == "" {
+		d, ok := parseBirthDate(v)
+		if !ok {
+			return "", fmt.Errorf("unrecognised date %q", v)
+		}
+		return d.Format("2006-01-02"), nil
+	}
+	d, err := time.Parse(layout, v)
+	if err != nil {
+		return "", fmt.Errorf("date %q does not match %q", v, layout)
+	}
+	return d.Format("2006-01-02"), nil
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+var (
+	// ErrInvalidProfile is returned when an import profile fails validation.
+	ErrInvalidProfile = errors.New("invalid import profile")
+	// ErrUnknownProfile is returned when an import names a profile that does not exist.
+	ErrUnknownProfile = errors.New("unknown import profile")
+)
+
+type ImportProfileService struct {
+	repo *repository.ImportProfileRepo
+}
+
+func NewImportProfileService(repo *repository.ImportProfileRepo) *ImportProfileService {
+	return &ImportProfileService{repo: repo}
+}
+
+func (s *ImportProfileService) List(ctx context.Context) ([]domain.ImportProfile, error) {
+	return s.repo.List(ctx)
+}
+
+// Get finds a profile by id or name.
+func (s *ImportProfileService) Get(ctx context.Context, key string) (*domain.ImportProfile, error) {
+	return s.repo.GetByKey(ctx, key)
+}
+
+func (s *ImportProfileService) Create(ctx context.Context, p *domain.ImportProfile) error {
+	if err := validateProfile(p); err != nil {
+		return err
+	}
+	p.ID = uuid.New()
+	return s.repo.Insert(ctx, p)
+}
+
+func (s *ImportProfileService) Update(ctx context.Context, p *domain.ImportProfile) error {
+	if err := validateProfile(p); err != nil {
+		return err
+	}
+	return s.repo.Update(ctx, p)
+}
+
+func (s *ImportProfileService) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.repo.Delete(ctx, id)
+}
+
+var profileTransforms = []string{
+	domain.TransformTrim, domain.TransformUpper, domain.TransformLower,
+	domain.TransformSplit, domain.TransformDate, domain.TransformEnum,
+}
+
+func validateProfile(p *domain.ImportProfile) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProfile)
+	}
+	if p.Type != nil && *p.Type == "" {
+		p.Type = nil
+	}
+	if p.Type != nil && knownColumns[*p.Type] == nil {
+		return fmt.Errorf("%w: type must be tickets, managers or business_units", ErrInvalidProfile)
+	}
+	if p.Columns == nil {
+		p.Columns = []domain.ColumnMapping{}
+	}
+	if p.Defaults == nil {
+		p.Defaults = map[string]string{}
+	}
+
+	sources := map[string]bool{}
+	for i := range p.Columns {
+		m := &p.Columns[i]
+		m.Source = strings.TrimSpace(m.Source)
+		if m.Source == "" {
+			return fmt.Errorf("%w: columns[%d]: source is required", ErrInvalidProfile, i)
+		}
+		if sources[headerKey(m.Source)] {
+			return fmt.Errorf("%w: column %q is mapped twice", ErrInvalidProfile, m.Source)
+		}
+		sources[headerKey(m.Source)] = true
+		if len(m.Fields) > 0 {
+			if m.Field != "" {
+				return fmt.Errorf("%w: column %q: give either field or fields", ErrInvalidProfile, m.Source)
+			}
+			if !slices.Contains(m.Transforms, domain.TransformSplit) {
+				return fmt.Errorf("%w: column %q: fields needs the split transform", ErrInvalidProfile, m.Source)
+			}
+			for j, field := range m.Fields {
+				if !isProfileField(p.Type, field) {
+					return fmt.Errorf("%w: column %q: unknown field %q", ErrInvalidProfile, m.Source, field)
+				}
+				if slices.Contains(m.Fields[:j], field) {
+					return fmt.Errorf("%w: column %q: field %q is listed twice", ErrInvalidProfile, m.Source, field)
+				}
+			}
+		} else if !isProfileField(p.Type, m.Field) {
+			return fmt.Errorf("%w: column %q: unknown field %q", ErrInvalidProfile, m.Source, m.Field)
+		}
+		for _, t := range m.Transforms {
+			if !slices.Contains(profileTransforms, t) {
+				return fmt.Errorf("%w: column %q: unknown transform %q (%s)", ErrInvalidProfile, m.Source, t, strings.Join(profileTransforms, ", "))
+			}
+		}
+		if slices.Contains(m.Transforms, domain.TransformSplit) && m.Separator == "" {
+			return fmt.Errorf("%w: column %q: split needs a separator", ErrInvalidProfile, m.Source)
+		}
+		if slices.Contains(m.Transforms, domain.TransformEnum) && len(m.Values) == 0 {
+			return fmt.Errorf("%w: column %q: enum needs values", ErrInvalidProfile, m.Source)
+		}
+	}
+	for field := range p.Defaults {
+		if !isProfileField(p.Type, field) {
+			return fmt.Errorf("%w: defaults: unknown field %q", ErrInvalidProfile, field)
+		}
+	}
+	return nil
+}
+
+// isProfileField reports whether field is a canonical field of fileType, or
+// of any import type when fileType is nil.
+func isProfileField(fileType *string, field string) bool {
+	if fileType != nil {
+		return slices.Contains(knownColumns[*fileType], field)
+	}
+	for _, fields := range knownColumns {
+		if slices.Contains(fields, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// ── Suggestions ──
+
+// ProfileMatch is a saved profile scored against a header.
+type ProfileMatch struct {
+	Profile domain.ImportProfile `json:"profile"`
+	Score   float64              `json:"score"`   // overlap of the header and the profile's source columns, 0..1
+	Missing []string             `json:"missing"` // profile source columns absent from the header
+}
+
+// ProfileSuggestion helps set up an import for a header not seen before.
+type ProfileSuggestion struct {
+	Header   []string             `json:"header"`
+	Type     string               `json:"type"`     // detected from the draft mapping
+	Matches  []ProfileMatch       `json:"matches"`  // saved profiles, best first
+	Draft    domain.ImportProfile `json:"draft"`    // a mapping guessed from the header, ready to save
+	Unmapped []string             `json:"unmapped"` // header columns the draft could not map
+}
+
+// maxProfileMatches caps the saved profiles listed in a suggestion.
+const maxProfileMatches = 5
+
+// minHeaderSimilarity is the bigram similarity from which a header is taken
+// for a known column name.
+const minHeaderSimilarity = 0.6
+
+// headerContainsSimilarity scores a header that contains every word of a known name.
+const headerContainsSimilarity = 0.8
+
+// SuggestForFile reads the header of an uploaded file and suggests a profile for it.
+func (s *ImportProfileService) SuggestForFile(ctx context.Context, src ImportSource, r io.Reader) (*ProfileSuggestion, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, _ := br.Peek(512)
+	format, err := detectImportFormat(head, src)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := newRowReader(br, format, src.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	return s.Suggest(ctx, slices.Clone(header))
+}
+
+// Suggest ranks the saved profiles against header and drafts a new profile,
+// mapping each column to the canonical field with the closest known name.
+func (s *ImportProfileService) Suggest(ctx context.Context, header []string) (*ProfileSuggestion, error) {
+	profiles, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	for _, col := range header {
+		keys[headerKey(col)] = true
+	}
+	suggestion := &ProfileSuggestion{Header: header, Matches: []ProfileMatch{}, Unmapped: []string{}}
+	for _, p := range profiles {
+		match := ProfileMatch{Profile: p, Missing: []string{}}
+		common := 0
+		for _, m := range p.Columns {
+			if keys[headerKey(m.Source)] {
+				common++
+			} else {
+				match.Missing = append(match.Missing, m.Source)
+			}
+		}
+		if common == 0 {
+			continue
+		}
+		match.Score = float64(common) / float64(len(keys)+len(match.Missing))
+		suggestion.Matches = append(suggestion.Matches, match)
+	}
+	sort.SliceStable(suggestion.Matches, func(i, j int) bool {
+		return suggestion.Matches[i].Score > suggestion.Matches[j].Score
+	})
+	if len(suggestion.Matches) > maxProfileMatches {
+		suggestion.Matches = suggestion.Matches[:maxProfileMatches]
+	}
+
+	// Exact names first: they decide the type, which narrows the fuzzy pass.
+	fields := make([]string, len(header))
+	colIdx := map[string]int{}
+	for i, col := range header {
+		if field := exactField(col); field != "" {
+			fields[i] = field
+			colIdx[field] = i
+		}
+	}
+	suggestion.Type = detectFileType(colIdx)
+	for i, col := range header {
+		if fields[i] == "" {
+			fields[i] = closestField(col, suggestion.Type)
+		}
+	}
+
+	draft := domain.ImportProfile{Columns: []domain.ColumnMapping{}, Defaults: map[string]string{}}
+	if suggestion.Type != "unknown" {
+		draft.Type = &suggestion.Type
+	}
+	for i, col := range header {
+		if fields[i] == "" {
+			suggestion.Unmapped = append(suggestion.Unmapped, col)
+			continue
+		}
+		draft.Columns = append(draft.Columns, domain.ColumnMapping{Source: strings.TrimPrefix(col, "\xef\xbb\xbf"), Field: fields[i]})
+	}
+	suggestion.Draft = draft
+	return suggestion, nil
+}
+
+// exactField returns the canonical field a header names directly or through
+// a built-in alias.
+func exactField(col string) string {
+	key := headerKey(col)
+	if field, ok := columnAliases[key]; ok {
+		return field
+	}
+	key = strings.ReplaceAll(key, " ", "_")
+	for _, fields := range knownColumns {
+		if slices.Contains(fields, key) {
+			return key
+		}
+	}
+	return ""
+}
+
+// closestField returns the canonical field of fileType whose name or alias
+// is most similar to col, or "" if none is close enough.
+func closestField(col, fileType string) string {
+	words := normalizeText(col)
+	key := strings.Join(words, " ")
+	best, bestSim := "", 0.0
+	consider := func(name, field string) {
+		if fileType != "unknown" && !slices.Contains(knownColumns[fileType], field) {
+			return
+		}
+		nameWords := normalizeText(name)
+		sim := bigramSimilarity(key, strings.Join(nameWords, " "))
+		// "ФИО менеджера" still names "ФИО": all words of a known name count as a close match.
+		if len(nameWords) > 0 && !slices.ContainsFunc(nameWords, func(w string) bool { return !slices.Contains(words, w) }) {
+			sim = max(sim, headerContainsSimilarity)
+		}
+		if sim < minHeaderSimilarity {
+			return
+		}
+		// Ties go to the alphabetically first field, so suggestions are stable.
+		if sim > bestSim || (sim == bestSim && field < best) {
+			best, bestSim = field, sim
+		}
+	}
+	for alias, field := range columnAliases {
+		consider(alias, field)
+	}
+	for _, fields := range knownColumns {
+		for _, field := range fields {
+			consider(strings.ReplaceAll(field, "_", " "), field)
+		}
+	}
+	return best
+}
+
+// bigramSimilarity is the Dice coefficient of the character bigrams of a and b.
+func bigramSimilarity(a, b string) float64 {
+	bigrams := func(s string) map[string]int {
+		r := []rune(s)
+		m := map[string]int{}
+		for i := 0; i+1 < len(r); i++ {
+			m[string(r[i:i+2])]++
+		}
+		return m
+	}
+	ba, bb := bigrams(a), bigrams(b)
+	total, common := 0, 0
+	for g, n := range ba {
+		total += n
+		common += min(n, bb[g])
+	}
+	for _, n := range bb {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(common) / float64(total)
+}
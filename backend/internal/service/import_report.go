@@ -0,0 +1,367 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FieldError is a problem with one field of an import row.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// What importing a row would do.
+const (
+	ImportRowInsert  = "insert"
+	ImportRowUpdate  = "update"
+	ImportRowInvalid = "invalid"
+)
+
+// ImportRowReport is the dry-run outcome of one row. Errors make the row
+// invalid; warnings are values the import would ignore or replace.
+type ImportRowReport struct {
+	Line     int          `json:"line"`
+	Status   string       `json:"status"`
+	Key      string       `json:"key,omitempty"` // external_id, email or office name the row upserts on
+	Errors   []FieldError `json:"errors,omitempty"`
+	Warnings []FieldError `json:"warnings,omitempty"`
+}
+
+// ImportReport is what an import would do, computed without writing anything.
+type ImportReport struct {
+	Type           string            `json:"type"`
+	Format         string            `json:"format"`
+	Total          int               `json:"total"`
+	Inserts        int               `json:"inserts"`
+	Updates        int               `json:"updates"`
+	Invalid        int               `json:"invalid"`
+	UnknownColumns []string          `json:"unknown_columns"`
+	Rows           []ImportRowReport `json:"rows"`
+	RowsTruncated  bool              `json:"rows_truncated,omitempty"` // counters still cover all rows
+}
+
+// maxReportRows caps the rows listed in a dry-run report.
+const maxReportRows = 10000
+
+func (rep *ImportReport) add(row ImportRowReport) {
+	rep.Total++
+	switch row.Status {
+	case ImportRowInsert:
+		rep.Inserts++
+	case ImportRowUpdate:
+		rep.Updates++
+	case ImportRowInvalid:
+		rep.Invalid++
+	}
+	if len(rep.Rows) < maxReportRows {
+		rep.Rows = append(rep.Rows, row)
+	} else {
+		rep.RowsTruncated = true
+	}
+}
+
+// knownColumns are the canonical columns each import type reads (see mapColumns).
+var knownColumns = map[string][]string{
+	"tickets": {
+		"client_guid", "external_id", "subject", "body", "client_segment", "client_name", "source_channel",
+		"raw_address", "country", "region", "city", "street", "house", "attachments", "gender", "birth_date",
+	},
+	"managers": {
+		"full_name", "email", "business_unit_id", "name", "position", "is_chief_spec",
+		"skills", "is_vip_skill", "languages", "current_load",
+	},
+	"business_units": {"name", "city", "address"},
+}
+
+// clientSegments are the segments routing knows about.
+var clientSegments = []string{"Mass", "VIP", "Priority"}
+
+// unknownColumns returns the header columns an import of fileType ignores.
+func unknownColumns(header []string, fileType string) []string {
+	unknown := []string{}
+	for i, col := range header {
+		colIdx := mapColumns([]string{col})
+		known := false
+		for _, key := range knownColumns[fileType] {
+			if _, ok := colIdx[key]; ok {
+				known = true
+				break
+			}
+		}
+		if !known {
+			if i == 0 {
+				col = strings.TrimPrefix(col, "\xef\xbb\xbf")
+			}
+			unknown = append(unknown, col)
+		}
+	}
+	return unknown
+}
+
+// DryRun parses and validates a file like an import would, resolves office
+// references and checks which rows would insert or update, without writing.
+// fileType is "tickets", "managers", "business_units" or "" to detect it.
+func (s *ImportService) DryRun(ctx context.Context, src ImportSource, fileType string, r io.Reader) (*ImportReport, error) {
+	profile, err := s.profile(ctx, src.Profile)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil && fileType == "" && profile.Type != nil {
+		fileType = *profile.Type
+	}
+
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, _ := br.Peek(512)
+	format, err := detectImportFormat(head, src)
+	if err != nil {
+		return nil, err
+	}
+	var reader rowReader
+	reader, err = newRowReader(br, format, src.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	if profile != nil {
+		reader = newProfileRows(reader, profile)
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	header = slices.Clone(header) // the reader reuses its record slice
+	colIdx := mapColumns(header)
+	if fileType == "" {
+		fileType = detectFileType(colIdx)
+	}
+
+	rep := &ImportReport{Type: fileType, Format: string(format), Rows: []ImportRowReport{}}
+	switch fileType {
+	case "tickets":
+		err = s.dryRunTickets(ctx, rep, reader, colIdx)
+	case "managers":
+		err = s.dryRunManagers(ctx, rep, reader, colIdx)
+	case "business_units":
+		err = s.dryRunBusinessUnits(ctx, rep, reader, colIdx)
+	default:
+		return nil, fmt.Errorf("unable to detect file type from CSV headers: %v", header)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rep.UnknownColumns = unknownColumns(header, fileType)
+	return rep, nil
+}
+
+// keyedRows queues a dry run's rows in line order until their upsert keys
+// are looked up in the database. A key repeated in the file updates the
+// row of its first occurrence.
+type keyedRows struct {
+	seen    map[string]int // key → line of its first occurrence
+	pending []ImportRowReport
+}
+
+func newKeyedRows() *keyedRows {
+	return &keyedRows{seen: map[string]int{}}
+}
+
+// add queues a valid row; rows without a key always insert.
+func (k *keyedRows) add(row ImportRowReport, field string) {
+	if row.Key == "" {
+		row.Status = ImportRowInsert
+	} else if first, ok := k.seen[row.Key]; ok {
+		row.Status = ImportRowUpdate
+		row.Warnings = append(row.Warnings, FieldError{Field: field, Message: fmt.Sprintf("repeats line %d; the later row wins", first)})
+	} else {
+		k.seen[row.Key] = row.Line
+	}
+	k.pending = append(k.pending, row)
+}
+
+// invalid queues a row that fails validation.
+func (k *keyedRows) invalid(line int, err error) {
+	fe, ok := err.(FieldError)
+	if !ok {
+		fe = FieldError{Message: err.Error()}
+	}
+	k.pending = append(k.pending, ImportRowReport{Line: line, Status: ImportRowInvalid, Errors: []FieldError{fe}})
+}
+
+// resolve looks up the keys of the queued rows and adds them to the report.
+func (k *keyedRows) resolve(rep *ImportReport, lookup func([]string) (map[string]bool, error)) error {
+	var keys []string
+	for _, row := range k.pending {
+		if row.Status == "" {
+			keys = append(keys, row.Key)
+		}
+	}
+	existing, err := lookup(keys)
+	if err != nil {
+		return err
+	}
+	for _, row := range k.pending {
+		if row.Status == "" {
+			row.Status = ImportRowInsert
+			if existing[row.Key] {
+				row.Status = ImportRowUpdate
+			}
+		}
+		rep.add(row)
+	}
+	k.pending = k.pending[:0]
+	return nil
+}
+
+func (s *ImportService) dryRunTickets(ctx context.Context, rep *ImportReport, reader rowReader, colIdx map[string]int) error {
+	rows := newKeyedRows()
+	lookup := func(keys []string) (map[string]bool, error) {
+		return s.ticketRepo.ExistingExternalIDs(ctx, keys)
+	}
+
+	for lineNum := 2; ; lineNum++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rows.invalid(lineNum, err)
+			if errors.Is(err, errUnreadable) {
+				break
+			}
+			continue
+		}
+		t, err := parseTicketRow(record, colIdx)
+		if err != nil {
+			rows.invalid(lineNum, err)
+			continue
+		}
+
+		row := ImportRowReport{Line: lineNum}
+		if t.ExternalID != nil {
+			row.Key = *t.ExternalID
+		}
+		if t.ClientSegment != nil && !slices.Contains(clientSegments, *t.ClientSegment) {
+			row.Warnings = append(row.Warnings, FieldError{Field: "client_segment", Message: fmt.Sprintf("unknown segment %q, routed like Mass", *t.ClientSegment)})
+		}
+		if v := getCol(record, colIdx, "birth_date"); v != "" {
+			if _, ok := parseBirthDate(v); !ok {
+				row.Warnings = append(row.Warnings, FieldError{Field: "birth_date", Message: fmt.Sprintf("unrecognised date %q, ignored", v)})
+			}
+		}
+		rows.add(row, "external_id")
+
+		if len(rows.pending) >= s.chunkSize {
+			if err := rows.resolve(rep, lookup); err != nil {
+				return fmt.Errorf("look up tickets: %w", err)
+			}
+		}
+	}
+	if err := rows.resolve(rep, lookup); err != nil {
+		return fmt.Errorf("look up tickets: %w", err)
+	}
+	return nil
+}
+
+func (s *ImportService) dryRunManagers(ctx context.Context, rep *ImportReport, reader rowReader, colIdx map[string]int) error {
+	buMap, err := s.buildBusinessUnitMap(ctx)
+	if err != nil {
+		return fmt.Errorf("load business units: %w", err)
+	}
+	skills, err := loadSkillCatalog(ctx, s.skillRepo)
+	if err != nil {
+		return err
+	}
+	rows := newKeyedRows()
+
+	for lineNum := 2; ; lineNum++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rows.invalid(lineNum, err)
+			if errors.Is(err, errUnreadable) {
+				break
+			}
+			continue
+		}
+		m, err := parseManagerRow(record, colIdx, lineNum, buMap, skills)
+		if err != nil {
+			rows.invalid(lineNum, err)
+			continue
+		}
+
+		row := ImportRowReport{Line: lineNum, Key: *m.Email}
+		if getCol(record, colIdx, "email") == "" {
+			row.Warnings = append(row.Warnings, FieldError{Field: "email", Message: fmt.Sprintf("missing, generated %s", *m.Email)})
+		}
+		if v := getCol(record, colIdx, "skills"); v != "" {
+			_, problems := parseSkillList(v, skills)
+			for _, p := range problems {
+				row.Warnings = append(row.Warnings, FieldError{Field: "skills", Message: p + ", ignored"})
+			}
+		}
+		if v := getCol(record, colIdx, "current_load"); v != "" {
+			if _, err := strconv.Atoi(v); err != nil {
+				row.Warnings = append(row.Warnings, FieldError{Field: "current_load", Message: fmt.Sprintf("not a number %q, ignored", v)})
+			}
+		}
+		rows.add(row, "email")
+	}
+	if err := rows.resolve(rep, func(keys []string) (map[string]bool, error) {
+		return s.managerRepo.ExistingEmails(ctx, keys)
+	}); err != nil {
+		return fmt.Errorf("look up managers: %w", err)
+	}
+	return nil
+}
+
+func (s *ImportService) dryRunBusinessUnits(ctx context.Context, rep *ImportReport, reader rowReader, colIdx map[string]int) error {
+	rows := newKeyedRows()
+
+	for lineNum := 2; ; lineNum++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rows.invalid(lineNum, err)
+			if errors.Is(err, errUnreadable) {
+				break
+			}
+			continue
+		}
+		bu, err := parseBusinessUnitRow(record, colIdx)
+		if err != nil {
+			rows.invalid(lineNum, err)
+			continue
+		}
+		rows.add(ImportRowReport{Line: lineNum, Key: bu.Name}, "name")
+	}
+	if err := rows.resolve(rep, func(keys []string) (map[string]bool, error) {
+		units, err := s.buRepo.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		existing := make(map[string]bool, len(units))
+		for _, u := range units {
+			existing[u.Name] = true
+		}
+		return existing, nil
+	}); err != nil {
+		return fmt.Errorf("look up business units: %w", err)
+	}
+	return nil
+}
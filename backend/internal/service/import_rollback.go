@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+)
+
+// ErrImportNotRollbackable is returned when rolling back an import that is
+// still in progress or already rolled back, or whose rows are now in use.
+var ErrImportNotRollbackable = errors.New("import cannot be rolled back")
+
+// RollbackResult is the rolled back import and what undoing it did to each entity it wrote.
+type RollbackResult struct {
+	Import   *domain.Import          `json:"import"`
+	Entities map[string]RollbackStat `json:"entities"`
+}
+
+// RollbackStat counts the rows of one entity a rollback touched.
+type RollbackStat struct {
+	Deleted  int `json:"deleted"`  // rows the import inserted
+	Restored int `json:"restored"` // rows the import overwrote, given back their previous values
+	Kept     int `json:"kept"`     // rows left as they are: written by a later import, or clients still referenced
+}
+
+// Rollback undoes an import in one transaction: rows it inserted are deleted
+// and rows it overwrote get back the values logged before the import wrote
+// them. Rows a later import has written since are kept. Deleted tickets take
+// their assignments and audit trail with them; managers and offices that
+// other data now refers to make the rollback fail.
+func (s *ImportService) Rollback(ctx context.Context, id uuid.UUID) (*RollbackResult, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	job, err := s.importRepo.LockForRollback(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != domain.ImportStatusCompleted && job.Status != domain.ImportStatusFailed {
+		return nil, fmt.Errorf("%w: import is %s", ErrImportNotRollbackable, job.Status)
+	}
+
+	// Tickets go before the clients they refer to, managers before their offices.
+	deletes := []struct {
+		entity string
+		delete func(context.Context, pgx.Tx, []uuid.UUID) (int, error)
+	}{
+		{domain.ImportEntityTickets, s.ticketRepo.DeleteImported},
+		{domain.ImportEntityClients, s.clientRepo.DeleteUnreferenced},
+		{domain.ImportEntityManagers, s.managerRepo.DeleteImported},
+		{domain.ImportEntityBusinessUnits, s.buRepo.DeleteImported},
+	}
+	result := &RollbackResult{Entities: map[string]RollbackStat{}}
+	for _, d := range deletes {
+		var stat RollbackStat
+		ids, superseded, err := s.importRepo.InsertedRows(ctx, tx, id, d.entity)
+		if err != nil {
+			return nil, fmt.Errorf("list inserted %s: %w", d.entity, err)
+		}
+		if stat.Deleted, err = d.delete(ctx, tx, ids); err != nil {
+			return nil, rollbackError(d.entity, err)
+		}
+		stat.Kept = superseded + len(ids) - stat.Deleted
+
+		restored, superseded, err := s.importRepo.RestoreUpdated(ctx, tx, id, d.entity)
+		if err != nil {
+			return nil, rollbackError(d.entity, err)
+		}
+		stat.Restored = restored
+		stat.Kept += superseded
+
+		if stat != (RollbackStat{}) {
+			result.Entities[d.entity] = stat
+		}
+	}
+
+	if result.Import, err = s.importRepo.MarkRolledBack(ctx, tx, id); err != nil {
+		return nil, fmt.Errorf("mark import rolled back: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit rollback: %w", err)
+	}
+
+	// A failed import keeps its spooled file for resuming, which it no longer can.
+	if job.SpoolPath != nil {
+		os.Remove(*job.SpoolPath)
+	}
+	s.progress(&importRun{job: result.Import})
+	return result, nil
+}
+
+// rollbackError explains a foreign key violation as rows still in use.
+func rollbackError(entity string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return fmt.Errorf("%w: %s of this import are in use (%s)", ErrImportNotRollbackable, entity, pgErr.Detail)
+	}
+	return fmt.Errorf("roll back %s: %w", entity, err)
+}
@@ -2,91 +2,74 @@ package service
 
 import (
 	"context"
-	"encoding/csv"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/arslan/fire-challenge/internal/domain"
 	"github.com/arslan/fire-challenge/internal/repository"
 )
 
 type ImportService struct {
+	pool        *pgxpool.Pool
 	ticketRepo  *repository.TicketRepo
 	managerRepo *repository.ManagerRepo
 	buRepo      *repository.BusinessUnitRepo
+	clientRepo  *repository.ClientRepo
+	importRepo  *repository.ImportRepo
+	profileRepo *repository.ImportProfileRepo
+	skillRepo   *repository.SkillRepo
+	threads     *ThreadService
+	spoolDir    string
+	chunkSize   int
+
+	// OnProgress is called with a snapshot of the import after every committed
+	// chunk and when it finishes (e.g. to push it over SSE).
+	OnProgress func(domain.Import)
 }
 
-func NewImportService(tr *repository.TicketRepo, mr *repository.ManagerRepo, br *repository.BusinessUnitRepo) *ImportService {
-	return &ImportService{ticketRepo: tr, managerRepo: mr, buRepo: br}
+func NewImportService(
+	pool *pgxpool.Pool,
+	tr *repository.TicketRepo, mr *repository.ManagerRepo, br *repository.BusinessUnitRepo,
+	cr *repository.ClientRepo, ir *repository.ImportRepo, pr *repository.ImportProfileRepo, sr *repository.SkillRepo,
+	threads *ThreadService, spoolDir string, chunkSize int,
+) *ImportService {
+	return &ImportService{
+		pool: pool, ticketRepo: tr, managerRepo: mr, buRepo: br, clientRepo: cr, importRepo: ir, profileRepo: pr, skillRepo: sr,
+		threads: threads, spoolDir: spoolDir, chunkSize: chunkSize,
+	}
 }
 
 type ImportResult struct {
+	ImportID    uuid.UUID   `json:"import_id"`
 	Type        string      `json:"type"`
 	Total       int         `json:"total"`
 	Imported    int         `json:"imported"`
 	Skipped     int         `json:"skipped"`
 	Errors      []string    `json:"errors"`
 	ImportedIDs []uuid.UUID `json:"imported_ids,omitempty"`
-}
-
-// DetectAndImport reads CSV headers to auto-detect the file type, then imports accordingly.
-func (s *ImportService) DetectAndImport(ctx context.Context, r io.Reader) (*ImportResult, error) {
-	// Read all content so we can peek at headers then re-read
-	data, err := io.ReadAll(r)
-	if err != nil {
-		return nil, fmt.Errorf("read file: %w", err)
-	}
-
-	// Peek at headers
-	peek := csv.NewReader(strings.NewReader(string(data)))
-	peek.LazyQuotes = true
-	peek.TrimLeadingSpace = true
-	header, err := peek.Read()
-	if err != nil {
-		return nil, fmt.Errorf("read header: %w", err)
-	}
-
-	colIdx := mapColumns(header)
-	fileType := detectFileType(colIdx)
-
-	reader := strings.NewReader(string(data))
-	switch fileType {
-	case "tickets":
-		res, err := s.ImportTickets(ctx, reader)
-		if err != nil {
-			return nil, err
-		}
-		res.Type = "tickets"
-		return res, nil
-	case "managers":
-		res, err := s.ImportManagers(ctx, reader)
-		if err != nil {
-			return nil, err
-		}
-		res.Type = "managers"
-		return res, nil
-	case "business_units":
-		res, err := s.ImportBusinessUnits(ctx, reader)
-		if err != nil {
-			return nil, err
-		}
-		res.Type = "business_units"
-		return res, nil
-	default:
-		return nil, fmt.Errorf("unable to detect file type from CSV headers: %v", header)
-	}
+	Linked      int         `json:"linked,omitempty"` // tickets joined to an existing client thread
 }
 
 // detectFileType guesses the CSV type by checking which known columns are present.
 func detectFileType(colIdx map[string]int) string {
-	// Tickets: has "body" or "external_id" or "client_segment"
+	// Tickets: has "body", "client_guid", "external_id" or "client_segment"
 	if _, ok := colIdx["body"]; ok {
 		return "tickets"
 	}
+	if _, ok := colIdx["client_guid"]; ok {
+		return "tickets"
+	}
 	if _, ok := colIdx["external_id"]; ok {
 		return "tickets"
 	}
@@ -110,37 +93,44 @@ func detectFileType(colIdx map[string]int) string {
 	return "unknown"
 }
 
+// columnAliases maps the Russian headers of the reference exports to
+// canonical internal keys. Mapping profiles extend this per partner.
+var columnAliases = map[string]string{
+	// Business units (Russian)
+	"офис":  "name",
+	"адрес": "address",
+	// Managers (Russian)
+	"фио":       "full_name",
+	"должность": "position",
+	"навыки":    "skills",
+	"количество обращений в работе": "current_load",
+	// Tickets (Russian)
+	"guid клиента":     "client_guid",
+	"пол клиента":      "gender",
+	"дата рождения":    "birth_date",
+	"описание":         "body",
+	"вложения":         "attachments",
+	"сегмент клиента":  "client_segment",
+	"страна":           "country",
+	"область":          "region",
+	"населённый пункт": "city",
+	"улица":            "street",
+	"дом":              "house",
+}
+
+// headerKey normalizes a header cell for matching.
+func headerKey(col string) string {
+	key := strings.TrimSpace(strings.ToLower(col))
+	// Strip UTF-8 BOM from first column
+	return strings.TrimPrefix(key, "\xef\xbb\xbf")
+}
+
 // mapColumns maps both English and Russian CSV headers to canonical internal keys.
 func mapColumns(header []string) map[string]int {
-	aliases := map[string]string{
-		// Business units (Russian)
-		"офис":  "name",
-		"адрес": "address",
-		// Managers (Russian)
-		"фио":       "full_name",
-		"должность": "position",
-		"навыки":    "skills",
-		"количество обращений в работе": "current_load",
-		// Tickets (Russian)
-		"guid клиента":     "external_id",
-		"пол клиента":      "gender",
-		"дата рождения":    "birth_date",
-		"описание":         "body",
-		"вложения":         "attachments",
-		"сегмент клиента":  "client_segment",
-		"страна":           "country",
-		"область":          "region",
-		"населённый пункт": "city",
-		"улица":            "street",
-		"дом":              "house",
-	}
-
 	m := make(map[string]int)
 	for i, col := range header {
-		key := strings.TrimSpace(strings.ToLower(col))
-		// Strip UTF-8 BOM from first column
-		key = strings.TrimPrefix(key, "\xef\xbb\xbf")
-		if alias, ok := aliases[key]; ok {
+		key := headerKey(col)
+		if alias, ok := columnAliases[key]; ok {
 			m[alias] = i
 		}
 		m[key] = i
@@ -155,135 +145,276 @@ func getCol(record []string, colIdx map[string]int, key string) string {
 	return ""
 }
 
-func (s *ImportService) ImportTickets(ctx context.Context, r io.Reader) (*ImportResult, error) {
-	reader := csv.NewReader(r)
-	reader.LazyQuotes = true
-	reader.TrimLeadingSpace = true
-
-	header, err := reader.Read()
-	if err != nil {
-		return nil, fmt.Errorf("read header: %w", err)
+// importTickets streams ticket rows into the database in chunks of
+// s.chunkSize. Each chunk, its clients and the import checkpoint commit in one
+// transaction; rows up to the job's checkpoint were committed by an earlier
+// attempt and are skipped.
+func (s *ImportService) importTickets(ctx context.Context, run *importRun, reader rowReader, colIdx map[string]int) error {
+	job := run.job
+	resumeAfter := job.CheckpointRow
+
+	var chunk []domain.Ticket
+	clients := map[string]*domain.Client{}
+	var clientOrder []string
+
+	flush := func() error {
+		batch := make([]domain.Client, 0, len(clientOrder))
+		for _, guid := range clientOrder {
+			batch = append(batch, *clients[guid])
+		}
+		if err := s.commitTicketChunk(ctx, run, chunk, batch); err != nil {
+			return err
+		}
+		chunk = chunk[:0]
+		clients = map[string]*domain.Client{}
+		clientOrder = nil
+		return nil
 	}
 
-	colIdx := mapColumns(header)
-	result := &ImportResult{}
-	var tickets []domain.Ticket
-
-	for lineNum := 2; ; lineNum++ {
+	for row := 1; ; row++ {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
-		if err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("line %d: %v", lineNum, err))
+		if errors.Is(err, errUnreadable) {
+			return err
+		}
+		if row <= resumeAfter {
 			continue
 		}
-
-		t := domain.Ticket{
-			ID:     uuid.New(),
-			Status: "new",
+		job.RowsRead++
+		lineNum := row + 1
+		if err != nil {
+			run.addError(fmt.Sprintf("line %d: %v", lineNum, err))
+			job.Skipped++
+		} else if t, err := parseTicketRow(record, colIdx); err != nil {
+			run.addError(fmt.Sprintf("line %d: %v", lineNum, err))
+			job.Skipped++
+		} else {
+			if t.ClientGUID != nil {
+				c, ok := clients[*t.ClientGUID]
+				if !ok {
+					c = &domain.Client{ID: uuid.New(), GUID: *t.ClientGUID}
+					clients[c.GUID] = c
+					clientOrder = append(clientOrder, c.GUID)
+				}
+				mergeClientProfile(c, &t, getCol(record, colIdx, "gender"), getCol(record, colIdx, "birth_date"))
+			}
+			chunk = append(chunk, t)
 		}
 
-		// External ID
-		if v := getCol(record, colIdx, "external_id"); v != "" {
-			t.ExternalID = &v
+		if job.RowsRead-job.CheckpointRow >= s.chunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
 		}
+	}
+	return flush()
+}
 
-		// Body
-		t.Body = getCol(record, colIdx, "body")
+// parseTicketRow builds a ticket from one CSV record.
+func parseTicketRow(record []string, colIdx map[string]int) (domain.Ticket, error) {
+	t := domain.Ticket{
+		ID:     uuid.New(),
+		Status: "new",
+	}
 
-		// Subject — from column or generate from body
-		t.Subject = getCol(record, colIdx, "subject")
-		if t.Subject == "" && t.Body != "" {
-			t.Subject = generateSubject(t.Body)
-		}
+	// Client and ticket identity
+	if v := getCol(record, colIdx, "client_guid"); v != "" {
+		t.ClientGUID = &v
+	}
+	if v := getCol(record, colIdx, "external_id"); v != "" {
+		t.ExternalID = &v
+	}
 
-		// Client segment
-		if v := getCol(record, colIdx, "client_segment"); v != "" {
-			t.ClientSegment = &v
-		}
+	// Body
+	t.Body = getCol(record, colIdx, "body")
 
-		// Client name
-		if v := getCol(record, colIdx, "client_name"); v != "" {
-			t.ClientName = &v
-		}
+	// Subject — from column or generate from body
+	t.Subject = getCol(record, colIdx, "subject")
+	if t.Subject == "" && t.Body != "" {
+		t.Subject = generateSubject(t.Body)
+	}
 
-		// Source channel
-		if v := getCol(record, colIdx, "source_channel"); v != "" {
-			t.SourceChannel = &v
-		} else {
-			ch := "email"
-			t.SourceChannel = &ch
+	// Client segment
+	if v := getCol(record, colIdx, "client_segment"); v != "" {
+		t.ClientSegment = &v
+	}
+
+	// Client name
+	if v := getCol(record, colIdx, "client_name"); v != "" {
+		t.ClientName = &v
+	}
+
+	// Source channel
+	if v := getCol(record, colIdx, "source_channel"); v != "" {
+		t.SourceChannel = &v
+	} else {
+		ch := "email"
+		t.SourceChannel = &ch
+	}
+
+	// Raw address — from column or compose from individual fields
+	if v := getCol(record, colIdx, "raw_address"); v != "" {
+		t.RawAddress = &v
+	} else {
+		addr := composeAddress(
+			getCol(record, colIdx, "country"),
+			getCol(record, colIdx, "region"),
+			getCol(record, colIdx, "city"),
+			getCol(record, colIdx, "street"),
+			getCol(record, colIdx, "house"),
+		)
+		if addr != "" {
+			t.RawAddress = &addr
 		}
+	}
 
-		// Raw address — from column or compose from individual fields
-		if v := getCol(record, colIdx, "raw_address"); v != "" {
-			t.RawAddress = &v
+	// Attachments
+	if v := getCol(record, colIdx, "attachments"); v != "" {
+		t.Attachments = &v
+	}
+
+	// Handle tickets with only attachments (no text body)
+	if t.Subject == "" && t.Body == "" {
+		if t.Attachments != nil && *t.Attachments != "" {
+			t.Subject = "Вложение: " + *t.Attachments
+			t.Body = "Клиент отправил вложение: " + *t.Attachments
 		} else {
-			addr := composeAddress(
-				getCol(record, colIdx, "country"),
-				getCol(record, colIdx, "region"),
-				getCol(record, colIdx, "city"),
-				getCol(record, colIdx, "street"),
-				getCol(record, colIdx, "house"),
-			)
-			if addr != "" {
-				t.RawAddress = &addr
-			}
+			return t, FieldError{Field: "body", Message: "missing subject and body"}
 		}
+	}
 
-		// Attachments
-		if v := getCol(record, colIdx, "attachments"); v != "" {
-			t.Attachments = &v
-		}
+	// Without a ticket id, key the ticket by client and content so
+	// re-importing a file updates rather than duplicates it.
+	if t.ExternalID == nil && t.ClientGUID != nil {
+		key := ticketKey(*t.ClientGUID, t.Body, t.SourceChannel, t.Attachments)
+		t.ExternalID = &key
+	}
 
-		// Handle tickets with only attachments (no text body)
-		if t.Subject == "" && t.Body == "" {
-			if t.Attachments != nil && *t.Attachments != "" {
-				t.Subject = "Вложение: " + *t.Attachments
-				t.Body = "Клиент отправил вложение: " + *t.Attachments
-			} else {
-				result.Errors = append(result.Errors, fmt.Sprintf("line %d: missing subject and body", lineNum))
-				result.Skipped++
-				continue
+	return t, nil
+}
+
+// commitTicketChunk upserts a chunk's clients and tickets and advances the
+// import checkpoint in one transaction, then links the new tickets into
+// client threads.
+func (s *ImportService) commitTicketChunk(ctx context.Context, run *importRun, tickets []domain.Ticket, clients []domain.Client) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if len(clients) > 0 {
+		clientIDs, err := s.clientRepo.BulkUpsert(ctx, tx, run.job.ID, clients)
+		if err != nil {
+			return fmt.Errorf("upsert clients: %w", err)
+		}
+		for i := range tickets {
+			if g := tickets[i].ClientGUID; g != nil {
+				id := clientIDs[*g]
+				tickets[i].ClientID = &id
 			}
 		}
+	}
 
-		tickets = append(tickets, t)
+	var ids []uuid.UUID
+	if len(tickets) > 0 {
+		if ids, err = s.ticketRepo.CopyMerge(ctx, tx, run.job.ID, tickets); err != nil {
+			return fmt.Errorf("merge tickets: %w", err)
+		}
 	}
 
-	result.Total = len(tickets) + result.Skipped
+	// Only apply the new counters once the chunk is committed.
+	next := *run.job
+	next.Imported += len(ids)
+	next.Skipped += len(tickets) - len(ids)
+	next.CheckpointRow = next.RowsRead
+	next.BytesRead = run.bytesRead()
+	if err := s.importRepo.Checkpoint(ctx, tx, &next); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit chunk: %w", err)
+	}
+	*run.job = next
 
-	if len(tickets) > 0 {
-		ids, err := s.ticketRepo.BulkInsert(ctx, tickets)
+	if len(ids) > 0 {
+		linked, err := s.threads.LinkTickets(ctx, ids)
 		if err != nil {
-			return nil, fmt.Errorf("bulk insert tickets: %w", err)
+			run.addError(fmt.Sprintf("thread linking: %v", err))
 		}
-		result.Imported = len(ids)
-		result.ImportedIDs = ids
-		result.Skipped += len(tickets) - len(ids)
+		run.job.Linked += linked
 	}
+	s.progress(run)
+	return nil
+}
 
-	return result, nil
+// mergeClientProfile copies the profile fields of a ticket row onto its client;
+// later rows win, empty values never clear earlier ones.
+func mergeClientProfile(c *domain.Client, t *domain.Ticket, gender, birthDate string) {
+	if t.ClientName != nil {
+		c.FullName = t.ClientName
+	}
+	if t.ClientSegment != nil {
+		c.Segment = t.ClientSegment
+	}
+	if t.RawAddress != nil {
+		c.RawAddress = t.RawAddress
+	}
+	if gender != "" {
+		c.Gender = &gender
+	}
+	if d, ok := parseBirthDate(birthDate); ok {
+		c.BirthDate = &d
+	}
 }
 
-func (s *ImportService) ImportManagers(ctx context.Context, r io.Reader) (*ImportResult, error) {
-	reader := csv.NewReader(r)
-	reader.LazyQuotes = true
-	reader.TrimLeadingSpace = true
+// birthDateLayouts are the date formats seen in client exports.
+var birthDateLayouts = []string{"2006-01-02", "2.1.2006", "2006-01-02 15:04:05", "2006-01-02 15:04", "2.1.2006 15:04", "1/2/2006"}
 
-	header, err := reader.Read()
-	if err != nil {
-		return nil, fmt.Errorf("read header: %w", err)
+func parseBirthDate(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range birthDateLayouts {
+		if d, err := time.Parse(layout, s); err == nil {
+			return d, true
+		}
+	}
+	// XLSX cells are read raw: dates are serial day numbers since 1899-12-30.
+	if days, err := strconv.ParseFloat(s, 64); err == nil && days >= 1 && days < 2958466 {
+		return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days)), true
+	}
+	return time.Time{}, false
+}
+
+// ticketKey derives a ticket's external_id from its client and content.
+// Migration 023 computes the same key for tickets imported before it.
+func ticketKey(clientGUID, body string, channel, attachments *string) string {
+	parts := []string{body}
+	if channel != nil {
+		parts = append(parts, *channel)
+	}
+	if attachments != nil {
+		parts = append(parts, *attachments)
 	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x1f")))
+	return clientGUID + ":" + hex.EncodeToString(sum[:])[:16]
+}
 
-	colIdx := mapColumns(header)
-	result := &ImportResult{}
+// importManagers reads the whole file and inserts the managers in one batch.
+func (s *ImportService) importManagers(ctx context.Context, run *importRun, reader rowReader, colIdx map[string]int) error {
+	job := run.job
 
 	// Build office name → UUID map from existing business units in DB
 	buMap, err := s.buildBusinessUnitMap(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("load business units: %w", err)
+		return fmt.Errorf("load business units: %w", err)
+	}
+	skills, err := loadSkillCatalog(ctx, s.skillRepo)
+	if err != nil {
+		return err
 	}
 
 	var managers []domain.Manager
@@ -293,127 +424,147 @@ func (s *ImportService) ImportManagers(ctx context.Context, r io.Reader) (*Impor
 		if err == io.EOF {
 			break
 		}
+		if errors.Is(err, errUnreadable) {
+			return err
+		}
+		job.RowsRead++
 		if err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("line %d: %v", lineNum, err))
+			run.addError(fmt.Sprintf("line %d: %v", lineNum, err))
+			job.Skipped++
 			continue
 		}
 
-		m := domain.Manager{
-			ID:       uuid.New(),
-			IsActive: true,
-			MaxLoad:  50,
-		}
-
-		// Full name
-		m.FullName = getCol(record, colIdx, "full_name")
-		if m.FullName == "" {
-			result.Errors = append(result.Errors, fmt.Sprintf("line %d: missing full_name", lineNum))
-			result.Skipped++
+		m, err := parseManagerRow(record, colIdx, lineNum, buMap, skills)
+		if err != nil {
+			run.addError(fmt.Sprintf("line %d: %v", lineNum, err))
+			job.Skipped++
 			continue
 		}
 
-		// Email — from CSV or auto-generate
-		if v := getCol(record, colIdx, "email"); v != "" {
-			m.Email = &v
-		} else {
-			email := fmt.Sprintf("manager%d@freedom.kz", lineNum-1)
-			m.Email = &email
-		}
+		managers = append(managers, m)
+	}
 
-		// Business unit — from UUID column or look up by office name
-		if v := getCol(record, colIdx, "business_unit_id"); v != "" {
-			buID, err := uuid.Parse(v)
-			if err != nil {
-				result.Errors = append(result.Errors, fmt.Sprintf("line %d: invalid business_unit_id", lineNum))
-				result.Skipped++
-				continue
-			}
-			m.BusinessUnitID = buID
-		} else if officeName := getCol(record, colIdx, "name"); officeName != "" {
-			// "Офис" column maps to "name" alias
-			buID, ok := buMap[officeName]
-			if !ok {
-				result.Errors = append(result.Errors, fmt.Sprintf("line %d: office '%s' not found in DB", lineNum, officeName))
-				result.Skipped++
+	if len(managers) > 0 {
+		tx, err := s.pool.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("begin tx: %w", err)
+		}
+		defer tx.Rollback(ctx)
+		inserted, err := s.managerRepo.BulkInsert(ctx, tx, job.ID, managers)
+		if err != nil {
+			return fmt.Errorf("bulk insert managers: %w", err)
+		}
+		for _, m := range managers {
+			if m.Skills == nil {
 				continue
 			}
-			m.BusinessUnitID = buID
-		}
-
-		// Position (Должность) → is_chief_spec
-		if v := getCol(record, colIdx, "position"); v != "" {
-			m.IsChiefSpec = strings.Contains(strings.ToLower(v), "главный")
-		} else if v := getCol(record, colIdx, "is_chief_spec"); v != "" {
-			m.IsChiefSpec = strings.ToLower(v) == "true"
-		}
-
-		// Skills (Навыки) → is_vip_skill + languages
-		if v := getCol(record, colIdx, "skills"); v != "" {
-			skills := strings.Split(v, ",")
-			langs := []string{"RU"}
-			for _, skill := range skills {
-				skill = strings.TrimSpace(strings.ToUpper(skill))
-				switch skill {
-				case "VIP":
-					m.IsVIPSkill = true
-				case "ENG":
-					langs = append(langs, "EN")
-				case "KZ":
-					langs = append(langs, "KZ")
-				}
-			}
-			m.Languages = langs
-		} else {
-			if v := getCol(record, colIdx, "is_vip_skill"); v != "" {
-				m.IsVIPSkill = strings.ToLower(v) == "true"
-			}
-			if v := getCol(record, colIdx, "languages"); v != "" {
-				langs := strings.Split(v, ";")
-				for i := range langs {
-					langs[i] = strings.TrimSpace(langs[i])
-				}
-				m.Languages = langs
-			} else {
-				m.Languages = []string{"RU"}
+			if err := s.skillRepo.ReplaceManagerSkills(ctx, tx, m.ID, m.Skills); err != nil {
+				return fmt.Errorf("set skills of %s: %w", m.FullName, err)
 			}
 		}
-
-		// Current load (Количество обращений в работе)
-		if v := getCol(record, colIdx, "current_load"); v != "" {
-			if load, err := strconv.Atoi(v); err == nil {
-				m.CurrentLoad = load
-			}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("commit managers: %w", err)
 		}
+		job.Imported = inserted
+		job.Skipped += len(managers) - inserted
+	}
+	job.CheckpointRow = job.RowsRead
+	job.BytesRead = run.bytesRead()
+	return nil
+}
 
-		managers = append(managers, m)
+// parseManagerRow builds a manager from one CSV record; buMap resolves office
+// names and skills the Навыки column.
+func parseManagerRow(record []string, colIdx map[string]int, lineNum int, buMap map[string]uuid.UUID, skills skillCatalog) (domain.Manager, error) {
+	m := domain.Manager{
+		ID:       uuid.New(),
+		IsActive: true,
+		MaxLoad:  50,
 	}
 
-	result.Total = len(managers) + result.Skipped
+	// Full name
+	m.FullName = getCol(record, colIdx, "full_name")
+	if m.FullName == "" {
+		return m, FieldError{Field: "full_name", Message: "missing full_name"}
+	}
 
-	if len(managers) > 0 {
-		inserted, err := s.managerRepo.BulkInsert(ctx, managers)
+	// Email — from CSV or auto-generate
+	if v := getCol(record, colIdx, "email"); v != "" {
+		m.Email = &v
+	} else {
+		email := fmt.Sprintf("manager%d@freedom.kz", lineNum-1)
+		m.Email = &email
+	}
+
+	// Business unit — from UUID column or look up by office name
+	if v := getCol(record, colIdx, "business_unit_id"); v != "" {
+		buID, err := uuid.Parse(v)
 		if err != nil {
-			return nil, fmt.Errorf("bulk insert managers: %w", err)
+			return m, FieldError{Field: "business_unit_id", Message: "invalid business_unit_id"}
+		}
+		if !slices.Contains(slices.Collect(maps.Values(buMap)), buID) {
+			return m, FieldError{Field: "business_unit_id", Message: fmt.Sprintf("business unit %s not found in DB", buID)}
 		}
-		result.Imported = inserted
-		result.Skipped += len(managers) - inserted
+		m.BusinessUnitID = buID
+	} else if officeName := getCol(record, colIdx, "name"); officeName != "" {
+		// "Офис" column maps to "name" alias
+		buID, ok := buMap[officeName]
+		if !ok {
+			return m, FieldError{Field: "name", Message: fmt.Sprintf("office '%s' not found in DB", officeName)}
+		}
+		m.BusinessUnitID = buID
+	} else {
+		return m, FieldError{Field: "name", Message: "missing office"}
 	}
 
-	return result, nil
-}
+	// Position (Должность) → is_chief_spec
+	if v := getCol(record, colIdx, "position"); v != "" {
+		m.IsChiefSpec = strings.Contains(strings.ToLower(v), "главный")
+	} else if v := getCol(record, colIdx, "is_chief_spec"); v != "" {
+		m.IsChiefSpec = strings.ToLower(v) == "true"
+	}
 
-func (s *ImportService) ImportBusinessUnits(ctx context.Context, r io.Reader) (*ImportResult, error) {
-	reader := csv.NewReader(r)
-	reader.LazyQuotes = true
-	reader.TrimLeadingSpace = true
+	// Skills (Навыки) → manager_skills; the legacy columns follow them.
+	// Every manager speaks Russian, and the position still makes a chief
+	// specialist. Unknown skills are left out (the dry run warns about them).
+	if v := getCol(record, colIdx, "skills"); v != "" {
+		m.Skills, _ = parseSkillList(v, skills)
+		m.Skills = withSkill(m.Skills, skills, "RU")
+		if m.IsChiefSpec {
+			m.Skills = withSkill(m.Skills, skills, domain.SkillChiefSpec)
+		}
+		m.IsVIPSkill = hasSkill(m.Skills, domain.SkillVIP)
+		m.IsChiefSpec = hasSkill(m.Skills, domain.SkillChiefSpec)
+		m.Languages = languageCodes(m.Skills)
+	} else {
+		if v := getCol(record, colIdx, "is_vip_skill"); v != "" {
+			m.IsVIPSkill = strings.ToLower(v) == "true"
+		}
+		if v := getCol(record, colIdx, "languages"); v != "" {
+			// "RU;EN" in CSV; JSON arrays arrive comma-separated
+			langs := strings.FieldsFunc(v, func(r rune) bool { return r == ';' || r == ',' })
+			for i := range langs {
+				langs[i] = strings.TrimSpace(langs[i])
+			}
+			m.Languages = langs
+		} else {
+			m.Languages = []string{"RU"}
+		}
+	}
 
-	header, err := reader.Read()
-	if err != nil {
-		return nil, fmt.Errorf("read header: %w", err)
+	// Current load (Количество обращений в работе)
+	if v := getCol(record, colIdx, "current_load"); v != "" {
+		if load, err := strconv.Atoi(v); err == nil {
+			m.CurrentLoad = load
+		}
 	}
 
-	colIdx := mapColumns(header)
-	result := &ImportResult{}
+	return m, nil
+}
+
+// importBusinessUnits reads the whole file and inserts the offices in one batch.
+func (s *ImportService) importBusinessUnits(ctx context.Context, run *importRun, reader rowReader, colIdx map[string]int) error {
+	job := run.job
 	var units []domain.BusinessUnit
 
 	for lineNum := 2; ; lineNum++ {
@@ -421,48 +572,70 @@ func (s *ImportService) ImportBusinessUnits(ctx context.Context, r io.Reader) (*
 		if err == io.EOF {
 			break
 		}
+		if errors.Is(err, errUnreadable) {
+			return err
+		}
+		job.RowsRead++
 		if err != nil {
-			result.Errors = append(result.Errors, fmt.Sprintf("line %d: %v", lineNum, err))
+			run.addError(fmt.Sprintf("line %d: %v", lineNum, err))
+			job.Skipped++
 			continue
 		}
 
-		bu := domain.BusinessUnit{ID: uuid.New()}
-
-		// Name — from "name" key (maps from "Офис" alias)
-		bu.Name = getCol(record, colIdx, "name")
-
-		// City — from "city" key, or use office name (same for KZ offices)
-		bu.City = getCol(record, colIdx, "city")
-		if bu.City == "" {
-			bu.City = bu.Name
-		}
-
-		// Address
-		if v := getCol(record, colIdx, "address"); v != "" {
-			bu.Address = &v
-		}
-
-		if bu.Name == "" {
-			result.Errors = append(result.Errors, fmt.Sprintf("line %d: missing name", lineNum))
-			result.Skipped++
+		bu, err := parseBusinessUnitRow(record, colIdx)
+		if err != nil {
+			run.addError(fmt.Sprintf("line %d: %v", lineNum, err))
+			job.Skipped++
 			continue
 		}
 
 		units = append(units, bu)
 	}
 
-	result.Total = len(units) + result.Skipped
-
 	if len(units) > 0 {
-		inserted, err := s.buRepo.BulkInsert(ctx, units)
+		tx, err := s.pool.Begin(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("bulk insert business units: %w", err)
+			return fmt.Errorf("begin tx: %w", err)
+		}
+		defer tx.Rollback(ctx)
+		inserted, err := s.buRepo.BulkInsert(ctx, tx, job.ID, units)
+		if err != nil {
+			return fmt.Errorf("bulk insert business units: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("commit business units: %w", err)
 		}
-		result.Imported = inserted
-		result.Skipped += len(units) - inserted
+		job.Imported = inserted
+		job.Skipped += len(units) - inserted
+	}
+	job.CheckpointRow = job.RowsRead
+	job.BytesRead = run.bytesRead()
+	return nil
+}
+
+// parseBusinessUnitRow builds an office from one CSV record.
+func parseBusinessUnitRow(record []string, colIdx map[string]int) (domain.BusinessUnit, error) {
+	bu := domain.BusinessUnit{ID: uuid.New()}
+
+	// Name — from "name" key (maps from "Офис" alias)
+	bu.Name = getCol(record, colIdx, "name")
+
+	// City — from "city" key, or use office name (same for KZ offices)
+	bu.City = getCol(record, colIdx, "city")
+	if bu.City == "" {
+		bu.City = bu.Name
+	}
+
+	// Address
+	if v := getCol(record, colIdx, "address"); v != "" {
+		bu.Address = &v
+	}
+
+	if bu.Name == "" {
+		return bu, FieldError{Field: "name", Message: "missing name"}
 	}
 
-	return result, nil
+	return bu, nil
 }
 
 // buildBusinessUnitMap returns a map of office name/city → UUID for looking up
@@ -2,20 +2,52 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/arslan/fire-challenge/internal/domain"
 	"github.com/arslan/fire-challenge/internal/repository"
 )
 
+var (
+	// ErrInvalidManager is returned when a manager fails validation.
+	ErrInvalidManager = errors.New("invalid manager")
+	// ErrInvalidOffice is returned when an office fails validation.
+	ErrInvalidOffice = errors.New("invalid office")
+	// ErrOfficeInUse is returned when deactivating an office that still has active managers.
+	ErrOfficeInUse = errors.New("office has active managers")
+)
+
 type ManagerService struct {
-	managerRepo *repository.ManagerRepo
-	buRepo      *repository.BusinessUnitRepo
+	pool           *pgxpool.Pool
+	managerRepo    *repository.ManagerRepo
+	buRepo         *repository.BusinessUnitRepo
+	ticketRepo     *repository.TicketRepo
+	assignmentRepo *repository.AssignmentRepo
+	auditRepo      *repository.AuditRepo
+	changeRepo     *repository.EntityAuditRepo
+	skillRepo      *repository.SkillRepo
+	routing        *RoutingService
 }
 
-func NewManagerService(mr *repository.ManagerRepo, br *repository.BusinessUnitRepo) *ManagerService {
-	return &ManagerService{managerRepo: mr, buRepo: br}
+func NewManagerService(
+	pool *pgxpool.Pool,
+	mr *repository.ManagerRepo, br *repository.BusinessUnitRepo, tr *repository.TicketRepo,
+	ar *repository.AssignmentRepo, audit *repository.AuditRepo, changes *repository.EntityAuditRepo, sr *repository.SkillRepo,
+	routing *RoutingService,
+) *ManagerService {
+	return &ManagerService{
+		pool: pool, managerRepo: mr, buRepo: br, ticketRepo: tr,
+		assignmentRepo: ar, auditRepo: audit, changeRepo: changes, skillRepo: sr, routing: routing,
+	}
 }
 
 func (s *ManagerService) List(ctx context.Context) ([]domain.ManagerWithOffice, error) {
@@ -33,3 +65,499 @@ func (s *ManagerService) ListOffices(ctx context.Context) ([]domain.BusinessUnit
 func (s *ManagerService) GetOffice(ctx context.Context, id uuid.UUID) (*domain.BusinessUnit, error) {
 	return s.buRepo.GetByID(ctx, id)
 }
+
+// Changes returns the audited changes to a manager or office, newest first.
+func (s *ManagerService) Changes(ctx context.Context, entity string, id uuid.UUID) ([]domain.EntityAudit, error) {
+	return s.changeRepo.List(ctx, entity, id)
+}
+
+// ── Managers ──
+
+// Create adds an active manager with no load.
+func (s *ManagerService) Create(ctx context.Context, m *domain.Manager, actor string) error {
+	if err := s.validateManager(ctx, m); err != nil {
+		return err
+	}
+	m.ID = uuid.New()
+	m.CurrentLoad = 0
+	m.IsActive = true
+
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		if err := s.managerRepo.Insert(ctx, tx, m); err != nil {
+			return err
+		}
+		if err := s.replaceSkills(ctx, tx, m); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, domain.ImportEntityManagers, m.ID, domain.EntityActionCreate, actor, nil, m, nil)
+	})
+}
+
+// Update replaces a manager's profile: name, email, office, skills, languages
+// and max load. With skills given they replace the manager's catalogue
+// skills and the legacy skill columns follow them. Load and the active flag
+// change through routing and Deactivate/Activate only.
+func (s *ManagerService) Update(ctx context.Context, m *domain.Manager, actor string) (*domain.Manager, error) {
+	if err := s.validateManager(ctx, m); err != nil {
+		return nil, err
+	}
+	var updated *domain.Manager
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := s.managerRepo.GetForUpdate(ctx, tx, m.ID)
+		if err != nil {
+			return err
+		}
+		if updated, err = s.managerRepo.Update(ctx, tx, m); err != nil {
+			return err
+		}
+		if m.Skills != nil {
+			if before.Skills, err = s.skillRepo.ManagerSkills(ctx, m.ID); err != nil {
+				return err
+			}
+			if err := s.replaceSkills(ctx, tx, m); err != nil {
+				return err
+			}
+			updated.Skills = m.Skills
+		}
+		return s.audit(ctx, tx, domain.ImportEntityManagers, m.ID, domain.EntityActionUpdate, actor, before, updated, nil)
+	})
+	return updated, err
+}
+
+// Skills returns a manager's catalogue skills with their levels.
+func (s *ManagerService) Skills(ctx context.Context, id uuid.UUID) ([]domain.ManagerSkill, error) {
+	if _, err := s.managerRepo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.skillRepo.ManagerSkills(ctx, id)
+}
+
+// SetSkills replaces a manager's catalogue skills, keeping the rest of the profile.
+func (s *ManagerService) SetSkills(ctx context.Context, id uuid.UUID, skills []domain.ManagerSkill, actor string) (*domain.Manager, error) {
+	m, err := s.managerRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if skills == nil {
+		skills = []domain.ManagerSkill{}
+	}
+	m.Skills = skills
+	return s.Update(ctx, m, actor)
+}
+
+// replaceSkills writes m.Skills when given; the legacy columns were derived
+// from them in validateManager.
+func (s *ManagerService) replaceSkills(ctx context.Context, tx pgx.Tx, m *domain.Manager) error {
+	if m.Skills == nil {
+		return nil
+	}
+	if err := s.skillRepo.ReplaceManagerSkills(ctx, tx, m.ID, m.Skills); err != nil {
+		return fmt.Errorf("set skills: %w", err)
+	}
+	return nil
+}
+
+// Activate lets routing assign tickets to a manager again.
+func (s *ManagerService) Activate(ctx context.Context, id uuid.UUID, actor string) (*domain.Manager, error) {
+	var activated *domain.Manager
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := s.managerRepo.GetForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		office, err := s.buRepo.GetByID(ctx, before.BusinessUnitID)
+		if err != nil {
+			return fmt.Errorf("load office: %w", err)
+		}
+		if !office.IsActive {
+			return fmt.Errorf("%w: office %s is inactive", ErrInvalidManager, office.Name)
+		}
+		if activated, err = s.managerRepo.SetActive(ctx, tx, id, true); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, domain.ImportEntityManagers, id, domain.EntityActionActivate, actor, before, activated, nil)
+	})
+	return activated, err
+}
+
+// DeactivationResult is what deactivating a manager did with their open tickets.
+type DeactivationResult struct {
+	Manager   *domain.Manager  `json:"manager"`
+	HandedOff []uuid.UUID      `json:"handed_off"` // moved to the handoff manager
+	Rerouted  []uuid.UUID      `json:"rerouted"`   // routed again through the pipeline
+	Unrouted  []RerouteFailure `json:"unrouted"`   // left waiting for routing, status "enriched"
+}
+
+// RerouteFailure is an open ticket of a deactivated manager that could not be routed again.
+type RerouteFailure struct {
+	TicketID uuid.UUID `json:"ticket_id"`
+	Error    string    `json:"error"`
+}
+
+// Deactivate stops routing tickets to a manager and moves their open (not
+// resolved or closed) tickets: to handoffTo when given, keeping the tickets'
+// status, or otherwise back through the routing pipeline, which no longer
+// considers the manager. A handoff manager must have room and the skills for
+// every ticket, or nothing is changed.
+func (s *ManagerService) Deactivate(ctx context.Context, id uuid.UUID, handoffTo *uuid.UUID, actor string) (*DeactivationResult, error) {
+	if handoffTo != nil && *handoffTo == id {
+		return nil, fmt.Errorf("%w: cannot hand off tickets to the manager being deactivated", ErrInvalidManager)
+	}
+
+	result := &DeactivationResult{HandedOff: []uuid.UUID{}, Rerouted: []uuid.UUID{}, Unrouted: []RerouteFailure{}}
+	var target *domain.Manager
+	var open []uuid.UUID
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := s.managerRepo.GetForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if handoffTo != nil {
+			if target, err = s.lockHandOffTarget(ctx, tx, *handoffTo); err != nil {
+				return err
+			}
+		}
+		if result.Manager, err = s.managerRepo.SetActive(ctx, tx, id, false); err != nil {
+			return err
+		}
+		if open, err = s.assignmentRepo.ReleaseOpen(ctx, tx, id); err != nil {
+			return fmt.Errorf("release open tickets: %w", err)
+		}
+		if err := s.managerRepo.AddLoad(ctx, tx, id, -len(open)); err != nil {
+			return fmt.Errorf("release load: %w", err)
+		}
+		result.Manager.CurrentLoad = max(result.Manager.CurrentLoad-len(open), 0)
+
+		details := map[string]interface{}{"open_tickets": open}
+		if target != nil {
+			if err := s.handOff(ctx, tx, before, target, open); err != nil {
+				return err
+			}
+			details["handoff_to"] = target.ID
+			result.HandedOff = open
+		}
+		return s.audit(ctx, tx, domain.ImportEntityManagers, id, domain.EntityActionDeactivate, actor, before, result.Manager, details)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if target == nil {
+		for _, ticketID := range open {
+			if err := s.reroute(ctx, ticketID, result.Manager); err != nil {
+				result.Unrouted = append(result.Unrouted, RerouteFailure{TicketID: ticketID, Error: err.Error()})
+				continue
+			}
+			result.Rerouted = append(result.Rerouted, ticketID)
+		}
+	}
+	return result, nil
+}
+
+// lockHandOffTarget locks the manager tickets are handed off to, so their
+// status and load cannot change before the handoff commits.
+func (s *ManagerService) lockHandOffTarget(ctx context.Context, tx pgx.Tx, id uuid.UUID) (*domain.Manager, error) {
+	target, err := s.managerRepo.GetForUpdate(ctx, tx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: handoff manager %s not found", ErrInvalidManager, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !target.IsActive {
+		return nil, fmt.Errorf("%w: handoff manager %s is inactive", ErrInvalidManager, target.FullName)
+	}
+	return target, nil
+}
+
+// handOff assigns the released tickets of a deactivated manager to target,
+// refusing tickets beyond target's max load or needing skills target lacks.
+func (s *ManagerService) handOff(ctx context.Context, tx pgx.Tx, from, target *domain.Manager, tickets []uuid.UUID) error {
+	if target.MaxLoad > 0 && target.CurrentLoad+len(tickets) > target.MaxLoad {
+		return fmt.Errorf("%w: handoff manager %s has room for %d of %d open tickets",
+			ErrInvalidManager, target.FullName, max(target.MaxLoad-target.CurrentLoad, 0), len(tickets))
+	}
+	unqualified, err := s.ticketRepo.Unqualified(ctx, tx, target.ID, tickets)
+	if err != nil {
+		return fmt.Errorf("check skills: %w", err)
+	}
+	if len(unqualified) > 0 {
+		return fmt.Errorf("%w: handoff manager %s lacks the skills for %d open tickets, e.g. %s",
+			ErrInvalidManager, target.FullName, len(unqualified), unqualified[0])
+	}
+
+	reason := fmt.Sprintf("Handoff: %s deactivated", from.FullName)
+	decision := fmt.Sprintf("Manager %s deactivated — handed off to %s", from.FullName, target.FullName)
+	output, _ := json.Marshal(map[string]interface{}{"from_manager_id": from.ID, "manager_id": target.ID, "manager_name": target.FullName})
+	candidates, _ := json.Marshal([]uuid.UUID{target.ID})
+
+	for _, ticketID := range tickets {
+		if err := s.assignmentRepo.Insert(ctx, tx, &domain.TicketAssignment{
+			ID:             uuid.New(),
+			TicketID:       ticketID,
+			ManagerID:      target.ID,
+			BusinessUnitID: target.BusinessUnitID,
+			OfficeID:       target.BusinessUnitID,
+			RoutingBucket:  "handoff",
+			RoutingReason:  &reason,
+			IsCurrent:      true,
+		}); err != nil {
+			return fmt.Errorf("assign ticket %s: %w", ticketID, err)
+		}
+		if err := s.auditRepo.InsertTx(ctx, tx, &domain.AuditLog{
+			ID:         uuid.New(),
+			TicketID:   ticketID,
+			Step:       domain.AuditStepHandoff,
+			OutputData: output,
+			Decision:   decision,
+			Candidates: candidates,
+		}); err != nil {
+			return fmt.Errorf("audit ticket %s: %w", ticketID, err)
+		}
+	}
+	if err := s.managerRepo.AddLoad(ctx, tx, target.ID, len(tickets)); err != nil {
+		return fmt.Errorf("add load: %w", err)
+	}
+	return nil
+}
+
+// reroute sends a released ticket back through routing. A ticket that cannot
+// be routed is set back to "enriched" to show it awaits routing.
+func (s *ManagerService) reroute(ctx context.Context, ticketID uuid.UUID, from *domain.Manager) error {
+	output, _ := json.Marshal(map[string]interface{}{"from_manager_id": from.ID})
+	s.auditRepo.Insert(ctx, &domain.AuditLog{
+		ID:         uuid.New(),
+		TicketID:   ticketID,
+		Step:       domain.AuditStepHandoff,
+		OutputData: output,
+		Decision:   fmt.Sprintf("Manager %s deactivated — re-routing", from.FullName),
+	})
+
+	err := s.routeAgain(ctx, ticketID)
+	if err != nil {
+		if serr := s.ticketRepo.UpdateStatus(ctx, ticketID, "enriched"); serr != nil {
+			return fmt.Errorf("%w (reset status: %v)", err, serr)
+		}
+	}
+	return err
+}
+
+func (s *ManagerService) routeAgain(ctx context.Context, ticketID uuid.UUID) error {
+	ticket, err := s.ticketRepo.GetByID(ctx, ticketID)
+	if err != nil {
+		return fmt.Errorf("load ticket: %w", err)
+	}
+	ai, err := s.ticketRepo.GetAI(ctx, ticketID)
+	if err != nil {
+		return fmt.Errorf("load AI analysis: %w", err)
+	}
+	return s.routing.RouteTicket(ctx, ticket, ai)
+}
+
+func (s *ManagerService) validateManager(ctx context.Context, m *domain.Manager) error {
+	m.FullName = strings.Join(strings.Fields(m.FullName), " ")
+	if m.FullName == "" {
+		return fmt.Errorf("%w: full_name is required", ErrInvalidManager)
+	}
+	if m.Email != nil {
+		email := strings.TrimSpace(*m.Email)
+		if email == "" {
+			m.Email = nil
+		} else if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			return fmt.Errorf("%w: invalid email %q", ErrInvalidManager, email)
+		} else {
+			m.Email = &email
+		}
+	}
+	if m.MaxLoad < 0 {
+		return fmt.Errorf("%w: max_load must not be negative", ErrInvalidManager)
+	}
+
+	skills, err := loadSkillCatalog(ctx, s.skillRepo)
+	if err != nil {
+		return err
+	}
+	if m.Skills != nil {
+		// Skills given: the legacy columns follow them.
+		if m.Skills, err = skills.resolve(m.Skills); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidManager, err)
+		}
+		if len(languageCodes(m.Skills)) == 0 {
+			m.Skills = withSkill(m.Skills, skills, "RU")
+		}
+		m.IsVIPSkill = hasSkill(m.Skills, domain.SkillVIP)
+		m.IsChiefSpec = hasSkill(m.Skills, domain.SkillChiefSpec)
+		m.Languages = languageCodes(m.Skills)
+	}
+
+	langs := []string{}
+	for _, l := range m.Languages {
+		l = strings.ToUpper(strings.TrimSpace(l))
+		if sk, ok := skills.lookup(l); !ok || sk.Code != l || sk.Category != domain.SkillCategoryLanguage {
+			return fmt.Errorf("%w: unknown language %q", ErrInvalidManager, l)
+		}
+		if !slices.Contains(langs, l) {
+			langs = append(langs, l)
+		}
+	}
+	if len(langs) == 0 {
+		langs = []string{"RU"}
+	}
+	m.Languages = langs
+
+	if m.BusinessUnitID == uuid.Nil {
+		return fmt.Errorf("%w: business_unit_id is required", ErrInvalidManager)
+	}
+	office, err := s.buRepo.GetByID(ctx, m.BusinessUnitID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: business unit %s not found", ErrInvalidManager, m.BusinessUnitID)
+	}
+	if err != nil {
+		return err
+	}
+	if !office.IsActive {
+		return fmt.Errorf("%w: office %s is inactive", ErrInvalidManager, office.Name)
+	}
+	return nil
+}
+
+// ── Offices ──
+
+// CreateOffice adds an active office.
+func (s *ManagerService) CreateOffice(ctx context.Context, bu *domain.BusinessUnit, actor string) error {
+	if err := validateOffice(bu); err != nil {
+		return err
+	}
+	bu.ID = uuid.New()
+	bu.IsActive = true
+
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		if err := s.buRepo.Insert(ctx, tx, bu); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, domain.ImportEntityBusinessUnits, bu.ID, domain.EntityActionCreate, actor, nil, bu, nil)
+	})
+}
+
+// UpdateOffice replaces an office's name, city, address and coordinates.
+func (s *ManagerService) UpdateOffice(ctx context.Context, bu *domain.BusinessUnit, actor string) (*domain.BusinessUnit, error) {
+	if err := validateOffice(bu); err != nil {
+		return nil, err
+	}
+	var updated *domain.BusinessUnit
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := s.buRepo.GetForUpdate(ctx, tx, bu.ID)
+		if err != nil {
+			return err
+		}
+		if updated, err = s.buRepo.Update(ctx, tx, bu); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, domain.ImportEntityBusinessUnits, bu.ID, domain.EntityActionUpdate, actor, before, updated, nil)
+	})
+	return updated, err
+}
+
+// SetOfficeActive activates or deactivates an office. Routing only sends
+// tickets to active offices; an office with active managers cannot be
+// deactivated until they are moved or deactivated.
+func (s *ManagerService) SetOfficeActive(ctx context.Context, id uuid.UUID, active bool, actor string) (*domain.BusinessUnit, error) {
+	action := domain.EntityActionActivate
+	if !active {
+		action = domain.EntityActionDeactivate
+	}
+	var changed *domain.BusinessUnit
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := s.buRepo.GetForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if !active {
+			n, err := s.buRepo.CountActiveManagers(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return fmt.Errorf("%w: %s has %d active managers", ErrOfficeInUse, before.Name, n)
+			}
+		}
+		if changed, err = s.buRepo.SetActive(ctx, tx, id, active); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, domain.ImportEntityBusinessUnits, id, action, actor, before, changed, nil)
+	})
+	return changed, err
+}
+
+func validateOffice(bu *domain.BusinessUnit) error {
+	bu.Name = strings.TrimSpace(bu.Name)
+	bu.City = strings.TrimSpace(bu.City)
+	if bu.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidOffice)
+	}
+	if bu.City == "" {
+		bu.City = bu.Name
+	}
+	if bu.Address != nil {
+		if address := strings.TrimSpace(*bu.Address); address == "" {
+			bu.Address = nil
+		} else {
+			bu.Address = &address
+		}
+	}
+	if (bu.Lat == nil) != (bu.Lon == nil) {
+		return fmt.Errorf("%w: lat and lon must be given together", ErrInvalidOffice)
+	}
+	if bu.Lat != nil && (*bu.Lat < -90 || *bu.Lat > 90) {
+		return fmt.Errorf("%w: lat must be between -90 and 90", ErrInvalidOffice)
+	}
+	if bu.Lon != nil && (*bu.Lon < -180 || *bu.Lon > 180) {
+		return fmt.Errorf("%w: lon must be between -180 and 180", ErrInvalidOffice)
+	}
+	return nil
+}
+
+// ── Helpers ──
+
+func (s *ManagerService) inTx(ctx context.Context, fn func(pgx.Tx) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// audit records a change with the entity before and after it.
+func (s *ManagerService) audit(ctx context.Context, tx pgx.Tx, entity string, id uuid.UUID, action, actor string, before, after, details interface{}) error {
+	return recordChange(ctx, s.changeRepo, tx, entity, id, action, actor, before, after, details)
+}
+
+// recordChange records a change to a manager, office or team within tx.
+func recordChange(ctx context.Context, changes *repository.EntityAuditRepo, tx pgx.Tx, entity string, id uuid.UUID, action, actor string, before, after, details interface{}) error {
+	e := &domain.EntityAudit{Entity: entity, EntityID: id, Action: action}
+	if actor != "" {
+		e.Actor = &actor
+	}
+	var err error
+	if before != nil {
+		if e.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if e.After, err = json.Marshal(after); err != nil {
+		return err
+	}
+	if details != nil {
+		if e.Details, err = json.Marshal(details); err != nil {
+			return err
+		}
+	}
+	if err := changes.Insert(ctx, tx, e); err != nil {
+		return fmt.Errorf("audit change: %w", err)
+	}
+	return nil
+}
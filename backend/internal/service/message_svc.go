@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+// ErrInvalidMessage is returned when a ticket message or action check fails validation.
+var ErrInvalidMessage = errors.New("invalid message")
+
+// MessageService keeps the conversation on tickets: notes, replies, client
+// messages with their attachments, and the checklist of recommended actions.
+type MessageService struct {
+	pool        *pgxpool.Pool
+	messageRepo *repository.MessageRepo
+	ticketRepo  *repository.TicketRepo
+	managerRepo *repository.ManagerRepo
+	dir         string
+}
+
+func NewMessageService(pool *pgxpool.Pool, msr *repository.MessageRepo, tr *repository.TicketRepo, mr *repository.ManagerRepo, attachmentsDir string) *MessageService {
+	return &MessageService{pool: pool, messageRepo: msr, ticketRepo: tr, managerRepo: mr, dir: attachmentsDir}
+}
+
+// PostMessageRequest is a new ticket message. Attachments are links given
+// by an integration; files are uploaded alongside.
+type PostMessageRequest struct {
+	Kind        string                     `json:"kind"` // note (default), reply or client
+	Body        string                     `json:"body"`
+	Author      string                     `json:"author"` // defaults to the manager, the client or the request user
+	ManagerID   *uuid.UUID                 `json:"manager_id"`
+	DoneActions []int                      `json:"done_actions"` // recommended actions this message checks off
+	Attachments []domain.MessageAttachment `json:"attachments"`
+}
+
+// MessageUpload is a file uploaded with a message.
+type MessageUpload struct {
+	Name        string
+	ContentType string
+	Open        func() (io.ReadCloser, error)
+}
+
+// Conversation returns a ticket's messages, oldest first, and its
+// recommended actions with what is done.
+func (s *MessageService) Conversation(ctx context.Context, ticketID uuid.UUID) (*domain.TicketConversation, error) {
+	if _, err := s.ticketRepo.GetByID(ctx, ticketID); err != nil {
+		return nil, err
+	}
+	messages, err := s.messageRepo.List(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := s.actions(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.TicketConversation{Messages: messages, Actions: actions}, nil
+}
+
+// Post adds a message to a ticket, storing its uploads and checking off the
+// recommended actions it names.
+func (s *MessageService) Post(ctx context.Context, ticketID uuid.UUID, req PostMessageRequest, uploads []MessageUpload, actor string) (*domain.TicketMessage, error) {
+	msg := &domain.TicketMessage{
+		ID:          uuid.New(),
+		TicketID:    ticketID,
+		Kind:        req.Kind,
+		ManagerID:   req.ManagerID,
+		Body:        strings.TrimSpace(req.Body),
+		Attachments: []domain.MessageAttachment{},
+		DoneActions: []int{},
+	}
+	if msg.Kind == "" {
+		msg.Kind = domain.MessageKindNote
+	}
+	switch msg.Kind {
+	case domain.MessageKindNote, domain.MessageKindReply, domain.MessageKindClient:
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q (want note, reply or client)", ErrInvalidMessage, msg.Kind)
+	}
+	for _, a := range req.Attachments {
+		link, err := validateLink(a)
+		if err != nil {
+			return nil, err
+		}
+		msg.Attachments = append(msg.Attachments, link)
+	}
+	if msg.Body == "" && len(msg.Attachments) == 0 && len(uploads) == 0 {
+		return nil, fmt.Errorf("%w: body or attachments are required", ErrInvalidMessage)
+	}
+	if len(req.DoneActions) > 0 && msg.Kind == domain.MessageKindClient {
+		return nil, fmt.Errorf("%w: client messages cannot check off actions", ErrInvalidMessage)
+	}
+
+	ticket, err := s.ticketRepo.GetByID(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	var manager *domain.Manager
+	if req.ManagerID != nil {
+		manager, err = s.managerRepo.GetByID(ctx, *req.ManagerID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: manager %s not found", ErrInvalidMessage, *req.ManagerID)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	msg.Author = strings.TrimSpace(req.Author)
+	switch {
+	case msg.Author != "":
+	case manager != nil:
+		msg.Author = manager.FullName
+	case msg.Kind == domain.MessageKindClient && ticket.ClientName != nil && *ticket.ClientName != "":
+		msg.Author = *ticket.ClientName
+	case actor != "":
+		msg.Author = actor
+	default:
+		msg.Author = "unknown"
+	}
+
+	done := map[int]string{}
+	if len(req.DoneActions) > 0 {
+		actions, err := s.actions(ctx, ticketID)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range req.DoneActions {
+			if i < 0 || i >= len(actions) {
+				return nil, fmt.Errorf("%w: the ticket has no recommended action %d", ErrInvalidMessage, i)
+			}
+			if !slices.Contains(msg.DoneActions, i) {
+				msg.DoneActions = append(msg.DoneActions, i)
+				done[i] = actions[i].Text
+			}
+		}
+	}
+
+	msgDir := filepath.Join(s.dir, ticketID.String(), msg.ID.String())
+	for _, u := range uploads {
+		a, err := s.store(msgDir, msg, u)
+		if err != nil {
+			os.RemoveAll(msgDir)
+			return nil, err
+		}
+		msg.Attachments = append(msg.Attachments, *a)
+	}
+
+	err = s.inTx(ctx, func(tx pgx.Tx) error {
+		if err := s.messageRepo.Insert(ctx, tx, msg); err != nil {
+			return err
+		}
+		if len(done) > 0 {
+			if err := s.messageRepo.MarkActionsDone(ctx, tx, ticketID, done, &msg.ID, msg.Author); err != nil {
+				return fmt.Errorf("check off actions: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		os.RemoveAll(msgDir)
+		return nil, err
+	}
+	return msg, nil
+}
+
+// SetAction checks a recommended action off as done or unchecks it, outside
+// of any message, and returns the ticket's actions.
+func (s *MessageService) SetAction(ctx context.Context, ticketID uuid.UUID, index int, done bool, actor string) ([]domain.TicketAction, error) {
+	if _, err := s.ticketRepo.GetByID(ctx, ticketID); err != nil {
+		return nil, err
+	}
+	actions, err := s.actions(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(actions) {
+		return nil, fmt.Errorf("%w: the ticket has no recommended action %d", ErrInvalidMessage, index)
+	}
+
+	if done {
+		err = s.inTx(ctx, func(tx pgx.Tx) error {
+			return s.messageRepo.MarkActionsDone(ctx, tx, ticketID, map[int]string{index: actions[index].Text}, nil, actor)
+		})
+	} else {
+		err = s.messageRepo.ClearAction(ctx, ticketID, index)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.actions(ctx, ticketID)
+}
+
+// Attachment returns the path of a file uploaded with a message, with its
+// description. It returns pgx.ErrNoRows if there is no such upload.
+func (s *MessageService) Attachment(ctx context.Context, ticketID, messageID uuid.UUID, name string) (string, *domain.MessageAttachment, error) {
+	msg, err := s.messageRepo.GetByID(ctx, ticketID, messageID)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, a := range msg.Attachments {
+		if a.Uploaded && a.Name == name {
+			return filepath.Join(s.dir, ticketID.String(), messageID.String(), a.Name), &a, nil
+		}
+	}
+	return "", nil, pgx.ErrNoRows
+}
+
+// actions returns the AI's recommended actions for a ticket, each done if it
+// was checked off for the same text; none if the ticket is not enriched.
+func (s *MessageService) actions(ctx context.Context, ticketID uuid.UUID) ([]domain.TicketAction, error) {
+	actions := []domain.TicketAction{}
+	ai, err := s.ticketRepo.GetAI(ctx, ticketID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return actions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var texts []string
+	if len(ai.RecommendedActions) > 0 {
+		if err := json.Unmarshal(ai.RecommendedActions, &texts); err != nil {
+			return actions, nil
+		}
+	}
+
+	done, err := s.messageRepo.DoneActions(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	for i, text := range texts {
+		a := domain.TicketAction{Index: i, Text: text}
+		if d, ok := done[i]; ok && d.Text == text {
+			a = d
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+// store writes an upload under dir with a name unique within the message.
+func (s *MessageService) store(dir string, msg *domain.TicketMessage, u MessageUpload) (*domain.MessageAttachment, error) {
+	name := filepath.Base(strings.ReplaceAll(strings.TrimSpace(u.Name), `\`, "/"))
+	if name == "" || name == "." || name == ".." || name == "/" {
+		name = "attachment"
+	}
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for n := 2; slices.ContainsFunc(msg.Attachments, func(a domain.MessageAttachment) bool { return a.Name == name }); n++ {
+		name = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+	}
+	contentType := u.ContentType
+	if contentType == "" || contentType == "application/octet-stream" {
+		if t := mime.TypeByExtension(ext); t != "" {
+			contentType = t
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create attachments dir: %w", err)
+	}
+	src, err := u.Open()
+	if err != nil {
+		return nil, fmt.Errorf("read upload %s: %w", name, err)
+	}
+	defer src.Close()
+	dst, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("create attachment %s: %w", name, err)
+	}
+	size, err := io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store attachment %s: %w", name, err)
+	}
+
+	return &domain.MessageAttachment{
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		URL:         fmt.Sprintf("/api/v1/tickets/%s/messages/%s/attachments/%s", msg.TicketID, msg.ID, url.PathEscape(name)),
+		Uploaded:    true,
+	}, nil
+}
+
+// validateLink checks an attachment given as a link; its name defaults to
+// the last part of the URL.
+func validateLink(a domain.MessageAttachment) (domain.MessageAttachment, error) {
+	u, err := url.Parse(strings.TrimSpace(a.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return a, fmt.Errorf("%w: attachment url must be an http(s) link", ErrInvalidMessage)
+	}
+	a.URL = u.String()
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Name == "" {
+		a.Name = path.Base(u.Path)
+	}
+	if a.Name == "" || a.Name == "." || a.Name == "/" {
+		a.Name = u.Host
+	}
+	a.Uploaded = false
+	return a, nil
+}
+
+func (s *MessageService) inTx(ctx context.Context, fn func(pgx.Tx) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
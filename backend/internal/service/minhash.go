@@ -0,0 +1,95 @@
+package service
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+const (
+	minhashSize  = 64 // hash functions per signature
+	shingleWords = 2  // words per shingle
+)
+
+// minhashSeeds are fixed so signatures are comparable across runs.
+var minhashSeeds = func() [minhashSize]uint64 {
+	var seeds [minhashSize]uint64
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range seeds {
+		x = splitmix64(x)
+		seeds[i] = x
+	}
+	return seeds
+}()
+
+// normalizeText lowercases s, folds ё to е and splits it into words of
+// letters and digits, so casing and punctuation that differ between channels
+// don't affect similarity.
+func normalizeText(s string) []string {
+	s = strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// shingles returns the set of hashed word n-grams of text.
+func shingles(text string) map[uint64]struct{} {
+	words := normalizeText(text)
+	set := make(map[uint64]struct{})
+	if len(words) == 0 {
+		return set
+	}
+	n := shingleWords
+	if len(words) < n {
+		n = len(words)
+	}
+	for i := 0; i+n <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+n], " ")))
+		set[h.Sum64()] = struct{}{}
+	}
+	return set
+}
+
+// minhashSignature computes the MinHash signature of text, or nil for text
+// without words.
+func minhashSignature(text string) []uint64 {
+	set := shingles(text)
+	if len(set) == 0 {
+		return nil
+	}
+	sig := make([]uint64, minhashSize)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for sh := range set {
+		for i, seed := range minhashSeeds {
+			if v := splitmix64(sh ^ seed); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// minhashSimilarity estimates the Jaccard similarity of the shingle sets
+// behind two signatures as the share of matching positions.
+func minhashSimilarity(a, b []uint64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
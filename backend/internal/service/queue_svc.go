@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+// ErrManagerUnavailable is returned when an inactive manager pulls a ticket.
+var ErrManagerUnavailable = errors.New("manager is inactive")
+
+const (
+	queueDefaultLimit = 50
+	queueMaxLimit     = 500
+)
+
+// QueueService orders managers' work queues and hands out the next ticket.
+type QueueService struct {
+	pool           *pgxpool.Pool
+	ticketRepo     *repository.TicketRepo
+	managerRepo    *repository.ManagerRepo
+	assignmentRepo *repository.AssignmentRepo
+	auditRepo      *repository.AuditRepo
+	scoring        domain.QueueScoring
+}
+
+func NewQueueService(
+	pool *pgxpool.Pool, tr *repository.TicketRepo, mr *repository.ManagerRepo, asr *repository.AssignmentRepo, ar *repository.AuditRepo,
+	scoring domain.QueueScoring,
+) *QueueService {
+	return &QueueService{pool: pool, ticketRepo: tr, managerRepo: mr, assignmentRepo: asr, auditRepo: ar, scoring: scoring}
+}
+
+// NewQueueScoring builds the queue ordering from configuration: weights by
+// component (priority, sla, segment, age), SLA hours by client segment with
+// "default" for the rest, and segment weights.
+func NewQueueScoring(weights map[string]float64, slaHours map[string]int, segmentWeights map[string]float64) (domain.QueueScoring, error) {
+	sc := domain.QueueScoring{SLAHours: map[string]int{}, SegmentWeights: segmentWeights}
+	for name, w := range weights {
+		if w < 0 {
+			return sc, fmt.Errorf("queue weight %s must not be negative", name)
+		}
+		switch strings.ToLower(name) {
+		case "priority":
+			sc.Priority = w
+		case "sla":
+			sc.SLA = w
+		case "segment":
+			sc.Segment = w
+		case "age":
+			sc.Age = w
+		default:
+			return sc, fmt.Errorf("unknown queue weight %q (want priority, sla, segment or age)", name)
+		}
+	}
+	for segment, h := range slaHours {
+		if h <= 0 {
+			return sc, fmt.Errorf("SLA hours for %s must be positive", segment)
+		}
+		if segment == "default" {
+			sc.DefaultSLAHours = h
+		} else {
+			sc.SLAHours[segment] = h
+		}
+	}
+	if sc.DefaultSLAHours == 0 {
+		return sc, errors.New(`SLA hours need a "default" entry`)
+	}
+	return sc, nil
+}
+
+// Queue returns a manager's work queue, best ticket first: their routed
+// tickets and, while they have free capacity, unassigned tickets they have
+// the skills for.
+func (s *QueueService) Queue(ctx context.Context, managerID uuid.UUID, limit int) ([]domain.QueueItem, error) {
+	m, err := s.managerRepo.GetByID(ctx, managerID)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = queueDefaultLimit
+	}
+	limit = min(limit, queueMaxLimit)
+	return s.ticketRepo.Queue(ctx, managerID, canTakeMore(m), s.scoring, limit)
+}
+
+// Next claims the best ticket of a manager's queue: it is set in progress,
+// assigned to the manager if it came from the pool, and audited. Concurrent
+// pulls lock different tickets. It returns nil if the queue is empty.
+func (s *QueueService) Next(ctx context.Context, managerID uuid.UUID) (*domain.QueueItem, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	m, err := s.managerRepo.GetForUpdate(ctx, tx, managerID)
+	if err != nil {
+		return nil, err
+	}
+	if !m.IsActive {
+		return nil, fmt.Errorf("%w: %s", ErrManagerUnavailable, m.FullName)
+	}
+
+	item, err := s.lockNext(ctx, tx, m)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	if item.Pool {
+		if err := s.managerRepo.AddLoad(ctx, tx, m.ID, 1); err != nil {
+			return nil, fmt.Errorf("add load: %w", err)
+		}
+		item.ManagerID, item.OfficeID = &m.ID, &m.BusinessUnitID
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE tickets SET status = $2, updated_at = now() WHERE id = $1`, item.ID, domain.TicketStatusInProgress); err != nil {
+		return nil, fmt.Errorf("update ticket status: %w", err)
+	}
+	item.Status = domain.TicketStatusInProgress
+
+	source := "own queue"
+	if item.Pool {
+		source = "unassigned pool"
+	}
+	output, _ := json.Marshal(map[string]interface{}{
+		"manager_id":   m.ID,
+		"manager_name": m.FullName,
+		"score":        item.Score,
+		"pool":         item.Pool,
+		"sla_deadline": item.SLADeadline,
+	})
+	candidates, _ := json.Marshal([]uuid.UUID{m.ID})
+	if err := s.auditRepo.InsertTx(ctx, tx, &domain.AuditLog{
+		ID:         uuid.New(),
+		TicketID:   item.ID,
+		Step:       domain.AuditStepQueuePull,
+		OutputData: output,
+		Decision:   fmt.Sprintf("Pulled by %s from the %s (score %.2f)", m.FullName, source, item.Score),
+		Candidates: candidates,
+	}); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return item, nil
+}
+
+// lockNext locks the best ticket of the manager's queue and, if it comes
+// from the pool, assigns it to the manager. The queue query locks only the
+// ticket row, so the assignment is checked again under lock: a ticket moved
+// or claimed by a concurrent rebalance or routing in the meantime is passed
+// over for the next one.
+func (s *QueueService) lockNext(ctx context.Context, tx pgx.Tx, m *domain.Manager) (*domain.QueueItem, error) {
+	tried := []uuid.UUID{}
+	for {
+		item, err := s.ticketRepo.LockNextInQueue(ctx, tx, m.ID, canTakeMore(m), s.scoring, tried)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("lock next ticket: %w", err)
+		}
+		tried = append(tried, item.ID)
+
+		current, err := s.assignmentRepo.LockCurrent(ctx, tx, item.ID)
+		if err != nil {
+			return nil, fmt.Errorf("lock assignment: %w", err)
+		}
+		if !item.Pool {
+			if current != nil && *current == m.ID {
+				return item, nil
+			}
+			continue
+		}
+		if current != nil {
+			continue
+		}
+		reason := fmt.Sprintf("Queue: pulled by %s", m.FullName)
+		err = s.assignmentRepo.Insert(ctx, tx, &domain.TicketAssignment{
+			ID:             uuid.New(),
+			TicketID:       item.ID,
+			ManagerID:      m.ID,
+			BusinessUnitID: m.BusinessUnitID,
+			OfficeID:       m.BusinessUnitID,
+			RoutingBucket:  "queue",
+			RoutingReason:  &reason,
+			IsCurrent:      true,
+		})
+		if errors.Is(err, repository.ErrAlreadyAssigned) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("assign: %w", err)
+		}
+		return item, nil
+	}
+}
+
+// canTakeMore reports whether a manager may pull unassigned tickets.
+func canTakeMore(m *domain.Manager) bool {
+	return m.IsActive && (m.MaxLoad <= 0 || m.CurrentLoad < m.MaxLoad)
+}
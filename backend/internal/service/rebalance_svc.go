@@ -0,0 +1,510 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+var (
+	// ErrInvalidRebalance is returned when a rebalance request fails validation.
+	ErrInvalidRebalance = errors.New("invalid rebalance")
+	// ErrRebalanceNotRunnable is returned when applying a rebalance that is already running or completed.
+	ErrRebalanceNotRunnable = errors.New("rebalance is already running or completed")
+)
+
+const (
+	rebalanceDefaultLimit = 200
+	rebalanceMaxLimit     = 1000
+	rebalanceListLimit    = 50
+	rebalanceBucket       = "rebalance"
+)
+
+type RebalanceService struct {
+	pool           *pgxpool.Pool
+	repo           *repository.RebalanceRepo
+	ticketRepo     *repository.TicketRepo
+	managerRepo    *repository.ManagerRepo
+	assignmentRepo *repository.AssignmentRepo
+	auditRepo      *repository.AuditRepo
+	routing        *RoutingService
+
+	// OnProgress is called with a snapshot of the job after every applied,
+	// skipped or failed move, and when the job ends.
+	OnProgress func(domain.RebalanceJob)
+}
+
+func NewRebalanceService(
+	pool *pgxpool.Pool, repo *repository.RebalanceRepo, tr *repository.TicketRepo, mr *repository.ManagerRepo,
+	asr *repository.AssignmentRepo, ar *repository.AuditRepo, routing *RoutingService,
+) *RebalanceService {
+	return &RebalanceService{
+		pool: pool, repo: repo, ticketRepo: tr, managerRepo: mr, assignmentRepo: asr, auditRepo: ar, routing: routing,
+	}
+}
+
+// RebalanceRequest describes the tickets to rebalance and how to move them:
+// in reroute mode by re-running routing under Policy (typically excluding the
+// managers being relieved), in distribute mode by spreading them over Targets.
+type RebalanceRequest struct {
+	Mode    string                   `json:"mode"`
+	Filter  domain.RebalanceFilter   `json:"filter"`
+	Policy  RoutingPolicy            `json:"policy"`
+	Targets []domain.RebalanceTarget `json:"targets"`
+}
+
+// RebalancePreview is a planned job, its moves and how the selected tickets
+// would be spread across managers.
+type RebalancePreview struct {
+	Job      *domain.RebalanceJob   `json:"job"`
+	Moves    []domain.RebalanceMove `json:"moves"`
+	Managers []ManagerShift         `json:"managers"`
+}
+
+// RebalanceResult is an applied job and the tickets it moved.
+type RebalanceResult struct {
+	Job   *domain.RebalanceJob `json:"job"`
+	Moved []uuid.UUID          `json:"moved"`
+}
+
+// Preview selects the open tickets matching the filter, plans their moves and
+// stores them as a job to apply. Nothing is reassigned yet.
+func (s *RebalanceService) Preview(ctx context.Context, req RebalanceRequest, actor string) (*RebalancePreview, error) {
+	if err := validateRebalanceFilter(&req.Filter); err != nil {
+		return nil, err
+	}
+	switch req.Mode {
+	case domain.RebalanceModeReroute:
+		if len(req.Targets) > 0 {
+			return nil, fmt.Errorf("%w: targets only apply in distribute mode", ErrInvalidRebalance)
+		}
+	case domain.RebalanceModeDistribute:
+		if err := s.validateTargets(ctx, req.Targets); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: mode must be %s or %s", ErrInvalidRebalance, domain.RebalanceModeReroute, domain.RebalanceModeDistribute)
+	}
+
+	tickets, err := s.ticketRepo.ListOpenForRebalance(ctx, req.Filter, req.Filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list tickets: %w", err)
+	}
+
+	job := &domain.RebalanceJob{
+		ID:       uuid.New(),
+		Mode:     req.Mode,
+		Status:   domain.RebalanceStatusPreview,
+		Filter:   req.Filter,
+		Targets:  []domain.RebalanceTarget{},
+		Selected: len(tickets),
+	}
+	if actor != "" {
+		job.Initiator = &actor
+	}
+
+	var moves []domain.RebalanceMove
+	if req.Mode == domain.RebalanceModeReroute {
+		if job.Policy, err = json.Marshal(req.Policy); err != nil {
+			return nil, err
+		}
+		moves, err = s.planReroute(ctx, tickets, req.Policy)
+	} else {
+		job.Targets = req.Targets
+		moves, err = s.planDistribute(ctx, tickets, req.Targets)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[uuid.UUID]int{}
+	after := map[uuid.UUID]int{}
+	for _, t := range tickets {
+		current[*t.ManagerID]++
+		after[*t.ManagerID]++
+	}
+	for i := range moves {
+		m := &moves[i]
+		m.JobID, m.Position = job.ID, i
+		if m.Status == domain.RebalanceMoveFailed {
+			job.Failed++
+			continue
+		}
+		job.Planned++
+		after[m.FromManagerID]--
+		after[*m.ToManagerID]++
+	}
+	job.Unchanged = job.Selected - job.Planned - job.Failed
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if err := s.repo.Create(ctx, tx, job, moves); err != nil {
+		return nil, fmt.Errorf("create rebalance: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	shifts, err := managerShifts(ctx, s.managerRepo, current, after)
+	if err != nil {
+		return nil, err
+	}
+	return &RebalancePreview{Job: job, Moves: moves, Managers: shifts}, nil
+}
+
+// planReroute routes the tickets again under the policy. Tickets routing
+// sends back to their manager are left out; tickets it cannot route are
+// planned as failed.
+func (s *RebalanceService) planReroute(ctx context.Context, tickets []domain.Ticket, policy RoutingPolicy) ([]domain.RebalanceMove, error) {
+	results, err := s.routing.SimulateBatch(ctx, tickets, policy)
+	if errors.Is(err, ErrInvalidSimulation) {
+		return nil, fmt.Errorf("%w: policy: %v", ErrInvalidRebalance, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("simulate routing: %w", err)
+	}
+
+	moves := []domain.RebalanceMove{}
+	for i, sr := range results {
+		from := *tickets[i].ManagerID
+		switch {
+		case sr.Manager == nil:
+			msg := sr.Error
+			moves = append(moves, domain.RebalanceMove{
+				TicketID: sr.TicketID, FromManagerID: from, RoutingBucket: rebalanceBucket,
+				Status: domain.RebalanceMoveFailed, Error: &msg,
+			})
+		case sr.Manager.ID != from:
+			to, reason := sr.Manager.ID, "Rebalance: "+sr.RoutingReason
+			moves = append(moves, domain.RebalanceMove{
+				TicketID: sr.TicketID, FromManagerID: from, ToManagerID: &to, RoutingBucket: sr.RoutingBucket,
+				Reason: &reason, Status: domain.RebalanceMovePlanned,
+			})
+		}
+	}
+	return moves, nil
+}
+
+// planDistribute spreads the tickets over the targets in proportion to their
+// shares. Tickets already with a target stay while its quota lasts; the rest,
+// oldest first, go to the target furthest below its quota.
+func (s *RebalanceService) planDistribute(ctx context.Context, tickets []domain.Ticket, targets []domain.RebalanceTarget) ([]domain.RebalanceMove, error) {
+	quota := targetQuotas(targets, len(tickets))
+	load := make(map[uuid.UUID]int, len(targets))
+	names := make(map[uuid.UUID]string, len(targets))
+	for _, t := range targets {
+		m, err := s.managerRepo.GetByID(ctx, t.ManagerID)
+		if err != nil {
+			return nil, err
+		}
+		load[m.ID], names[m.ID] = m.CurrentLoad, m.FullName
+	}
+
+	var rest []domain.Ticket
+	for _, t := range tickets {
+		if quota[*t.ManagerID] > 0 {
+			quota[*t.ManagerID]--
+			continue
+		}
+		rest = append(rest, t)
+	}
+
+	moves := []domain.RebalanceMove{}
+	for _, t := range rest {
+		to := targets[0].ManagerID
+		for _, c := range targets[1:] {
+			id := c.ManagerID
+			if quota[id] > quota[to] || (quota[id] == quota[to] && load[id] < load[to]) {
+				to = id
+			}
+		}
+		quota[to]--
+		load[to]++
+		if to == *t.ManagerID {
+			continue
+		}
+		reason := fmt.Sprintf("Rebalance: target distribution — assigned to %s", names[to])
+		moves = append(moves, domain.RebalanceMove{
+			TicketID: t.ID, FromManagerID: *t.ManagerID, ToManagerID: &to, RoutingBucket: rebalanceBucket,
+			Reason: &reason, Status: domain.RebalanceMovePlanned,
+		})
+	}
+	return moves, nil
+}
+
+// targetQuotas splits n tickets by share, largest remainders first.
+func targetQuotas(targets []domain.RebalanceTarget, n int) map[uuid.UUID]int {
+	total := 0.0
+	for _, t := range targets {
+		total += t.Share
+	}
+	quota := make(map[uuid.UUID]int, len(targets))
+	remainders := make([]float64, len(targets))
+	assigned := 0
+	for i, t := range targets {
+		exact := float64(n) * t.Share / total
+		quota[t.ManagerID] = int(math.Floor(exact))
+		remainders[i] = exact - math.Floor(exact)
+		assigned += quota[t.ManagerID]
+	}
+	order := make([]int, len(targets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for _, i := range order[:n-assigned] {
+		quota[targets[i].ManagerID]++
+	}
+	return quota
+}
+
+func validateRebalanceFilter(f *domain.RebalanceFilter) error {
+	if len(f.ManagerIDs) == 0 && len(f.OfficeIDs) == 0 && f.MinAgeHours == 0 && f.MinPriority == nil && f.MaxPriority == nil {
+		return fmt.Errorf("%w: filter needs at least one of manager_ids, office_ids, min_age_hours, min_priority, max_priority", ErrInvalidRebalance)
+	}
+	if f.MinAgeHours < 0 {
+		return fmt.Errorf("%w: min_age_hours must not be negative", ErrInvalidRebalance)
+	}
+	for _, p := range []*int{f.MinPriority, f.MaxPriority} {
+		if p != nil && (*p < 1 || *p > 10) {
+			return fmt.Errorf("%w: priorities must be between 1 and 10", ErrInvalidRebalance)
+		}
+	}
+	if f.Limit == 0 {
+		f.Limit = rebalanceDefaultLimit
+	}
+	if f.Limit < 0 || f.Limit > rebalanceMaxLimit {
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidRebalance, rebalanceMaxLimit)
+	}
+	return nil
+}
+
+func (s *RebalanceService) validateTargets(ctx context.Context, targets []domain.RebalanceTarget) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("%w: distribute mode needs targets", ErrInvalidRebalance)
+	}
+	seen := make(map[uuid.UUID]bool, len(targets))
+	for _, t := range targets {
+		if t.Share <= 0 {
+			return fmt.Errorf("%w: share of manager %s must be positive", ErrInvalidRebalance, t.ManagerID)
+		}
+		if seen[t.ManagerID] {
+			return fmt.Errorf("%w: manager %s is listed twice", ErrInvalidRebalance, t.ManagerID)
+		}
+		seen[t.ManagerID] = true
+		m, err := s.managerRepo.GetByID(ctx, t.ManagerID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: manager %s not found", ErrInvalidRebalance, t.ManagerID)
+		}
+		if err != nil {
+			return err
+		}
+		if !m.IsActive {
+			return fmt.Errorf("%w: manager %s is inactive", ErrInvalidRebalance, m.FullName)
+		}
+	}
+	return nil
+}
+
+func (s *RebalanceService) List(ctx context.Context) ([]domain.RebalanceJob, error) {
+	return s.repo.List(ctx, rebalanceListLimit)
+}
+
+// Get returns a job with its progress.
+func (s *RebalanceService) Get(ctx context.Context, id uuid.UUID) (*domain.RebalanceJob, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// Moves returns a job's moves, filtered by status unless empty.
+func (s *RebalanceService) Moves(ctx context.Context, id uuid.UUID, status string) ([]domain.RebalanceMove, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Moves(ctx, id, status)
+}
+
+// RecoverInterrupted marks jobs that were running when the server stopped
+// as failed, so they can be resumed. Call it once at startup.
+func (s *RebalanceService) RecoverInterrupted(ctx context.Context) (int64, error) {
+	return s.repo.FailInterrupted(ctx)
+}
+
+// Apply carries out a previewed job. Each move is its own transaction:
+// the ticket is reassigned, both managers' loads adjusted and the move
+// audited. Tickets closed or reassigned since the preview, and moves to
+// managers who have since been deactivated, are skipped. A failed job resumes
+// with its remaining planned moves.
+func (s *RebalanceService) Apply(ctx context.Context, id uuid.UUID) (*RebalanceResult, error) {
+	job, err := s.repo.Claim(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := s.repo.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrRebalanceNotRunnable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claim rebalance: %w", err)
+	}
+	s.progress(job)
+
+	result := &RebalanceResult{Job: job, Moved: []uuid.UUID{}}
+	if err := s.apply(ctx, result); err != nil {
+		// The request may be gone by now; the failure must still be recorded.
+		ctx := context.WithoutCancel(ctx)
+		if ferr := s.repo.Fail(ctx, id, err.Error()); ferr != nil {
+			return nil, fmt.Errorf("%w (mark rebalance failed: %v)", err, ferr)
+		}
+		if failed, gerr := s.repo.GetByID(ctx, id); gerr == nil {
+			s.progress(failed)
+		}
+		return nil, err
+	}
+
+	if result.Job, err = s.repo.Finish(ctx, id); err != nil {
+		return nil, fmt.Errorf("finish rebalance: %w", err)
+	}
+	s.progress(result.Job)
+	return result, nil
+}
+
+func (s *RebalanceService) apply(ctx context.Context, result *RebalanceResult) error {
+	moves, err := s.repo.Moves(ctx, result.Job.ID, domain.RebalanceMovePlanned)
+	if err != nil {
+		return fmt.Errorf("list moves: %w", err)
+	}
+	managers, err := s.managerRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list managers: %w", err)
+	}
+	names := make(map[uuid.UUID]string, len(managers))
+	for _, m := range managers {
+		names[m.ID] = m.FullName
+	}
+
+	for _, m := range moves {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		job, applied, err := s.applyMove(ctx, result.Job, m, names)
+		if err != nil {
+			msg := err.Error()
+			job, err = s.recordMove(ctx, m, domain.RebalanceMoveFailed, &msg)
+			if err != nil {
+				return fmt.Errorf("record move of ticket %s: %w", m.TicketID, err)
+			}
+		}
+		if applied {
+			result.Moved = append(result.Moved, m.TicketID)
+		}
+		result.Job = job
+		s.progress(job)
+	}
+	return nil
+}
+
+// applyMove moves one ticket and records the outcome in the same transaction.
+func (s *RebalanceService) applyMove(ctx context.Context, job *domain.RebalanceJob, m domain.RebalanceMove, names map[uuid.UUID]string) (*domain.RebalanceJob, bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	skip := func(msg string) (*domain.RebalanceJob, bool, error) {
+		updated, err := s.repo.RecordMove(ctx, tx, job.ID, m.TicketID, domain.RebalanceMoveSkipped, &msg)
+		if err != nil {
+			return nil, false, err
+		}
+		return updated, false, tx.Commit(ctx)
+	}
+
+	target, err := s.managerRepo.GetForUpdate(ctx, tx, *m.ToManagerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return skip("target manager no longer exists")
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("lock target manager: %w", err)
+	}
+	if !target.IsActive {
+		return skip(fmt.Sprintf("target manager %s is inactive", target.FullName))
+	}
+
+	reason := ""
+	if m.Reason != nil {
+		reason = *m.Reason
+	}
+	moved, err := s.assignmentRepo.Move(ctx, tx, m.TicketID, m.FromManagerID, target, m.RoutingBucket, reason)
+	if err != nil {
+		return nil, false, fmt.Errorf("reassign: %w", err)
+	}
+	if !moved {
+		return skip("ticket was closed or reassigned after the preview")
+	}
+	if err := s.managerRepo.AddLoad(ctx, tx, m.FromManagerID, -1); err != nil {
+		return nil, false, fmt.Errorf("release load: %w", err)
+	}
+	if err := s.managerRepo.AddLoad(ctx, tx, target.ID, 1); err != nil {
+		return nil, false, fmt.Errorf("add load: %w", err)
+	}
+
+	output, _ := json.Marshal(map[string]interface{}{
+		"rebalance_id":    job.ID,
+		"mode":            job.Mode,
+		"from_manager_id": m.FromManagerID,
+		"manager_id":      target.ID,
+		"manager_name":    target.FullName,
+	})
+	candidates, _ := json.Marshal([]uuid.UUID{target.ID})
+	if err := s.auditRepo.InsertTx(ctx, tx, &domain.AuditLog{
+		ID:         uuid.New(),
+		TicketID:   m.TicketID,
+		Step:       domain.AuditStepRebalance,
+		OutputData: output,
+		Decision:   fmt.Sprintf("Rebalance (%s): moved from %s to %s", job.Mode, names[m.FromManagerID], target.FullName),
+		Candidates: candidates,
+	}); err != nil {
+		return nil, false, fmt.Errorf("audit: %w", err)
+	}
+
+	updated, err := s.repo.RecordMove(ctx, tx, job.ID, m.TicketID, domain.RebalanceMoveApplied, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("record move: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("commit tx: %w", err)
+	}
+	return updated, true, nil
+}
+
+// recordMove records the outcome of a move that could not be applied.
+func (s *RebalanceService) recordMove(ctx context.Context, m domain.RebalanceMove, status string, msg *string) (*domain.RebalanceJob, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	job, err := s.repo.RecordMove(ctx, tx, m.JobID, m.TicketID, status, msg)
+	if err != nil {
+		return nil, err
+	}
+	return job, tx.Commit(ctx)
+}
+
+func (s *RebalanceService) progress(job *domain.RebalanceJob) {
+	if s.OnProgress != nil {
+		s.OnProgress(*job)
+	}
+}
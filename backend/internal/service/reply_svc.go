@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+var (
+	// ErrInvalidReplyTemplate is returned when a reply template or a draft request fails validation.
+	ErrInvalidReplyTemplate = errors.New("invalid reply template")
+	// ErrSpamTicket is returned when a reply is drafted for a ticket classified as spam.
+	ErrSpamTicket = errors.New("spam tickets get no reply")
+)
+
+// ReplyService drafts replies to clients from the reply template library,
+// polishing them with the LLM when one is configured.
+type ReplyService struct {
+	templateRepo   *repository.ReplyTemplateRepo
+	ticketRepo     *repository.TicketRepo
+	assignmentRepo *repository.AssignmentRepo
+	managerRepo    *repository.ManagerRepo
+	buRepo         *repository.BusinessUnitRepo
+	clientRepo     *repository.ClientRepo
+	ai             *AIService
+	loc            *time.Location
+}
+
+func NewReplyService(trr *repository.ReplyTemplateRepo, tr *repository.TicketRepo, ar *repository.AssignmentRepo, mr *repository.ManagerRepo, br *repository.BusinessUnitRepo, cr *repository.ClientRepo, ai *AIService, loc *time.Location) *ReplyService {
+	return &ReplyService{templateRepo: trr, ticketRepo: tr, assignmentRepo: ar, managerRepo: mr, buRepo: br, clientRepo: cr, ai: ai, loc: loc}
+}
+
+// ── Template library ──
+
+// ListTemplates returns the library, optionally of one language only.
+func (s *ReplyService) ListTemplates(ctx context.Context, lang string) ([]domain.ReplyTemplate, error) {
+	return s.templateRepo.List(ctx, strings.ToUpper(strings.TrimSpace(lang)))
+}
+
+// CreateTemplate adds an active template.
+func (s *ReplyService) CreateTemplate(ctx context.Context, t *domain.ReplyTemplate) error {
+	if err := validateReplyTemplate(t); err != nil {
+		return err
+	}
+	t.ID = uuid.New()
+	t.IsActive = true
+	return s.templateRepo.Insert(ctx, t)
+}
+
+// UpdateTemplate replaces a template; set is_active to false to disable it.
+func (s *ReplyService) UpdateTemplate(ctx context.Context, t *domain.ReplyTemplate) error {
+	if err := validateReplyTemplate(t); err != nil {
+		return err
+	}
+	return s.templateRepo.Update(ctx, t)
+}
+
+var replyLangs = []string{domain.LangRU, domain.LangKZ, domain.LangEN}
+
+func validateReplyTemplate(t *domain.ReplyTemplate) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidReplyTemplate)
+	}
+	t.Lang = strings.ToUpper(strings.TrimSpace(t.Lang))
+	if !slices.Contains(replyLangs, t.Lang) {
+		return fmt.Errorf("%w: lang must be RU, KZ or EN", ErrInvalidReplyTemplate)
+	}
+	t.Body = strings.TrimSpace(t.Body)
+	if t.Body == "" {
+		return fmt.Errorf("%w: body is required", ErrInvalidReplyTemplate)
+	}
+	for _, m := range placeholderRe.FindAllStringSubmatch(t.Body, -1) {
+		if !slices.Contains(replyPlaceholders, m[1]) {
+			return fmt.Errorf("%w: unknown placeholder {{%s}} (%s)", ErrInvalidReplyTemplate, m[1], strings.Join(replyPlaceholders, ", "))
+		}
+	}
+	t.TicketTypes = cleanList(t.TicketTypes, false)
+	t.Segments = cleanList(t.Segments, false)
+	return nil
+}
+
+// ── Drafts ──
+
+// DraftReplyRequest chooses how a reply is drafted; every field is optional.
+type DraftReplyRequest struct {
+	TemplateID *uuid.UUID `json:"template_id"` // use this template instead of the best match, in its language
+	Lang       string     `json:"lang"`        // reply in this language instead of the detected one
+	Polish     *bool      `json:"polish"`      // false skips the LLM; defaults to true
+}
+
+// Draft suggests a reply to a ticket: the template that fits its language,
+// type and client segment best, filled from the client and the ticket, then
+// polished by the LLM if one is configured. Without a fitting template, or
+// when the LLM is unavailable or fails, the deterministic draft is returned.
+func (s *ReplyService) Draft(ctx context.Context, ticketID uuid.UUID, req DraftReplyRequest) (*domain.DraftReply, error) {
+	ticket, err := s.ticketRepo.GetByID(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	ai, err := s.ticketRepo.GetAI(ctx, ticketID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	reply := &domain.DraftReply{TicketID: ticketID, Missing: []string{}}
+	var summary string
+	if ai != nil {
+		if ai.Lang != "" {
+			reply.Lang = normalizeReplyLang(ai.Lang)
+		}
+		if ai.Type != nil {
+			reply.Type = *ai.Type
+		}
+		if ai.Summary != nil {
+			summary = *ai.Summary
+		}
+	}
+	if reply.Lang == "" || reply.Type == "" {
+		// Not enriched yet: classify the way enrichment starts.
+		pre := PreEnrich(ticket)
+		if reply.Lang == "" {
+			reply.Lang = normalizeReplyLang(pre.Lang)
+		}
+		if reply.Type == "" {
+			reply.Type = pre.Type
+		}
+	}
+	if req.Lang != "" {
+		reply.Lang = strings.ToUpper(strings.TrimSpace(req.Lang))
+		if !slices.Contains(replyLangs, reply.Lang) {
+			return nil, fmt.Errorf("%w: lang must be RU, KZ or EN", ErrInvalidReplyTemplate)
+		}
+	}
+
+	var client *domain.Client
+	if ticket.ClientID != nil {
+		client, err = s.clientRepo.GetByKey(ctx, ticket.ClientID.String())
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+	}
+	switch {
+	case ticket.ClientSegment != nil:
+		reply.Segment = *ticket.ClientSegment
+	case client != nil && client.Segment != nil:
+		reply.Segment = *client.Segment
+	}
+
+	var tmpl *domain.ReplyTemplate
+	if req.TemplateID != nil {
+		tmpl, err = s.templateRepo.GetByID(ctx, *req.TemplateID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: template %s not found", ErrInvalidReplyTemplate, *req.TemplateID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		reply.Lang = tmpl.Lang
+	} else {
+		if reply.Type == "Спам" {
+			return nil, ErrSpamTicket
+		}
+		tmpl, err = s.templateRepo.Match(ctx, reply.Lang, reply.Type, reply.Segment)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+	}
+	body := builtinReply(reply.Lang)
+	reply.TemplateName = "builtin"
+	if tmpl != nil {
+		body = tmpl.Body
+		reply.TemplateID = &tmpl.ID
+		reply.TemplateName = tmpl.Name
+	}
+
+	values, err := s.placeholderValues(ctx, ticket, client)
+	if err != nil {
+		return nil, err
+	}
+	reply.Draft = placeholderRe.ReplaceAllStringFunc(body, func(m string) string {
+		name := placeholderRe.FindStringSubmatch(m)[1]
+		if v := values[name]; v != "" {
+			return v
+		}
+		if !slices.Contains(replyPlaceholders, name) {
+			return m
+		}
+		if !slices.Contains(reply.Missing, name) {
+			reply.Missing = append(reply.Missing, name)
+		}
+		return placeholderDefaults[name][reply.Lang]
+	})
+	reply.Text = reply.Draft
+
+	if (req.Polish == nil || *req.Polish) && s.ai.apiKey != "" {
+		polished, err := s.polish(ctx, ticket, reply, summary)
+		if err != nil {
+			log.Warn().Err(err).Str("ticket_id", ticketID.String()).Msg("OpenAI failed, using the template reply")
+		} else if polished != "" {
+			reply.Text = polished
+			reply.Polished = true
+		}
+	}
+	return reply, nil
+}
+
+// Placeholders of reply templates.
+var replyPlaceholders = []string{"client_name", "ticket_number", "subject", "created_date", "manager_name", "office"}
+
+var placeholderRe = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// placeholderDefaults stand in, per language, for placeholders the ticket
+// has no value for; the ticket number and date are always known.
+var placeholderDefaults = map[string]map[string]string{
+	"client_name":  {domain.LangRU: "уважаемый клиент", domain.LangKZ: "құрметті клиент", domain.LangEN: "valued client"},
+	"subject":      {domain.LangRU: "ваше обращение", domain.LangKZ: "сіздің өтінішіңіз", domain.LangEN: "your request"},
+	"manager_name": {domain.LangRU: "Служба поддержки", domain.LangKZ: "Қолдау қызметі", domain.LangEN: "Customer Support"},
+	"office":       {domain.LangRU: "Freedom Broker", domain.LangKZ: "Freedom Broker", domain.LangEN: "Freedom Broker"},
+}
+
+// placeholderValues fills the placeholders from the ticket, its client and
+// its assigned manager; values it has no data for are left empty.
+func (s *ReplyService) placeholderValues(ctx context.Context, ticket *domain.Ticket, client *domain.Client) (map[string]string, error) {
+	values := map[string]string{
+		"ticket_number": ticket.ID.String()[:8],
+		"subject":       strings.TrimSpace(ticket.Subject),
+		"created_date":  ticket.CreatedAt.In(s.loc).Format("02.01.2006"),
+	}
+	if ticket.ExternalID != nil && strings.TrimSpace(*ticket.ExternalID) != "" {
+		values["ticket_number"] = strings.TrimSpace(*ticket.ExternalID)
+	}
+	switch {
+	case ticket.ClientName != nil && strings.TrimSpace(*ticket.ClientName) != "":
+		values["client_name"] = strings.TrimSpace(*ticket.ClientName)
+	case client != nil && client.FullName != nil:
+		values["client_name"] = strings.TrimSpace(*client.FullName)
+	}
+
+	assignment, err := s.assignmentRepo.GetByTicketID(ctx, ticket.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	manager, err := s.managerRepo.GetByID(ctx, assignment.ManagerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values["manager_name"] = manager.FullName
+	if bu, err := s.buRepo.GetByID(ctx, manager.BusinessUnitID); err == nil {
+		values["office"] = bu.Name
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	return values, nil
+}
+
+// normalizeReplyLang maps a detected language onto a reply language; anything
+// unknown is answered in Russian.
+func normalizeReplyLang(lang string) string {
+	switch strings.ToUpper(strings.TrimSpace(lang)) {
+	case "KZ", "KAZ", "KK":
+		return domain.LangKZ
+	case "EN", "ENG":
+		return domain.LangEN
+	default:
+		return domain.LangRU
+	}
+}
+
+// builtinReply returns the general reply used when the library has no active
+// template for a language.
+func builtinReply(lang string) string {
+	switch lang {
+	case domain.LangKZ:
+		return "Сәлеметсіз бе, {{client_name}}!\n\n" +
+			"№ {{ticket_number}} өтінішіңіз үшін рахмет. Біз оны қабылдап, қарастырып жатырмыз; жауап дайын болғанда менеджер сізбен байланысады.\n\n" +
+			"Құрметпен,\n{{manager_name}}\nFreedom Broker"
+	case domain.LangEN:
+		return "Hello {{client_name}},\n\n" +
+			"Thank you for your request No. {{ticket_number}}. We have received it and are working on it; your manager will get back to you as soon as we have an answer.\n\n" +
+			"Kind regards,\n{{manager_name}}\nFreedom Broker"
+	default:
+		return "Здравствуйте, {{client_name}}!\n\n" +
+			"Благодарим за обращение № {{ticket_number}}. Мы получили его и уже работаем над ним; менеджер свяжется с вами, как только будет готов ответ.\n\n" +
+			"С уважением,\n{{manager_name}}\nFreedom Broker"
+	}
+}
+
+const replyPolishPrompt = `Ты — менеджер поддержки банка Freedom Broker. Тебе дают обращение клиента и черновик ответа, собранный из шаблона. Доработай черновик так, чтобы он отвечал на обращение по существу.
+
+Правила:
+- Пиши на языке, указанном в запросе (RU — русский, KZ — казахский, EN — английский), даже если обращение написано на другом
+- Сохрани приветствие, подпись и все факты черновика: имена, номера, даты
+- Тон: для сегментов VIP и Priority — персональный и особенно внимательный, для остальных — вежливый и деловой; в ответ на жалобы и претензии — с извинением, без оправданий
+- Не обещай компенсаций, сроков и действий, которых нет в черновике, и не добавляй данных о клиенте
+- Без markdown
+- Верни ТОЛЬКО текст ответа`
+
+// polish asks the LLM to fit the draft to the ticket.
+func (s *ReplyService) polish(ctx context.Context, ticket *domain.Ticket, reply *domain.DraftReply, summary string) (string, error) {
+	segment := reply.Segment
+	if segment == "" {
+		segment = "Mass"
+	}
+	userMsg := fmt.Sprintf("Язык ответа: %s\nСегмент: %s\nТип обращения: %s", reply.Lang, segment, reply.Type)
+	if summary != "" {
+		userMsg += fmt.Sprintf("\nРезюме: %s", summary)
+	}
+	userMsg += fmt.Sprintf("\n\nОбращение:\nТема: %s\n%s\n\nЧерновик:\n%s", ticket.Subject, ticket.Body, reply.Draft)
+
+	content, err := s.ai.chat(ctx, replyPolishPrompt, userMsg)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stripCodeFences(content)), nil
+}
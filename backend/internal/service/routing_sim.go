@@ -0,0 +1,445 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+// ErrInvalidSimulation is returned when a simulation request or its policy fails validation.
+var ErrInvalidSimulation = errors.New("invalid simulation")
+
+const (
+	replayDefaultLimit = 100
+	replayMaxLimit     = 1000
+)
+
+// RoutingPolicy is a candidate routing configuration to simulate. Zero fields
+// keep the live configuration.
+type RoutingPolicy struct {
+	// SkillRequirements replaces the stored skill requirements, applied in list
+	// order; an empty list routes without skill rules.
+	SkillRequirements  []PolicyRequirement `json:"skill_requirements"`
+	AffinityWindowDays map[string]int      `json:"affinity_window_days"` // replaces AFFINITY_WINDOW_DAYS
+	Finalists          int                 `json:"finalists"`            // managers passed from load balancing to round robin, default 2
+	ExcludeManagers    []uuid.UUID         `json:"exclude_managers"`     // e.g. managers going on leave
+	IgnoreThreads      bool                `json:"ignore_threads"`
+	IgnoreSticky       bool                `json:"ignore_sticky"` // skips the affinity stage
+}
+
+// PolicyRequirement is a skill requirement of a candidate policy.
+type PolicyRequirement struct {
+	SkillCode   string   `json:"skill_code"` // code, name or alias
+	MinLevel    int      `json:"min_level"`
+	Segments    []string `json:"segments"`
+	TicketTypes []string `json:"ticket_types"`
+	Langs       []string `json:"langs"`
+}
+
+// SimulationRequest names an existing ticket or carries one inline. AI
+// overrides the stored enrichment of an existing ticket.
+type SimulationRequest struct {
+	TicketID *uuid.UUID       `json:"ticket_id"`
+	Ticket   *domain.Ticket   `json:"ticket"`
+	AI       *domain.TicketAI `json:"ai"`
+	Policy   RoutingPolicy    `json:"policy"`
+}
+
+// SimulationResult is the decision trace of a simulated routing and the
+// manager the ticket would go to.
+type SimulationResult struct {
+	TicketID       uuid.UUID       `json:"ticket_id"`
+	Steps          []RoutingStep   `json:"steps"`
+	Manager        *domain.Manager `json:"manager"` // nil when no manager could be chosen
+	BusinessUnitID *uuid.UUID      `json:"business_unit_id,omitempty"`
+	RoutingBucket  string          `json:"routing_bucket,omitempty"`
+	RoutingReason  string          `json:"routing_reason,omitempty"`
+	Error          string          `json:"error,omitempty"`
+}
+
+// ReplayParams selects the historical tickets to replay under a policy.
+type ReplayParams struct {
+	Limit    int
+	DateFrom *time.Time
+	DateTo   *time.Time
+	Policy   RoutingPolicy
+}
+
+// ReplayResult compares the actual distribution of replayed tickets with the
+// simulated one.
+type ReplayResult struct {
+	Tickets  int             `json:"tickets"`
+	Routed   int             `json:"routed"`
+	Changed  int             `json:"changed"` // routed to a different manager than today
+	Failed   int             `json:"failed"`
+	Managers []ManagerShift  `json:"managers"`
+	Changes  []RoutingChange `json:"changes"`
+}
+
+// ManagerShift is how many of a set of tickets a manager has and would have.
+type ManagerShift struct {
+	ManagerID uuid.UUID `json:"manager_id"`
+	FullName  string    `json:"full_name"`
+	Current   int       `json:"current"`
+	Simulated int       `json:"simulated"`
+	Delta     int       `json:"delta"`
+}
+
+// RoutingChange is a replayed ticket the policy sends to another manager.
+type RoutingChange struct {
+	TicketID      uuid.UUID  `json:"ticket_id"`
+	From          *uuid.UUID `json:"from"` // nil when the ticket is unassigned today
+	To            uuid.UUID  `json:"to"`
+	RoutingBucket string     `json:"routing_bucket"`
+}
+
+// simulation is the state a simulated routing runs with: the policy and the
+// load and round-robin pointers earlier simulated tickets moved. A nil
+// simulation is live routing.
+type simulation struct {
+	policy       RoutingPolicy
+	requirements []domain.SkillRequirement // nil keeps the stored requirements
+	exclude      map[uuid.UUID]bool
+	load         map[uuid.UUID]int
+	pointers     map[string]int
+}
+
+func (sim *simulation) routingPolicy() RoutingPolicy {
+	if sim == nil {
+		return RoutingPolicy{}
+	}
+	return sim.policy
+}
+
+func (sim *simulation) skillRequirements() []domain.SkillRequirement {
+	if sim == nil {
+		return nil
+	}
+	return sim.requirements
+}
+
+func (sim *simulation) excluded(id uuid.UUID) bool {
+	return sim != nil && sim.exclude[id]
+}
+
+// adjust applies the simulated load change to m.
+func (sim *simulation) adjust(m *domain.Manager) {
+	if sim == nil {
+		return
+	}
+	m.CurrentLoad = max(m.CurrentLoad+sim.load[m.ID], 0)
+}
+
+// available drops excluded managers and applies simulated load changes.
+func (sim *simulation) available(managers []domain.Manager) []domain.Manager {
+	if sim == nil {
+		return managers
+	}
+	out := make([]domain.Manager, 0, len(managers))
+	for _, m := range managers {
+		if sim.exclude[m.ID] {
+			continue
+		}
+		sim.adjust(&m)
+		out = append(out, m)
+	}
+	return out
+}
+
+// newSimulation validates a policy and resolves its skill requirements
+// against the catalogue.
+func (s *RoutingService) newSimulation(ctx context.Context, policy RoutingPolicy) (*simulation, error) {
+	if policy.Finalists < 0 {
+		return nil, fmt.Errorf("%w: finalists must not be negative", ErrInvalidSimulation)
+	}
+	for segment, days := range policy.AffinityWindowDays {
+		if days < 0 {
+			return nil, fmt.Errorf("%w: affinity window for %q must not be negative", ErrInvalidSimulation, segment)
+		}
+	}
+
+	sim := &simulation{
+		policy:   policy,
+		exclude:  make(map[uuid.UUID]bool, len(policy.ExcludeManagers)),
+		load:     map[uuid.UUID]int{},
+		pointers: map[string]int{},
+	}
+	for _, id := range policy.ExcludeManagers {
+		sim.exclude[id] = true
+	}
+
+	if policy.SkillRequirements != nil {
+		catalog, err := loadSkillCatalog(ctx, s.skillRepo)
+		if err != nil {
+			return nil, err
+		}
+		sim.requirements = make([]domain.SkillRequirement, 0, len(policy.SkillRequirements))
+		for i, pr := range policy.SkillRequirements {
+			sk, ok := catalog.lookup(pr.SkillCode)
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown skill %q", ErrInvalidSimulation, pr.SkillCode)
+			}
+			if pr.MinLevel == 0 {
+				pr.MinLevel = domain.SkillLevelMin
+			}
+			if pr.MinLevel < domain.SkillLevelMin || pr.MinLevel > domain.SkillLevelMax {
+				return nil, fmt.Errorf("%w: skill %s: min_level must be between %d and %d", ErrInvalidSimulation, sk.Code, domain.SkillLevelMin, domain.SkillLevelMax)
+			}
+			sim.requirements = append(sim.requirements, domain.SkillRequirement{
+				SkillID:     sk.ID,
+				SkillCode:   sk.Code,
+				Category:    sk.Category,
+				MinLevel:    pr.MinLevel,
+				Segments:    cleanList(pr.Segments, false),
+				TicketTypes: cleanList(pr.TicketTypes, false),
+				Langs:       cleanList(pr.Langs, true),
+				Position:    i,
+				IsActive:    true,
+			})
+		}
+	}
+	return sim, nil
+}
+
+// Simulate runs the routing pipeline for a ticket under a policy and returns
+// the decision trace and the manager it would pick, without assigning,
+// advancing round-robin pointers or changing loads. Inline tickets are not in
+// the database, so the thread, sticky and affinity steps find nothing for them.
+func (s *RoutingService) Simulate(ctx context.Context, req SimulationRequest) (*SimulationResult, error) {
+	var ticket *domain.Ticket
+	ai := req.AI
+	switch {
+	case req.TicketID != nil:
+		var err error
+		if ticket, err = s.ticketRepo.GetByID(ctx, *req.TicketID); err != nil {
+			return nil, err
+		}
+		if ai == nil {
+			if ai, err = s.ticketRepo.GetAI(ctx, ticket.ID); errors.Is(err, pgx.ErrNoRows) {
+				return nil, fmt.Errorf("%w: ticket has not been enriched yet; pass ai to simulate it", ErrInvalidSimulation)
+			} else if err != nil {
+				return nil, err
+			}
+		}
+	case req.Ticket != nil:
+		if ai == nil {
+			return nil, fmt.Errorf("%w: an inline ticket needs ai", ErrInvalidSimulation)
+		}
+		ticket = req.Ticket
+		if ticket.ID == uuid.Nil {
+			ticket.ID = uuid.New()
+		}
+	default:
+		return nil, fmt.Errorf("%w: ticket_id or ticket is required", ErrInvalidSimulation)
+	}
+
+	sim, err := s.newSimulation(ctx, req.Policy)
+	if err != nil {
+		return nil, err
+	}
+	return s.simulate(ctx, ticket, ai, sim)
+}
+
+// simulate plans a ticket and previews round robin, then books the chosen
+// manager into the simulation so the next simulated ticket sees the load.
+func (s *RoutingService) simulate(ctx context.Context, ticket *domain.Ticket, ai *domain.TicketAI, sim *simulation) (*SimulationResult, error) {
+	p, err := s.plan(ctx, ticket, ai, sim)
+	if err != nil {
+		return nil, err
+	}
+	result := &SimulationResult{
+		TicketID:      ticket.ID,
+		Steps:         p.Steps,
+		RoutingBucket: p.SkillGroup,
+		RoutingReason: p.RoutingReason,
+	}
+	if p.BusinessUnitID != uuid.Nil {
+		result.BusinessUnitID = &p.BusinessUnitID
+	}
+
+	switch {
+	case p.Direct != nil:
+		result.Manager = p.Direct
+		result.Steps = append(result.Steps, RoutingStep{
+			Step:       p.DirectStep,
+			Decision:   p.DirectDecision,
+			Candidates: []uuid.UUID{p.Direct.ID},
+			Output: map[string]interface{}{
+				"manager_id":   p.Direct.ID,
+				"manager_name": p.Direct.FullName,
+			},
+		})
+	case len(p.Finalists) == 0:
+		result.Error = "no candidates after load balancing"
+		return result, nil
+	default:
+		rrResult, err := s.roundRobin.Preview(ctx, p.BusinessUnitID, p.SkillGroup, p.Finalists, sim.pointers)
+		if err != nil {
+			return nil, fmt.Errorf("round robin: %w", err)
+		}
+		result.Manager = &rrResult.SelectedManager
+		result.Steps = append(result.Steps, RoutingStep{
+			Step:       domain.AuditStepRoundRobin,
+			Decision:   rrResult.Decision,
+			Candidates: []uuid.UUID{rrResult.SelectedManager.ID},
+			Output:     rrResult,
+		})
+	}
+
+	sim.load[result.Manager.ID]++
+	return result, nil
+}
+
+// Replay re-routes enriched historical tickets, oldest first, under a policy
+// and compares where they would go with where they are. The replayed tickets
+// are first taken off their current managers' load, then booked as simulated.
+// Nothing is written.
+func (s *RoutingService) Replay(ctx context.Context, params ReplayParams) (*ReplayResult, error) {
+	if params.Limit == 0 {
+		params.Limit = replayDefaultLimit
+	}
+	if params.Limit < 0 || params.Limit > replayMaxLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSimulation, replayMaxLimit)
+	}
+	if params.DateFrom != nil && params.DateTo != nil && params.DateTo.Before(*params.DateFrom) {
+		return nil, fmt.Errorf("%w: date_to is before date_from", ErrInvalidSimulation)
+	}
+
+	sim, err := s.newSimulation(ctx, params.Policy)
+	if err != nil {
+		return nil, err
+	}
+	tickets, err := s.ticketRepo.ListForReplay(ctx, params.DateFrom, params.DateTo, params.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list tickets: %w", err)
+	}
+
+	results, err := s.simulateBatch(ctx, tickets, sim)
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[uuid.UUID]int{}
+	simulated := map[uuid.UUID]int{}
+	result := &ReplayResult{Tickets: len(tickets), Changes: []RoutingChange{}}
+	for i, sr := range results {
+		t := tickets[i]
+		if t.ManagerID != nil {
+			current[*t.ManagerID]++
+		}
+		if sr.Manager == nil {
+			result.Failed++
+			continue
+		}
+		result.Routed++
+		simulated[sr.Manager.ID]++
+		if t.ManagerID == nil || *t.ManagerID != sr.Manager.ID {
+			result.Changed++
+			result.Changes = append(result.Changes, RoutingChange{
+				TicketID: t.ID, From: t.ManagerID, To: sr.Manager.ID, RoutingBucket: sr.RoutingBucket,
+			})
+		}
+	}
+
+	shifts, err := managerShifts(ctx, s.managerRepo, current, simulated)
+	if err != nil {
+		return nil, err
+	}
+	result.Managers = shifts
+	return result, nil
+}
+
+// SimulateBatch routes tickets one after another, in the given order, under
+// a policy. The tickets are first taken off their current managers' load;
+// each simulated ticket then adds load to the manager it would go to and
+// advances round robin. Tickets that cannot be routed carry the reason in
+// Error. Nothing is written.
+func (s *RoutingService) SimulateBatch(ctx context.Context, tickets []domain.Ticket, policy RoutingPolicy) ([]*SimulationResult, error) {
+	sim, err := s.newSimulation(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+	return s.simulateBatch(ctx, tickets, sim)
+}
+
+func (s *RoutingService) simulateBatch(ctx context.Context, tickets []domain.Ticket, sim *simulation) ([]*SimulationResult, error) {
+	for _, t := range tickets {
+		if t.ManagerID != nil {
+			sim.load[*t.ManagerID]--
+		}
+	}
+
+	results := make([]*SimulationResult, len(tickets))
+	for i := range tickets {
+		t := &tickets[i]
+		ai, err := s.ticketRepo.GetAI(ctx, t.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			results[i] = &SimulationResult{TicketID: t.ID, Steps: []RoutingStep{}, Error: "ticket has not been enriched yet"}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ticket %s: %w", t.ID, err)
+		}
+		if results[i], err = s.simulate(ctx, t, ai, sim); err != nil {
+			return nil, fmt.Errorf("ticket %s: %w", t.ID, err)
+		}
+	}
+	return results, nil
+}
+
+// managerShifts lists every manager with some of the tickets today or after
+// the change, biggest change first.
+func managerShifts(ctx context.Context, managerRepo *repository.ManagerRepo, current, simulated map[uuid.UUID]int) ([]ManagerShift, error) {
+	ids := make([]uuid.UUID, 0, len(current)+len(simulated))
+	for id := range current {
+		ids = append(ids, id)
+	}
+	for id := range simulated {
+		if _, ok := current[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+
+	managers, err := managerRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list managers: %w", err)
+	}
+	names := make(map[uuid.UUID]string, len(managers))
+	for _, m := range managers {
+		names[m.ID] = m.FullName
+	}
+
+	shifts := make([]ManagerShift, 0, len(ids))
+	for _, id := range ids {
+		shifts = append(shifts, ManagerShift{
+			ManagerID: id,
+			FullName:  names[id],
+			Current:   current[id],
+			Simulated: simulated[id],
+			Delta:     simulated[id] - current[id],
+		})
+	}
+	sort.Slice(shifts, func(i, j int) bool {
+		di, dj := abs(shifts[i].Delta), abs(shifts[j].Delta)
+		if di != dj {
+			return di > dj
+		}
+		return shifts[i].FullName < shifts[j].FullName
+	})
+	return shifts, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
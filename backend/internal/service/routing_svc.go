@@ -3,9 +3,11 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/arslan/fire-challenge/internal/domain"
@@ -17,21 +19,23 @@ type RoutingService struct {
 	pool         *pgxpool.Pool
 	geoFilter    *routing.GeoFilter
 	skillFilter  *routing.SkillFilter
+	affinity     *routing.Affinity
 	loadBalancer *routing.LoadBalancer
 	roundRobin   *routing.RoundRobin
 	managerRepo  *repository.ManagerRepo
 	auditRepo    *repository.AuditRepo
 	ticketRepo   *repository.TicketRepo
+	skillRepo    *repository.SkillRepo
 }
 
 func NewRoutingService(
 	pool *pgxpool.Pool,
-	gf *routing.GeoFilter, sf *routing.SkillFilter, lb *routing.LoadBalancer, rr *routing.RoundRobin,
-	mr *repository.ManagerRepo, ar *repository.AuditRepo, tr *repository.TicketRepo,
+	gf *routing.GeoFilter, sf *routing.SkillFilter, af *routing.Affinity, lb *routing.LoadBalancer, rr *routing.RoundRobin,
+	mr *repository.ManagerRepo, ar *repository.AuditRepo, tr *repository.TicketRepo, sr *repository.SkillRepo,
 ) *RoutingService {
 	return &RoutingService{
-		pool: pool, geoFilter: gf, skillFilter: sf, loadBalancer: lb, roundRobin: rr,
-		managerRepo: mr, auditRepo: ar, ticketRepo: tr,
+		pool: pool, geoFilter: gf, skillFilter: sf, affinity: af, loadBalancer: lb, roundRobin: rr,
+		managerRepo: mr, auditRepo: ar, ticketRepo: tr, skillRepo: sr,
 	}
 }
 
@@ -47,6 +51,108 @@ func (s *RoutingService) RouteTicket(ctx context.Context, ticket *domain.Ticket,
 		return nil
 	}
 
+	p, err := s.plan(ctx, ticket, ai, nil)
+	if err != nil {
+		return err
+	}
+	for _, step := range p.Steps {
+		if step.Candidates == nil {
+			s.writeAudit(ctx, ticket.ID, step.Step, nil, step.Output, step.Decision)
+		} else {
+			s.writeAuditWithCandidates(ctx, ticket.ID, step.Step, step.Output, step.Decision, step.Candidates)
+		}
+	}
+
+	if p.Direct != nil {
+		return s.assignDirect(ctx, ticket.ID, p.Direct, p.DirectStep, p.SkillGroup, p.RoutingReason, p.DirectDecision)
+	}
+	if len(p.Finalists) == 0 {
+		return fmt.Errorf("no candidates after load balancing")
+	}
+
+	// Step 4: Round robin (transactional)
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rrResult, err := s.roundRobin.Assign(ctx, tx, ticket.ID, p.BusinessUnitID, p.SkillGroup, p.Finalists, p.RoutingReason)
+	if err != nil {
+		return fmt.Errorf("round robin: %w", err)
+	}
+
+	// Update ticket status to routed, unless it was claimed meanwhile
+	if !rrResult.Existing {
+		if _, err := tx.Exec(ctx, `UPDATE tickets SET status = 'routed', updated_at = now() WHERE id = $1`, ticket.ID); err != nil {
+			return fmt.Errorf("update ticket status: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	s.writeAuditWithCandidates(ctx, ticket.ID, domain.AuditStepRoundRobin, rrResult, rrResult.Decision, []uuid.UUID{rrResult.SelectedManager.ID})
+
+	return nil
+}
+
+// RoutingStep is one decision of the routing pipeline, as written to the audit log.
+type RoutingStep struct {
+	Step       string      `json:"step"`
+	Decision   string      `json:"decision"`
+	Candidates []uuid.UUID `json:"candidates,omitempty"` // nil for steps that record no candidates
+	Output     interface{} `json:"output,omitempty"`
+}
+
+// routingPlan is what the pipeline decided for a ticket up to round robin,
+// before anything is written.
+type routingPlan struct {
+	Steps []RoutingStep
+
+	// Direct is the thread owner or pinned manager the ticket goes to,
+	// skipping geo, skill and load steps; DirectStep is the audit step.
+	Direct         *domain.Manager
+	DirectStep     string
+	DirectDecision string
+
+	BusinessUnitID uuid.UUID
+	SkillGroup     string // round-robin bucket
+	Finalists      []domain.Manager
+	RoutingReason  string
+}
+
+func (p *routingPlan) add(step string, output interface{}, decision string, candidates []uuid.UUID) {
+	p.Steps = append(p.Steps, RoutingStep{Step: step, Decision: decision, Candidates: candidates, Output: output})
+}
+
+func (p *routingPlan) direct(manager *domain.Manager, step, bucket, reason, decision string) *routingPlan {
+	p.Direct, p.DirectStep, p.DirectDecision = manager, step, decision
+	p.BusinessUnitID, p.SkillGroup, p.RoutingReason = manager.BusinessUnitID, bucket, reason
+	return p
+}
+
+// plan runs steps 0–3 of the pipeline without side effects. sim is nil for
+// live routing; a simulation may change the policy and carries the load its
+// earlier tickets added.
+func (s *RoutingService) plan(ctx context.Context, ticket *domain.Ticket, ai *domain.TicketAI, sim *simulation) (*routingPlan, error) {
+	p := &routingPlan{}
+	policy := sim.routingPolicy()
+
+	// Step 0: Thread — tickets linked to a client thread stay with the manager who owns it
+	if !policy.IgnoreThreads {
+		owner, err := s.threadOwner(ctx, ticket.ID)
+		if err != nil {
+			return nil, fmt.Errorf("thread owner: %w", err)
+		}
+		if owner != nil && !sim.excluded(owner.ID) {
+			return p.direct(owner, domain.AuditStepThread, "thread",
+				fmt.Sprintf("Thread: client thread owned by %s", owner.FullName),
+				"Related to an earlier ticket of the client — assigned to thread owner "+owner.FullName), nil
+		}
+	}
+
 	// Step 1: Geo filter — extract city hint from raw address for fallback matching
 	rawCityPtr := extractCityFromAddress(ticket.RawAddress)
 	rawCity := ""
@@ -55,22 +161,23 @@ func (s *RoutingService) RouteTicket(ctx context.Context, ticket *domain.Ticket,
 	}
 	geoResult, err := s.geoFilter.Resolve(ctx, ticket.ID, ai.Lat, ai.Lon, ai.GeoStatus, rawCity)
 	if err != nil {
-		return fmt.Errorf("geo filter: %w", err)
+		return nil, fmt.Errorf("geo filter: %w", err)
 	}
-
-	s.writeAudit(ctx, ticket.ID, domain.AuditStepGeoFilter, nil, geoResult, geoResult.Decision)
+	p.add(domain.AuditStepGeoFilter, geoResult, geoResult.Decision, nil)
 
 	// Step 2: Skill filter
 	managers, err := s.managerRepo.ListByBusinessUnit(ctx, geoResult.BusinessUnitID)
 	if err != nil {
-		return fmt.Errorf("list managers: %w", err)
+		return nil, fmt.Errorf("list managers: %w", err)
 	}
+	managers = sim.available(managers)
 	// Fallback: if no managers in resolved office, use all active managers
 	if len(managers) == 0 {
 		managers, err = s.managerRepo.ListAllActive(ctx)
 		if err != nil {
-			return fmt.Errorf("list all managers fallback: %w", err)
+			return nil, fmt.Errorf("list all managers fallback: %w", err)
 		}
+		managers = sim.available(managers)
 	}
 
 	segment := ""
@@ -82,52 +189,120 @@ func (s *RoutingService) RouteTicket(ctx context.Context, ticket *domain.Ticket,
 		ticketType = *ai.Type
 	}
 
-	skillResult := s.skillFilter.Filter(managers, segment, ticketType, ai.Lang)
+	var skillResult *routing.SkillResult
+	if reqs := sim.skillRequirements(); reqs != nil {
+		reqs = routing.MatchRequirements(reqs, segment, ticketType, ai.Lang)
+		skillResult, err = s.skillFilter.Apply(ctx, managers, reqs, segment, ticketType, ai.Lang)
+	} else {
+		skillResult, err = s.skillFilter.Filter(ctx, managers, segment, ticketType, ai.Lang)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("skill filter: %w", err)
+	}
 
 	candidateIDs := make([]uuid.UUID, len(skillResult.Candidates))
 	for i, c := range skillResult.Candidates {
 		candidateIDs[i] = c.ID
 	}
-	s.writeAuditWithCandidates(ctx, ticket.ID, domain.AuditStepSkillFilter, skillResult, skillResult.Decision, candidateIDs)
+	p.add(domain.AuditStepSkillFilter, skillResult, skillResult.Decision, candidateIDs)
 
-	// Step 3: Load balancer
-	loadResult := s.loadBalancer.PickTwo(skillResult.Candidates)
+	// Step 3a: Affinity — returning clients stay with their previous manager
+	// within the segment's window; segments without a window skip the stage
+	affinity := s.affinity
+	if policy.AffinityWindowDays != nil {
+		affinity = affinity.WithWindows(policy.AffinityWindowDays)
+	}
+	var loadResult *routing.LoadResult
+	if affinity.Enabled(segment) && !policy.IgnoreSticky {
+		affinityResult, err := affinity.Prefer(ctx, ticket.ID, segment, skillResult.Candidates)
+		if err != nil {
+			return nil, fmt.Errorf("affinity: %w", err)
+		}
+		preferred := []uuid.UUID{}
+		if affinityResult.Manager != nil {
+			preferred = []uuid.UUID{affinityResult.Manager.ID}
+			loadResult = &routing.LoadResult{
+				Finalists: []domain.Manager{*affinityResult.Manager},
+				Decision:  "Affinity: " + affinityResult.Decision,
+			}
+		}
+		p.add(domain.AuditStepAffinity, affinityResult, affinityResult.Decision, preferred)
+	}
 
-	finalistIDs := make([]uuid.UUID, len(loadResult.Finalists))
-	for i, f := range loadResult.Finalists {
-		finalistIDs[i] = f.ID
+	// Step 3: Load balancer (skipped when affinity already chose the manager)
+	if loadResult == nil {
+		finalists := 2
+		if policy.Finalists > 0 {
+			finalists = policy.Finalists
+		}
+		loadResult = s.loadBalancer.Pick(skillResult.Candidates, finalists)
+
+		finalistIDs := make([]uuid.UUID, len(loadResult.Finalists))
+		for i, f := range loadResult.Finalists {
+			finalistIDs[i] = f.ID
+		}
+		p.add(domain.AuditStepLoadBalance, loadResult, loadResult.Decision, finalistIDs)
 	}
-	s.writeAuditWithCandidates(ctx, ticket.ID, domain.AuditStepLoadBalance, loadResult, loadResult.Decision, finalistIDs)
 
-	if len(loadResult.Finalists) == 0 {
-		return fmt.Errorf("no candidates after load balancing")
+	p.BusinessUnitID = geoResult.BusinessUnitID
+	p.SkillGroup = skillResult.SkillGroup
+	p.Finalists = loadResult.Finalists
+	p.RoutingReason = fmt.Sprintf("Geo: %s | Skills: %s | Load: %s", geoResult.Decision, skillResult.Decision, loadResult.Decision)
+	return p, nil
+}
+
+// threadOwner returns the active manager currently assigned to another ticket
+// of the ticket's thread, or nil if the ticket is not threaded or the thread
+// has no owner yet.
+func (s *RoutingService) threadOwner(ctx context.Context, ticketID uuid.UUID) (*domain.Manager, error) {
+	var managerID uuid.UUID
+	err := s.pool.QueryRow(ctx,
+		`SELECT a.manager_id
+		 FROM tickets self
+		 JOIN tickets t ON t.thread_id = self.thread_id AND t.id <> self.id
+		 JOIN ticket_assignment a ON a.ticket_id = t.id AND a.is_current = true
+		 JOIN managers m ON m.id = a.manager_id AND m.is_active = true
+		 WHERE self.id = $1
+		 ORDER BY a.assigned_at DESC
+		 LIMIT 1`, ticketID,
+	).Scan(&managerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
+	return s.managerRepo.GetByID(ctx, managerID)
+}
 
-	// Step 4: Round robin (transactional)
+// assignDirect assigns the ticket straight to manager, skipping the geo, skill
+// and load steps, and records the decision under the given audit step.
+func (s *RoutingService) assignDirect(ctx context.Context, ticketID uuid.UUID, manager *domain.Manager, step, bucket, reason, decision string) error {
 	tx, err := s.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	routingReason := fmt.Sprintf("Geo: %s | Skills: %s | Load: %s", geoResult.Decision, skillResult.Decision, loadResult.Decision)
-
-	rrResult, err := s.roundRobin.Assign(ctx, tx, ticket.ID, geoResult.BusinessUnitID, skillResult.SkillGroup, loadResult.Finalists, routingReason)
+	rrResult, err := s.roundRobin.Assign(ctx, tx, ticketID, manager.BusinessUnitID, bucket, []domain.Manager{*manager}, reason)
 	if err != nil {
-		return fmt.Errorf("round robin: %w", err)
+		return fmt.Errorf("assign %s: %w", step, err)
 	}
-
-	// Update ticket status to routed
-	if _, err := tx.Exec(ctx, `UPDATE tickets SET status = 'routed', updated_at = now() WHERE id = $1`, ticket.ID); err != nil {
+	if rrResult.Existing {
+		// Claimed by a concurrent assignment; leave it as it is.
+		return nil
+	}
+	if _, err := tx.Exec(ctx, `UPDATE tickets SET status = 'routed', updated_at = now() WHERE id = $1`, ticketID); err != nil {
 		return fmt.Errorf("update ticket status: %w", err)
 	}
-
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("commit tx: %w", err)
 	}
 
-	s.writeAuditWithCandidates(ctx, ticket.ID, domain.AuditStepRoundRobin, rrResult, rrResult.Decision, []uuid.UUID{rrResult.SelectedManager.ID})
-
+	s.writeAuditWithCandidates(ctx, ticketID, step, map[string]interface{}{
+		"manager_id":   manager.ID,
+		"manager_name": manager.FullName,
+	}, decision, []uuid.UUID{manager.ID})
 	return nil
 }
 
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+// ErrInvalidSkill is returned when a skill or skill requirement fails validation.
+var ErrInvalidSkill = errors.New("invalid skill")
+
+var skillCategories = []string{
+	domain.SkillCategoryProduct, domain.SkillCategoryLanguage,
+	domain.SkillCategorySegment, domain.SkillCategoryCertification,
+}
+
+type SkillService struct {
+	repo *repository.SkillRepo
+}
+
+func NewSkillService(repo *repository.SkillRepo) *SkillService {
+	return &SkillService{repo: repo}
+}
+
+func (s *SkillService) List(ctx context.Context) ([]domain.Skill, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *SkillService) Create(ctx context.Context, sk *domain.Skill) error {
+	sk.Code = strings.ToUpper(strings.TrimSpace(sk.Code))
+	if sk.Code == "" {
+		return fmt.Errorf("%w: code is required", ErrInvalidSkill)
+	}
+	if strings.IndexFunc(sk.Code, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' }) >= 0 {
+		return fmt.Errorf("%w: code may only contain letters, digits and _", ErrInvalidSkill)
+	}
+	if !slices.Contains(skillCategories, sk.Category) {
+		return fmt.Errorf("%w: category must be one of %s", ErrInvalidSkill, strings.Join(skillCategories, ", "))
+	}
+	if err := validateSkill(sk); err != nil {
+		return err
+	}
+	sk.ID = uuid.New()
+	return s.repo.Insert(ctx, sk)
+}
+
+// Update renames a skill and replaces its aliases.
+func (s *SkillService) Update(ctx context.Context, sk *domain.Skill) (*domain.Skill, error) {
+	if err := validateSkill(sk); err != nil {
+		return nil, err
+	}
+	return s.repo.Update(ctx, sk)
+}
+
+func validateSkill(sk *domain.Skill) error {
+	sk.Name = strings.TrimSpace(sk.Name)
+	if sk.Name == "" {
+		sk.Name = sk.Code
+	}
+	aliases := []string{}
+	for _, a := range sk.Aliases {
+		a = strings.TrimSpace(a)
+		if a == "" || slices.Contains(aliases, a) {
+			continue
+		}
+		if strings.ContainsAny(a, ",;:") {
+			return fmt.Errorf("%w: alias %q must not contain , ; or :", ErrInvalidSkill, a)
+		}
+		aliases = append(aliases, a)
+	}
+	sk.Aliases = aliases
+	return nil
+}
+
+// ── Requirements ──
+
+func (s *SkillService) ListRequirements(ctx context.Context) ([]domain.SkillRequirement, error) {
+	return s.repo.ListRequirements(ctx)
+}
+
+// CreateRequirement adds an active routing rule.
+func (s *SkillService) CreateRequirement(ctx context.Context, q *domain.SkillRequirement) (*domain.SkillRequirement, error) {
+	if err := s.validateRequirement(ctx, q); err != nil {
+		return nil, err
+	}
+	q.ID = uuid.New()
+	q.IsActive = true
+	if err := s.repo.InsertRequirement(ctx, q); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRequirement(ctx, q.ID)
+}
+
+// UpdateRequirement replaces a routing rule; set is_active to false to disable it.
+func (s *SkillService) UpdateRequirement(ctx context.Context, q *domain.SkillRequirement) (*domain.SkillRequirement, error) {
+	if err := s.validateRequirement(ctx, q); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateRequirement(ctx, q); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRequirement(ctx, q.ID)
+}
+
+func (s *SkillService) validateRequirement(ctx context.Context, q *domain.SkillRequirement) error {
+	if _, err := s.repo.GetByID(ctx, q.SkillID); errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: skill %s not found", ErrInvalidSkill, q.SkillID)
+	} else if err != nil {
+		return err
+	}
+	if q.MinLevel == 0 {
+		q.MinLevel = domain.SkillLevelMin
+	}
+	if q.MinLevel < domain.SkillLevelMin || q.MinLevel > domain.SkillLevelMax {
+		return fmt.Errorf("%w: min_level must be between %d and %d", ErrInvalidSkill, domain.SkillLevelMin, domain.SkillLevelMax)
+	}
+	q.Segments = cleanList(q.Segments, false)
+	q.TicketTypes = cleanList(q.TicketTypes, false)
+	q.Langs = cleanList(q.Langs, true)
+	return nil
+}
+
+// cleanList trims the values of a requirement condition and drops empty and
+// repeated ones.
+func cleanList(values []string, upper bool) []string {
+	out := []string{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if upper {
+			v = strings.ToUpper(v)
+		}
+		if v != "" && !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ── Manager skills ──
+
+// skillCatalog looks skills up by code, name or alias, ignoring case.
+type skillCatalog map[string]domain.Skill
+
+func newSkillCatalog(skills []domain.Skill) skillCatalog {
+	c := make(skillCatalog, len(skills)*3)
+	for _, sk := range skills {
+		for _, key := range append([]string{sk.Name}, sk.Aliases...) {
+			c[strings.ToUpper(strings.TrimSpace(key))] = sk
+		}
+	}
+	// Codes win over another skill's name or alias.
+	for _, sk := range skills {
+		c[sk.Code] = sk
+	}
+	return c
+}
+
+func (c skillCatalog) lookup(name string) (domain.Skill, bool) {
+	sk, ok := c[strings.ToUpper(strings.TrimSpace(name))]
+	return sk, ok
+}
+
+// resolve checks manager skills against the catalogue: names and aliases
+// become codes, a missing level becomes the default.
+func (c skillCatalog) resolve(skills []domain.ManagerSkill) ([]domain.ManagerSkill, error) {
+	out := make([]domain.ManagerSkill, 0, len(skills))
+	for _, ms := range skills {
+		sk, ok := c.lookup(ms.Code)
+		if !ok {
+			return nil, fmt.Errorf("unknown skill %q", ms.Code)
+		}
+		if ms.Level == 0 {
+			ms.Level = domain.SkillLevelDefault
+		}
+		if ms.Level < domain.SkillLevelMin || ms.Level > domain.SkillLevelMax {
+			return nil, fmt.Errorf("skill %s: level must be between %d and %d", sk.Code, domain.SkillLevelMin, domain.SkillLevelMax)
+		}
+		if slices.ContainsFunc(out, func(o domain.ManagerSkill) bool { return o.Code == sk.Code }) {
+			return nil, fmt.Errorf("skill %s is listed twice", sk.Code)
+		}
+		out = append(out, domain.ManagerSkill{Code: sk.Code, Name: sk.Name, Category: sk.Category, Level: ms.Level})
+	}
+	return out, nil
+}
+
+// parseSkillList reads a Навыки cell: skills separated by commas or
+// semicolons, each optionally with a level after a colon ("VIP, ENG:4").
+// Tokens that are not in the catalogue or have a bad level are returned as
+// problems and left out.
+func parseSkillList(v string, c skillCatalog) (skills []domain.ManagerSkill, problems []string) {
+	skills = []domain.ManagerSkill{}
+	for _, token := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ';' }) {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		name, level := token, domain.SkillLevelDefault
+		if i := strings.LastIndex(token, ":"); i >= 0 {
+			n, err := strconv.Atoi(strings.TrimSpace(token[i+1:]))
+			if err != nil || n < domain.SkillLevelMin || n > domain.SkillLevelMax {
+				problems = append(problems, fmt.Sprintf("skill %q: level must be between %d and %d", token, domain.SkillLevelMin, domain.SkillLevelMax))
+				continue
+			}
+			name, level = token[:i], n
+		}
+		sk, ok := c.lookup(name)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown skill %q", strings.TrimSpace(name)))
+			continue
+		}
+		if i := slices.IndexFunc(skills, func(o domain.ManagerSkill) bool { return o.Code == sk.Code }); i >= 0 {
+			skills[i].Level = max(skills[i].Level, level)
+			continue
+		}
+		skills = append(skills, domain.ManagerSkill{Code: sk.Code, Name: sk.Name, Category: sk.Category, Level: level})
+	}
+	return skills, problems
+}
+
+// hasSkill reports whether skills include code.
+func hasSkill(skills []domain.ManagerSkill, code string) bool {
+	return slices.ContainsFunc(skills, func(s domain.ManagerSkill) bool { return s.Code == code })
+}
+
+// withSkill adds code at the default level unless skills already include it.
+func withSkill(skills []domain.ManagerSkill, c skillCatalog, code string) []domain.ManagerSkill {
+	if hasSkill(skills, code) {
+		return skills
+	}
+	sk, ok := c.lookup(code)
+	if !ok {
+		return skills
+	}
+	return append(skills, domain.ManagerSkill{Code: sk.Code, Name: sk.Name, Category: sk.Category, Level: domain.SkillLevelDefault})
+}
+
+// languageCodes returns the language skills among skills, in order.
+func languageCodes(skills []domain.ManagerSkill) []string {
+	langs := []string{}
+	for _, s := range skills {
+		if s.Category == domain.SkillCategoryLanguage {
+			langs = append(langs, s.Code)
+		}
+	}
+	return langs
+}
+
+// loadSkillCatalog reads the catalogue for lookups.
+func loadSkillCatalog(ctx context.Context, repo *repository.SkillRepo) (skillCatalog, error) {
+	skills, err := repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load skills: %w", err)
+	}
+	return newSkillCatalog(skills), nil
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	pganalyze "github.com/pganalyze/pg_query_go/v6"
+	pg_query "github.com/wasilibs/go-pgquery"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Rejection codes returned by the Star SQL guard.
+const (
+	RejectParseError       = "parse_error"
+	RejectEmptyQuery       = "empty_query"
+	RejectMultipleStmts    = "multiple_statements"
+	RejectNotSelect        = "not_select"
+	RejectWriteStatement   = "write_statement"
+	RejectSelectInto       = "select_into"
+	RejectLockingClause    = "locking_clause"
+	RejectSchemaNotAllowed = "schema_not_allowed"
+	RejectTableNotAllowed  = "table_not_allowed"
+	RejectColumnNotAllowed = "column_not_allowed"
+	RejectFuncNotAllowed   = "function_not_allowed"
+)
+
+// SQLRejection explains why Star refused to execute a query.
+type SQLRejection struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+	Object string `json:"object,omitempty"`
+}
+
+func (e *SQLRejection) Error() string {
+	if e.Object != "" {
+		return fmt.Sprintf("%s: %s (%s)", e.Code, e.Reason, e.Object)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Reason)
+}
+
+// starTables lists the tables Star may read, in the order the prompt
+// describes them. Their columns come from information_schema (LoadSchema), so
+// columns added by later migrations become readable without code changes.
+var starTables = []string{"tickets", "ticket_ai", "ticket_assignment", "managers", "business_units", "audit_log", "geo_cache"}
+
+// starDeniedColumns are columns of starTables that Star must never read. The
+// Star DB role is not granted SELECT on them either.
+var starDeniedColumns = map[string][]string{
+	"tickets":        {"import_id"},
+	"managers":       {"import_id"},
+	"business_units": {"import_id"},
+}
+
+// starAllowedFuncs is the set of functions Star-generated SQL may call.
+var starAllowedFuncs = []string{
+	// aggregates
+	"count", "sum", "avg", "min", "max", "string_agg", "array_agg", "bool_and", "bool_or",
+	"stddev", "variance", "percentile_cont", "percentile_disc", "mode",
+	// window
+	"row_number", "rank", "dense_rank", "ntile", "lag", "lead", "first_value", "last_value",
+	// math
+	"round", "abs", "ceil", "ceiling", "floor", "trunc",
+	// date/time
+	"now", "date", "date_trunc", "date_part", "extract", "age", "to_char", "to_date", "timezone", "make_interval",
+	// text
+	"lower", "upper", "length", "char_length", "btrim", "ltrim", "rtrim", "substring", "substr",
+	"concat", "concat_ws", "split_part", "replace", "left", "right", "position", "initcap",
+	// arrays / json
+	"array_length", "cardinality", "unnest", "array_to_string",
+	"jsonb_array_length", "jsonb_array_elements_text", "jsonb_typeof",
+	// generators
+	"generate_series",
+}
+
+// StarSQLGuard parses Star SQL with the PostgreSQL parser and checks it against allowlists.
+type StarSQLGuard struct {
+	tables map[string]map[string]bool // allowed columns per table
+	known  map[string]map[string]bool // all columns per table, allowed or not
+	funcs  map[string]bool
+}
+
+// NewStarSQLGuard returns a guard that allows no columns until SetColumns is
+// called with the introspected schema.
+func NewStarSQLGuard() *StarSQLGuard {
+	g := &StarSQLGuard{funcs: make(map[string]bool, len(starAllowedFuncs))}
+	g.SetColumns(nil)
+	for _, f := range starAllowedFuncs {
+		g.funcs[f] = true
+	}
+	return g
+}
+
+// SetColumns sets the columns the tables actually have. All of them except
+// starDeniedColumns are allowed; tables not in starTables are ignored.
+func (g *StarSQLGuard) SetColumns(columns map[string][]string) {
+	tables := make(map[string]map[string]bool, len(columns))
+	known := make(map[string]map[string]bool, len(columns))
+	for table, cols := range columns {
+		if !slices.Contains(starTables, table) {
+			continue
+		}
+		tables[table], known[table] = map[string]bool{}, map[string]bool{}
+		for _, c := range cols {
+			known[table][c] = true
+			if !slices.Contains(starDeniedColumns[table], c) {
+				tables[table][c] = true
+			}
+		}
+	}
+	g.tables, g.known = tables, known
+}
+
+// AllowedColumn reports whether Star may read column of table.
+func (g *StarSQLGuard) AllowedColumn(table, column string) bool {
+	return g.tables[table][column]
+}
+
+// Validate returns a *SQLRejection if the query is not a single read-only SELECT
+// over allowlisted tables, columns and functions.
+func (g *StarSQLGuard) Validate(sql string) error {
+	if strings.TrimSpace(sql) == "" {
+		return &SQLRejection{Code: RejectEmptyQuery, Reason: "query is empty"}
+	}
+
+	tree, err := pg_query.Parse(sql)
+	if err != nil {
+		return &SQLRejection{Code: RejectParseError, Reason: err.Error()}
+	}
+	if len(tree.Stmts) == 0 {
+		return &SQLRejection{Code: RejectEmptyQuery, Reason: "query is empty"}
+	}
+	if len(tree.Stmts) > 1 {
+		return &SQLRejection{Code: RejectMultipleStmts, Reason: fmt.Sprintf("expected 1 statement, got %d", len(tree.Stmts))}
+	}
+
+	root := tree.Stmts[0].Stmt
+	if root.GetSelectStmt() == nil {
+		return &SQLRejection{Code: RejectNotSelect, Reason: "only SELECT queries are allowed"}
+	}
+
+	// First pass: collect what the query defines itself. rels maps every name a
+	// column may be qualified with (table, alias, CTE or subquery) to the tables
+	// it can stand for, "" for a derived relation; local holds output column
+	// names the query introduces, which references may use.
+	ctes := map[string]bool{}
+	walkProto(root, func(m proto.Message) error {
+		if n, ok := m.(*pganalyze.CommonTableExpr); ok {
+			ctes[n.Ctename] = true
+		}
+		return nil
+	})
+	rels := map[string][]string{}
+	local := map[string]bool{}
+	walkProto(root, func(m proto.Message) error {
+		switch n := m.(type) {
+		case *pganalyze.CommonTableExpr:
+			rels[n.Ctename] = append(rels[n.Ctename], "")
+			for _, c := range n.Aliascolnames {
+				local[c.GetString_().GetSval()] = true
+			}
+		case *pganalyze.RangeVar:
+			name, table := n.Relname, n.Relname
+			if n.Alias != nil {
+				name = n.Alias.Aliasname
+			}
+			if n.Schemaname == "" && ctes[n.Relname] {
+				table = ""
+			}
+			rels[name] = append(rels[name], table)
+		case *pganalyze.RangeSubselect:
+			if n.Alias != nil {
+				rels[n.Alias.Aliasname] = append(rels[n.Alias.Aliasname], "")
+			}
+		case *pganalyze.RangeFunction:
+			if n.Alias != nil {
+				rels[n.Alias.Aliasname] = append(rels[n.Alias.Aliasname], "")
+			}
+		case *pganalyze.JoinExpr:
+			if n.Alias != nil {
+				rels[n.Alias.Aliasname] = append(rels[n.Alias.Aliasname], "")
+			}
+		case *pganalyze.Alias:
+			for _, c := range n.Colnames {
+				local[c.GetString_().GetSval()] = true
+			}
+		case *pganalyze.ResTarget:
+			if n.Name != "" {
+				local[n.Name] = true
+			}
+		}
+		return nil
+	})
+	var tables []string
+	for _, entries := range rels {
+		for _, t := range entries {
+			if t != "" && !slices.Contains(tables, t) {
+				tables = append(tables, t)
+			}
+		}
+	}
+	cols := &columnScope{guard: g, tables: tables, local: local}
+
+	// Second pass: enforce the allowlists.
+	return walkProto(root, func(m proto.Message) error {
+		switch n := m.(type) {
+		case *pganalyze.InsertStmt, *pganalyze.UpdateStmt, *pganalyze.DeleteStmt, *pganalyze.MergeStmt:
+			return &SQLRejection{Code: RejectWriteStatement, Reason: "data-modifying statements are not allowed"}
+		case *pganalyze.SelectStmt:
+			if n.IntoClause != nil {
+				return &SQLRejection{Code: RejectSelectInto, Reason: "SELECT INTO is not allowed"}
+			}
+			if len(n.LockingClause) > 0 {
+				return &SQLRejection{Code: RejectLockingClause, Reason: "FOR UPDATE/SHARE is not allowed"}
+			}
+		case *pganalyze.RangeVar:
+			if n.Catalogname != "" || (n.Schemaname != "" && n.Schemaname != "public") {
+				return &SQLRejection{Code: RejectSchemaNotAllowed, Reason: "only the public schema is accessible", Object: n.Schemaname + "." + n.Relname}
+			}
+			if _, ok := g.tables[n.Relname]; !ok && !(n.Schemaname == "" && ctes[n.Relname]) {
+				return &SQLRejection{Code: RejectTableNotAllowed, Reason: "table is not available to Star", Object: n.Relname}
+			}
+			if n.Alias != nil && len(n.Alias.Colnames) > 0 {
+				return &SQLRejection{Code: RejectColumnNotAllowed, Reason: "renaming the columns of a table is not allowed", Object: n.Alias.Aliasname}
+			}
+		case *pganalyze.JoinExpr:
+			if n.IsNatural {
+				return &SQLRejection{Code: RejectColumnNotAllowed, Reason: "NATURAL JOIN is not allowed; join on named columns"}
+			}
+			for _, c := range n.UsingClause {
+				if err := cols.check("", c.GetString_().GetSval()); err != nil {
+					return err
+				}
+			}
+		case *pganalyze.FuncCall:
+			name, schema := qualifiedName(n.Funcname)
+			if schema != "" && schema != "pg_catalog" {
+				return &SQLRejection{Code: RejectSchemaNotAllowed, Reason: "functions outside pg_catalog are not allowed", Object: schema + "." + name}
+			}
+			if !g.funcs[name] {
+				return &SQLRejection{Code: RejectFuncNotAllowed, Reason: "function is not allowed", Object: name}
+			}
+		case *pganalyze.ColumnRef:
+			names := make([]string, len(n.Fields))
+			for i, f := range n.Fields {
+				if f.GetAStar() != nil {
+					return &SQLRejection{Code: RejectColumnNotAllowed, Reason: "* is not allowed; name the columns"}
+				}
+				names[i] = f.GetString_().GetSval()
+			}
+			switch len(names) {
+			case 1:
+				if _, ok := rels[names[0]]; ok {
+					return &SQLRejection{Code: RejectColumnNotAllowed, Reason: "whole-row references are not allowed; name the columns", Object: names[0]}
+				}
+				return cols.check("", names[0])
+			case 2:
+				return cols.checkIn(rels[names[0]], names[0], names[1])
+			case 3:
+				if names[0] != "public" {
+					return &SQLRejection{Code: RejectSchemaNotAllowed, Reason: "only the public schema is accessible", Object: strings.Join(names, ".")}
+				}
+				return cols.checkIn(rels[names[1]], names[1], names[2])
+			default:
+				return &SQLRejection{Code: RejectSchemaNotAllowed, Reason: "only the public schema is accessible", Object: strings.Join(names, ".")}
+			}
+		}
+		return nil
+	})
+}
+
+// columnScope checks the column references of one query: tables are the
+// allowlisted tables it reads, local the output column names it defines.
+type columnScope struct {
+	guard  *StarSQLGuard
+	tables []string
+	local  map[string]bool
+}
+
+// check checks an unqualified reference, or one through a derived relation
+// (qualifier set). An unqualified name that is a disallowed column of any
+// table read is refused even if it could mean something else, since the
+// guard does not resolve scopes the way PostgreSQL does.
+func (c *columnScope) check(qualifier, col string) error {
+	object := col
+	if qualifier != "" {
+		object = qualifier + "." + col
+	}
+	if qualifier == "" {
+		for _, t := range c.tables {
+			if c.guard.known[t][col] && !c.guard.tables[t][col] {
+				return &SQLRejection{Code: RejectColumnNotAllowed, Reason: "column is not available to Star", Object: t + "." + col}
+			}
+		}
+	}
+	if c.local[col] {
+		return nil
+	}
+	for _, t := range c.tables {
+		if c.guard.tables[t][col] {
+			return nil
+		}
+	}
+	return &SQLRejection{Code: RejectColumnNotAllowed, Reason: "column is not available to Star", Object: object}
+}
+
+// checkIn checks a reference qualified with a name standing for entries
+// (see rels): on a table, the column must be allowed on that very table.
+func (c *columnScope) checkIn(entries []string, qualifier, col string) error {
+	if len(entries) == 0 {
+		return &SQLRejection{Code: RejectColumnNotAllowed, Reason: "unknown table reference", Object: qualifier + "." + col}
+	}
+	for _, t := range entries {
+		if t == "" {
+			if err := c.check(qualifier, col); err != nil {
+				return err
+			}
+		} else if !c.guard.tables[t][col] {
+			return &SQLRejection{Code: RejectColumnNotAllowed, Reason: "column is not available to Star", Object: t + "." + col}
+		}
+	}
+	return nil
+}
+
+// statementText returns the one statement of sql without a trailing
+// semicolon or anything after it, so it can be embedded in another query.
+func statementText(sql string) (string, error) {
+	tree, err := pg_query.Parse(sql)
+	if err != nil {
+		return "", &SQLRejection{Code: RejectParseError, Reason: err.Error()}
+	}
+	if len(tree.Stmts) != 1 {
+		return "", &SQLRejection{Code: RejectMultipleStmts, Reason: fmt.Sprintf("expected 1 statement, got %d", len(tree.Stmts))}
+	}
+	start := int(tree.Stmts[0].StmtLocation)
+	if n := int(tree.Stmts[0].StmtLen); n > 0 {
+		return sql[start : start+n], nil
+	}
+	return sql[start:], nil
+}
+
+// qualifiedName splits a parser name list into (name, schema).
+func qualifiedName(parts []*pganalyze.Node) (string, string) {
+	names := make([]string, 0, len(parts))
+	for _, p := range parts {
+		names = append(names, strings.ToLower(p.GetString_().GetSval()))
+	}
+	switch len(names) {
+	case 0:
+		return "", ""
+	case 1:
+		return names[0], ""
+	default:
+		return names[len(names)-1], names[len(names)-2]
+	}
+}
+
+// walkProto visits every message in a parse tree depth-first, stopping at the first error.
+func walkProto(m proto.Message, visit func(proto.Message) error) error {
+	if m == nil {
+		return nil
+	}
+	if err := visit(m); err != nil {
+		return err
+	}
+
+	var walkErr error
+	m.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Kind() != protoreflect.MessageKind {
+			return true
+		}
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				if walkErr = walkProto(list.Get(i).Message().Interface(), visit); walkErr != nil {
+					return false
+				}
+			}
+		case fd.IsMap():
+			// pg_query protobufs do not use map fields
+		default:
+			walkErr = walkProto(v.Message().Interface(), visit)
+		}
+		return walkErr == nil
+	})
+	return walkErr
+}
@@ -0,0 +1,595 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+// ErrInvalidReport is returned when a report definition fails validation.
+var ErrInvalidReport = errors.New("invalid report")
+
+// Named placeholders a report's SQL may reference. They are bound as query
+// parameters, never interpolated; use e.g. (:date_from IS NULL OR t.created_at >= :date_from).
+const (
+	reportParamDateFrom = "date_from"
+	reportParamDateTo   = "date_to"
+	reportParamOffice   = "office"
+)
+
+type StarReportService struct {
+	repo         *repository.StarReportRepo
+	star         *StarService
+	reportsDir   string
+	webhookHosts []string
+	httpClient   *http.Client
+
+	cron    *cron.Cron
+	mu      sync.Mutex
+	entries map[uuid.UUID]cron.EntryID
+}
+
+// NewStarReportService creates the service. Reports may be posted only to
+// webhookHosts, and never to a private, loopback or link-local address.
+func NewStarReportService(repo *repository.StarReportRepo, star *StarService, reportsDir string, webhookHosts []string) *StarReportService {
+	return &StarReportService{
+		repo:         repo,
+		star:         star,
+		reportsDir:   reportsDir,
+		webhookHosts: webhookHosts,
+		httpClient:   newWebhookClient(),
+		cron:         cron.New(),
+		entries:      make(map[uuid.UUID]cron.EntryID),
+	}
+}
+
+func (s *StarReportService) List(ctx context.Context) ([]domain.StarReport, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *StarReportService) Get(ctx context.Context, id uuid.UUID) (*domain.StarReport, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *StarReportService) Create(ctx context.Context, r *domain.StarReport) error {
+	if err := s.validate(r); err != nil {
+		return err
+	}
+	r.ID = uuid.New()
+	if err := s.repo.Insert(ctx, r); err != nil {
+		return err
+	}
+	return s.schedule(*r)
+}
+
+func (s *StarReportService) Update(ctx context.Context, r *domain.StarReport) error {
+	if err := s.validate(r); err != nil {
+		return err
+	}
+	if err := s.repo.Update(ctx, r); err != nil {
+		return err
+	}
+	return s.schedule(*r)
+}
+
+func (s *StarReportService) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.unschedule(id)
+	return nil
+}
+
+// Run executes a saved report's SQL directly (no LLM) with the given
+// parameters over its default_params.
+func (s *StarReportService) Run(ctx context.Context, id uuid.UUID, params domain.StarReportParams) (*StarQueryResponse, error) {
+	r, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.run(ctx, r, params)
+}
+
+// Export streams a saved report's result to w, stopping after limit rows.
+func (s *StarReportService) Export(ctx context.Context, id uuid.UUID, params domain.StarReportParams, limit int, w ExportWriter) (bool, error) {
+	r, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	sql, args, err := bindReportParams(r.SQL, resolveReportParams(withDefaultParams(r, params)))
+	if err != nil {
+		return false, err
+	}
+	return s.star.StreamReadOnlySQL(ctx, sql, limit, args, w.WriteHeader, w.WriteRow)
+}
+
+func (s *StarReportService) run(ctx context.Context, r *domain.StarReport, params domain.StarReportParams) (*StarQueryResponse, error) {
+	sql, args, err := bindReportParams(r.SQL, resolveReportParams(withDefaultParams(r, params)))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.star.ExecuteReadOnlySQL(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	result.SQL = r.SQL
+	result.Question = r.Question
+	result.ChartType = r.ChartType
+	if r.XLabel != nil {
+		result.XLabel = *r.XLabel
+	}
+	if r.YLabel != nil {
+		result.YLabel = *r.YLabel
+	}
+	return result, nil
+}
+
+func (s *StarReportService) validate(r *domain.StarReport) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidReport)
+	}
+	if r.ChartType == "" {
+		r.ChartType = "table"
+	}
+	if r.DeliveryFormat == "" {
+		r.DeliveryFormat = "csv"
+	}
+	if r.DeliveryFormat != "csv" && r.DeliveryFormat != "json" {
+		return fmt.Errorf("%w: delivery_format must be csv or json", ErrInvalidReport)
+	}
+	if len(r.DefaultParams) == 0 {
+		r.DefaultParams = json.RawMessage(`{}`)
+	}
+	var defaults domain.StarReportParams
+	if err := json.Unmarshal(r.DefaultParams, &defaults); err != nil {
+		return fmt.Errorf("%w: default_params: %v", ErrInvalidReport, err)
+	}
+	if r.Schedule != nil && strings.TrimSpace(*r.Schedule) == "" {
+		r.Schedule = nil
+	}
+	if r.Schedule != nil {
+		if _, err := cron.ParseStandard(*r.Schedule); err != nil {
+			return fmt.Errorf("%w: schedule: %v", ErrInvalidReport, err)
+		}
+	}
+	if r.DeliveryTarget != nil {
+		var err error
+		if isWebhookTarget(*r.DeliveryTarget) {
+			err = s.checkWebhookURL(*r.DeliveryTarget)
+		} else {
+			_, err = s.deliveryDir(*r.DeliveryTarget)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidReport, err)
+		}
+	}
+
+	sql, _, err := bindReportParams(r.SQL, domain.StarReportParams{})
+	if err != nil {
+		return err
+	}
+	if err := s.star.ValidateSQL(sql); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ── Scheduling ──
+
+// Start registers all scheduled reports and starts the cron runner.
+func (s *StarReportService) Start(ctx context.Context) error {
+	reports, err := s.repo.ListScheduled(ctx)
+	if err != nil {
+		return fmt.Errorf("list scheduled reports: %w", err)
+	}
+	for _, r := range reports {
+		if err := s.schedule(r); err != nil {
+			log.Error().Err(err).Str("report", r.Name).Msg("failed to schedule report")
+		}
+	}
+	s.cron.Start()
+	log.Info().Int("count", len(reports)).Msg("Star report scheduler started")
+	return nil
+}
+
+// Stop halts the cron runner and waits for running jobs to finish.
+func (s *StarReportService) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+func (s *StarReportService) schedule(r domain.StarReport) error {
+	s.unschedule(r.ID)
+	if r.Schedule == nil {
+		return nil
+	}
+
+	id := r.ID
+	entryID, err := s.cron.AddFunc(*r.Schedule, func() { s.runScheduled(id) })
+	if err != nil {
+		return fmt.Errorf("schedule report: %w", err)
+	}
+
+	s.mu.Lock()
+	s.entries[r.ID] = entryID
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *StarReportService) unschedule(id uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entryID, ok := s.entries[id]; ok {
+		s.cron.Remove(entryID)
+		delete(s.entries, id)
+	}
+}
+
+func (s *StarReportService) runScheduled(id uuid.UUID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	r, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("report_id", id.String()).Msg("scheduled report not found")
+		return
+	}
+
+	runErr := s.runAndDeliver(ctx, r, domain.StarReportParams{})
+	var errText *string
+	if runErr != nil {
+		msg := runErr.Error()
+		errText = &msg
+		log.Error().Err(runErr).Str("report", r.Name).Msg("scheduled report failed")
+	} else {
+		log.Info().Str("report", r.Name).Msg("scheduled report delivered")
+	}
+	if err := s.repo.UpdateRunStatus(ctx, r.ID, errText); err != nil {
+		log.Error().Err(err).Str("report", r.Name).Msg("failed to record report run")
+	}
+}
+
+func (s *StarReportService) runAndDeliver(ctx context.Context, r *domain.StarReport, params domain.StarReportParams) error {
+	result, err := s.run(ctx, r, params)
+	if err != nil {
+		return err
+	}
+	if r.DeliveryTarget == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	contentType := "application/json"
+	if r.DeliveryFormat == "csv" {
+		contentType = "text/csv; charset=utf-8"
+		if err := writeResultRows(NewExportWriter(&buf, ExportCSV), result); err != nil {
+			return err
+		}
+	} else {
+		snapshot := map[string]interface{}{
+			"report":       r.Name,
+			"generated_at": time.Now().Format(time.RFC3339),
+			"columns":      result.Columns,
+			"rows":         result.Rows,
+			"truncated":    result.Truncated,
+		}
+		if err := json.NewEncoder(&buf).Encode(snapshot); err != nil {
+			return err
+		}
+	}
+
+	if isWebhookTarget(*r.DeliveryTarget) {
+		return s.deliverWebhook(ctx, r, *r.DeliveryTarget, contentType, buf.Bytes())
+	}
+	return s.deliverFile(r, *r.DeliveryTarget, buf.Bytes())
+}
+
+func (s *StarReportService) deliverWebhook(ctx context.Context, r *domain.StarReport, target, contentType string, body []byte) error {
+	// The allowlist may have changed since the report was saved.
+	if err := s.checkWebhookURL(target); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Report-ID", r.ID.String())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *StarReportService) deliverFile(r *domain.StarReport, target string, body []byte) error {
+	dir, err := s.deliveryDir(target)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create report dir: %w", err)
+	}
+	name := fmt.Sprintf("%s_%s.%s", slugify(r.Name), time.Now().Format("20060102_150405"), r.DeliveryFormat)
+	return os.WriteFile(filepath.Join(dir, name), body, 0o644)
+}
+
+// deliveryDir resolves a delivery target to a directory inside reportsDir.
+func (s *StarReportService) deliveryDir(target string) (string, error) {
+	if strings.Contains(target, "..") {
+		return "", fmt.Errorf("delivery_target must not contain '..'")
+	}
+	return filepath.Join(s.reportsDir, filepath.Clean("/"+target)), nil
+}
+
+func isWebhookTarget(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// checkWebhookURL accepts a webhook URL whose host is in webhookHosts.
+func (s *StarReportService) checkWebhookURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("delivery_target: %v", err)
+	}
+	if u.User != nil {
+		return fmt.Errorf("delivery_target must not contain credentials")
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range s.webhookHosts {
+		if host != "" && host == strings.ToLower(strings.TrimSpace(allowed)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("webhook host %q is not allowed (STAR_WEBHOOK_HOSTS)", host)
+}
+
+// newWebhookClient returns the client for report webhooks. It connects only
+// to public addresses, checked on the resolved IP so a host that resolves to
+// an internal address is refused too, and does not follow redirects.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// withDefaultParams fills the parameters p leaves unset from the report's
+// default_params. The date range is taken whole: from p if it sets any of
+// date_from, date_to and last_days, otherwise from the defaults.
+func withDefaultParams(r *domain.StarReport, p domain.StarReportParams) domain.StarReportParams {
+	var defaults domain.StarReportParams
+	_ = json.Unmarshal(r.DefaultParams, &defaults) // validated on save
+	if p.DateFrom == nil && p.DateTo == nil && p.LastDays == 0 {
+		p.DateFrom, p.DateTo, p.LastDays = defaults.DateFrom, defaults.DateTo, defaults.LastDays
+	}
+	if p.Office == nil {
+		p.Office = defaults.Office
+	}
+	return p
+}
+
+// resolveReportParams turns LastDays into an explicit range ending now.
+func resolveReportParams(p domain.StarReportParams) domain.StarReportParams {
+	if p.DateFrom == nil && p.LastDays > 0 {
+		now := time.Now()
+		from := now.AddDate(0, 0, -p.LastDays)
+		p.DateFrom, p.DateTo = &from, &now
+	}
+	return p
+}
+
+// bindReportParams rewrites :date_from, :date_to and :office placeholders to
+// positional parameters and returns the matching argument list. String literals
+// (escape and dollar-quoted ones too), quoted identifiers, comments and ::
+// casts are left untouched.
+func bindReportParams(sql string, p domain.StarReportParams) (string, []interface{}, error) {
+	values := map[string]interface{}{
+		reportParamDateFrom: p.DateFrom,
+		reportParamDateTo:   p.DateTo,
+		reportParamOffice:   p.Office,
+	}
+
+	var out strings.Builder
+	var args []interface{}
+	positions := map[string]int{}
+	runes := []rune(sql)
+	var quote rune
+
+	var escapes bool // inside an E'' string, where a backslash escapes the next character
+
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		if quote != 0 {
+			out.WriteRune(c)
+			if escapes && c == '\\' && i+1 < len(runes) {
+				i++
+				out.WriteRune(runes[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if end := sqlSkippedRegion(runes, i); end > i {
+			out.WriteString(string(runes[i:end]))
+			i = end - 1
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"':
+			quote = c
+			escapes = c == '\'' && i > 0 && (runes[i-1] == 'E' || runes[i-1] == 'e') &&
+				(i == 1 || !(unicode.IsLetter(runes[i-2]) || unicode.IsDigit(runes[i-2]) || runes[i-2] == '_'))
+			out.WriteRune(c)
+		case c == ':' && i+1 < len(runes) && runes[i+1] == ':':
+			out.WriteString("::")
+			i++
+		case c == ':' && i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || runes[i+1] == '_'):
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			name := string(runes[i+1 : j])
+			v, ok := values[name]
+			if !ok {
+				return "", nil, fmt.Errorf("%w: unknown report parameter :%s", ErrInvalidReport, name)
+			}
+			pos, seen := positions[name]
+			if !seen {
+				args = append(args, v)
+				pos = len(args)
+				positions[name] = pos
+			}
+			fmt.Fprintf(&out, "$%d", pos)
+			i = j - 1
+		default:
+			out.WriteRune(c)
+		}
+	}
+
+	if p.DateFrom != nil || p.DateTo != nil || p.Office != nil {
+		for name, v := range values {
+			if _, used := positions[name]; !used && !isNilParam(v) {
+				return "", nil, fmt.Errorf("%w: report does not accept parameter %s", ErrInvalidReport, name)
+			}
+		}
+	}
+
+	return out.String(), args, nil
+}
+
+// sqlSkippedRegion returns the end of the comment or dollar-quoted string
+// starting at runes[i], or i if there is none. An unterminated one runs to
+// the end of the text.
+func sqlSkippedRegion(runes []rune, i int) int {
+	rest := string(runes[i:])
+	switch {
+	case strings.HasPrefix(rest, "--"):
+		j := i
+		for j < len(runes) && runes[j] != '\n' {
+			j++
+		}
+		return j
+	case strings.HasPrefix(rest, "/*"):
+		// Block comments nest in PostgreSQL.
+		depth := 0
+		for j := i; j < len(runes); j++ {
+			switch {
+			case runes[j] == '/' && j+1 < len(runes) && runes[j+1] == '*':
+				depth++
+				j++
+			case runes[j] == '*' && j+1 < len(runes) && runes[j+1] == '/':
+				depth--
+				j++
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(runes)
+	case runes[i] == '$' && (i == 0 || !(unicode.IsLetter(runes[i-1]) || unicode.IsDigit(runes[i-1]) || runes[i-1] == '_')):
+		// $tag$ ... $tag$, where the tag is empty or an identifier; $1 is a parameter.
+		j := i + 1
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || runes[j] == '_' || (j > i+1 && unicode.IsDigit(runes[j]))) {
+			j++
+		}
+		if j >= len(runes) || runes[j] != '$' {
+			return i
+		}
+		delim := string(runes[i : j+1])
+		n := j + 1 - i
+		for k := j + 1; k+n <= len(runes); k++ {
+			if string(runes[k:k+n]) == delim {
+				return k + n
+			}
+		}
+		return len(runes)
+	}
+	return i
+}
+
+func isNilParam(v interface{}) bool {
+	switch val := v.(type) {
+	case *time.Time:
+		return val == nil
+	case *string:
+		return val == nil
+	}
+	return v == nil
+}
+
+func writeResultRows(w ExportWriter, result *StarQueryResponse) error {
+	if err := w.WriteHeader(result.Columns); err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		if err := w.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// slugify turns a report name into a filesystem-safe file name prefix.
+func slugify(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteRune('_')
+		}
+	}
+	slug := strings.Trim(b.String(), "_")
+	if slug == "" {
+		return "report"
+	}
+	return slug
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// starEnumColumns are low-cardinality columns whose distinct values are sampled into the prompt.
+var starEnumColumns = map[string][]string{
+	"tickets":           {"status", "client_segment", "source_channel"},
+	"ticket_ai":         {"type", "sentiment", "lang", "geo_status"},
+	"ticket_assignment": {"routing_bucket"},
+	"audit_log":         {"step"},
+}
+
+const starEnumSampleLimit = 20
+
+type starColumn struct {
+	Name    string
+	Type    string
+	Comment string
+	Values  []string
+}
+
+type starTable struct {
+	Name    string
+	Comment string
+	Columns []starColumn
+}
+
+// LoadSchema introspects information_schema for the tables Star may read,
+// grants the Star DB role SELECT on their allowed columns and rebuilds the
+// system prompt and the guard's column allowlist from them. Until it
+// succeeds Star can read nothing.
+func (s *StarService) LoadSchema(ctx context.Context) error {
+	tables, err := s.introspectSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string][]string, len(tables))
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			columns[t.Name] = append(columns[t.Name], c.Name)
+		}
+	}
+	guard := NewStarSQLGuard()
+	guard.SetColumns(columns)
+
+	// Describe and grant only the columns Star may read.
+	for i := range tables {
+		tables[i].Columns = slices.DeleteFunc(tables[i].Columns, func(c starColumn) bool {
+			return !guard.AllowedColumn(tables[i].Name, c.Name)
+		})
+	}
+	if err := s.grantColumns(ctx, tables); err != nil {
+		return fmt.Errorf("grant star columns: %w", err)
+	}
+	s.guard = guard
+	s.systemPrompt = buildStarPrompt(renderStarSchema(tables))
+
+	log.Info().Int("tables", len(tables)).Msg("Star: schema loaded from information_schema")
+	return nil
+}
+
+// grantColumns replaces the Star DB role's privileges on tables with SELECT on
+// exactly their listed columns, so the database enforces the guard's allowlist.
+func (s *StarService) grantColumns(ctx context.Context, tables []starTable) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	role := pgx.Identifier{s.dbRole}.Sanitize()
+	for _, t := range tables {
+		table := pgx.Identifier{t.Name}.Sanitize()
+		// Revoking the table privilege revokes the column privileges as well.
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`REVOKE SELECT ON %s FROM %s`, table, role)); err != nil {
+			return err
+		}
+		if len(t.Columns) == 0 {
+			continue
+		}
+		cols := make([]string, len(t.Columns))
+		for i, c := range t.Columns {
+			cols[i] = pgx.Identifier{c.Name}.Sanitize()
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`GRANT SELECT (%s) ON %s TO %s`, strings.Join(cols, ", "), table, role)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *StarService) introspectSchema(ctx context.Context) ([]starTable, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT c.table_name, c.column_name,
+		        CASE WHEN c.data_type = 'ARRAY' THEN ltrim(c.udt_name, '_') || '[]'
+		             WHEN c.data_type = 'USER-DEFINED' THEN c.udt_name
+		             ELSE upper(c.data_type) END,
+		        COALESCE(col_description(format('%I.%I', c.table_schema, c.table_name)::regclass, c.ordinal_position), ''),
+		        COALESCE(obj_description(format('%I.%I', c.table_schema, c.table_name)::regclass, 'pg_class'), '')
+		 FROM information_schema.columns c
+		 WHERE c.table_schema = 'public' AND c.table_name = ANY($1)
+		 ORDER BY c.table_name, c.ordinal_position`, starTables)
+	if err != nil {
+		return nil, fmt.Errorf("introspect columns: %w", err)
+	}
+	defer rows.Close()
+
+	byName := map[string]*starTable{}
+	for rows.Next() {
+		var tableName, tableComment string
+		var col starColumn
+		if err := rows.Scan(&tableName, &col.Name, &col.Type, &col.Comment, &tableComment); err != nil {
+			return nil, err
+		}
+		t, ok := byName[tableName]
+		if !ok {
+			t = &starTable{Name: tableName, Comment: tableComment}
+			byName[tableName] = t
+		}
+		t.Columns = append(t.Columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	tables := make([]starTable, 0, len(byName))
+	for _, name := range starTables {
+		t, ok := byName[name]
+		if !ok {
+			continue
+		}
+		for i := range t.Columns {
+			if !slices.Contains(starEnumColumns[name], t.Columns[i].Name) {
+				continue
+			}
+			values, err := s.sampleValues(ctx, name, t.Columns[i].Name)
+			if err != nil {
+				log.Warn().Err(err).Str("table", name).Str("column", t.Columns[i].Name).Msg("Star: failed to sample enum values")
+				continue
+			}
+			t.Columns[i].Values = values
+		}
+		tables = append(tables, *t)
+	}
+	return tables, nil
+}
+
+func (s *StarService) sampleValues(ctx context.Context, table, column string) ([]string, error) {
+	col := pgx.Identifier{column}.Sanitize()
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(
+		`SELECT DISTINCT %s::text FROM %s WHERE %s IS NOT NULL ORDER BY 1 LIMIT %d`,
+		col, pgx.Identifier{table}.Sanitize(), col, starEnumSampleLimit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// renderStarSchema formats introspected tables as the prompt's schema section.
+func renderStarSchema(tables []starTable) string {
+	var b strings.Builder
+	for i, t := range tables {
+		fmt.Fprintf(&b, "%d. %s", i+1, t.Name)
+		if t.Comment != "" {
+			fmt.Fprintf(&b, " (%s)", t.Comment)
+		}
+		b.WriteString(":\n")
+		for _, c := range t.Columns {
+			fmt.Fprintf(&b, "   - %s", c.Name)
+			if c.Type != "" {
+				fmt.Fprintf(&b, " %s", c.Type)
+			}
+			if c.Comment != "" {
+				fmt.Fprintf(&b, " — %s", c.Comment)
+			}
+			if len(c.Values) > 0 {
+				quoted := make([]string, len(c.Values))
+				for j, v := range c.Values {
+					quoted[j] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+				}
+				fmt.Fprintf(&b, " — значения: %s", strings.Join(quoted, ", "))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// fallbackStarSchema lists the tables without columns while the schema has
+// not been loaded; the guard rejects every column until then.
+func fallbackStarSchema() string {
+	tables := make([]starTable, len(starTables))
+	for i, name := range starTables {
+		tables[i] = starTable{Name: name}
+	}
+	return renderStarSchema(tables)
+}
+
+func buildStarPrompt(schema string) string {
+	return starPromptHeader + "\n\nСхема базы данных:\n\n" + schema + "\n\n" + starPromptRules
+}
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 )
@@ -19,14 +21,25 @@ type StarService struct {
 	apiKey     string
 	model      string
 	httpClient *http.Client
+
+	guard            *StarSQLGuard
+	systemPrompt     string
+	dbRole           string
+	statementTimeout time.Duration
+	maxRows          int
 }
 
-func NewStarService(pool *pgxpool.Pool, apiKey, model string) *StarService {
+func NewStarService(pool *pgxpool.Pool, apiKey, model, dbRole string, statementTimeout time.Duration, maxRows int) *StarService {
 	return &StarService{
-		pool:       pool,
-		apiKey:     apiKey,
-		model:      model,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		pool:             pool,
+		apiKey:           apiKey,
+		model:            model,
+		httpClient:       &http.Client{Timeout: 30 * time.Second},
+		guard:            NewStarSQLGuard(),
+		systemPrompt:     buildStarPrompt(fallbackStarSchema()),
+		dbRole:           dbRole,
+		statementTimeout: statementTimeout,
+		maxRows:          maxRows,
 	}
 }
 
@@ -44,71 +57,15 @@ type StarQueryResponse struct {
 	AnswerText string          `json:"answer_text,omitempty"`
 	XLabel     string          `json:"x_label,omitempty"`
 	YLabel     string          `json:"y_label,omitempty"`
+	Truncated  bool            `json:"truncated,omitempty"`
 	Error      string          `json:"error,omitempty"`
+	Rejection  *SQLRejection   `json:"rejection,omitempty"`
 }
 
-const starSystemPrompt = `Ты — AI-аналитик Freedom Broker. Генерируй SQL-запросы для PostgreSQL на основе вопросов пользователя.
-
-Схема базы данных:
-
-1. tickets (тикеты / обращения клиентов):
-   - id UUID PRIMARY KEY
-   - external_id TEXT — внешний идентификатор
-   - subject TEXT — тема обращения
-   - body TEXT — текст обращения
-   - client_name TEXT — имя клиента
-   - client_segment TEXT — сегмент: 'Mass', 'VIP', 'Priority'
-   - source_channel TEXT — канал: 'Email', 'Telegram', 'WhatsApp', 'Phone'
-   - status TEXT — статус: 'new', 'enriching', 'enriched', 'routed', 'in_progress', 'resolved'
-   - raw_address TEXT — адрес клиента
-   - created_at TIMESTAMPTZ
-
-2. ticket_ai (AI-обогащение тикетов, связь ticket_id → tickets.id):
-   - ticket_id UUID REFERENCES tickets(id)
-   - type TEXT — тип обращения: 'Жалоба', 'Претензия', 'Консультация', 'Неработоспособность', 'Смена данных', 'Спам'
-   - sentiment TEXT — 'Позитивный', 'Негативный', 'Нейтральный'
-   - priority_1_10 INT — приоритет от 1 до 10
-   - lang TEXT — язык: 'RU', 'KZ', 'EN'
-   - summary TEXT — краткое резюме
-   - lat FLOAT, lon FLOAT — координаты
-   - geo_status TEXT — 'known', 'unknown'
-   - processing_ms INT — время обработки AI в мс
-   - enriched_at TIMESTAMPTZ
-
-3. ticket_assignment (назначение тикетов менеджерам):
-   - ticket_id UUID REFERENCES tickets(id)
-   - manager_id UUID REFERENCES managers(id)
-   - business_unit_id UUID REFERENCES business_units(id)
-   - routing_reason TEXT — причина маршрутизации
-   - assigned_at TIMESTAMPTZ
-   - is_current BOOL — текущее назначение
-
-4. managers (менеджеры):
-   - id UUID PRIMARY KEY
-   - full_name TEXT
-   - email TEXT
-   - business_unit_id UUID REFERENCES business_units(id)
-   - is_vip_skill BOOL — может обрабатывать VIP
-   - is_chief_spec BOOL — главный специалист
-   - languages TEXT[] — массив языков
-   - current_load INT — текущая нагрузка
-   - max_load INT — максимальная нагрузка
-   - is_active BOOL
-
-5. business_units (офисы / бизнес-юниты):
-   - id UUID PRIMARY KEY
-   - name TEXT — название офиса
-   - city TEXT — город
-   - address TEXT — адрес
-
-Ответь СТРОГО JSON без markdown-обёрток, без тройных кавычек, без слова json:
-- tickets(id UUID, external_id TEXT, subject TEXT, body TEXT, client_name TEXT, client_segment TEXT, source_channel TEXT, status TEXT, raw_address TEXT, created_at TIMESTAMPTZ)
-- ticket_ai(ticket_id UUID, type TEXT, sentiment TEXT, priority_1_10 INT, lang TEXT, summary TEXT, lat FLOAT, lon FLOAT, geo_status TEXT, processing_ms INT, enriched_at TIMESTAMPTZ)
-- ticket_assignment(ticket_id UUID, manager_id UUID, business_unit_id UUID, routing_reason TEXT, assigned_at TIMESTAMPTZ, is_current BOOL)
-- managers(id UUID, full_name TEXT, email TEXT, business_unit_id UUID, is_vip_skill BOOL, is_chief_spec BOOL, languages TEXT[], current_load INT, max_load INT, is_active BOOL)
-- business_units(id UUID, name TEXT, city TEXT, address TEXT)
-
-Ответь ТОЛЬКО JSON без markdown:
+const starPromptHeader = `Ты — AI-аналитик Freedom Broker. Генерируй SQL-запросы для PostgreSQL на основе вопросов пользователя.`
+
+// starPromptRules follows the generated schema section of the system prompt.
+const starPromptRules = `Ответь ТОЛЬКО JSON без markdown:
 {
   "sql": "SELECT ...",
   "chart_type": "bar" | "pie" | "line" | "table" | "number",
@@ -119,6 +76,8 @@ const starSystemPrompt = `Ты — AI-аналитик Freedom Broker. Гене
 
 Правила генерации SQL:
 - ТОЛЬКО SELECT запросы! Никаких INSERT/UPDATE/DELETE/DROP/ALTER/TRUNCATE
+- Используй только таблицы из схемы выше и стандартные функции (агрегаты, даты, строки); служебные функции PostgreSQL запрещены
+- Перечисляй нужные столбцы явно: SELECT *, t.* и ссылки на строку целиком (SELECT t FROM tickets t) запрещены
 - ВАЖНО: Если ты используешь колонки из разных таблиц, ты ОБЯЗАН их правильно связать (JOIN):
   * Чтобы использовать ticket_ai, сделай JOIN ticket_ai ON tickets.id = ticket_ai.ticket_id
   * Чтобы использовать business_units (офисы), сделай JOIN ticket_assignment ON tickets.id = ticket_assignment.ticket_id JOIN business_units ON business_units.id = ticket_assignment.business_unit_id (используй is_current = true)
@@ -203,6 +162,7 @@ func (s *StarService) QueryWithAI(ctx context.Context, question string) (*StarQu
 				AnswerText: fmt.Sprintf("Не удалось выполнить запрос: %v", err),
 				ChartType:  "table",
 				Error:      err.Error(),
+				Rejection:  asRejection(err),
 			}, nil
 		}
 
@@ -215,6 +175,7 @@ func (s *StarService) QueryWithAI(ctx context.Context, question string) (*StarQu
 				AnswerText: fmt.Sprintf("Повторный запрос тоже не удался: %v", err),
 				ChartType:  "table",
 				Error:      err.Error(),
+				Rejection:  asRejection(err),
 			}, nil
 		}
 
@@ -234,7 +195,7 @@ func (s *StarService) QueryWithAI(ctx context.Context, question string) (*StarQu
 // callStarAI sends a question to OpenAI and parses the structured JSON response.
 func (s *StarService) callStarAI(ctx context.Context, question string) (*starAIResponse, error) {
 	return s.callStarAIWithMessages(ctx, []openAIMessage{
-		{Role: "system", Content: starSystemPrompt},
+		{Role: "system", Content: s.systemPrompt},
 		{Role: "user", Content: question},
 	})
 }
@@ -247,7 +208,7 @@ func (s *StarService) retryWithError(ctx context.Context, question, failedSQL, s
 	)
 
 	return s.callStarAIWithMessages(ctx, []openAIMessage{
-		{Role: "system", Content: starSystemPrompt},
+		{Role: "system", Content: s.systemPrompt},
 		{Role: "user", Content: question},
 		{Role: "assistant", Content: fmt.Sprintf(`{"sql": "%s"}`, strings.ReplaceAll(failedSQL, `"`, `\"`))},
 		{Role: "user", Content: retryMsg},
@@ -314,23 +275,84 @@ func (s *StarService) callStarAIWithMessages(ctx context.Context, messages []ope
 }
 
 
-// ExecuteReadOnlySQL safely executes a read-only SQL query.
-func (s *StarService) ExecuteReadOnlySQL(ctx context.Context, sql string) (*StarQueryResponse, error) {
-	trimmed := strings.TrimSpace(strings.ToUpper(sql))
-	if !strings.HasPrefix(trimmed, "SELECT") && !strings.HasPrefix(trimmed, "WITH") {
-		return nil, fmt.Errorf("only SELECT queries are allowed")
+// ExecuteReadOnlySQL validates a query with the SQL guard and runs it inside a
+// READ ONLY transaction under the Star DB role, with a statement timeout and row cap.
+func (s *StarService) ExecuteReadOnlySQL(ctx context.Context, sql string, args ...interface{}) (*StarQueryResponse, error) {
+	var columns []string
+	resultRows := [][]interface{}{}
+
+	truncated, err := s.StreamReadOnlySQL(ctx, sql, s.maxRows, args,
+		func(cols []string) error {
+			columns = cols
+			return nil
+		},
+		func(values []interface{}) error {
+			cleaned := make([]interface{}, len(values))
+			for i, v := range values {
+				switch val := v.(type) {
+				case time.Time:
+					cleaned[i] = val.Format("2006-01-02 15:04")
+				case []byte:
+					cleaned[i] = string(val)
+				default:
+					cleaned[i] = val
+				}
+			}
+			resultRows = append(resultRows, cleaned)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return &StarQueryResponse{
+		SQL:       sql,
+		Columns:   columns,
+		Rows:      resultRows,
+		Truncated: truncated,
+	}, nil
+}
+
+// StreamReadOnlySQL runs a guarded query like ExecuteReadOnlySQL but hands each
+// row's raw values to onRow instead of collecting them. onColumns is called once
+// before the first row. It stops after limit rows (0 = no limit) and reports
+// whether more rows were available; the limit is applied by the server, so a
+// huge result is never produced, let alone read.
+func (s *StarService) StreamReadOnlySQL(ctx context.Context, sql string, limit int, args []interface{},
+	onColumns func([]string) error, onRow func([]interface{}) error) (bool, error) {
+	if err := s.guard.Validate(sql); err != nil {
+		return false, err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return false, fmt.Errorf("begin read-only tx: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	forbidden := []string{"INSERT", "UPDATE", "DELETE", "DROP", "ALTER", "TRUNCATE", "CREATE", "GRANT", "REVOKE"}
-	for _, kw := range forbidden {
-		if strings.Contains(trimmed, " "+kw+" ") || strings.HasPrefix(trimmed, kw+" ") {
-			return nil, fmt.Errorf("forbidden keyword: %s", kw)
+	if s.dbRole != "" {
+		if _, err := tx.Exec(ctx, "SET LOCAL ROLE "+pgx.Identifier{s.dbRole}.Sanitize()); err != nil {
+			return false, fmt.Errorf("switch to role %s: %w", s.dbRole, err)
+		}
+	}
+	if s.statementTimeout > 0 {
+		if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", s.statementTimeout.Milliseconds())); err != nil {
+			return false, fmt.Errorf("set statement timeout: %w", err)
 		}
 	}
 
-	rows, err := s.pool.Query(ctx, sql)
+	query := sql
+	if limit > 0 {
+		stmt, err := statementText(sql)
+		if err != nil {
+			return false, err
+		}
+		// One row past the limit tells whether the result was cut.
+		query = fmt.Sprintf("SELECT * FROM (\n%s\n) AS star_query LIMIT %d", stmt, limit+1)
+	}
+	rows, err := tx.Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("execute query: %w", err)
+		return false, fmt.Errorf("execute query: %w", err)
 	}
 	defer rows.Close()
 
@@ -339,34 +361,48 @@ func (s *StarService) ExecuteReadOnlySQL(ctx context.Context, sql string) (*Star
 	for i, fd := range fieldDescs {
 		columns[i] = string(fd.Name)
 	}
+	if err := onColumns(columns); err != nil {
+		return false, err
+	}
 
-	var resultRows [][]interface{}
+	count := 0
+	truncated := false
 	for rows.Next() {
+		if limit > 0 && count >= limit {
+			truncated = true
+			break
+		}
 		values, err := rows.Values()
 		if err != nil {
-			return nil, err
+			return false, err
 		}
-		cleaned := make([]interface{}, len(values))
-		for i, v := range values {
-			switch val := v.(type) {
-			case time.Time:
-				cleaned[i] = val.Format("2006-01-02 15:04")
-			case []byte:
-				cleaned[i] = string(val)
-			default:
-				cleaned[i] = val
-			}
+		if err := onRow(values); err != nil {
+			return false, err
 		}
-		resultRows = append(resultRows, cleaned)
+		count++
 	}
-
-	if resultRows == nil {
-		resultRows = [][]interface{}{}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("execute query: %w", err)
 	}
+	return truncated, nil
+}
 
-	return &StarQueryResponse{
-		SQL:     sql,
-		Columns: columns,
-		Rows:    resultRows,
-	}, nil
+// Export streams a guarded query's result to w, stopping after limit rows.
+func (s *StarService) Export(ctx context.Context, sql string, limit int, w ExportWriter) (bool, error) {
+	return s.StreamReadOnlySQL(ctx, sql, limit, nil, w.WriteHeader, w.WriteRow)
+}
+
+// ValidateSQL checks a query against the Star SQL guard without executing it.
+func (s *StarService) ValidateSQL(sql string) error {
+	return s.guard.Validate(sql)
+}
+
+// asRejection returns the guard rejection wrapped in err, if any.
+func asRejection(err error) *SQLRejection {
+	var rej *SQLRejection
+	if errors.As(err, &rej) {
+		return rej
+	}
+	return nil
 }
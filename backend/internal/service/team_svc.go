@@ -0,0 +1,502 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+var (
+	// ErrInvalidTeam is returned when a team or its members fail validation.
+	ErrInvalidTeam = errors.New("invalid team")
+	// ErrInvalidEscalation is returned when an escalation request fails validation.
+	ErrInvalidEscalation = errors.New("invalid escalation")
+	// ErrNotEscalatable is returned when escalating a resolved or closed ticket.
+	ErrNotEscalatable = errors.New("ticket cannot be escalated")
+	// ErrNoEscalationTarget is returned when the escalation path finds no one
+	// to take the ticket.
+	ErrNoEscalationTarget = errors.New("no supervisor or chief specialist to escalate to")
+)
+
+const (
+	teamTicketsDefaultLimit = 100
+	teamTicketsMaxLimit     = 1000
+
+	// escalationMaxDepth bounds the walk up the escalation teams.
+	escalationMaxDepth = 10
+)
+
+// TeamService manages teams and their supervisors, and escalates tickets
+// along the team hierarchy.
+type TeamService struct {
+	pool           *pgxpool.Pool
+	teamRepo       *repository.TeamRepo
+	managerRepo    *repository.ManagerRepo
+	buRepo         *repository.BusinessUnitRepo
+	ticketRepo     *repository.TicketRepo
+	assignmentRepo *repository.AssignmentRepo
+	auditRepo      *repository.AuditRepo
+	changeRepo     *repository.EntityAuditRepo
+	scoring        domain.QueueScoring
+}
+
+func NewTeamService(
+	pool *pgxpool.Pool,
+	tr *repository.TeamRepo, mr *repository.ManagerRepo, br *repository.BusinessUnitRepo, tkr *repository.TicketRepo,
+	asr *repository.AssignmentRepo, ar *repository.AuditRepo, changes *repository.EntityAuditRepo,
+	scoring domain.QueueScoring,
+) *TeamService {
+	return &TeamService{
+		pool: pool, teamRepo: tr, managerRepo: mr, buRepo: br, ticketRepo: tkr,
+		assignmentRepo: asr, auditRepo: ar, changeRepo: changes, scoring: scoring,
+	}
+}
+
+// List returns the teams, optionally of one office or supervisor.
+func (s *TeamService) List(ctx context.Context, officeID, supervisorID *uuid.UUID) ([]domain.TeamWithInfo, error) {
+	return s.teamRepo.List(ctx, officeID, supervisorID)
+}
+
+// Changes returns the audited changes to a team, newest first.
+func (s *TeamService) Changes(ctx context.Context, id uuid.UUID) ([]domain.EntityAudit, error) {
+	return s.changeRepo.List(ctx, domain.EntityTeams, id)
+}
+
+// Overview returns a supervisor's view of a team: its members with their
+// load and the SLA state of their open tickets, and the totals.
+func (s *TeamService) Overview(ctx context.Context, id uuid.UUID) (*domain.TeamOverview, error) {
+	team, err := s.teamRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	office, err := s.buRepo.GetByID(ctx, team.BusinessUnitID)
+	if err != nil {
+		return nil, fmt.Errorf("load office: %w", err)
+	}
+	members, err := s.teamRepo.Members(ctx, id, s.scoring)
+	if err != nil {
+		return nil, fmt.Errorf("load members: %w", err)
+	}
+
+	o := &domain.TeamOverview{
+		TeamWithInfo: domain.TeamWithInfo{Team: *team, OfficeName: office.Name, Members: len(members)},
+		MemberLoad:   members,
+	}
+	if team.SupervisorID != nil {
+		if o.Supervisor, err = s.managerRepo.GetByID(ctx, *team.SupervisorID); err != nil {
+			return nil, fmt.Errorf("load supervisor: %w", err)
+		}
+		o.SupervisorName = &o.Supervisor.FullName
+		if o.Escalated, err = s.teamRepo.CountEscalatedTo(ctx, o.Supervisor.ID); err != nil {
+			return nil, fmt.Errorf("count escalations: %w", err)
+		}
+	}
+
+	var load, capacity int
+	for _, m := range members {
+		o.Open += m.Open
+		o.InProgress += m.InProgress
+		o.AtRisk += m.AtRisk
+		o.Overdue += m.Overdue
+		load += m.CurrentLoad
+		capacity += m.MaxLoad
+	}
+	if capacity > 0 {
+		o.Utilization = float64(load) / float64(capacity) * 100
+	}
+	return o, nil
+}
+
+// Tickets returns the open tickets of a team's members and supervisor,
+// nearest SLA deadline first, optionally only those in one SLA state.
+func (s *TeamService) Tickets(ctx context.Context, id uuid.UUID, slaState string, limit int) ([]domain.TeamTicket, error) {
+	switch slaState {
+	case "", domain.SLAStateOK, domain.SLAStateAtRisk, domain.SLAStateOverdue:
+	default:
+		return nil, fmt.Errorf("%w: unknown SLA state %q (want ok, at_risk or overdue)", ErrInvalidTeam, slaState)
+	}
+	if _, err := s.teamRepo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = teamTicketsDefaultLimit
+	}
+	limit = min(limit, teamTicketsMaxLimit)
+	return s.teamRepo.Tickets(ctx, id, s.scoring, slaState, limit)
+}
+
+// Create adds an active team with no members.
+func (s *TeamService) Create(ctx context.Context, t *domain.Team, actor string) error {
+	t.ID = uuid.New()
+	t.IsActive = true
+
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		office, err := s.buRepo.GetByID(ctx, t.BusinessUnitID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: business unit %s not found", ErrInvalidTeam, t.BusinessUnitID)
+		}
+		if err != nil {
+			return err
+		}
+		if !office.IsActive {
+			return fmt.Errorf("%w: office %s is inactive", ErrInvalidTeam, office.Name)
+		}
+		if err := s.validateTeam(ctx, tx, t); err != nil {
+			return err
+		}
+		if err := s.teamRepo.Insert(ctx, tx, t); err != nil {
+			return err
+		}
+		return recordChange(ctx, s.changeRepo, tx, domain.EntityTeams, t.ID, domain.EntityActionCreate, actor, nil, t, nil)
+	})
+}
+
+// Update replaces a team's name, supervisor and escalation team. The office
+// stays the one the team was created in.
+func (s *TeamService) Update(ctx context.Context, t *domain.Team, actor string) (*domain.Team, error) {
+	var updated *domain.Team
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := s.teamRepo.GetForUpdate(ctx, tx, t.ID)
+		if err != nil {
+			return err
+		}
+		if t.BusinessUnitID != uuid.Nil && t.BusinessUnitID != before.BusinessUnitID {
+			return fmt.Errorf("%w: a team cannot move to another office", ErrInvalidTeam)
+		}
+		t.BusinessUnitID = before.BusinessUnitID
+		if err := s.validateTeam(ctx, tx, t); err != nil {
+			return err
+		}
+		if updated, err = s.teamRepo.Update(ctx, tx, t); err != nil {
+			return err
+		}
+		return recordChange(ctx, s.changeRepo, tx, domain.EntityTeams, t.ID, domain.EntityActionUpdate, actor, before, updated, nil)
+	})
+	return updated, err
+}
+
+// SetMembers replaces a team's members. Members must work in the team's
+// office; a manager belongs to one team, so listed managers leave their
+// previous team.
+func (s *TeamService) SetMembers(ctx context.Context, id uuid.UUID, managerIDs []uuid.UUID, actor string) (*domain.TeamOverview, error) {
+	ids := []uuid.UUID{}
+	for _, mid := range managerIDs {
+		if !slices.Contains(ids, mid) {
+			ids = append(ids, mid)
+		}
+	}
+
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		team, err := s.teamRepo.GetForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		for _, mid := range ids {
+			m, err := s.managerRepo.GetForUpdate(ctx, tx, mid)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: manager %s not found", ErrInvalidTeam, mid)
+			}
+			if err != nil {
+				return err
+			}
+			if m.BusinessUnitID != team.BusinessUnitID {
+				return fmt.Errorf("%w: %s works in another office", ErrInvalidTeam, m.FullName)
+			}
+		}
+		before, err := s.teamRepo.MemberIDs(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.teamRepo.SetMembers(ctx, tx, id, ids); err != nil {
+			return fmt.Errorf("set members: %w", err)
+		}
+		details := map[string]interface{}{"members_before": before, "members": ids}
+		return recordChange(ctx, s.changeRepo, tx, domain.EntityTeams, id, domain.EntityActionUpdate, actor, team, team, details)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Overview(ctx, id)
+}
+
+// SetActive activates or deactivates a team. Escalations pass over inactive
+// teams to their escalation team; members stay.
+func (s *TeamService) SetActive(ctx context.Context, id uuid.UUID, active bool, actor string) (*domain.Team, error) {
+	action := domain.EntityActionActivate
+	if !active {
+		action = domain.EntityActionDeactivate
+	}
+	var changed *domain.Team
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := s.teamRepo.GetForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if changed, err = s.teamRepo.SetActive(ctx, tx, id, active); err != nil {
+			return err
+		}
+		return recordChange(ctx, s.changeRepo, tx, domain.EntityTeams, id, action, actor, before, changed, nil)
+	})
+	return changed, err
+}
+
+// validateTeam checks a team's name, supervisor and escalation team, which
+// must not lead back to the team.
+func (s *TeamService) validateTeam(ctx context.Context, tx pgx.Tx, t *domain.Team) error {
+	t.Name = strings.Join(strings.Fields(t.Name), " ")
+	if t.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTeam)
+	}
+	if t.SupervisorID != nil {
+		m, err := s.managerRepo.GetByID(ctx, *t.SupervisorID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: supervisor %s not found", ErrInvalidTeam, *t.SupervisorID)
+		}
+		if err != nil {
+			return err
+		}
+		if !m.IsActive {
+			return fmt.Errorf("%w: supervisor %s is inactive", ErrInvalidTeam, m.FullName)
+		}
+	}
+	for next, depth := t.EscalationTeamID, 0; next != nil; depth++ {
+		if *next == t.ID || depth >= escalationMaxDepth {
+			return fmt.Errorf("%w: escalation teams must not form a loop", ErrInvalidTeam)
+		}
+		up, err := s.teamRepo.GetTx(ctx, tx, *next)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: escalation team %s not found", ErrInvalidTeam, *next)
+		}
+		if err != nil {
+			return err
+		}
+		next = up.EscalationTeamID
+	}
+	return nil
+}
+
+// ── Escalation ──
+
+// EscalationRequest escalates a ticket. Without ToManagerID the ticket goes
+// up the escalation path of its manager.
+type EscalationRequest struct {
+	Reason      string     `json:"reason"`
+	ToManagerID *uuid.UUID `json:"to_manager_id"`
+}
+
+// EscalationResult is an escalated ticket's new manager and status.
+type EscalationResult struct {
+	Escalation domain.Escalation `json:"escalation"`
+	Manager    *domain.Manager   `json:"manager"`
+	Status     string            `json:"status"`
+}
+
+// Escalate moves an open ticket to the next person up its manager's
+// escalation path: the supervisor of the manager's team, else of the first
+// escalation team above it with an active supervisor, else the least loaded
+// chief specialist, preferring the manager's office. The ticket waits for
+// its new manager as "routed"; the move is kept with its reason and audited.
+func (s *TeamService) Escalate(ctx context.Context, ticketID uuid.UUID, req EscalationRequest, actor string) (*EscalationResult, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidEscalation)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status, err := s.ticketRepo.LockStatus(ctx, tx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if status == "resolved" || status == "closed" {
+		return nil, fmt.Errorf("%w: ticket is %s", ErrNotEscalatable, status)
+	}
+	currentID, err := s.assignmentRepo.LockCurrent(ctx, tx, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("lock assignment: %w", err)
+	}
+	var from *domain.Manager
+	if currentID != nil {
+		if from, err = s.managerRepo.GetByID(ctx, *currentID); err != nil {
+			return nil, fmt.Errorf("load manager: %w", err)
+		}
+	}
+
+	target, kind, teamID, err := s.escalationTarget(ctx, tx, from, req.ToManagerID)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := "Escalation: " + req.Reason
+	if from != nil {
+		moved, err := s.assignmentRepo.Move(ctx, tx, ticketID, from.ID, target, "escalation", reason)
+		if err != nil {
+			return nil, fmt.Errorf("reassign: %w", err)
+		}
+		if !moved {
+			return nil, fmt.Errorf("%w: ticket is no longer open", ErrNotEscalatable)
+		}
+		if err := s.managerRepo.AddLoad(ctx, tx, from.ID, -1); err != nil {
+			return nil, fmt.Errorf("release load: %w", err)
+		}
+	} else if err := s.assignmentRepo.Insert(ctx, tx, &domain.TicketAssignment{
+		ID:             uuid.New(),
+		TicketID:       ticketID,
+		ManagerID:      target.ID,
+		BusinessUnitID: target.BusinessUnitID,
+		OfficeID:       target.BusinessUnitID,
+		RoutingBucket:  "escalation",
+		RoutingReason:  &reason,
+		IsCurrent:      true,
+	}); err != nil {
+		return nil, fmt.Errorf("assign: %w", err)
+	}
+	if err := s.managerRepo.AddLoad(ctx, tx, target.ID, 1); err != nil {
+		return nil, fmt.Errorf("add load: %w", err)
+	}
+	target.CurrentLoad++
+
+	if status == "enriched" || status == domain.TicketStatusInProgress {
+		status = domain.TicketStatusRouted
+		if err := s.ticketRepo.SetStatus(ctx, tx, ticketID, status); err != nil {
+			return nil, fmt.Errorf("update ticket status: %w", err)
+		}
+	}
+
+	e := domain.Escalation{
+		ID:          uuid.New(),
+		TicketID:    ticketID,
+		ToManagerID: target.ID,
+		TeamID:      teamID,
+		Target:      kind,
+		Reason:      req.Reason,
+	}
+	if from != nil {
+		e.FromManagerID = &from.ID
+	}
+	if actor != "" {
+		e.Actor = &actor
+	}
+	if err := s.teamRepo.InsertEscalation(ctx, tx, &e); err != nil {
+		return nil, fmt.Errorf("record escalation: %w", err)
+	}
+
+	fromName := "unassigned"
+	if from != nil {
+		fromName = from.FullName
+	}
+	output, _ := json.Marshal(map[string]interface{}{
+		"from_manager_id": e.FromManagerID,
+		"manager_id":      target.ID,
+		"manager_name":    target.FullName,
+		"team_id":         teamID,
+		"target":          kind,
+		"level":           e.Level,
+		"reason":          req.Reason,
+	})
+	candidates, _ := json.Marshal([]uuid.UUID{target.ID})
+	if err := s.auditRepo.InsertTx(ctx, tx, &domain.AuditLog{
+		ID:         uuid.New(),
+		TicketID:   ticketID,
+		Step:       domain.AuditStepEscalation,
+		OutputData: output,
+		Decision:   fmt.Sprintf("Escalated (level %d) from %s to %s %s: %s", e.Level, fromName, strings.ReplaceAll(kind, "_", " "), target.FullName, req.Reason),
+		Candidates: candidates,
+	}); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return &EscalationResult{Escalation: e, Manager: target, Status: status}, nil
+}
+
+// escalationTarget picks who takes an escalated ticket from its current
+// manager (nil if unassigned), with the team whose supervisor that is.
+func (s *TeamService) escalationTarget(ctx context.Context, tx pgx.Tx, from *domain.Manager, to *uuid.UUID) (*domain.Manager, string, *uuid.UUID, error) {
+	if to != nil {
+		m, err := s.managerRepo.GetByID(ctx, *to)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, "", nil, fmt.Errorf("%w: manager %s not found", ErrInvalidEscalation, *to)
+		}
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if !m.IsActive {
+			return nil, "", nil, fmt.Errorf("%w: %s is inactive", ErrInvalidEscalation, m.FullName)
+		}
+		if from != nil && m.ID == from.ID {
+			return nil, "", nil, fmt.Errorf("%w: the ticket is already with %s", ErrInvalidEscalation, m.FullName)
+		}
+		return m, domain.EscalationTargetManager, nil, nil
+	}
+
+	exclude := []uuid.UUID{}
+	officeID := uuid.Nil
+	if from != nil {
+		exclude = append(exclude, from.ID)
+		officeID = from.BusinessUnitID
+
+		next := from.TeamID
+		for depth := 0; next != nil && depth < escalationMaxDepth; depth++ {
+			team, err := s.teamRepo.GetTx(ctx, tx, *next)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("load team: %w", err)
+			}
+			if team.IsActive && team.SupervisorID != nil && *team.SupervisorID != from.ID {
+				sup, err := s.managerRepo.GetByID(ctx, *team.SupervisorID)
+				if err != nil {
+					return nil, "", nil, fmt.Errorf("load supervisor: %w", err)
+				}
+				if sup.IsActive {
+					return sup, domain.EscalationTargetSupervisor, &team.ID, nil
+				}
+			}
+			next = team.EscalationTeamID
+		}
+	}
+
+	chief, err := s.managerRepo.LeastLoadedWithSkill(ctx, tx, domain.SkillChiefSpec, officeID, exclude)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", nil, ErrNoEscalationTarget
+	}
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("find chief specialist: %w", err)
+	}
+	return chief, domain.EscalationTargetChiefSpec, nil, nil
+}
+
+// Escalations returns a ticket's escalations, oldest first.
+func (s *TeamService) Escalations(ctx context.Context, ticketID uuid.UUID) ([]domain.Escalation, error) {
+	if _, err := s.ticketRepo.GetByID(ctx, ticketID); err != nil {
+		return nil, err
+	}
+	return s.teamRepo.Escalations(ctx, ticketID)
+}
+
+func (s *TeamService) inTx(ctx context.Context, fn func(pgx.Tx) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
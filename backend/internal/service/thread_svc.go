@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/arslan/fire-challenge/internal/domain"
+	"github.com/arslan/fire-challenge/internal/repository"
+)
+
+// ThreadService links a client's related tickets into threads. Two tickets
+// are related when they come from the same client within window and their
+// texts are at least relatedMin similar (MinHash over word shingles); from
+// duplicateMin on, the newer one is also marked as a near-duplicate.
+type ThreadService struct {
+	ticketRepo   *repository.TicketRepo
+	window       time.Duration
+	relatedMin   float64
+	duplicateMin float64
+}
+
+func NewThreadService(tr *repository.TicketRepo, window time.Duration, relatedMin, duplicateMin float64) *ThreadService {
+	return &ThreadService{ticketRepo: tr, window: window, relatedMin: relatedMin, duplicateMin: duplicateMin}
+}
+
+// LinkTickets matches each ticket against its client's earlier tickets and
+// joins it to the thread of the most similar one. ids must be in arrival
+// order; tickets already in a thread are left alone. It returns how many
+// tickets were linked.
+func (s *ThreadService) LinkTickets(ctx context.Context, ids []uuid.UUID) (int, error) {
+	tickets, err := s.ticketRepo.ListByIDs(ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("list tickets: %w", err)
+	}
+	byID := make(map[uuid.UUID]*domain.Ticket, len(tickets))
+	for i := range tickets {
+		byID[tickets[i].ID] = &tickets[i]
+	}
+
+	order := make(map[uuid.UUID]int, len(ids))
+	byClient := map[string][]*domain.Ticket{}
+	var clients []string
+	for i, id := range ids {
+		if _, seen := order[id]; seen {
+			continue
+		}
+		order[id] = i
+		t, ok := byID[id]
+		if !ok || t.ClientGUID == nil || t.ThreadID != nil {
+			continue
+		}
+		if _, ok := byClient[*t.ClientGUID]; !ok {
+			clients = append(clients, *t.ClientGUID)
+		}
+		byClient[*t.ClientGUID] = append(byClient[*t.ClientGUID], t)
+	}
+
+	linked := 0
+	for _, guid := range clients {
+		batch := byClient[guid]
+		from, to := batch[0].CreatedAt, batch[0].CreatedAt
+		for _, t := range batch[1:] {
+			if t.CreatedAt.Before(from) {
+				from = t.CreatedAt
+			}
+			if t.CreatedAt.After(to) {
+				to = t.CreatedAt
+			}
+		}
+		history, err := s.ticketRepo.ListByClient(ctx, guid, from.Add(-s.window), to)
+		if err != nil {
+			return linked, fmt.Errorf("list client tickets: %w", err)
+		}
+
+		known := make(map[uuid.UUID]*domain.Ticket, len(history))
+		for i := range history {
+			known[history[i].ID] = &history[i]
+		}
+		signatures := map[uuid.UUID][]uint64{}
+		signature := func(t *domain.Ticket) []uint64 {
+			sig, ok := signatures[t.ID]
+			if !ok {
+				sig = minhashSignature(t.Body)
+				signatures[t.ID] = sig
+			}
+			return sig
+		}
+		// earlier reports whether c arrived before t: by creation time, and
+		// by position in ids for tickets created in the same instant.
+		earlier := func(c, t *domain.Ticket) bool {
+			if !c.CreatedAt.Equal(t.CreatedAt) {
+				return c.CreatedAt.Before(t.CreatedAt)
+			}
+			ci, inBatch := order[c.ID]
+			return !inBatch || ci < order[t.ID]
+		}
+
+		for _, t := range batch {
+			sig := signature(t)
+			var best *domain.Ticket
+			bestSim := 0.0
+			for i := range history {
+				c := &history[i]
+				if c.ID == t.ID || !earlier(c, t) || t.CreatedAt.Sub(c.CreatedAt) > s.window {
+					continue
+				}
+				if sim := minhashSimilarity(sig, signature(c)); sim > bestSim {
+					best, bestSim = c, sim
+				}
+			}
+			if best == nil || bestSim < s.relatedMin {
+				continue
+			}
+
+			threadID := best.ID
+			if best.ThreadID != nil {
+				threadID = *best.ThreadID
+			}
+			var duplicateOf *uuid.UUID
+			if bestSim >= s.duplicateMin {
+				duplicateOf = &best.ID
+			}
+			if err := s.ticketRepo.LinkThread(ctx, t.ID, threadID, duplicateOf, bestSim); err != nil {
+				return linked, fmt.Errorf("link ticket %s: %w", t.ID, err)
+			}
+			linked++
+
+			// Keep the in-memory history in step for later tickets of the batch.
+			if root, ok := known[threadID]; ok && root.ThreadID == nil {
+				root.ThreadID = &threadID
+			}
+			if self, ok := known[t.ID]; ok {
+				self.ThreadID = &threadID
+			}
+		}
+	}
+	return linked, nil
+}
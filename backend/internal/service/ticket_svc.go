@@ -91,6 +91,16 @@ func (s *TicketService) GetWithDetails(ctx context.Context, id uuid.UUID) (*doma
 	}
 	result.AuditTrail = audit
 
+	// Related tickets of the same client
+	result.Thread = []domain.Ticket{}
+	if ticket.ThreadID != nil {
+		thread, err := s.ticketRepo.ListThread(ctx, *ticket.ThreadID)
+		if err != nil {
+			return nil, err
+		}
+		result.Thread = thread
+	}
+
 	return result, nil
 }
 
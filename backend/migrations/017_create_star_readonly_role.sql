@@ -0,0 +1,17 @@
+-- Migration 017: Low-privilege role for Star assistant queries.
+-- The backend switches to this role (SET LOCAL ROLE) inside a READ ONLY
+-- transaction before running any AI-generated SQL.
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'star_readonly') THEN
+        CREATE ROLE star_readonly NOLOGIN;
+    END IF;
+END $$;
+
+REVOKE ALL ON ALL TABLES IN SCHEMA public FROM star_readonly;
+GRANT USAGE ON SCHEMA public TO star_readonly;
+GRANT SELECT ON tickets, ticket_ai, ticket_assignment, managers, business_units TO star_readonly;
+
+-- Allow the application user to SET ROLE star_readonly.
+GRANT star_readonly TO CURRENT_USER;
@@ -0,0 +1,62 @@
+-- Migration 018: Column comments consumed by Star schema introspection.
+-- The Star assistant builds its prompt from information_schema + these comments,
+-- so describing a column here is enough for Star to use it correctly.
+
+COMMENT ON TABLE tickets IS 'Тикеты / обращения клиентов';
+COMMENT ON COLUMN tickets.external_id IS 'Внешний идентификатор (GUID клиента из CSV)';
+COMMENT ON COLUMN tickets.subject IS 'Тема обращения';
+COMMENT ON COLUMN tickets.body IS 'Текст обращения';
+COMMENT ON COLUMN tickets.client_name IS 'Имя клиента';
+COMMENT ON COLUMN tickets.client_segment IS 'Сегмент клиента';
+COMMENT ON COLUMN tickets.source_channel IS 'Канал обращения';
+COMMENT ON COLUMN tickets.status IS 'Статус обработки тикета';
+COMMENT ON COLUMN tickets.raw_address IS 'Адрес клиента';
+COMMENT ON COLUMN tickets.attachments IS 'Имена вложенных файлов через запятую';
+COMMENT ON COLUMN tickets.text IS 'n8n: копия body';
+COMMENT ON COLUMN tickets.address IS 'n8n: копия raw_address';
+COMMENT ON COLUMN tickets.segment IS 'n8n: копия client_segment';
+
+COMMENT ON TABLE ticket_ai IS 'AI-обогащение тикетов (ticket_id → tickets.id)';
+COMMENT ON COLUMN ticket_ai.type IS 'Тип обращения';
+COMMENT ON COLUMN ticket_ai.sentiment IS 'Тональность обращения';
+COMMENT ON COLUMN ticket_ai.priority_1_10 IS 'Приоритет от 1 до 10';
+COMMENT ON COLUMN ticket_ai.lang IS 'Язык обращения';
+COMMENT ON COLUMN ticket_ai.summary IS 'Краткое резюме';
+COMMENT ON COLUMN ticket_ai.recommended_actions IS 'JSON-массив рекомендованных действий';
+COMMENT ON COLUMN ticket_ai.lat IS 'Широта клиента';
+COMMENT ON COLUMN ticket_ai.lon IS 'Долгота клиента';
+COMMENT ON COLUMN ticket_ai.geo_status IS 'Статус геокодирования';
+COMMENT ON COLUMN ticket_ai.processing_ms IS 'Время AI-обработки в мс';
+COMMENT ON COLUMN ticket_ai.enriched_at IS 'Время обогащения';
+COMMENT ON COLUMN ticket_ai.priority IS 'n8n: копия priority_1_10';
+COMMENT ON COLUMN ticket_ai.recommendation IS 'n8n: первое рекомендованное действие';
+COMMENT ON COLUMN ticket_ai.confidence IS 'n8n: общая уверенность модели';
+
+COMMENT ON TABLE ticket_assignment IS 'Назначения тикетов менеджерам (используй is_current = true)';
+COMMENT ON COLUMN ticket_assignment.business_unit_id IS 'Офис назначения → business_units.id';
+COMMENT ON COLUMN ticket_assignment.office_id IS 'n8n: копия business_unit_id';
+COMMENT ON COLUMN ticket_assignment.routing_bucket IS 'Группа навыков маршрутизации';
+COMMENT ON COLUMN ticket_assignment.routing_reason IS 'Причина маршрутизации';
+COMMENT ON COLUMN ticket_assignment.is_current IS 'Текущее назначение';
+
+COMMENT ON TABLE managers IS 'Менеджеры';
+COMMENT ON COLUMN managers.is_vip_skill IS 'Может обрабатывать VIP';
+COMMENT ON COLUMN managers.is_chief_spec IS 'Главный специалист';
+COMMENT ON COLUMN managers.languages IS 'Массив языков';
+COMMENT ON COLUMN managers.current_load IS 'Текущая нагрузка';
+COMMENT ON COLUMN managers.max_load IS 'Максимальная нагрузка';
+COMMENT ON COLUMN managers.office_id IS 'n8n: копия business_unit_id';
+COMMENT ON COLUMN managers.active_count IS 'n8n: копия current_load';
+
+COMMENT ON TABLE business_units IS 'Офисы / бизнес-юниты';
+COMMENT ON COLUMN business_units.name IS 'Название офиса';
+COMMENT ON COLUMN business_units.city IS 'Город офиса';
+
+COMMENT ON TABLE audit_log IS 'Журнал шагов маршрутизации по тикетам';
+COMMENT ON COLUMN audit_log.step IS 'Шаг: ai_enrich, geo_filter, skill_filter, load_balance, round_robin';
+COMMENT ON COLUMN audit_log.decision IS 'Текстовое описание решения';
+
+COMMENT ON TABLE geo_cache IS 'Кэш геокодирования адресов';
+COMMENT ON COLUMN geo_cache.resolved_city IS 'Определённый город';
+
+GRANT SELECT ON audit_log, geo_cache TO star_readonly;
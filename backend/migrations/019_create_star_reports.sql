@@ -0,0 +1,21 @@
+-- Migration 019: Saved Star reports with optional cron schedule and delivery target.
+
+CREATE TABLE IF NOT EXISTS star_reports (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name            TEXT NOT NULL UNIQUE,
+    question        TEXT NOT NULL DEFAULT '',
+    sql             TEXT NOT NULL,
+    chart_type      TEXT NOT NULL DEFAULT 'table',
+    x_label         TEXT,
+    y_label         TEXT,
+    schedule        TEXT,
+    delivery_format TEXT NOT NULL DEFAULT 'csv' CHECK (delivery_format IN ('csv', 'json')),
+    delivery_target TEXT,
+    default_params  JSONB NOT NULL DEFAULT '{}'::jsonb,
+    last_run_at     TIMESTAMPTZ,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_star_reports_scheduled ON star_reports(id) WHERE schedule IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Indexes backing the shared dashboard filter and the single-pass stats query
+CREATE INDEX IF NOT EXISTS idx_tickets_segment ON tickets(client_segment);
+CREATE INDEX IF NOT EXISTS idx_tickets_channel ON tickets(source_channel);
+CREATE INDEX IF NOT EXISTS idx_ticket_ai_type ON ticket_ai(type);
+CREATE INDEX IF NOT EXISTS idx_assignment_bu_current ON ticket_assignment(business_unit_id) WHERE is_current = true;
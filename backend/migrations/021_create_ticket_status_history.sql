@@ -0,0 +1,46 @@
+-- Migration 021: Ticket status transitions
+-- Every status change (backend or n8n) is recorded by trigger so stage latencies
+-- (time-to-enrich, time-to-route, time-to-resolve) can be measured after the fact.
+
+CREATE TABLE IF NOT EXISTS ticket_status_history (
+    id          BIGSERIAL PRIMARY KEY,
+    ticket_id   UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    from_status TEXT,
+    to_status   TEXT NOT NULL,
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_history_ticket ON ticket_status_history(ticket_id, changed_at);
+CREATE INDEX IF NOT EXISTS idx_status_history_status ON ticket_status_history(to_status, changed_at);
+
+CREATE OR REPLACE FUNCTION record_ticket_status() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO ticket_status_history (ticket_id, from_status, to_status, changed_at)
+        VALUES (NEW.id, NULL, NEW.status, NEW.created_at);
+    ELSIF NEW.status IS DISTINCT FROM OLD.status THEN
+        INSERT INTO ticket_status_history (ticket_id, from_status, to_status)
+        VALUES (NEW.id, OLD.status, NEW.status);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_ticket_status_history ON tickets;
+CREATE TRIGGER trg_ticket_status_history
+    AFTER INSERT OR UPDATE OF status ON tickets
+    FOR EACH ROW EXECUTE FUNCTION record_ticket_status();
+
+-- Backfill tickets created before the trigger existed from the timestamps we already have.
+INSERT INTO ticket_status_history (ticket_id, from_status, to_status, changed_at)
+SELECT t.id, s.from_status, s.to_status, s.changed_at
+FROM tickets t
+CROSS JOIN LATERAL (
+    VALUES
+        (NULL, 'new', t.created_at),
+        ('new', 'enriched', (SELECT ai.enriched_at FROM ticket_ai ai WHERE ai.ticket_id = t.id)),
+        ('enriched', 'routed', (SELECT MIN(a.assigned_at) FROM ticket_assignment a WHERE a.ticket_id = t.id)),
+        ('routed', t.status, CASE WHEN t.status IN ('resolved', 'closed') THEN t.updated_at END)
+) AS s(from_status, to_status, changed_at)
+WHERE s.changed_at IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM ticket_status_history h WHERE h.ticket_id = t.id);
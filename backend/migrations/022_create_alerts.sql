@@ -0,0 +1,22 @@
+-- Migration 022: Anomaly alerts on ticket inflow and negative sentiment.
+-- One row per (metric, dimension value, hour); the detector re-evaluates the
+-- latest hour repeatedly and relies on the unique key to alert only once.
+
+CREATE TABLE IF NOT EXISTS alerts (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    metric          TEXT NOT NULL CHECK (metric IN ('inflow', 'negative')),
+    dimension       TEXT NOT NULL CHECK (dimension IN ('type', 'city', 'channel')),
+    dimension_value TEXT NOT NULL,
+    bucket_start    TIMESTAMPTZ NOT NULL,
+    observed        DOUBLE PRECISION NOT NULL,
+    expected        DOUBLE PRECISION NOT NULL,
+    z_score         DOUBLE PRECISION NOT NULL,
+    severity        TEXT NOT NULL CHECK (severity IN ('warning', 'critical')),
+    message         TEXT NOT NULL,
+    acknowledged_at TIMESTAMPTZ,
+    acknowledged_by TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (metric, dimension, dimension_value, bucket_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_open ON alerts(created_at DESC) WHERE acknowledged_at IS NULL;
@@ -0,0 +1,34 @@
+-- Migration 023: Client identity and ticket threads.
+-- external_id used to hold the CSV client GUID, so a client's second ticket
+-- overwrote the first on import. client_guid now identifies the client and
+-- external_id the ticket. Related tickets of one client share thread_id (the
+-- id of the first ticket in the thread); near-duplicates also set duplicate_of.
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'tickets' AND column_name = 'client_guid') THEN
+        ALTER TABLE tickets ADD COLUMN client_guid TEXT;
+
+        -- Move legacy client GUIDs over and derive the ticket key the importer
+        -- now uses (see ticketKey in import_svc.go) so re-imports stay idempotent.
+        UPDATE tickets SET
+            client_guid = external_id,
+            external_id = external_id || ':' || left(encode(sha256(convert_to(
+                concat_ws(E'\x1f', body, source_channel, attachments), 'UTF8')), 'hex'), 16)
+        WHERE external_id IS NOT NULL;
+    END IF;
+END $$;
+
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS thread_id UUID REFERENCES tickets(id) ON DELETE SET NULL;
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS duplicate_of UUID REFERENCES tickets(id) ON DELETE SET NULL;
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS similarity DOUBLE PRECISION;
+
+CREATE INDEX IF NOT EXISTS idx_tickets_client ON tickets(client_guid, created_at DESC) WHERE client_guid IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tickets_thread ON tickets(thread_id) WHERE thread_id IS NOT NULL;
+
+COMMENT ON COLUMN tickets.external_id IS 'Внешний идентификатор тикета';
+COMMENT ON COLUMN tickets.client_guid IS 'GUID клиента из CSV';
+COMMENT ON COLUMN tickets.thread_id IS 'Цепочка связанных обращений клиента (id первого тикета цепочки)';
+COMMENT ON COLUMN tickets.duplicate_of IS 'Тикет, почти дублем которого является этот';
+COMMENT ON COLUMN tickets.similarity IS 'Оценка сходства текста (MinHash) с ближайшим тикетом цепочки, 0–1';
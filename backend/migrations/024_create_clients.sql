@@ -0,0 +1,69 @@
+-- Migration 024: Clients keyed by the CSV client GUID.
+-- Profile fields used to be copied onto every ticket (and gender/birth date
+-- were dropped); the importer now upserts them here and links tickets by client_id.
+
+CREATE TABLE IF NOT EXISTS clients (
+    id                   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    guid                 TEXT NOT NULL UNIQUE,
+    full_name            TEXT,
+    segment              TEXT,
+    gender               TEXT,
+    birth_date           DATE,
+    raw_address          TEXT,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS client_segment_history (
+    id           BIGSERIAL PRIMARY KEY,
+    client_id    UUID NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    from_segment TEXT,
+    to_segment   TEXT,
+    changed_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_segment_history ON client_segment_history(client_id, changed_at);
+
+CREATE OR REPLACE FUNCTION record_client_segment() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO client_segment_history (client_id, from_segment, to_segment, changed_at)
+        VALUES (NEW.id, NULL, NEW.segment, NEW.created_at);
+    ELSIF NEW.segment IS DISTINCT FROM OLD.segment THEN
+        INSERT INTO client_segment_history (client_id, from_segment, to_segment)
+        VALUES (NEW.id, OLD.segment, NEW.segment);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_client_segment_history ON clients;
+CREATE TRIGGER trg_client_segment_history
+    AFTER INSERT OR UPDATE OF segment ON clients
+    FOR EACH ROW EXECUTE FUNCTION record_client_segment();
+
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS client_id UUID REFERENCES clients(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_tickets_client_id ON tickets(client_id, created_at DESC) WHERE client_id IS NOT NULL;
+
+-- Backfill clients from existing tickets: the latest ticket's profile, first-seen creation time.
+INSERT INTO clients (guid, full_name, segment, raw_address, created_at)
+SELECT DISTINCT ON (client_guid)
+       client_guid, client_name, client_segment, raw_address,
+       MIN(created_at) OVER (PARTITION BY client_guid)
+FROM tickets
+WHERE client_guid IS NOT NULL
+ORDER BY client_guid, created_at DESC
+ON CONFLICT (guid) DO NOTHING;
+
+UPDATE tickets t SET client_id = c.id
+FROM clients c
+WHERE t.client_guid = c.guid AND t.client_id IS NULL;
+
+COMMENT ON TABLE clients IS 'Клиенты (guid — GUID клиента из CSV)';
+COMMENT ON COLUMN clients.full_name IS 'Имя клиента';
+COMMENT ON COLUMN clients.segment IS 'Текущий сегмент клиента';
+COMMENT ON COLUMN clients.gender IS 'Пол клиента';
+COMMENT ON COLUMN clients.birth_date IS 'Дата рождения';
+COMMENT ON COLUMN clients.raw_address IS 'Последний известный адрес клиента';
+COMMENT ON TABLE client_segment_history IS 'История смены сегмента клиента';
+COMMENT ON COLUMN tickets.client_id IS 'Клиент (clients.id)';
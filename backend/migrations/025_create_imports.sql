@@ -0,0 +1,27 @@
+-- Migration 025: Import jobs.
+-- Uploads are spooled to disk and imported in chunks; every committed chunk
+-- advances checkpoint_row in the same transaction, so a failed or interrupted
+-- job can be resumed from the spooled file without re-importing rows.
+
+CREATE TABLE IF NOT EXISTS imports (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    type           TEXT CHECK (type IN ('tickets', 'managers', 'business_units')),
+    status         TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    filename       TEXT,
+    spool_path     TEXT,
+    size_bytes     BIGINT NOT NULL DEFAULT 0,
+    bytes_read     BIGINT NOT NULL DEFAULT 0,
+    rows_read      INT NOT NULL DEFAULT 0,
+    imported       INT NOT NULL DEFAULT 0,
+    skipped        INT NOT NULL DEFAULT 0,
+    linked         INT NOT NULL DEFAULT 0,
+    checkpoint_row INT NOT NULL DEFAULT 0,
+    error_count    INT NOT NULL DEFAULT 0,
+    errors         JSONB NOT NULL DEFAULT '[]',
+    error          TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_imports_created ON imports(created_at DESC);
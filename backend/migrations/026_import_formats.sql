@@ -0,0 +1,7 @@
+-- Migration 026: Import file formats.
+-- Imports accept XLSX, JSON and JSON Lines besides CSV; the detected format
+-- and the XLSX sheet are kept so a failed import resumes reading the same way.
+
+ALTER TABLE imports ADD COLUMN IF NOT EXISTS format TEXT NOT NULL DEFAULT 'csv'
+    CHECK (format IN ('csv', 'xlsx', 'json', 'jsonl'));
+ALTER TABLE imports ADD COLUMN IF NOT EXISTS sheet TEXT;
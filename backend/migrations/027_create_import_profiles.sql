@@ -0,0 +1,21 @@
+-- Migration 027: Import mapping profiles.
+-- A profile maps a partner's column headers onto the canonical import fields,
+-- with per-column transforms and default values, so a new header layout
+-- needs a profile instead of a code change.
+
+CREATE TABLE IF NOT EXISTS import_profiles (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name       TEXT NOT NULL UNIQUE,
+    type       TEXT CHECK (type IN ('tickets', 'managers', 'business_units')),
+    columns    JSONB NOT NULL DEFAULT '[]',
+    defaults   JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+COMMENT ON COLUMN import_profiles.type IS 'Import type the profile is for; NULL detects it from the mapped columns';
+COMMENT ON COLUMN import_profiles.columns IS 'Array of {source, field, transforms, separator, layout, values}';
+COMMENT ON COLUMN import_profiles.defaults IS 'Canonical field -> value used when the file leaves it empty';
+
+-- A resumed import must read the file with the same profile.
+ALTER TABLE imports ADD COLUMN IF NOT EXISTS profile_id UUID REFERENCES import_profiles(id) ON DELETE SET NULL;
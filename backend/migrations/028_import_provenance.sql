@@ -0,0 +1,41 @@
+-- Migration 028: Import provenance and rollback.
+-- Every import records the file hash and who started it, rows remember the
+-- import that last wrote them, and each insert or overwrite is logged with
+-- the row's previous values so the import can be rolled back.
+
+ALTER TABLE imports ADD COLUMN IF NOT EXISTS file_hash TEXT;
+ALTER TABLE imports ADD COLUMN IF NOT EXISTS initiator TEXT;
+ALTER TABLE imports ADD COLUMN IF NOT EXISTS rolled_back_at TIMESTAMPTZ;
+
+ALTER TABLE imports DROP CONSTRAINT IF EXISTS imports_status_check;
+ALTER TABLE imports ADD CONSTRAINT imports_status_check
+    CHECK (status IN ('pending', 'running', 'completed', 'failed', 'rolled_back'));
+
+CREATE INDEX IF NOT EXISTS idx_imports_file_hash ON imports(file_hash);
+
+COMMENT ON COLUMN imports.file_hash IS 'SHA-256 of the uploaded file, hex';
+COMMENT ON COLUMN imports.initiator IS 'X-User header of the upload request, or the client address';
+
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS import_id UUID REFERENCES imports(id) ON DELETE SET NULL;
+ALTER TABLE managers ADD COLUMN IF NOT EXISTS import_id UUID REFERENCES imports(id) ON DELETE SET NULL;
+ALTER TABLE business_units ADD COLUMN IF NOT EXISTS import_id UUID REFERENCES imports(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_tickets_import ON tickets(import_id) WHERE import_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_managers_import ON managers(import_id) WHERE import_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_business_units_import ON business_units(import_id) WHERE import_id IS NOT NULL;
+
+-- One row per row an import inserted or overwrote, in the order written.
+CREATE TABLE IF NOT EXISTS import_changes (
+    id         BIGSERIAL PRIMARY KEY,
+    import_id  UUID NOT NULL REFERENCES imports(id) ON DELETE CASCADE,
+    entity     TEXT NOT NULL CHECK (entity IN ('tickets', 'managers', 'business_units', 'clients')),
+    row_id     UUID NOT NULL,
+    action     TEXT NOT NULL CHECK (action IN ('insert', 'update')),
+    before     JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_changes_import ON import_changes(import_id, id);
+CREATE INDEX IF NOT EXISTS idx_import_changes_row ON import_changes(entity, row_id);
+
+COMMENT ON COLUMN import_changes.before IS 'The row as it was before an update; NULL for inserts';
@@ -0,0 +1,22 @@
+-- Migration 029: Manager and office administration.
+-- Offices can be deactivated like managers (routing skips them), and every
+-- change made through the API is kept with the values before and after it.
+
+ALTER TABLE business_units ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT true;
+
+CREATE TABLE IF NOT EXISTS entity_audit (
+    id         BIGSERIAL PRIMARY KEY,
+    entity     TEXT NOT NULL CHECK (entity IN ('managers', 'business_units')),
+    entity_id  UUID NOT NULL,
+    action     TEXT NOT NULL CHECK (action IN ('create', 'update', 'deactivate', 'activate')),
+    actor      TEXT,
+    before     JSONB,
+    after      JSONB,
+    details    JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_entity_audit_entity ON entity_audit(entity, entity_id, created_at DESC);
+
+COMMENT ON COLUMN entity_audit.actor IS 'X-User header of the request, or the client address';
+COMMENT ON COLUMN entity_audit.details IS 'What else the change did, e.g. the tickets a deactivation handed off';
@@ -0,0 +1,102 @@
+-- Migration 030: Skills catalogue.
+-- Manager skills become rows with a proficiency level instead of fixed
+-- columns, and the routing skill stage reads its rules from
+-- skill_requirements. is_vip_skill, is_chief_spec and languages stay for n8n
+-- and are mirrored into manager_skills by a trigger.
+
+CREATE TABLE IF NOT EXISTS skills (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code       TEXT NOT NULL UNIQUE,
+    name       TEXT NOT NULL,
+    category   TEXT NOT NULL CHECK (category IN ('product', 'language', 'segment', 'certification')),
+    aliases    TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+COMMENT ON COLUMN skills.code IS 'Upper-case key; language skills use the codes of managers.languages';
+COMMENT ON COLUMN skills.aliases IS 'Other spellings accepted in the Навыки import column';
+
+CREATE TABLE IF NOT EXISTS manager_skills (
+    manager_id UUID NOT NULL REFERENCES managers(id) ON DELETE CASCADE,
+    skill_id   UUID NOT NULL REFERENCES skills(id) ON DELETE CASCADE,
+    level      SMALLINT NOT NULL DEFAULT 3 CHECK (level BETWEEN 1 AND 5),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (manager_id, skill_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_manager_skills_skill ON manager_skills(skill_id);
+
+COMMENT ON COLUMN manager_skills.level IS 'Proficiency, 1 (basic) to 5 (expert)';
+
+-- A rule of the routing skill stage: tickets matching every non-empty
+-- condition go to managers with the skill at min_level or above.
+CREATE TABLE IF NOT EXISTS skill_requirements (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    skill_id     UUID NOT NULL REFERENCES skills(id) ON DELETE CASCADE,
+    min_level    SMALLINT NOT NULL DEFAULT 1 CHECK (min_level BETWEEN 1 AND 5),
+    segments     TEXT[] NOT NULL DEFAULT '{}',
+    ticket_types TEXT[] NOT NULL DEFAULT '{}',
+    langs        TEXT[] NOT NULL DEFAULT '{}',
+    position     INT NOT NULL DEFAULT 100,
+    is_active    BOOLEAN NOT NULL DEFAULT true,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+COMMENT ON COLUMN skill_requirements.segments IS 'Client segments the rule applies to; empty matches any';
+COMMENT ON COLUMN skill_requirements.position IS 'Rules apply in ascending position, each narrowing the candidates';
+
+INSERT INTO skills (code, name, category, aliases) VALUES
+    ('VIP', 'VIP-клиенты', 'segment', '{}'),
+    ('CHIEF_SPEC', 'Главный специалист', 'certification', '{"Глав спец"}'),
+    ('RU', 'Русский язык', 'language', '{"Русский"}'),
+    ('EN', 'Английский язык', 'language', '{"ENG", "English", "Английский"}'),
+    ('KZ', 'Казахский язык', 'language', '{"KAZ", "Казахский"}')
+ON CONFLICT (code) DO NOTHING;
+
+-- The rules the skill stage had built in. Fixed ids keep them from being
+-- seeded again; disable them instead of deleting.
+INSERT INTO skill_requirements (id, skill_id, segments, ticket_types, langs, position)
+SELECT r.id::uuid, s.id, r.segments::text[], r.ticket_types::text[], r.langs::text[], r.position
+FROM (VALUES
+    ('7a1e0c1e-5c1a-4f0e-9a10-000000000001', 'VIP', '{VIP,Priority}', '{}', '{}', 10),
+    ('7a1e0c1e-5c1a-4f0e-9a10-000000000002', 'CHIEF_SPEC', '{}', '{"Смена данных","Change Data"}', '{}', 20),
+    ('7a1e0c1e-5c1a-4f0e-9a10-000000000003', 'KZ', '{}', '{}', '{KZ}', 30),
+    ('7a1e0c1e-5c1a-4f0e-9a10-000000000004', 'EN', '{}', '{}', '{EN,ENG}', 40)
+) AS r(id, code, segments, ticket_types, langs, position)
+JOIN skills s ON s.code = r.code
+ON CONFLICT (id) DO NOTHING;
+
+-- Mirror the legacy skill columns into manager_skills: flagged skills are
+-- added at the default level, unflagged ones removed. Other skills and the
+-- levels of kept ones are left alone.
+CREATE OR REPLACE FUNCTION sync_manager_skills() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM manager_skills ms
+    USING skills s
+    WHERE ms.manager_id = NEW.id AND s.id = ms.skill_id
+      AND ((s.code = 'VIP' AND NOT NEW.is_vip_skill)
+        OR (s.code = 'CHIEF_SPEC' AND NOT NEW.is_chief_spec)
+        OR (s.category = 'language' AND NOT s.code = ANY(NEW.languages)));
+
+    INSERT INTO manager_skills (manager_id, skill_id)
+    SELECT NEW.id, s.id FROM skills s
+    WHERE (s.code = 'VIP' AND NEW.is_vip_skill)
+       OR (s.code = 'CHIEF_SPEC' AND NEW.is_chief_spec)
+       OR (s.category = 'language' AND s.code = ANY(NEW.languages))
+    ON CONFLICT DO NOTHING;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_sync_manager_skills ON managers;
+CREATE TRIGGER trg_sync_manager_skills
+AFTER INSERT OR UPDATE OF is_vip_skill, is_chief_spec, languages ON managers
+FOR EACH ROW EXECUTE FUNCTION sync_manager_skills();
+
+-- Backfill managers created before the trigger.
+INSERT INTO manager_skills (manager_id, skill_id)
+SELECT m.id, s.id FROM managers m JOIN skills s
+  ON (s.code = 'VIP' AND m.is_vip_skill)
+  OR (s.code = 'CHIEF_SPEC' AND m.is_chief_spec)
+  OR (s.category = 'language' AND s.code = ANY(m.languages))
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,47 @@
+-- Migration 031: Bulk rebalancing jobs.
+-- A job selects open tickets by filter and plans where each should move,
+-- either by re-running routing with exclusions or by a target distribution.
+-- The plan is stored as a preview; applying it moves each ticket in its own
+-- transaction, so a failed or interrupted job resumes with the planned moves.
+
+CREATE TABLE IF NOT EXISTS rebalance_jobs (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    mode        TEXT NOT NULL CHECK (mode IN ('reroute', 'distribute')),
+    status      TEXT NOT NULL DEFAULT 'preview' CHECK (status IN ('preview', 'running', 'completed', 'failed')),
+    filter      JSONB NOT NULL DEFAULT '{}',
+    policy      JSONB,
+    targets     JSONB NOT NULL DEFAULT '[]',
+    initiator   TEXT,
+    selected    INT NOT NULL DEFAULT 0,
+    planned     INT NOT NULL DEFAULT 0,
+    unchanged   INT NOT NULL DEFAULT 0,
+    applied     INT NOT NULL DEFAULT 0,
+    skipped     INT NOT NULL DEFAULT 0,
+    failed      INT NOT NULL DEFAULT 0,
+    error       TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at  TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_rebalance_jobs_created ON rebalance_jobs(created_at DESC);
+
+CREATE TABLE IF NOT EXISTS rebalance_moves (
+    job_id          UUID NOT NULL REFERENCES rebalance_jobs(id) ON DELETE CASCADE,
+    ticket_id       UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    position        INT NOT NULL,
+    from_manager_id UUID NOT NULL,
+    to_manager_id   UUID,
+    routing_bucket  TEXT NOT NULL DEFAULT 'rebalance',
+    reason          TEXT,
+    status          TEXT NOT NULL DEFAULT 'planned' CHECK (status IN ('planned', 'applied', 'skipped', 'failed')),
+    error           TEXT,
+    applied_at      TIMESTAMPTZ,
+    PRIMARY KEY (job_id, ticket_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rebalance_moves_order ON rebalance_moves(job_id, position);
+
+COMMENT ON COLUMN rebalance_moves.to_manager_id IS 'NULL when no manager could be planned for the ticket (status failed)';
+COMMENT ON COLUMN rebalance_moves.status IS 'skipped: the ticket was closed or reassigned after the preview, or the target became unavailable';
@@ -0,0 +1,48 @@
+-- Migration 032: Teams, supervisors and escalation.
+-- Managers of an office are grouped into teams led by a supervisor. A
+-- ticket escalated by its manager goes to the team's supervisor, up the
+-- chain of escalation teams, or finally to a chief specialist; every
+-- escalation is kept with its reason.
+
+CREATE TABLE IF NOT EXISTS teams (
+    id                 UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name               TEXT NOT NULL,
+    business_unit_id   UUID NOT NULL REFERENCES business_units(id),
+    supervisor_id      UUID REFERENCES managers(id) ON DELETE SET NULL,
+    escalation_team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    is_active          BOOLEAN NOT NULL DEFAULT true,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (business_unit_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_teams_supervisor ON teams(supervisor_id);
+
+COMMENT ON COLUMN teams.supervisor_id IS 'Receives the team''s escalations; need not be a member or work in the office';
+COMMENT ON COLUMN teams.escalation_team_id IS 'Next team up: escalations its supervisor raises, or that find no supervisor here, go there';
+
+ALTER TABLE managers ADD COLUMN IF NOT EXISTS team_id UUID REFERENCES teams(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_managers_team ON managers(team_id);
+
+CREATE TABLE IF NOT EXISTS ticket_escalations (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    ticket_id       UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    from_manager_id UUID REFERENCES managers(id) ON DELETE SET NULL,
+    to_manager_id   UUID NOT NULL REFERENCES managers(id),
+    team_id         UUID REFERENCES teams(id) ON DELETE SET NULL,
+    target          TEXT NOT NULL CHECK (target IN ('supervisor', 'chief_specialist', 'manager')),
+    level           INT NOT NULL,
+    reason          TEXT NOT NULL,
+    actor           TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ticket_escalations_ticket ON ticket_escalations(ticket_id, created_at);
+
+COMMENT ON COLUMN ticket_escalations.team_id IS 'Team whose supervisor took the ticket';
+COMMENT ON COLUMN ticket_escalations.level IS '1 for the first escalation of the ticket, then 2, 3, ...';
+
+-- Team changes are audited like manager and office changes.
+ALTER TABLE entity_audit DROP CONSTRAINT IF EXISTS entity_audit_entity_check;
+ALTER TABLE entity_audit ADD CONSTRAINT entity_audit_entity_check
+    CHECK (entity IN ('managers', 'business_units', 'teams'));
@@ -0,0 +1,33 @@
+-- Migration 033: Ticket conversation.
+-- Internal notes, replies to the client and the client's own messages,
+-- oldest first, and which of the AI's recommended actions are done.
+
+CREATE TABLE IF NOT EXISTS ticket_messages (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    ticket_id    UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    kind         TEXT NOT NULL CHECK (kind IN ('note', 'reply', 'client')),
+    author       TEXT NOT NULL,
+    manager_id   UUID REFERENCES managers(id) ON DELETE SET NULL,
+    body         TEXT NOT NULL DEFAULT '',
+    attachments  JSONB NOT NULL DEFAULT '[]',
+    done_actions INT[] NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ticket_messages_ticket ON ticket_messages(ticket_id, created_at);
+
+COMMENT ON COLUMN ticket_messages.kind IS 'note: internal, reply: sent to the client, client: received from the client';
+COMMENT ON COLUMN ticket_messages.attachments IS '[{"name", "content_type", "size", "url"}]; uploads are kept under MESSAGE_ATTACHMENTS_DIR';
+COMMENT ON COLUMN ticket_messages.done_actions IS 'Indexes of ticket_ai.recommended_actions this message checked off';
+
+-- A recommended action checked off as done. The action text is kept so a
+-- re-enrichment that changes the list does not carry the check over.
+CREATE TABLE IF NOT EXISTS ticket_action_status (
+    ticket_id  UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    position   INT NOT NULL,
+    action     TEXT NOT NULL,
+    message_id UUID REFERENCES ticket_messages(id) ON DELETE SET NULL,
+    done_by    TEXT,
+    done_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (ticket_id, position)
+);
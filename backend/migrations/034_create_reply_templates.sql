@@ -0,0 +1,221 @@
+-- Migration 034: Reply template library.
+-- Draft replies to clients are filled from these templates in the ticket's
+-- language, picked by ticket type and client segment, and optionally
+-- polished by the LLM.
+
+CREATE TABLE IF NOT EXISTS reply_templates (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name         TEXT NOT NULL UNIQUE,
+    lang         TEXT NOT NULL CHECK (lang IN ('RU', 'KZ', 'EN')),
+    ticket_types TEXT[] NOT NULL DEFAULT '{}',
+    segments     TEXT[] NOT NULL DEFAULT '{}',
+    body         TEXT NOT NULL,
+    is_active    BOOLEAN NOT NULL DEFAULT true,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reply_templates_lang ON reply_templates(lang) WHERE is_active;
+
+COMMENT ON COLUMN reply_templates.ticket_types IS 'Ticket types the template answers; empty matches any';
+COMMENT ON COLUMN reply_templates.segments IS 'Client segments the template answers; empty matches any';
+COMMENT ON COLUMN reply_templates.body IS 'Reply text with {{client_name}}, {{ticket_number}}, {{subject}}, {{created_date}}, {{manager_name}}, {{office}}';
+
+-- The starting library. Fixed ids keep the templates from being seeded
+-- again; disable them instead of deleting.
+INSERT INTO reply_templates (id, name, lang, ticket_types, segments, body) VALUES
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000001', 'RU: общий ответ', 'RU', '{}', '{}',
+'Здравствуйте, {{client_name}}!
+
+Благодарим за обращение № {{ticket_number}} «{{subject}}». Мы получили его и уже работаем над ним; менеджер свяжется с вами, как только будет готов ответ.
+
+С уважением,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000002', 'RU: жалоба', 'RU', '{Жалоба}', '{}',
+'Здравствуйте, {{client_name}}!
+
+Приносим извинения за неудобства, с которыми вы столкнулись. Ваша жалоба № {{ticket_number}} от {{created_date}} зарегистрирована, мы разберёмся в ситуации и сообщим вам о результатах.
+
+С уважением,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000003', 'RU: претензия', 'RU', '{Претензия}', '{}',
+'Здравствуйте, {{client_name}}!
+
+Ваша претензия № {{ticket_number}} от {{created_date}} зарегистрирована. Мы проверим изложенные обстоятельства и основания для компенсации и направим вам официальный ответ в установленный срок.
+
+С уважением,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000004', 'RU: консультация', 'RU', '{Консультация}', '{}',
+'Здравствуйте, {{client_name}}!
+
+Спасибо за ваш вопрос «{{subject}}». Мы подготовим подробный ответ и пришлём его в ближайшее время. Если появятся дополнительные вопросы, просто ответьте на это сообщение.
+
+С уважением,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000005', 'RU: неработоспособность', 'RU', '{Неработоспособность}', '{}',
+'Здравствуйте, {{client_name}}!
+
+Спасибо, что сообщили о проблеме. Мы передали обращение № {{ticket_number}} технической службе. Чтобы ускорить решение, пришлите, пожалуйста, скриншот ошибки и укажите, когда она возникла.
+
+С уважением,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000006', 'RU: смена данных', 'RU', '{"Смена данных","Change Data"}', '{}',
+'Здравствуйте, {{client_name}}!
+
+Мы получили ваш запрос на изменение данных (обращение № {{ticket_number}}). Для его обработки понадобятся подтверждающие документы; после их проверки мы сообщим вам сроки.
+
+С уважением,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000007', 'RU: VIP, общий ответ', 'RU', '{}', '{VIP,Priority}',
+'{{client_name}}, добрый день!
+
+Благодарим вас за обращение № {{ticket_number}}. Оно рассматривается в приоритетном порядке: я лично прослежу за его решением и свяжусь с вами в ближайшее время.
+
+С уважением,
+{{manager_name}}, ваш персональный менеджер
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000008', 'RU: VIP, жалоба или претензия', 'RU', '{Жалоба,Претензия}', '{VIP,Priority}',
+'{{client_name}}, добрый день!
+
+Искренне сожалеем о произошедшем и благодарим, что сообщили нам. Ваше обращение № {{ticket_number}} от {{created_date}} рассматривается в приоритетном порядке под контролем руководителя подразделения. Я лично свяжусь с вами, как только будут результаты проверки.
+
+С уважением,
+{{manager_name}}, ваш персональный менеджер
+Freedom Broker'),
+
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000011', 'KZ: жалпы жауап', 'KZ', '{}', '{}',
+'Сәлеметсіз бе, {{client_name}}!
+
+№ {{ticket_number}} «{{subject}}» өтінішіңіз үшін рахмет. Біз оны қабылдап, қарастырып жатырмыз; жауап дайын болғанда менеджер сізбен байланысады.
+
+Құрметпен,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000012', 'KZ: шағым', 'KZ', '{Жалоба}', '{}',
+'Сәлеметсіз бе, {{client_name}}!
+
+Туындаған қолайсыздықтар үшін кешірім сұраймыз. {{created_date}} күнгі № {{ticket_number}} шағымыңыз тіркелді, біз жағдайды анықтап, нәтижесі туралы сізге хабарлаймыз.
+
+Құрметпен,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000013', 'KZ: талап-арыз', 'KZ', '{Претензия}', '{}',
+'Сәлеметсіз бе, {{client_name}}!
+
+{{created_date}} күнгі № {{ticket_number}} талап-арызыңыз тіркелді. Біз көрсетілген мән-жайларды және өтемақы негіздерін тексеріп, белгіленген мерзімде ресми жауап жолдаймыз.
+
+Құрметпен,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000014', 'KZ: кеңес', 'KZ', '{Консультация}', '{}',
+'Сәлеметсіз бе, {{client_name}}!
+
+«{{subject}}» сұрағыңыз үшін рахмет. Толық жауапты дайындап, жақын арада жібереміз. Қосымша сұрақтарыңыз болса, осы хатқа жауап беріңіз.
+
+Құрметпен,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000015', 'KZ: ақаулық', 'KZ', '{Неработоспособность}', '{}',
+'Сәлеметсіз бе, {{client_name}}!
+
+Ақаулық туралы хабарлағаныңыз үшін рахмет. № {{ticket_number}} өтінішіңізді техникалық қызметке жібердік. Мәселені тезірек шешу үшін қатенің скриншотын жіберіп, оның қашан пайда болғанын көрсетуіңізді сұраймыз.
+
+Құрметпен,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000016', 'KZ: деректерді өзгерту', 'KZ', '{"Смена данных","Change Data"}', '{}',
+'Сәлеметсіз бе, {{client_name}}!
+
+Деректерді өзгерту туралы сұрауыңызды алдық (№ {{ticket_number}} өтініш). Оны өңдеу үшін растайтын құжаттар қажет; оларды тексергеннен кейін мерзімдері туралы хабарлаймыз.
+
+Құрметпен,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000017', 'KZ: VIP, жалпы жауап', 'KZ', '{}', '{VIP,Priority}',
+'{{client_name}}, сәлеметсіз бе!
+
+№ {{ticket_number}} өтінішіңіз үшін алғыс айтамыз. Ол бірінші кезекте қаралуда: мен оның шешілуін жеке өзім бақылап, жақын арада сізбен байланысамын.
+
+Құрметпен,
+{{manager_name}}, сіздің жеке менеджеріңіз
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000018', 'KZ: VIP, шағым немесе талап-арыз', 'KZ', '{Жалоба,Претензия}', '{VIP,Priority}',
+'{{client_name}}, сәлеметсіз бе!
+
+Болған жағдайға шын жүректен өкінеміз және бізге хабарлағаныңыз үшін алғыс айтамыз. {{created_date}} күнгі № {{ticket_number}} өтінішіңіз бөлім басшысының бақылауымен бірінші кезекте қаралуда. Тексеру нәтижелері дайын болғанда, сізбен жеке өзім хабарласамын.
+
+Құрметпен,
+{{manager_name}}, сіздің жеке менеджеріңіз
+Freedom Broker'),
+
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000021', 'EN: general reply', 'EN', '{}', '{}',
+'Hello {{client_name}},
+
+Thank you for contacting us about "{{subject}}" (request No. {{ticket_number}}). We have received your request and are working on it; your manager will get back to you as soon as we have an answer.
+
+Kind regards,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000022', 'EN: complaint', 'EN', '{Жалоба}', '{}',
+'Hello {{client_name}},
+
+We apologise for the inconvenience you have experienced. Your complaint No. {{ticket_number}} of {{created_date}} has been registered; we will look into the matter and let you know the outcome.
+
+Kind regards,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000023', 'EN: claim', 'EN', '{Претензия}', '{}',
+'Hello {{client_name}},
+
+Your claim No. {{ticket_number}} of {{created_date}} has been registered. We will review the circumstances and the grounds for compensation and send you a formal response within the established time frame.
+
+Kind regards,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000024', 'EN: consultation', 'EN', '{Консультация}', '{}',
+'Hello {{client_name}},
+
+Thank you for your question "{{subject}}". We are preparing a detailed answer and will send it to you shortly. If you have any further questions, simply reply to this message.
+
+Kind regards,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000025', 'EN: malfunction', 'EN', '{Неработоспособность}', '{}',
+'Hello {{client_name}},
+
+Thank you for reporting the problem. We have passed request No. {{ticket_number}} to our technical team. To help us resolve it faster, please send a screenshot of the error and tell us when it occurred.
+
+Kind regards,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000026', 'EN: change of details', 'EN', '{"Смена данных","Change Data"}', '{}',
+'Hello {{client_name}},
+
+We have received your request to update your details (request No. {{ticket_number}}). We will need supporting documents to process it; once they are verified, we will let you know the timeline.
+
+Kind regards,
+{{manager_name}}
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000027', 'EN: VIP, general reply', 'EN', '{}', '{VIP,Priority}',
+'Dear {{client_name}},
+
+Thank you for your request No. {{ticket_number}}. It is being handled as a priority: I will personally see it through and contact you shortly.
+
+Kind regards,
+{{manager_name}}, your personal manager
+Freedom Broker'),
+    ('5e7d1b2a-3c4f-4a6b-8d10-000000000028', 'EN: VIP, complaint or claim', 'EN', '{Жалоба,Претензия}', '{VIP,Priority}',
+'Dear {{client_name}},
+
+We sincerely regret what happened and thank you for letting us know. Your request No. {{ticket_number}} of {{created_date}} is being reviewed as a priority under the supervision of the head of the department. I will contact you personally as soon as the review is complete.
+
+Kind regards,
+{{manager_name}}, your personal manager
+Freedom Broker')
+ON CONFLICT (id) DO NOTHING;
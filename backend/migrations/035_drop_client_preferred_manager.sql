@@ -0,0 +1,5 @@
+-- Migration 035: Sticky routing follows the client's last assignment.
+-- Clients are no longer pinned to a manager by hand, so the pin is dropped
+-- from databases that already have it.
+
+ALTER TABLE clients DROP COLUMN IF EXISTS preferred_manager_id;
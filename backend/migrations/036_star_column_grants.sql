@@ -0,0 +1,9 @@
+-- Migration 036: Column-level SELECT for the Star role.
+-- Star may only read the columns the SQL guard allows: every column of its
+-- tables except starDeniedColumns (internal/service/star_guard.go). On startup
+-- StarService.LoadSchema grants the role SELECT on exactly those columns, read
+-- from information_schema, so columns added by later migrations follow
+-- automatically. Here the table-level grants of 017/018 are taken back, so
+-- the role reads nothing if that step fails.
+
+REVOKE SELECT ON tickets, ticket_ai, ticket_assignment, managers, business_units, audit_log, geo_cache FROM star_readonly;